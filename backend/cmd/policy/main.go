@@ -0,0 +1,219 @@
+// Command policy plans and applies desired-state documents against a running web panel.
+//
+// Usage:
+//
+//	uwp-policy plan  -f policy.yaml [--prune]
+//	uwp-policy apply -f policy.yaml [--prune]
+//	uwp-policy export [-o policy.yaml]
+//
+// Requests are authenticated with a panel session token (the "token" returned by
+// POST /api/auth/login), taken from --session-token or the UWP_SESSION_TOKEN
+// environment variable.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+type policyChange struct {
+	Kind   string `json:"kind"`
+	Action string `json:"action"`
+	Key    string `json:"key"`
+	Status string `json:"status"`
+	Error  string `json:"error"`
+}
+
+type policyPlan struct {
+	DocumentHash string         `json:"document_hash"`
+	Changes      []policyChange `json:"changes"`
+	Unchanged    int            `json:"unchanged"`
+	Unmanaged    int            `json:"unmanaged"`
+	Prune        bool           `json:"prune"`
+}
+
+type applyResponse struct {
+	Plan    policyPlan `json:"plan"`
+	Applied int        `json:"applied"`
+	Failed  int        `json:"failed"`
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	command := os.Args[1]
+	fs := flag.NewFlagSet(command, flag.ExitOnError)
+	file := fs.String("f", "", "Policy document (YAML or JSON), - for stdin")
+	output := fs.String("o", "", "Output file for export (default stdout)")
+	prune := fs.Bool("prune", false, "Delete live entries that are not in the document")
+	url := fs.String("url", "http://127.0.0.1:8080", "Web panel base URL")
+	token := fs.String("session-token", os.Getenv("UWP_SESSION_TOKEN"), "Session token from /api/auth/login (default $UWP_SESSION_TOKEN)")
+	fs.Parse(os.Args[2:])
+
+	if *token == "" {
+		fatalf("no session token, use --session-token or set UWP_SESSION_TOKEN")
+	}
+	client := &apiClient{baseURL: strings.TrimRight(*url, "/"), token: *token}
+
+	switch command {
+	case "plan", "apply":
+		if *file == "" {
+			fatalf("-f is required")
+		}
+		document, err := readDocument(*file)
+		if err != nil {
+			fatalf("failed to read %s: %v", *file, err)
+		}
+		body := map[string]interface{}{"document": document, "prune": *prune}
+
+		if command == "plan" {
+			var plan policyPlan
+			if err := client.post("/api/policy/plan", body, &plan); err != nil {
+				fatalf("%v", err)
+			}
+			printPlan(plan)
+			return
+		}
+
+		var result applyResponse
+		if err := client.post("/api/policy/apply", body, &result); err != nil {
+			fatalf("%v", err)
+		}
+		printPlan(result.Plan)
+		fmt.Printf("\nApplied %d, failed %d\n", result.Applied, result.Failed)
+		if result.Failed > 0 {
+			os.Exit(1)
+		}
+	case "export":
+		data, err := client.get("/api/policy/export?format=yaml")
+		if err != nil {
+			fatalf("%v", err)
+		}
+		if *output == "" {
+			os.Stdout.Write(data)
+			return
+		}
+		if err := os.WriteFile(*output, data, 0644); err != nil {
+			fatalf("failed to write %s: %v", *output, err)
+		}
+	default:
+		usage()
+		os.Exit(2)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: uwp-policy <plan|apply|export> [flags]")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "  plan    Show the changes needed to reach the document's state")
+	fmt.Fprintln(os.Stderr, "  apply   Apply the changes")
+	fmt.Fprintln(os.Stderr, "  export  Write the current state as a policy document")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Flags: -f <file> [--prune] [-o <file>] [--url <panel url>] [--session-token <token>]")
+}
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "error: "+format+"\n", args...)
+	os.Exit(1)
+}
+
+func readDocument(path string) (string, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	return string(data), err
+}
+
+func printPlan(plan policyPlan) {
+	fmt.Printf("Document %s\n", plan.DocumentHash)
+	if len(plan.Changes) == 0 {
+		fmt.Println("No changes.")
+	}
+	for _, change := range plan.Changes {
+		symbol := "~"
+		switch change.Action {
+		case "create":
+			symbol = "+"
+		case "delete":
+			symbol = "-"
+		}
+		line := fmt.Sprintf("  %s %-16s %s", symbol, change.Kind, change.Key)
+		if change.Status == "failed" {
+			line += "  FAILED: " + change.Error
+		}
+		fmt.Println(line)
+	}
+	fmt.Printf("\n%d change(s), %d unchanged, %d unmanaged", len(plan.Changes), plan.Unchanged, plan.Unmanaged)
+	if plan.Unmanaged > 0 && !plan.Prune {
+		fmt.Print(" (use --prune to delete)")
+	}
+	fmt.Println()
+}
+
+type apiClient struct {
+	baseURL string
+	token   string
+}
+
+func (a *apiClient) do(method, path string, body io.Reader) ([]byte, error) {
+	req, err := http.NewRequest(method, a.baseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+a.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	httpClient := &http.Client{Timeout: 5 * time.Minute}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Error != "" {
+			return nil, fmt.Errorf("%s (HTTP %d)", apiErr.Error, resp.StatusCode)
+		}
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return data, nil
+}
+
+func (a *apiClient) get(path string) ([]byte, error) {
+	return a.do(http.MethodGet, path, nil)
+}
+
+func (a *apiClient) post(path string, body interface{}, out interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	data, err := a.do(http.MethodPost, path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
@@ -13,7 +13,10 @@ import (
 	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/database"
 	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/plugins"
 	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/rpc"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/services/geoip"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/services/logstream"
 	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/services/notifications"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/services/reputation"
 	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/services/scheduler"
 	"github.com/gin-gonic/gin"
 )
@@ -51,6 +54,14 @@ func main() {
 	}
 	defer database.Close()
 
+	// Load GeoIP/ASN databases from the data directory (reloaded when replaced)
+	geoIP := geoip.Initialize()
+	defer geoIP.Stop()
+
+	// Load local reputation lists (DNSBLs are queried on demand)
+	reputationLists := reputation.Initialize()
+	defer reputationLists.Stop()
+
 	// Initialize plugin system
 	initializePlugins()
 
@@ -63,6 +74,10 @@ func main() {
 	// Initialize notification service (registers webhook hooks)
 	notifications.Initialize()
 
+	// Initialize log stream service (subscribes to IRCd log events for the ban ledger etc.)
+	logStream := logstream.Initialize()
+	defer logStream.Stop()
+
 	// Initialize scheduler service (handles cron jobs, scheduled commands, digests)
 	sched := scheduler.Initialize()
 	defer sched.Stop()
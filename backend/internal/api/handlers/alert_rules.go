@@ -12,6 +12,8 @@ import (
 	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/api/middleware"
 	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/database"
 	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/database/models"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/services/geoip"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/utils"
 )
 
 // CreateAlertRuleRequest represents a request to create an alert rule
@@ -307,13 +309,11 @@ func ProcessAlertRules(event *UnrealIRCdLogEvent, rawPayload []byte) {
 		return
 	}
 
-	// Build event data map
-	eventData := map[string]string{
-		"subsystem": event.Subsystem,
-		"event_id":  event.EventID,
-		"level":     event.Level,
-		"message":   event.Msg,
-		"timestamp": event.Timestamp,
+	eventData := buildAlertEventData(event, rawPayload)
+	if alertRulesUseFields(rules, "listed", "listed_on", "listing_confidence") {
+		for key, value := range reputationAlertData(eventData["client_ip"]) {
+			eventData[key] = value
+		}
 	}
 
 	// Process each rule
@@ -358,6 +358,94 @@ func ProcessAlertRules(event *UnrealIRCdLogEvent, rawPayload []byte) {
 	}
 }
 
+// panelAlertSubsystem is the subsystem of events the panel detects itself, such as template drift
+const panelAlertSubsystem = "webpanel"
+
+// raisePanelAlert runs the alert rules for an event detected by the panel rather than
+// logged by UnrealIRCd. Rules match it with event type "webpanel"; fields can be used
+// in conditions.
+func raisePanelAlert(eventID, level, msg string, fields map[string]string) {
+	event := &UnrealIRCdLogEvent{
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+		Level:     level,
+		Subsystem: panelAlertSubsystem,
+		EventID:   eventID,
+		LogSource: panelAlertSubsystem,
+		Msg:       msg,
+	}
+	payload, _ := json.Marshal(map[string]interface{}{
+		"timestamp":         event.Timestamp,
+		"level":             event.Level,
+		"subsystem":         event.Subsystem,
+		"event_id":          event.EventID,
+		"msg":               event.Msg,
+		panelAlertSubsystem: fields,
+	})
+	ProcessAlertRules(event, payload)
+}
+
+// buildAlertEventData builds the fields alert conditions can match on. Besides the
+// log event fields this includes the client involved and its GeoIP data, if any.
+func buildAlertEventData(event *UnrealIRCdLogEvent, rawPayload []byte) map[string]string {
+	eventData := map[string]string{
+		"subsystem": event.Subsystem,
+		"event_id":  event.EventID,
+		"level":     event.Level,
+		"message":   event.Msg,
+		"timestamp": event.Timestamp,
+	}
+
+	var payload map[string]interface{}
+	if len(rawPayload) == 0 || json.Unmarshal(rawPayload, &payload) != nil {
+		return eventData
+	}
+
+	for key, value := range utils.SafeMapGetMap(payload, panelAlertSubsystem) {
+		eventData[key] = toString(value)
+	}
+
+	client := utils.SafeMapGetMap(payload, "client")
+	if client == nil {
+		return eventData
+	}
+	eventData["client_nick"] = utils.SafeMapGetString(client, "name")
+	ip := utils.SafeMapGetString(client, "ip")
+	eventData["client_ip"] = ip
+
+	if info := geoip.Lookup(ip); info != nil {
+		eventData["country"] = info.CountryCode
+		eventData["asn"] = strconv.FormatUint(uint64(info.ASN), 10)
+		eventData["as_org"] = info.ASOrg
+	} else if geo := utils.SafeMapGetMap(client, "geoip"); geo != nil {
+		eventData["country"] = utils.SafeMapGetString(geo, "country_code")
+		if asn := utils.SafeMapGetInt(geo, "asn"); asn != 0 {
+			eventData["asn"] = strconv.Itoa(asn)
+		}
+		eventData["as_org"] = utils.SafeMapGetString(geo, "asname")
+	}
+
+	return eventData
+}
+
+// alertRulesUseFields reports whether any rule has a condition on one of the fields,
+// so expensive fields are only computed when needed
+func alertRulesUseFields(rules []models.AlertRule, fields ...string) bool {
+	for _, rule := range rules {
+		var conditions []models.AlertCondition
+		if err := json.Unmarshal([]byte(rule.Conditions), &conditions); err != nil {
+			continue
+		}
+		for _, condition := range conditions {
+			for _, field := range fields {
+				if condition.Field == field {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
 // evaluateAllConditions checks if all conditions match
 func evaluateAllConditions(conditions []models.AlertCondition, data map[string]string) bool {
 	if len(conditions) == 0 {
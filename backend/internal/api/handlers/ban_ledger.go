@@ -0,0 +1,380 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/database"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/database/models"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/rpc"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/utils"
+)
+
+// Ledger actions and sources
+const (
+	ledgerActionAdd    = "add"
+	ledgerActionDelete = "delete"
+	ledgerActionExpire = "expire"
+
+	ledgerSourcePanel    = "panel"
+	ledgerSourceLog      = "log"
+	ledgerSourceSnapshot = "snapshot"
+)
+
+// ledgerDedupWindow is how far back we look for an existing entry describing the same change.
+// The same change is typically seen by the panel, the log stream and the snapshot diff.
+const ledgerDedupWindow = 10 * time.Minute
+
+// banSnapshotState holds the last known ban lists used for diffing
+var banSnapshotState struct {
+	mu      sync.Mutex
+	entries map[string]banSnapshotEntry
+}
+
+type banSnapshotEntry struct {
+	BanType  string
+	Mask     string
+	Reason   string
+	SetBy    string
+	Duration string
+	ExpireAt int64
+}
+
+// GetBanLedger searches the ban ledger by mask, actor, action, type and time range
+func GetBanLedger(c *gin.Context) {
+	db := database.Get()
+
+	query := db.Model(&models.BanLedgerEntry{})
+
+	if mask := c.Query("mask"); mask != "" {
+		if strings.ContainsAny(mask, "*?") {
+			query = query.Where("mask LIKE ? ESCAPE ?", wildcardLikePattern(mask), likeEscape)
+		} else {
+			query = query.Where("mask = ?", mask)
+		}
+	}
+	if actor := c.Query("actor"); actor != "" {
+		query = query.Where("actor LIKE ? OR set_by LIKE ?", "%"+actor+"%", "%"+actor+"%")
+	}
+	if action := c.Query("action"); action != "" {
+		query = query.Where("action = ?", action)
+	}
+	if banType := c.Query("ban_type"); banType != "" {
+		query = query.Where("ban_type = ?", banType)
+	}
+	if source := c.Query("source"); source != "" {
+		query = query.Where("source = ?", source)
+	}
+	if since, ok := parseLedgerTime(c.Query("since")); ok {
+		query = query.Where("occurred_at >= ?", since)
+	}
+	if until, ok := parseLedgerTime(c.Query("until")); ok {
+		query = query.Where("occurred_at <= ?", until)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	limit := 100
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 1000 {
+			limit = parsed
+		}
+	}
+	offset := 0
+	if o := c.Query("offset"); o != "" {
+		if parsed, err := strconv.Atoi(o); err == nil && parsed >= 0 {
+			offset = parsed
+		}
+	}
+
+	var entries []models.BanLedgerEntry
+	if err := query.Order("occurred_at DESC").Limit(limit).Offset(offset).Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch ban ledger"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"entries": entries,
+		"total":   total,
+		"limit":   limit,
+		"offset":  offset,
+	})
+}
+
+// RecordBanLogEvent records TKL_ADD, TKL_DEL and TKL_EXPIRE log events in the ban ledger
+func RecordBanLogEvent(entry map[string]interface{}) {
+	var action string
+	switch utils.SafeMapGetString(entry, "event_id") {
+	case "TKL_ADD":
+		action = ledgerActionAdd
+	case "TKL_DEL":
+		action = ledgerActionDelete
+	case "TKL_EXPIRE":
+		action = ledgerActionExpire
+	default:
+		return
+	}
+
+	tkl := utils.SafeMapGetMap(entry, "tkl")
+	if tkl == nil {
+		return
+	}
+
+	ledgerEntry := models.BanLedgerEntry{
+		OccurredAt: parseLogTimestamp(utils.SafeMapGetString(entry, "timestamp")),
+		Action:     action,
+		BanType:    normalizeLedgerBanType(utils.SafeMapGetString(tkl, "type")),
+		Mask:       utils.SafeMapGetString(tkl, "name"),
+		Reason:     utils.SafeMapGetString(tkl, "reason"),
+		SetBy:      utils.SafeMapGetString(tkl, "set_by"),
+		Duration:   utils.SafeMapGetString(tkl, "duration_string"),
+		Source:     ledgerSourceLog,
+		Server:     utils.SafeMapGetString(entry, "log_source"),
+	}
+	if expireAt, err := time.Parse(time.RFC3339, utils.SafeMapGetString(tkl, "expire_at")); err == nil {
+		ledgerEntry.ExpireAt = &expireAt
+	}
+
+	// The client that caused the change; for expiries this is the server itself
+	if client := utils.SafeMapGetMap(entry, "client"); client != nil {
+		ledgerEntry.Actor = utils.SafeMapGetString(client, "name")
+	}
+	if ledgerEntry.Actor == "" && action == ledgerActionAdd {
+		ledgerEntry.Actor = ledgerEntry.SetBy
+	}
+
+	if ledgerEntry.Mask == "" {
+		return
+	}
+
+	recordBanLedger(ledgerEntry)
+}
+
+// DiffBanSnapshot compares the live ban lists with the previous snapshot and records
+// anything that was added or removed without the panel or log stream noticing
+func DiffBanSnapshot() {
+	current, err := fetchBanSnapshot()
+	if err != nil {
+		log.Printf("[BanLedger] Failed to fetch ban lists: %v", err)
+		return
+	}
+
+	banSnapshotState.mu.Lock()
+	previous := banSnapshotState.entries
+	banSnapshotState.entries = current
+	banSnapshotState.mu.Unlock()
+
+	// First run after startup only establishes a baseline
+	if previous == nil {
+		return
+	}
+
+	now := time.Now()
+
+	for key, ban := range current {
+		if _, existed := previous[key]; existed {
+			continue
+		}
+		recordBanLedger(snapshotLedgerEntry(ban, ledgerActionAdd, now))
+	}
+
+	for key, ban := range previous {
+		if _, exists := current[key]; exists {
+			continue
+		}
+		action := ledgerActionDelete
+		if ban.ExpireAt > 0 && ban.ExpireAt <= now.Add(time.Minute).Unix() {
+			action = ledgerActionExpire
+		}
+		recordBanLedger(snapshotLedgerEntry(ban, action, now))
+	}
+}
+
+// Helper functions
+
+// recordPanelBanChange records a change made through the panel
+func recordPanelBanChange(actor, action, banType, mask, reason, duration string) {
+	// Server bans and exceptions are recorded with the mask the IRCd stores, as the log stream does
+	if banType != banMetadataTypeName && banType != banMetadataTypeSpamfilter {
+		mask = normalizeServerBanMask(mask)
+	}
+	recordBanLedger(models.BanLedgerEntry{
+		OccurredAt: time.Now(),
+		Action:     action,
+		BanType:    banType,
+		Mask:       mask,
+		Reason:     reason,
+		Duration:   duration,
+		Actor:      actor,
+		Source:     ledgerSourcePanel,
+	})
+}
+
+// recordBanLedger stores a ledger entry unless another source already recorded the same
+// change recently. Only entries since the ban last changed the other way count, so a
+// remove and re-add within the window is kept. Panel entries take precedence: they carry
+// the panel user as actor, so they replace entries for the same change that came from
+// the log stream or a snapshot diff.
+func recordBanLedger(entry models.BanLedgerEntry) {
+	db := database.Get()
+	if db == nil {
+		return
+	}
+
+	since := entry.OccurredAt.Add(-ledgerDedupWindow)
+	opposites := []string{ledgerActionAdd}
+	if entry.Action == ledgerActionAdd {
+		opposites = []string{ledgerActionDelete, ledgerActionExpire}
+	}
+	var opposite models.BanLedgerEntry
+	if db.Where("action IN ? AND ban_type = ? AND mask = ? AND occurred_at > ?",
+		opposites, entry.BanType, entry.Mask, since).
+		Order("occurred_at DESC").First(&opposite).Error == nil {
+		since = opposite.OccurredAt
+	}
+
+	var existing models.BanLedgerEntry
+	err := db.Where("action = ? AND ban_type = ? AND mask = ? AND source <> ? AND occurred_at > ?",
+		entry.Action, entry.BanType, entry.Mask, entry.Source, since).
+		Order("occurred_at DESC").First(&existing).Error
+	if err == nil {
+		if entry.Source == ledgerSourcePanel && existing.Source != ledgerSourcePanel {
+			db.Model(&existing).Updates(map[string]interface{}{
+				"actor":  entry.Actor,
+				"source": ledgerSourcePanel,
+			})
+		} else if entry.Source == ledgerSourceLog && existing.Source == ledgerSourceSnapshot {
+			db.Model(&existing).Updates(map[string]interface{}{
+				"actor":       entry.Actor,
+				"source":      ledgerSourceLog,
+				"occurred_at": entry.OccurredAt,
+				"server":      entry.Server,
+			})
+		}
+		return
+	}
+
+	if err := db.Create(&entry).Error; err != nil {
+		log.Printf("[BanLedger] Failed to record %s of %s %s: %v", entry.Action, entry.BanType, entry.Mask, err)
+	}
+}
+
+func snapshotLedgerEntry(ban banSnapshotEntry, action string, now time.Time) models.BanLedgerEntry {
+	entry := models.BanLedgerEntry{
+		OccurredAt: now,
+		Action:     action,
+		BanType:    ban.BanType,
+		Mask:       ban.Mask,
+		Reason:     ban.Reason,
+		SetBy:      ban.SetBy,
+		Duration:   ban.Duration,
+		Source:     ledgerSourceSnapshot,
+	}
+	if action == ledgerActionAdd {
+		entry.Actor = ban.SetBy
+	}
+	if ban.ExpireAt > 0 {
+		expireAt := time.Unix(ban.ExpireAt, 0)
+		entry.ExpireAt = &expireAt
+	}
+	return entry
+}
+
+// fetchBanSnapshot retrieves all ban lists from the IRCd keyed by type and mask
+func fetchBanSnapshot() (map[string]banSnapshotEntry, error) {
+	manager := rpc.GetManager()
+	entries := make(map[string]banSnapshotEntry)
+
+	bansResult, err := manager.WithRetry(func(client *rpc.Client) (interface{}, error) {
+		return client.ServerBan().GetAll()
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, ban := range parseServerBanList(bansResult) {
+		addSnapshotEntry(entries, banSnapshotEntry{ban.Type, ban.Name, ban.Reason, ban.SetBy, ban.Duration, ban.ExpireAt})
+	}
+
+	nameBansResult, err := manager.WithRetry(func(client *rpc.Client) (interface{}, error) {
+		return client.NameBan().GetAll()
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, ban := range parseNameBanList(nameBansResult) {
+		addSnapshotEntry(entries, banSnapshotEntry{banMetadataTypeName, ban.Name, ban.Reason, ban.SetBy, ban.Duration, ban.ExpireAt})
+	}
+
+	exceptionsResult, err := manager.WithRetry(func(client *rpc.Client) (interface{}, error) {
+		return client.ServerBanException().GetAll()
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, exc := range parseBanExceptionList(exceptionsResult) {
+		addSnapshotEntry(entries, banSnapshotEntry{banMetadataTypeException, exc.Name, exc.Reason, exc.SetBy, exc.Duration, exc.ExpireAt})
+	}
+
+	spamfiltersResult, err := manager.WithRetry(func(client *rpc.Client) (interface{}, error) {
+		return client.Spamfilter().GetAll()
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, sf := range parseSpamfilterList(spamfiltersResult) {
+		addSnapshotEntry(entries, banSnapshotEntry{banMetadataTypeSpamfilter, sf.Name, sf.Reason, sf.SetBy, sf.BanDuration, 0})
+	}
+
+	return entries, nil
+}
+
+func addSnapshotEntry(entries map[string]banSnapshotEntry, entry banSnapshotEntry) {
+	entries[banMetadataKey(entry.BanType, entry.Mask)] = entry
+}
+
+// normalizeLedgerBanType maps TKL type names from log events onto the types used in the ledger
+func normalizeLedgerBanType(tklType string) string {
+	switch strings.ToLower(tklType) {
+	case "qline", "gqline", "nick", "global_nick":
+		return banMetadataTypeName
+	case "except", "gexcept", "eline", "exception", "global_exception":
+		return banMetadataTypeException
+	case "spamfilter", "global_spamfilter":
+		return banMetadataTypeSpamfilter
+	default:
+		return strings.ToLower(tklType)
+	}
+}
+
+// parseLogTimestamp parses the timestamp of an UnrealIRCd JSON log entry
+func parseLogTimestamp(ts string) time.Time {
+	if ts != "" {
+		if t, err := time.Parse(time.RFC3339Nano, ts); err == nil {
+			return t
+		}
+	}
+	return time.Now()
+}
+
+// parseLedgerTime accepts either a unix timestamp or an RFC3339 time
+func parseLedgerTime(value string) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+	if unix, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(unix, 0), true
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, true
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,528 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/api/middleware"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/database"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/database/models"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/utils"
+	"gorm.io/gorm"
+)
+
+// Ban types used as metadata keys for entries that don't carry their own TKL type
+const (
+	banMetadataTypeName       = "name"
+	banMetadataTypeSpamfilter = "spamfilter"
+	banMetadataTypeException  = "exception"
+)
+
+// placeholderPattern matches {placeholder} tokens in reason templates
+var placeholderPattern = regexp.MustCompile(`\{([a-zA-Z0-9_]+)\}`)
+
+// BanMetadataRequest represents a request to create/update ban metadata
+type BanMetadataRequest struct {
+	BanType      string `json:"ban_type" binding:"required"`
+	Mask         string `json:"mask" binding:"required"`
+	CaseRef      string `json:"case_ref"`
+	Evidence     string `json:"evidence"`
+	InternalNote string `json:"internal_note"`
+	TemplateID   *uint  `json:"template_id"`
+}
+
+// BanReasonTemplateRequest represents a request to create/update a reason template
+type BanReasonTemplateRequest struct {
+	Name            string `json:"name" binding:"required"`
+	Description     string `json:"description"`
+	BanKind         string `json:"ban_kind"`
+	Reason          string `json:"reason" binding:"required"`
+	DefaultDuration string `json:"default_duration"`
+}
+
+// BanReasonRequest carries the optional template and metadata fields accepted when adding a ban
+type BanReasonRequest struct {
+	TemplateID     *uint             `json:"template_id"`
+	TemplateValues map[string]string `json:"template_values"`
+	CaseRef        string            `json:"case_ref"`
+	Evidence       string            `json:"evidence"`
+	InternalNote   string            `json:"internal_note"`
+}
+
+// GetBanMetadata returns metadata entries, optionally filtered by type, mask or case reference
+func GetBanMetadata(c *gin.Context) {
+	db := database.Get()
+
+	query := db.Model(&models.BanMetadata{})
+	if banType := c.Query("ban_type"); banType != "" {
+		query = query.Where("ban_type = ?", banType)
+	}
+	if mask := c.Query("mask"); mask != "" {
+		query = query.Where("mask = ?", mask)
+	}
+	if caseRef := c.Query("case_ref"); caseRef != "" {
+		query = query.Where("case_ref LIKE ?", "%"+caseRef+"%")
+	}
+
+	var entries []models.BanMetadata
+	if err := query.Order("updated_at DESC").Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch ban metadata"})
+		return
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
+
+// SaveBanMetadata creates or updates the metadata for a ban
+func SaveBanMetadata(c *gin.Context) {
+	user := middleware.GetCurrentUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Not authenticated"})
+		return
+	}
+
+	var req BanMetadataRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	meta, err := saveBanMetadata(user, req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save ban metadata"})
+		return
+	}
+
+	logAction(c, user, "update_ban_metadata", map[string]string{
+		"ban_type": req.BanType,
+		"mask":     req.Mask,
+		"case_ref": req.CaseRef,
+	})
+
+	c.JSON(http.StatusOK, meta)
+}
+
+// DeleteBanMetadata removes metadata for a ban
+func DeleteBanMetadata(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	db := database.Get()
+	result := db.Delete(&models.BanMetadata{}, id)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete ban metadata"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ban metadata not found"})
+		return
+	}
+
+	currentUser := middleware.GetCurrentUser(c)
+	if currentUser != nil {
+		logAction(c, currentUser, "delete_ban_metadata", map[string]string{
+			"id": c.Param("id"),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Ban metadata deleted"})
+}
+
+// GetBanReasonTemplates returns all reason templates, optionally filtered by ban kind
+func GetBanReasonTemplates(c *gin.Context) {
+	db := database.Get()
+
+	query := db.Model(&models.BanReasonTemplate{})
+	if kind := c.Query("kind"); kind != "" {
+		query = query.Where("ban_kind = ? OR ban_kind = ''", kind)
+	}
+
+	var templates []models.BanReasonTemplate
+	if err := query.Order("use_count DESC, name ASC").Find(&templates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reason templates"})
+		return
+	}
+
+	c.JSON(http.StatusOK, templates)
+}
+
+// CreateBanReasonTemplate creates a new reason template
+func CreateBanReasonTemplate(c *gin.Context) {
+	user := middleware.GetCurrentUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Not authenticated"})
+		return
+	}
+
+	var req BanReasonTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	template := models.BanReasonTemplate{
+		Name:              req.Name,
+		Description:       req.Description,
+		BanKind:           req.BanKind,
+		Reason:            req.Reason,
+		DefaultDuration:   req.DefaultDuration,
+		CreatedBy:         user.ID,
+		CreatedByUsername: user.Username,
+	}
+
+	db := database.Get()
+	if err := db.Create(&template).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create reason template"})
+		return
+	}
+
+	logAction(c, user, "create_ban_reason_template", map[string]string{
+		"name": req.Name,
+	})
+
+	c.JSON(http.StatusCreated, template)
+}
+
+// UpdateBanReasonTemplate updates an existing reason template
+func UpdateBanReasonTemplate(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	db := database.Get()
+
+	var template models.BanReasonTemplate
+	if err := db.First(&template, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reason template not found"})
+		return
+	}
+
+	var req BanReasonTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	template.Name = req.Name
+	template.Description = req.Description
+	template.BanKind = req.BanKind
+	template.Reason = req.Reason
+	template.DefaultDuration = req.DefaultDuration
+
+	if err := db.Save(&template).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update reason template"})
+		return
+	}
+
+	currentUser := middleware.GetCurrentUser(c)
+	if currentUser != nil {
+		logAction(c, currentUser, "update_ban_reason_template", map[string]string{
+			"id":   c.Param("id"),
+			"name": req.Name,
+		})
+	}
+
+	c.JSON(http.StatusOK, template)
+}
+
+// DeleteBanReasonTemplate deletes a reason template
+func DeleteBanReasonTemplate(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	db := database.Get()
+	result := db.Delete(&models.BanReasonTemplate{}, id)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete reason template"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reason template not found"})
+		return
+	}
+
+	currentUser := middleware.GetCurrentUser(c)
+	if currentUser != nil {
+		logAction(c, currentUser, "delete_ban_reason_template", map[string]string{
+			"id": c.Param("id"),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Reason template deleted"})
+}
+
+// PreviewBanReasonTemplate renders a template with the given values without using it
+func PreviewBanReasonTemplate(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var req struct {
+		Values   map[string]string `json:"values"`
+		Duration string            `json:"duration"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	db := database.Get()
+	var template models.BanReasonTemplate
+	if err := db.First(&template, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reason template not found"})
+		return
+	}
+
+	values := banTemplateValues(req.Values, req.Duration, template.DefaultDuration)
+	reason, missing := renderPlaceholders(template.Reason, values)
+
+	c.JSON(http.StatusOK, gin.H{
+		"reason":       reason,
+		"placeholders": templatePlaceholders(template.Reason),
+		"missing":      missing,
+	})
+}
+
+// Helper functions
+
+// renderPlaceholders substitutes {name} tokens from values and reports the ones left unfilled
+func renderPlaceholders(text string, values map[string]string) (string, []string) {
+	missing := make([]string, 0)
+	rendered := placeholderPattern.ReplaceAllStringFunc(text, func(token string) string {
+		key := token[1 : len(token)-1]
+		if value, ok := values[key]; ok && value != "" {
+			return value
+		}
+		missing = append(missing, key)
+		return token
+	})
+	return rendered, missing
+}
+
+// templatePlaceholders lists the distinct placeholder names used in a template
+func templatePlaceholders(text string) []string {
+	seen := make(map[string]bool)
+	names := make([]string, 0)
+	for _, match := range placeholderPattern.FindAllStringSubmatch(text, -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			names = append(names, match[1])
+		}
+	}
+	return names
+}
+
+// banTemplateValues fills the built-in placeholders ({duration}, {contact}) around user-supplied values
+func banTemplateValues(values map[string]string, duration, defaultDuration string) map[string]string {
+	result := make(map[string]string)
+	if duration == "" {
+		duration = defaultDuration
+	}
+	if duration == "" || duration == "0" {
+		duration = "permanent"
+	}
+	result["duration"] = duration
+	if contact := GetBanContact(); contact != "" {
+		result["contact"] = contact
+	}
+	for k, v := range values {
+		result[k] = v
+	}
+	return result
+}
+
+// resolveBanReason applies a reason template to a ban add request.
+// It returns the rendered reason and the duration to use, falling back to the
+// request's own values when no template was selected. Placeholders left without
+// a value are an error, so that they never reach the IRCd.
+func resolveBanReason(req *BanReasonRequest, kind, reason, duration string) (string, string, error) {
+	if req.TemplateID == nil {
+		return reason, duration, nil
+	}
+
+	db := database.Get()
+	var template models.BanReasonTemplate
+	if err := db.First(&template, *req.TemplateID).Error; err != nil {
+		return "", "", err
+	}
+	if template.BanKind != "" && template.BanKind != kind {
+		return "", "", errTemplateKind
+	}
+
+	values := make(map[string]string)
+	if req.CaseRef != "" {
+		values["ticket"] = req.CaseRef
+	}
+	for k, v := range req.TemplateValues {
+		values[k] = v
+	}
+
+	if duration == "" {
+		duration = template.DefaultDuration
+	}
+	rendered, missing := renderPlaceholders(template.Reason, banTemplateValues(values, duration, template.DefaultDuration))
+	if len(missing) > 0 {
+		return "", "", errors.New("no value for placeholders {" + strings.Join(missing, "}, {") + "}")
+	}
+
+	return rendered, duration, nil
+}
+
+// errTemplateKind is returned when a template is used with the wrong kind of ban
+var errTemplateKind = errors.New("reason template is not applicable to this kind of ban")
+
+// recordBanMetadataFromRequest stores metadata supplied along with a ban add request, if any
+func recordBanMetadataFromRequest(user *models.User, banType, mask string, req *BanReasonRequest) {
+	if req.TemplateID != nil {
+		database.Get().Model(&models.BanReasonTemplate{}).Where("id = ?", *req.TemplateID).
+			UpdateColumn("use_count", gorm.Expr("use_count + 1"))
+	}
+	if user == nil {
+		return
+	}
+	if req.CaseRef == "" && req.Evidence == "" && req.InternalNote == "" && req.TemplateID == nil {
+		return
+	}
+	saveBanMetadata(user, BanMetadataRequest{
+		BanType:      banType,
+		Mask:         mask,
+		CaseRef:      req.CaseRef,
+		Evidence:     req.Evidence,
+		InternalNote: req.InternalNote,
+		TemplateID:   req.TemplateID,
+	})
+}
+
+func saveBanMetadata(user *models.User, req BanMetadataRequest) (*models.BanMetadata, error) {
+	db := database.Get()
+
+	var meta models.BanMetadata
+	err := db.Where("ban_type = ? AND mask = ?", req.BanType, req.Mask).First(&meta).Error
+	if err != nil {
+		meta = models.BanMetadata{
+			BanType:           req.BanType,
+			Mask:              req.Mask,
+			CreatedBy:         user.ID,
+			CreatedByUsername: user.Username,
+		}
+	}
+
+	meta.CaseRef = req.CaseRef
+	meta.Evidence = req.Evidence
+	meta.InternalNote = req.InternalNote
+	meta.TemplateID = req.TemplateID
+	meta.UpdatedByUsername = user.Username
+
+	if err := db.Save(&meta).Error; err != nil {
+		return nil, err
+	}
+	return &meta, nil
+}
+
+// loadBanMetadata returns all metadata for the given ban types keyed by banMetadataKey
+func loadBanMetadata(banTypes ...string) map[string]*models.BanMetadata {
+	result := make(map[string]*models.BanMetadata)
+
+	db := database.Get()
+	query := db.Model(&models.BanMetadata{})
+	if len(banTypes) > 0 {
+		query = query.Where("ban_type IN ?", banTypes)
+	}
+
+	var entries []models.BanMetadata
+	if err := query.Find(&entries).Error; err != nil {
+		return result
+	}
+	for i := range entries {
+		result[banMetadataKey(entries[i].BanType, entries[i].Mask)] = &entries[i]
+	}
+	return result
+}
+
+func banMetadataKey(banType, mask string) string {
+	return strings.ToLower(banType) + "|" + strings.ToLower(mask)
+}
+
+func attachServerBanMetadata(bans []ServerBan) {
+	if len(bans) == 0 {
+		return
+	}
+	meta := loadBanMetadata()
+	for i := range bans {
+		bans[i].Metadata = meta[banMetadataKey(bans[i].Type, bans[i].Name)]
+	}
+}
+
+func attachNameBanMetadata(bans []NameBan) {
+	if len(bans) == 0 {
+		return
+	}
+	meta := loadBanMetadata(banMetadataTypeName)
+	for i := range bans {
+		bans[i].Metadata = meta[banMetadataKey(banMetadataTypeName, bans[i].Name)]
+	}
+}
+
+func attachSpamfilterMetadata(filters []Spamfilter) {
+	if len(filters) == 0 {
+		return
+	}
+	meta := loadBanMetadata(banMetadataTypeSpamfilter)
+	for i := range filters {
+		mask := spamfilterMetadataMask(filters[i].Name, filters[i].MatchType, filters[i].SpamfilterTargets, filters[i].BanAction)
+		filters[i].Metadata = meta[banMetadataKey(banMetadataTypeSpamfilter, mask)]
+	}
+}
+
+// addedServerBanMask returns the mask the IRCd stored for a server ban it just added.
+// The IRCd normalises masks (a bare host becomes *@host); if the reply does not carry
+// the stored mask, the same normalisation is applied here.
+func addedServerBanMask(result interface{}, mask string) string {
+	return addedBanName(result, normalizeServerBanMask(mask))
+}
+
+// addedBanName returns the name the IRCd stored for a ban it just added, or fallback
+// when the reply does not carry it
+func addedBanName(result interface{}, fallback string) string {
+	reply := utils.InterfaceToMap(result)
+	if tkl := utils.SafeMapGetMap(reply, "tkl"); tkl != nil {
+		reply = tkl
+	}
+	if name := utils.SafeMapGetString(reply, "name"); name != "" {
+		return name
+	}
+	return fallback
+}
+
+// normalizeServerBanMask turns a bare host or IP into the user@host mask the IRCd stores.
+// Extended server bans (~account:...) are left as they are.
+func normalizeServerBanMask(mask string) string {
+	mask = strings.TrimSpace(mask)
+	if mask == "" || strings.HasPrefix(mask, "~") || strings.Contains(mask, "@") {
+		return mask
+	}
+	return "*@" + mask
+}
+
+// spamfilterMetadataMask is the metadata mask of a spamfilter. The match string alone
+// is not unique: the IRCd tells spamfilters apart by match type, targets and action too.
+func spamfilterMetadataMask(name, matchType, targets, action string) string {
+	letters := strings.Split(strings.ToLower(targets), "")
+	sort.Strings(letters)
+	return strings.ToLower(matchType) + ":" + strings.Join(letters, "") + ":" + strings.ToLower(action) + ":" + name
+}
@@ -0,0 +1,901 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/api/middleware"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/auth"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/database/models"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/rpc"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/utils"
+)
+
+// Kinds of entries that can be imported and exported
+const (
+	transferKindServerBan  = "server_ban"
+	transferKindNameBan    = "name_ban"
+	transferKindException  = "ban_exception"
+	transferKindSpamfilter = "spamfilter"
+)
+
+// Import diff statuses
+const (
+	transferStatusNew       = "new"
+	transferStatusUnchanged = "unchanged"
+	transferStatusChanged   = "changed"
+)
+
+// exceptionTypeNames maps ban exception type letters to their config names
+var exceptionTypeNames = map[byte]string{
+	'k': "kline",
+	'G': "gline",
+	'z': "zline",
+	'Z': "gzline",
+	's': "shun",
+	'F': "spamfilter",
+	'q': "qline",
+	'b': "blacklist",
+	'c': "connect-flood",
+	'd': "handshake-data-flood",
+	'm': "maxperip",
+	'r': "antirandom",
+	'8': "antimixedutf8",
+	'v': "ban-version",
+}
+
+// spamfilterTargetNames maps spamfilter target letters to their config names
+var spamfilterTargetNames = map[byte]string{
+	'c': "channel",
+	'p': "private",
+	'n': "private-notice",
+	'N': "channel-notice",
+	'P': "part",
+	'q': "quit",
+	'd': "dcc",
+	'a': "away",
+	't': "topic",
+	'T': "message-tag",
+	'u': "user",
+	'r': "raw",
+}
+
+// BanTransferItem is a single entry in an import or export
+type BanTransferItem struct {
+	Kind           string `json:"kind" yaml:"kind,omitempty"`                                 // server_ban, name_ban, ban_exception, spamfilter
+	Type           string `json:"type,omitempty" yaml:"type,omitempty"`                       // TKL type for server bans (gline, kline, zline, ...)
+	Mask           string `json:"mask" yaml:"mask"`                                           // Ban mask or spamfilter match string
+	Reason         string `json:"reason,omitempty" yaml:"reason,omitempty"`                   // Reason
+	Duration       string `json:"duration,omitempty" yaml:"duration,omitempty"`               // Remaining duration, "0" for permanent
+	ExceptionTypes string `json:"exception_types,omitempty" yaml:"exception_types,omitempty"` // Exception type letters
+	MatchType      string `json:"match_type,omitempty" yaml:"match_type,omitempty"`           // Spamfilter match type
+	Targets        string `json:"targets,omitempty" yaml:"targets,omitempty"`                 // Spamfilter target letters
+	Action         string `json:"action,omitempty" yaml:"action,omitempty"`                   // Spamfilter action
+	SetBy          string `json:"set_by,omitempty" yaml:"-"`                                  // Setter, informational only
+}
+
+// BanImportRequest represents an import preview/apply request
+type BanImportRequest struct {
+	Format         string `json:"format" binding:"required"` // conf, json or csv
+	Content        string `json:"content" binding:"required"`
+	UserBanType    string `json:"user_ban_type"`   // TKL type used for `ban user {}` blocks (default gline)
+	IPBanType      string `json:"ip_ban_type"`     // TKL type used for `ban ip {}` blocks (default gzline)
+	ReplaceChanged bool   `json:"replace_changed"` // Re-add entries whose reason or settings differ
+	Items          []int  `json:"items"`           // Only apply these item indexes (all when empty)
+}
+
+// BanImportDiffEntry describes how an imported item compares to the live lists
+type BanImportDiffEntry struct {
+	Index   int              `json:"index"`
+	Item    BanTransferItem  `json:"item"`
+	Status  string           `json:"status"`
+	Current *BanTransferItem `json:"current,omitempty"`
+}
+
+// BanImportResult describes the outcome of applying a single imported item
+type BanImportResult struct {
+	Index  int             `json:"index"`
+	Item   BanTransferItem `json:"item"`
+	Status string          `json:"status"` // added, replaced, skipped, failed
+	Error  string          `json:"error,omitempty"`
+}
+
+// ExportBans exports bans, exceptions and spamfilters as UnrealIRCd config, JSON or CSV
+func ExportBans(c *gin.Context) {
+	format := c.DefaultQuery("format", "json")
+	kinds := parseTransferKinds(c.Query("kinds"))
+
+	items, err := fetchLiveTransferItems(kinds)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch ban lists: " + err.Error()})
+		return
+	}
+
+	filename := fmt.Sprintf("bans_%s", time.Now().Format("20060102_150405"))
+
+	switch format {
+	case "conf":
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.conf", filename))
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(writeTransferConf(items)))
+	case "csv":
+		data, err := writeTransferCSV(items)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate CSV"})
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.csv", filename))
+		c.Data(http.StatusOK, "text/csv", data)
+	case "json":
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.json", filename))
+		c.JSON(http.StatusOK, items)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid format. Use conf, json or csv"})
+	}
+}
+
+// PreviewBanImport parses an import and shows how it differs from the live lists
+func PreviewBanImport(c *gin.Context) {
+	var req BanImportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+
+	items, parseErrors, err := parseTransferItems(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	diff, err := diffTransferItems(items)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch ban lists: " + err.Error()})
+		return
+	}
+
+	summary := map[string]int{
+		transferStatusNew:       0,
+		transferStatusUnchanged: 0,
+		transferStatusChanged:   0,
+	}
+	for _, d := range diff {
+		summary[d.Status]++
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"diff":    diff,
+		"summary": summary,
+		"errors":  parseErrors,
+	})
+}
+
+// ApplyBanImport applies an import as a batch and reports the result of each item
+func ApplyBanImport(c *gin.Context) {
+	var req BanImportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+
+	currentUser := middleware.GetCurrentUser(c)
+	if currentUser == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Not authenticated"})
+		return
+	}
+
+	items, parseErrors, err := parseTransferItems(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	diff, err := diffTransferItems(items)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch ban lists: " + err.Error()})
+		return
+	}
+
+	selected := make(map[int]bool)
+	for _, idx := range req.Items {
+		selected[idx] = true
+	}
+
+	results := make([]BanImportResult, 0, len(diff))
+	counts := make(map[string]int)
+
+	for _, d := range diff {
+		result := BanImportResult{Index: d.Index, Item: d.Item}
+
+		switch {
+		case len(selected) > 0 && !selected[d.Index]:
+			result.Status = "skipped"
+			result.Error = "not selected"
+		case d.Status == transferStatusUnchanged:
+			result.Status = "skipped"
+			result.Error = "already present"
+		case d.Status == transferStatusChanged && !req.ReplaceChanged:
+			result.Status = "skipped"
+			result.Error = "differs from live entry"
+		case !auth.UserCan(currentUser, transferAddPermission(d.Item.Kind)):
+			result.Status = "failed"
+			result.Error = "permission denied"
+		case d.Status == transferStatusChanged && d.Current != nil && !auth.UserCan(currentUser, transferDeletePermission(d.Current.Kind)):
+			result.Status = "failed"
+			result.Error = "permission denied: replacing an entry requires permission to remove it"
+		default:
+			if d.Status == transferStatusChanged && d.Current != nil {
+				if err := removeTransferItem(*d.Current); err != nil {
+					result.Status = "failed"
+					result.Error = "failed to remove existing entry: " + err.Error()
+					break
+				}
+				if err := addTransferItem(d.Item); err != nil {
+					result.Status = "failed"
+					result.Error = err.Error()
+					if restoreErr := addTransferItem(*d.Current); restoreErr != nil {
+						result.Error = fmt.Sprintf("%v (restoring previous entry also failed: %v)", err, restoreErr)
+						recordPanelBanChange(currentUser.Username, ledgerActionDelete, transferLedgerType(*d.Current), d.Current.Mask, d.Current.Reason, "")
+					}
+					break
+				}
+				recordPanelBanChange(currentUser.Username, ledgerActionDelete, transferLedgerType(*d.Current), d.Current.Mask, d.Current.Reason, "")
+			} else if err := addTransferItem(d.Item); err != nil {
+				result.Status = "failed"
+				result.Error = err.Error()
+				break
+			}
+			recordPanelBanChange(currentUser.Username, ledgerActionAdd, transferLedgerType(d.Item), d.Item.Mask, d.Item.Reason, d.Item.Duration)
+			if d.Status == transferStatusChanged {
+				result.Status = "replaced"
+			} else {
+				result.Status = "added"
+			}
+		}
+
+		counts[result.Status]++
+		results = append(results, result)
+	}
+
+	logAction(c, currentUser, "import_bans", map[string]string{
+		"format":   req.Format,
+		"items":    strconv.Itoa(len(items)),
+		"added":    strconv.Itoa(counts["added"]),
+		"replaced": strconv.Itoa(counts["replaced"]),
+		"skipped":  strconv.Itoa(counts["skipped"]),
+		"failed":   strconv.Itoa(counts["failed"]),
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"results": results,
+		"summary": counts,
+		"errors":  parseErrors,
+	})
+}
+
+// Helper functions
+
+func parseTransferKinds(value string) map[string]bool {
+	kinds := map[string]bool{
+		transferKindServerBan:  true,
+		transferKindNameBan:    true,
+		transferKindException:  true,
+		transferKindSpamfilter: true,
+	}
+	if value == "" {
+		return kinds
+	}
+	selected := make(map[string]bool)
+	for _, k := range strings.Split(value, ",") {
+		k = strings.TrimSpace(k)
+		if kinds[k] {
+			selected[k] = true
+		}
+	}
+	return selected
+}
+
+// fetchLiveTransferItems returns the live lists from the IRCd as transfer items
+func fetchLiveTransferItems(kinds map[string]bool) ([]BanTransferItem, error) {
+	manager := rpc.GetManager()
+	items := make([]BanTransferItem, 0)
+	now := time.Now().Unix()
+
+	if kinds[transferKindServerBan] {
+		result, err := manager.WithRetry(func(client *rpc.Client) (interface{}, error) {
+			return client.ServerBan().GetAll()
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, ban := range parseServerBanList(result) {
+			items = append(items, BanTransferItem{
+				Kind:     transferKindServerBan,
+				Type:     ban.Type,
+				Mask:     ban.Name,
+				Reason:   ban.Reason,
+				Duration: remainingBanDuration(ban.ExpireAt, now),
+				SetBy:    ban.SetBy,
+			})
+		}
+	}
+
+	if kinds[transferKindNameBan] {
+		result, err := manager.WithRetry(func(client *rpc.Client) (interface{}, error) {
+			return client.NameBan().GetAll()
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, ban := range parseNameBanList(result) {
+			items = append(items, BanTransferItem{
+				Kind:     transferKindNameBan,
+				Mask:     ban.Name,
+				Reason:   ban.Reason,
+				Duration: remainingBanDuration(ban.ExpireAt, now),
+				SetBy:    ban.SetBy,
+			})
+		}
+	}
+
+	if kinds[transferKindException] {
+		result, err := manager.WithRetry(func(client *rpc.Client) (interface{}, error) {
+			return client.ServerBanException().GetAll()
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, exc := range parseBanExceptionList(result) {
+			items = append(items, BanTransferItem{
+				Kind:           transferKindException,
+				Mask:           exc.Name,
+				Reason:         exc.Reason,
+				Duration:       remainingBanDuration(exc.ExpireAt, now),
+				ExceptionTypes: exc.ExceptionTypes,
+				SetBy:          exc.SetBy,
+			})
+		}
+	}
+
+	if kinds[transferKindSpamfilter] {
+		result, err := manager.WithRetry(func(client *rpc.Client) (interface{}, error) {
+			return client.Spamfilter().GetAll()
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, sf := range parseSpamfilterList(result) {
+			items = append(items, BanTransferItem{
+				Kind:      transferKindSpamfilter,
+				Mask:      sf.Name,
+				Reason:    sf.Reason,
+				Duration:  sf.BanDuration,
+				MatchType: sf.MatchType,
+				Targets:   sf.SpamfilterTargets,
+				Action:    sf.BanAction,
+				SetBy:     sf.SetBy,
+			})
+		}
+	}
+
+	return items, nil
+}
+
+// remainingBanDuration converts an expiry timestamp into a duration usable when re-adding the ban
+func remainingBanDuration(expireAt, now int64) string {
+	if expireAt <= 0 {
+		return "0"
+	}
+	remaining := expireAt - now
+	if remaining < 1 {
+		remaining = 1
+	}
+	return formatBanDuration(remaining)
+}
+
+// formatBanDuration formats seconds in the UnrealIRCd time format (e.g. 1d2h30m)
+func formatBanDuration(seconds int64) string {
+	if seconds <= 0 {
+		return "0"
+	}
+	var sb strings.Builder
+	units := []struct {
+		suffix string
+		size   int64
+	}{{"w", 604800}, {"d", 86400}, {"h", 3600}, {"m", 60}, {"s", 1}}
+	for _, u := range units {
+		if seconds >= u.size {
+			sb.WriteString(strconv.FormatInt(seconds/u.size, 10))
+			sb.WriteString(u.suffix)
+			seconds %= u.size
+		}
+	}
+	return sb.String()
+}
+
+// transferItemKey identifies an item so that imports can be matched against live entries
+func transferItemKey(item BanTransferItem) string {
+	mask := strings.ToLower(item.Mask)
+	switch item.Kind {
+	case transferKindServerBan:
+		// The IRCd stores "host" as "*@host", so compare the stored form
+		return item.Kind + "|" + strings.ToLower(item.Type) + "|" + strings.ToLower(normalizeServerBanMask(item.Mask))
+	case transferKindException:
+		return item.Kind + "|" + strings.ToLower(normalizeServerBanMask(item.Mask))
+	case transferKindSpamfilter:
+		return item.Kind + "|" + strings.ToLower(item.MatchType) + "|" + sortLetters(item.Targets) + "|" + strings.ToLower(item.Action) + "|" + item.Mask
+	default:
+		return item.Kind + "|" + mask
+	}
+}
+
+// transferItemsEqual compares the parts of an item that can't be changed in place
+func transferItemsEqual(a, b BanTransferItem) bool {
+	if a.Reason != b.Reason {
+		return false
+	}
+	if a.Kind == transferKindException && sortLetters(a.ExceptionTypes) != sortLetters(b.ExceptionTypes) {
+		return false
+	}
+	return true
+}
+
+func sortLetters(s string) string {
+	letters := []byte(s)
+	sort.Slice(letters, func(i, j int) bool { return letters[i] < letters[j] })
+	return string(letters)
+}
+
+func diffTransferItems(items []BanTransferItem) ([]BanImportDiffEntry, error) {
+	kinds := make(map[string]bool)
+	for _, item := range items {
+		kinds[item.Kind] = true
+	}
+
+	live, err := fetchLiveTransferItems(kinds)
+	if err != nil {
+		return nil, err
+	}
+	liveByKey := make(map[string]BanTransferItem, len(live))
+	for _, item := range live {
+		liveByKey[transferItemKey(item)] = item
+	}
+
+	diff := make([]BanImportDiffEntry, 0, len(items))
+	for i, item := range items {
+		entry := BanImportDiffEntry{Index: i, Item: item, Status: transferStatusNew}
+		if current, ok := liveByKey[transferItemKey(item)]; ok {
+			current := current
+			entry.Current = &current
+			if transferItemsEqual(item, current) {
+				entry.Status = transferStatusUnchanged
+			} else {
+				entry.Status = transferStatusChanged
+			}
+		}
+		diff = append(diff, entry)
+	}
+	return diff, nil
+}
+
+func transferAddPermission(kind string) string {
+	switch kind {
+	case transferKindNameBan:
+		return models.PermissionNameBanAdd
+	case transferKindException:
+		return models.PermissionBanExceptionAdd
+	case transferKindSpamfilter:
+		return models.PermissionSpamfilterAdd
+	default:
+		return models.PermissionServerBanAdd
+	}
+}
+
+func transferDeletePermission(kind string) string {
+	switch kind {
+	case transferKindNameBan:
+		return models.PermissionNameBanDel
+	case transferKindException:
+		return models.PermissionBanExceptionDel
+	case transferKindSpamfilter:
+		return models.PermissionSpamfilterDel
+	default:
+		return models.PermissionServerBanDel
+	}
+}
+
+// transferLedgerType returns the ban ledger type for an item
+func transferLedgerType(item BanTransferItem) string {
+	switch item.Kind {
+	case transferKindNameBan:
+		return banMetadataTypeName
+	case transferKindException:
+		return banMetadataTypeException
+	case transferKindSpamfilter:
+		return banMetadataTypeSpamfilter
+	default:
+		return item.Type
+	}
+}
+
+func addTransferItem(item BanTransferItem) error {
+	manager := rpc.GetManager()
+
+	reason := item.Reason
+	if reason == "" {
+		reason = "No reason specified"
+	}
+	duration := item.Duration
+	if duration == "" {
+		duration = "0"
+	}
+
+	_, err := manager.WithRetry(func(client *rpc.Client) (interface{}, error) {
+		switch item.Kind {
+		case transferKindNameBan:
+			var d *string
+			if duration != "0" {
+				d = &duration
+			}
+			return client.NameBan().Add(item.Mask, reason, d, nil)
+		case transferKindException:
+			var d *string
+			if duration != "0" {
+				d = &duration
+			}
+			return client.ServerBanException().Add(item.Mask, item.ExceptionTypes, reason, nil, d)
+		case transferKindSpamfilter:
+			return client.Spamfilter().Add(item.Mask, item.MatchType, item.Targets, item.Action, duration, reason)
+		default:
+			return client.ServerBan().Add(item.Mask, item.Type, duration, reason)
+		}
+	})
+	return err
+}
+
+func removeTransferItem(item BanTransferItem) error {
+	manager := rpc.GetManager()
+
+	_, err := manager.WithRetry(func(client *rpc.Client) (interface{}, error) {
+		switch item.Kind {
+		case transferKindNameBan:
+			return client.NameBan().Delete(item.Mask)
+		case transferKindException:
+			return client.ServerBanException().Delete(item.Mask)
+		case transferKindSpamfilter:
+			return client.Spamfilter().Delete(item.Mask, item.MatchType, item.Targets, item.Action)
+		default:
+			return client.ServerBan().Delete(item.Mask, item.Type)
+		}
+	})
+	return err
+}
+
+// validateTransferItem checks that an item has everything needed to add it
+func validateTransferItem(item *BanTransferItem) error {
+	if item.Mask == "" {
+		return fmt.Errorf("mask is required")
+	}
+	switch item.Kind {
+	case transferKindServerBan:
+		if item.Type == "" {
+			return fmt.Errorf("type is required for server bans")
+		}
+	case transferKindNameBan:
+	case transferKindException:
+		if item.ExceptionTypes == "" {
+			return fmt.Errorf("exception_types is required for ban exceptions")
+		}
+	case transferKindSpamfilter:
+		if item.MatchType == "" || item.Targets == "" || item.Action == "" {
+			return fmt.Errorf("match_type, targets and action are required for spamfilters")
+		}
+	default:
+		return fmt.Errorf("unknown kind '%s'", item.Kind)
+	}
+	return nil
+}
+
+// parseTransferItems parses import content. Invalid entries are reported as
+// errors but don't stop the rest of the document from being imported.
+func parseTransferItems(req *BanImportRequest) ([]BanTransferItem, []string, error) {
+	var items []BanTransferItem
+	var parseErrors []string
+
+	switch req.Format {
+	case "json":
+		if err := json.Unmarshal([]byte(req.Content), &items); err != nil {
+			return nil, nil, fmt.Errorf("invalid JSON: %v", err)
+		}
+	case "csv":
+		var err error
+		items, parseErrors, err = parseTransferCSV(req.Content)
+		if err != nil {
+			return nil, nil, err
+		}
+	case "conf":
+		var err error
+		items, parseErrors, err = parseTransferConf(req.Content, req.UserBanType, req.IPBanType)
+		if err != nil {
+			return nil, nil, err
+		}
+	default:
+		return nil, nil, fmt.Errorf("invalid format, use conf, json or csv")
+	}
+
+	valid := make([]BanTransferItem, 0, len(items))
+	for i := range items {
+		if err := validateTransferItem(&items[i]); err != nil {
+			parseErrors = append(parseErrors, fmt.Sprintf("item %d (%s): %v", i+1, items[i].Mask, err))
+			continue
+		}
+		valid = append(valid, items[i])
+	}
+
+	if parseErrors == nil {
+		parseErrors = []string{}
+	}
+	return valid, parseErrors, nil
+}
+
+var transferCSVHeader = []string{"kind", "type", "mask", "reason", "duration", "exception_types", "match_type", "targets", "action"}
+
+func writeTransferCSV(items []BanTransferItem) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write(transferCSVHeader)
+	for _, item := range items {
+		w.Write([]string{item.Kind, item.Type, item.Mask, item.Reason, item.Duration, item.ExceptionTypes, item.MatchType, item.Targets, item.Action})
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+func parseTransferCSV(content string) ([]BanTransferItem, []string, error) {
+	r := csv.NewReader(strings.NewReader(content))
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid CSV: %v", err)
+	}
+	if len(records) == 0 {
+		return []BanTransferItem{}, nil, nil
+	}
+
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["mask"]; !ok {
+		return nil, nil, fmt.Errorf("CSV header must include a 'mask' column")
+	}
+
+	get := func(record []string, name string) string {
+		if idx, ok := columns[name]; ok && idx < len(record) {
+			return strings.TrimSpace(record[idx])
+		}
+		return ""
+	}
+
+	items := make([]BanTransferItem, 0, len(records)-1)
+	for _, record := range records[1:] {
+		items = append(items, BanTransferItem{
+			Kind:           get(record, "kind"),
+			Type:           get(record, "type"),
+			Mask:           get(record, "mask"),
+			Reason:         get(record, "reason"),
+			Duration:       get(record, "duration"),
+			ExceptionTypes: get(record, "exception_types"),
+			MatchType:      get(record, "match_type"),
+			Targets:        get(record, "targets"),
+			Action:         get(record, "action"),
+		})
+	}
+	return items, nil, nil
+}
+
+// writeTransferConf renders items as UnrealIRCd configuration blocks
+func writeTransferConf(items []BanTransferItem) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("/* Exported by UnrealIRCd Web Panel on %s */\n\n", time.Now().Format(time.RFC1123)))
+
+	for _, item := range items {
+		switch item.Kind {
+		case transferKindServerBan:
+			block := "user"
+			switch strings.ToLower(item.Type) {
+			case "zline", "gzline":
+				block = "ip"
+			case "kline", "gline":
+			default:
+				sb.WriteString(fmt.Sprintf("/* %s on %s has no config equivalent: %s */\n\n", item.Type, item.Mask, item.Reason))
+				continue
+			}
+			sb.WriteString(fmt.Sprintf("/* %s, duration %s */\n", item.Type, item.Duration))
+			sb.WriteString(fmt.Sprintf("ban %s {\n\tmask %s;\n\treason %s;\n};\n\n", block, utils.QuoteConfString(item.Mask), utils.QuoteConfString(item.Reason)))
+		case transferKindNameBan:
+			sb.WriteString(fmt.Sprintf("ban nick {\n\tmask %s;\n\treason %s;\n};\n\n", utils.QuoteConfString(item.Mask), utils.QuoteConfString(item.Reason)))
+		case transferKindException:
+			sb.WriteString(fmt.Sprintf("except ban {\n\tmask %s;\n\ttype { ", utils.QuoteConfString(item.Mask)))
+			for i := 0; i < len(item.ExceptionTypes); i++ {
+				if name, ok := exceptionTypeNames[item.ExceptionTypes[i]]; ok {
+					sb.WriteString(name + "; ")
+				}
+			}
+			sb.WriteString("};\n")
+			if item.Reason != "" {
+				sb.WriteString(fmt.Sprintf("\treason %s;\n", utils.QuoteConfString(item.Reason)))
+			}
+			sb.WriteString("};\n\n")
+		case transferKindSpamfilter:
+			sb.WriteString("spamfilter {\n")
+			sb.WriteString(fmt.Sprintf("\tmatch-type %s;\n", item.MatchType))
+			sb.WriteString(fmt.Sprintf("\tmatch %s;\n", utils.QuoteConfString(item.Mask)))
+			sb.WriteString("\ttarget { ")
+			for i := 0; i < len(item.Targets); i++ {
+				if name, ok := spamfilterTargetNames[item.Targets[i]]; ok {
+					sb.WriteString(name + "; ")
+				}
+			}
+			sb.WriteString("};\n")
+			sb.WriteString(fmt.Sprintf("\taction %s;\n", item.Action))
+			if item.Duration != "" && item.Duration != "0" {
+				sb.WriteString(fmt.Sprintf("\tban-time %s;\n", item.Duration))
+			}
+			sb.WriteString(fmt.Sprintf("\treason %s;\n", utils.QuoteConfString(item.Reason)))
+			sb.WriteString("};\n\n")
+		}
+	}
+
+	return sb.String()
+}
+
+// parseTransferConf extracts ban, except ban and spamfilter blocks from UnrealIRCd configuration
+func parseTransferConf(content, userBanType, ipBanType string) ([]BanTransferItem, []string, error) {
+	entries, err := utils.ParseUnrealConf(content)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid config: %v", err)
+	}
+
+	if userBanType == "" {
+		userBanType = "gline"
+	}
+	if ipBanType == "" {
+		ipBanType = "gzline"
+	}
+
+	items := make([]BanTransferItem, 0)
+	var parseErrors []string
+
+	for _, entry := range entries {
+		switch strings.ToLower(entry.Name) {
+		case "ban":
+			mask := entry.ChildValue("mask")
+			item := BanTransferItem{Mask: mask, Reason: entry.ChildValue("reason"), Duration: "0"}
+			switch strings.ToLower(entry.Value) {
+			case "user":
+				item.Kind = transferKindServerBan
+				item.Type = userBanType
+				if !strings.Contains(mask, "@") {
+					item.Mask = "*@" + mask
+				}
+			case "ip":
+				item.Kind = transferKindServerBan
+				item.Type = ipBanType
+				if !strings.Contains(mask, "@") {
+					item.Mask = "*@" + mask
+				}
+			case "nick":
+				item.Kind = transferKindNameBan
+			default:
+				parseErrors = append(parseErrors, fmt.Sprintf("line %d: 'ban %s' blocks are not supported", entry.Line, entry.Value))
+				continue
+			}
+			items = append(items, item)
+		case "except":
+			if !strings.EqualFold(entry.Value, "ban") {
+				continue
+			}
+			types := ""
+			if typeBlock := entry.Child("type"); typeBlock != nil {
+				names := make([]string, 0)
+				if typeBlock.Value != "" {
+					names = append(names, typeBlock.Value)
+				}
+				for _, child := range typeBlock.Children {
+					names = append(names, child.Name)
+				}
+				for _, name := range names {
+					letter, ok := lookupLetter(exceptionTypeNames, name)
+					if !ok {
+						parseErrors = append(parseErrors, fmt.Sprintf("line %d: unknown exception type '%s'", entry.Line, name))
+						continue
+					}
+					types += string(letter)
+				}
+			} else {
+				// Without a type block, UnrealIRCd exempts from all bans
+				types = "kGzZs"
+			}
+			masks := []string{entry.ChildValue("mask")}
+			if maskBlock := entry.Child("mask"); maskBlock != nil && len(maskBlock.Children) > 0 {
+				masks = masks[:0]
+				for _, child := range maskBlock.Children {
+					masks = append(masks, child.Name)
+				}
+			}
+			for _, mask := range masks {
+				reason := entry.ChildValue("reason")
+				if reason == "" {
+					reason = "Imported from configuration"
+				}
+				items = append(items, BanTransferItem{
+					Kind:           transferKindException,
+					Mask:           mask,
+					Reason:         reason,
+					Duration:       "0",
+					ExceptionTypes: types,
+				})
+			}
+		case "spamfilter":
+			targets := ""
+			if targetBlock := entry.Child("target"); targetBlock != nil {
+				names := make([]string, 0)
+				if targetBlock.Value != "" {
+					names = append(names, targetBlock.Value)
+				}
+				for _, child := range targetBlock.Children {
+					names = append(names, child.Name)
+				}
+				for _, name := range names {
+					letter, ok := lookupLetter(spamfilterTargetNames, name)
+					if !ok {
+						parseErrors = append(parseErrors, fmt.Sprintf("line %d: unknown spamfilter target '%s'", entry.Line, name))
+						continue
+					}
+					targets += string(letter)
+				}
+			}
+			match := entry.ChildValue("match")
+			if match == "" {
+				// UnrealIRCd 5 style
+				match = entry.ChildValue("match-string")
+			}
+			matchType := entry.ChildValue("match-type")
+			if matchType == "" {
+				matchType = "simple"
+			}
+			action := entry.ChildValue("action")
+			if action == "" {
+				action = "block"
+			}
+			items = append(items, BanTransferItem{
+				Kind:      transferKindSpamfilter,
+				Mask:      match,
+				Reason:    entry.ChildValue("reason"),
+				Duration:  entry.ChildValue("ban-time"),
+				MatchType: matchType,
+				Targets:   targets,
+				Action:    action,
+			})
+		}
+	}
+
+	return items, parseErrors, nil
+}
+
+func lookupLetter(names map[byte]string, name string) (byte, bool) {
+	for letter, n := range names {
+		if strings.EqualFold(n, name) {
+			return letter, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import "testing"
+
+func TestTransferItemKey(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b BanTransferItem
+		same bool
+	}{
+		{
+			name: "bare host matches stored server ban",
+			a:    BanTransferItem{Kind: transferKindServerBan, Type: "gline", Mask: "bad.example.com"},
+			b:    BanTransferItem{Kind: transferKindServerBan, Type: "GLINE", Mask: "*@Bad.Example.com"},
+			same: true,
+		},
+		{
+			name: "bare host matches stored exception",
+			a:    BanTransferItem{Kind: transferKindException, Mask: "10.0.0.1"},
+			b:    BanTransferItem{Kind: transferKindException, Mask: "*@10.0.0.1"},
+			same: true,
+		},
+		{
+			name: "extended ban masks are kept",
+			a:    BanTransferItem{Kind: transferKindServerBan, Type: "gline", Mask: "~account:evil"},
+			b:    BanTransferItem{Kind: transferKindServerBan, Type: "gline", Mask: "*@~account:evil"},
+			same: false,
+		},
+		{
+			name: "different server ban types",
+			a:    BanTransferItem{Kind: transferKindServerBan, Type: "gline", Mask: "*@host"},
+			b:    BanTransferItem{Kind: transferKindServerBan, Type: "kline", Mask: "*@host"},
+			same: false,
+		},
+		{
+			name: "name bans are not given a user part",
+			a:    BanTransferItem{Kind: transferKindNameBan, Mask: "Guest*"},
+			b:    BanTransferItem{Kind: transferKindNameBan, Mask: "*@guest*"},
+			same: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := transferItemKey(tt.a) == transferItemKey(tt.b); got != tt.same {
+				t.Errorf("transferItemKey(%q) == transferItemKey(%q) is %v, want %v", tt.a.Mask, tt.b.Mask, got, tt.same)
+			}
+		})
+	}
+}
@@ -5,7 +5,9 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/api/middleware"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/database/models"
 	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/rpc"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/services/geoip"
 	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/utils"
 )
 
@@ -21,6 +23,9 @@ type ServerBan struct {
 	Reason         string `json:"reason,omitempty"`
 	SetAtString    string `json:"set_at_string,omitempty"`
 	ExpireAtString string `json:"expire_at_string,omitempty"`
+
+	Metadata *models.BanMetadata `json:"metadata,omitempty"`
+	Geo      *geoip.Info         `json:"geo,omitempty"` // For IP and CIDR masks
 }
 
 // NameBan represents a name ban (Q-Line)
@@ -31,6 +36,8 @@ type NameBan struct {
 	ExpireAt int64  `json:"expire_at,omitempty"`
 	Duration string `json:"duration,omitempty"`
 	Reason   string `json:"reason,omitempty"`
+
+	Metadata *models.BanMetadata `json:"metadata,omitempty"`
 }
 
 // BanException represents a ban exception (E-Line)
@@ -42,6 +49,8 @@ type BanException struct {
 	ExpireAt       int64  `json:"expire_at,omitempty"`
 	Duration       string `json:"duration,omitempty"`
 	Reason         string `json:"reason,omitempty"`
+
+	Geo *geoip.Info `json:"geo,omitempty"` // For IP and CIDR masks
 }
 
 // Spamfilter represents a spamfilter entry
@@ -56,6 +65,8 @@ type Spamfilter struct {
 	Reason            string `json:"reason,omitempty"`
 	HitCount          int    `json:"hits,omitempty"`
 	HitCountOper      int    `json:"hits_except,omitempty"`
+
+	Metadata *models.BanMetadata `json:"metadata,omitempty"`
 }
 
 // GetServerBans returns all server bans
@@ -71,6 +82,7 @@ func GetServerBans(c *gin.Context) {
 	}
 
 	bans := parseServerBanList(result)
+	attachServerBanMetadata(bans)
 	c.JSON(http.StatusOK, bans)
 }
 
@@ -80,6 +92,7 @@ type AddServerBanRequest struct {
 	Type     string `json:"type" binding:"required"` // gline, kline, zline, etc.
 	Duration string `json:"duration"`
 	Reason   string `json:"reason"`
+	BanReasonRequest
 }
 
 // AddServerBan adds a server ban
@@ -92,25 +105,31 @@ func AddServerBan(c *gin.Context) {
 
 	manager := rpc.GetManager()
 
-	duration := req.Duration
+	reason, duration, err := resolveBanReason(&req.BanReasonRequest, "server", req.Reason, req.Duration)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reason template: " + err.Error()})
+		return
+	}
+
 	if duration == "" {
 		duration = "0" // Permanent
 	}
 
-	reason := req.Reason
 	if reason == "" {
 		reason = "No reason specified"
 	}
 
-	_, err := manager.WithRetry(func(client *rpc.Client) (interface{}, error) {
+	result, err := manager.WithRetry(func(client *rpc.Client) (interface{}, error) {
 		return client.ServerBan().Add(req.Name, req.Type, duration, reason)
 	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add ban: " + err.Error()})
 		return
 	}
+	mask := addedServerBanMask(result, req.Name)
 
 	currentUser := middleware.GetCurrentUser(c)
+	recordBanMetadataFromRequest(currentUser, req.Type, mask, &req.BanReasonRequest)
 	if currentUser != nil {
 		logAction(c, currentUser, "add_server_ban", map[string]string{
 			"name":     req.Name,
@@ -118,6 +137,7 @@ func AddServerBan(c *gin.Context) {
 			"duration": duration,
 			"reason":   reason,
 		})
+		recordPanelBanChange(currentUser.Username, ledgerActionAdd, req.Type, mask, reason, duration)
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Server ban added successfully"})
@@ -153,6 +173,7 @@ func DeleteServerBan(c *gin.Context) {
 			"name": req.Name,
 			"type": req.Type,
 		})
+		recordPanelBanChange(currentUser.Username, ledgerActionDelete, req.Type, req.Name, "", "")
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Server ban removed successfully"})
@@ -171,6 +192,7 @@ func GetNameBans(c *gin.Context) {
 	}
 
 	bans := parseNameBanList(result)
+	attachNameBanMetadata(bans)
 	c.JSON(http.StatusOK, bans)
 }
 
@@ -178,7 +200,8 @@ func GetNameBans(c *gin.Context) {
 type AddNameBanRequest struct {
 	Name     string `json:"name" binding:"required"`
 	Duration string `json:"duration"`
-	Reason   string `json:"reason" binding:"required"`
+	Reason   string `json:"reason"`
+	BanReasonRequest
 }
 
 // AddNameBan adds a name ban
@@ -189,15 +212,25 @@ func AddNameBan(c *gin.Context) {
 		return
 	}
 
+	reason, durationStr, err := resolveBanReason(&req.BanReasonRequest, "name", req.Reason, req.Duration)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reason template: " + err.Error()})
+		return
+	}
+	if reason == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Reason is required"})
+		return
+	}
+
 	manager := rpc.GetManager()
 
 	var duration *string
-	if req.Duration != "" {
-		duration = &req.Duration
+	if durationStr != "" {
+		duration = &durationStr
 	}
 
-	_, err := manager.WithRetry(func(client *rpc.Client) (interface{}, error) {
-		return client.NameBan().Add(req.Name, req.Reason, duration, nil)
+	result, err := manager.WithRetry(func(client *rpc.Client) (interface{}, error) {
+		return client.NameBan().Add(req.Name, reason, duration, nil)
 	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add name ban: " + err.Error()})
@@ -205,11 +238,13 @@ func AddNameBan(c *gin.Context) {
 	}
 
 	currentUser := middleware.GetCurrentUser(c)
+	recordBanMetadataFromRequest(currentUser, banMetadataTypeName, req.Name, &req.BanReasonRequest)
 	if currentUser != nil {
 		logAction(c, currentUser, "add_name_ban", map[string]string{
 			"name":   req.Name,
-			"reason": req.Reason,
+			"reason": reason,
 		})
+		recordPanelBanChange(currentUser.Username, ledgerActionAdd, banMetadataTypeName, addedBanName(result, req.Name), reason, durationStr)
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Name ban added successfully"})
@@ -238,6 +273,7 @@ func DeleteNameBan(c *gin.Context) {
 		logAction(c, currentUser, "delete_name_ban", map[string]string{
 			"name": name,
 		})
+		recordPanelBanChange(currentUser.Username, ledgerActionDelete, banMetadataTypeName, name, "", "")
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Name ban removed successfully"})
@@ -282,7 +318,7 @@ func AddBanException(c *gin.Context) {
 		duration = &req.Duration
 	}
 
-	_, err := manager.WithRetry(func(client *rpc.Client) (interface{}, error) {
+	result, err := manager.WithRetry(func(client *rpc.Client) (interface{}, error) {
 		return client.ServerBanException().Add(req.Name, req.ExceptionTypes, req.Reason, nil, duration)
 	})
 	if err != nil {
@@ -296,6 +332,7 @@ func AddBanException(c *gin.Context) {
 			"name":   req.Name,
 			"reason": req.Reason,
 		})
+		recordPanelBanChange(currentUser.Username, ledgerActionAdd, banMetadataTypeException, addedServerBanMask(result, req.Name), req.Reason, req.Duration)
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Ban exception added successfully"})
@@ -324,6 +361,7 @@ func DeleteBanException(c *gin.Context) {
 		logAction(c, currentUser, "delete_ban_exception", map[string]string{
 			"name": name,
 		})
+		recordPanelBanChange(currentUser.Username, ledgerActionDelete, banMetadataTypeException, name, "", "")
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Ban exception removed successfully"})
@@ -342,6 +380,7 @@ func GetSpamfilters(c *gin.Context) {
 	}
 
 	filters := parseSpamfilterList(result)
+	attachSpamfilterMetadata(filters)
 	c.JSON(http.StatusOK, filters)
 }
 
@@ -352,7 +391,8 @@ type AddSpamfilterRequest struct {
 	SpamfilterTargets string `json:"spamfilter_targets" binding:"required"`
 	BanAction         string `json:"ban_action" binding:"required"`
 	BanDuration       string `json:"ban_duration"`
-	Reason            string `json:"reason" binding:"required"`
+	Reason            string `json:"reason"`
+	BanReasonRequest
 }
 
 // AddSpamfilter adds a spamfilter
@@ -363,15 +403,24 @@ func AddSpamfilter(c *gin.Context) {
 		return
 	}
 
+	reason, duration, err := resolveBanReason(&req.BanReasonRequest, "spamfilter", req.Reason, req.BanDuration)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reason template: " + err.Error()})
+		return
+	}
+	if reason == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Reason is required"})
+		return
+	}
+
 	manager := rpc.GetManager()
 
-	duration := req.BanDuration
 	if duration == "" {
 		duration = "0"
 	}
 
-	_, err := manager.WithRetry(func(client *rpc.Client) (interface{}, error) {
-		return client.Spamfilter().Add(req.Name, req.MatchType, req.SpamfilterTargets, req.BanAction, duration, req.Reason)
+	result, err := manager.WithRetry(func(client *rpc.Client) (interface{}, error) {
+		return client.Spamfilter().Add(req.Name, req.MatchType, req.SpamfilterTargets, req.BanAction, duration, reason)
 	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add spamfilter: " + err.Error()})
@@ -379,13 +428,16 @@ func AddSpamfilter(c *gin.Context) {
 	}
 
 	currentUser := middleware.GetCurrentUser(c)
+	recordBanMetadataFromRequest(currentUser, banMetadataTypeSpamfilter,
+		spamfilterMetadataMask(req.Name, req.MatchType, req.SpamfilterTargets, req.BanAction), &req.BanReasonRequest)
 	if currentUser != nil {
 		logAction(c, currentUser, "add_spamfilter", map[string]string{
 			"name":       req.Name,
 			"match_type": req.MatchType,
 			"action":     req.BanAction,
-			"reason":     req.Reason,
+			"reason":     reason,
 		})
+		recordPanelBanChange(currentUser.Username, ledgerActionAdd, banMetadataTypeSpamfilter, addedBanName(result, req.Name), reason, duration)
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Spamfilter added successfully"})
@@ -424,6 +476,7 @@ func DeleteSpamfilter(c *gin.Context) {
 			"match_type": req.MatchType,
 			"action":     req.BanAction,
 		})
+		recordPanelBanChange(currentUser.Username, ledgerActionDelete, banMetadataTypeSpamfilter, req.Name, "", "")
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Spamfilter removed successfully"})
@@ -450,8 +503,9 @@ func parseServerBan(result interface{}) *ServerBan {
 		return nil
 	}
 
+	name := utils.SafeMapGetString(m, "name")
 	return &ServerBan{
-		Name:           utils.SafeMapGetString(m, "name"),
+		Name:           name,
 		Type:           utils.SafeMapGetString(m, "type"),
 		TypeString:     utils.SafeMapGetString(m, "type_string"),
 		SetBy:          utils.SafeMapGetString(m, "set_by"),
@@ -461,6 +515,7 @@ func parseServerBan(result interface{}) *ServerBan {
 		Reason:         utils.SafeMapGetString(m, "reason"),
 		SetAtString:    utils.SafeMapGetString(m, "set_at_string"),
 		ExpireAtString: utils.SafeMapGetString(m, "expire_at_string"),
+		Geo:            geoip.LookupMask(name),
 	}
 }
 
@@ -512,14 +567,16 @@ func parseBanException(result interface{}) *BanException {
 		return nil
 	}
 
+	name := utils.SafeMapGetString(m, "name")
 	return &BanException{
-		Name:           utils.SafeMapGetString(m, "name"),
+		Name:           name,
 		ExceptionTypes: utils.SafeMapGetString(m, "exception_types"),
 		SetBy:          utils.SafeMapGetString(m, "set_by"),
 		SetAt:          int64(utils.SafeMapGetInt(m, "set_at")),
 		ExpireAt:       int64(utils.SafeMapGetInt(m, "expire_at")),
 		Duration:       utils.SafeMapGetString(m, "duration_string"),
 		Reason:         utils.SafeMapGetString(m, "reason"),
+		Geo:            geoip.LookupMask(name),
 	}
 }
 
@@ -0,0 +1,775 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/api/middleware"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/database"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/database/models"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/rpc"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/utils"
+)
+
+// driftSetBy is who the topic is set by when the panel re-converges a channel
+const driftSetBy = "webpanel"
+
+var errDriftNotFound = errors.New("channel drift not found")
+
+// driftCheckMu keeps the scheduled and on-demand checks from running at the same time
+var driftCheckMu sync.Mutex
+
+// ChannelTemplateBindingRequest binds a template to a channel name or pattern
+type ChannelTemplateBindingRequest struct {
+	TemplateID    uint   `json:"template_id" binding:"required"`
+	Pattern       string `json:"pattern" binding:"required"`
+	AutoRemediate bool   `json:"auto_remediate"`
+}
+
+// ChannelDriftExemptionRequest exempts a channel from drift checks
+type ChannelDriftExemptionRequest struct {
+	Channel   string     `json:"channel" binding:"required"`
+	Reason    string     `json:"reason"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// DriftDifference is one way a channel differs from its template
+type DriftDifference struct {
+	Field    string `json:"field"` // modes, topic, bans, excepts or invites
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+}
+
+// DriftCheckSummary is the outcome of one drift check
+type DriftCheckSummary struct {
+	Checked    int      `json:"checked"`    // Bound channels compared
+	Drifted    int      `json:"drifted"`    // Channels that differ from their template
+	Remediated int      `json:"remediated"` // Channels re-converged by auto-remediation
+	Exempt     int      `json:"exempt"`     // Bound channels skipped because of an exemption
+	Errors     []string `json:"errors,omitempty"`
+}
+
+// GetChannelTemplateBindings returns all template bindings
+func GetChannelTemplateBindings(c *gin.Context) {
+	db := database.Get()
+	query := db.Order("pattern ASC")
+	if templateID := c.Query("template_id"); templateID != "" {
+		query = query.Where("template_id = ?", templateID)
+	}
+
+	var bindings []models.ChannelTemplateBinding
+	if err := query.Find(&bindings).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch template bindings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, bindings)
+}
+
+// CreateChannelTemplateBinding binds a template to a channel name or wildcard pattern
+func CreateChannelTemplateBinding(c *gin.Context) {
+	var req ChannelTemplateBindingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user := middleware.GetCurrentUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	template, err := findBindableTemplate(req.TemplateID, user.ID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Channel template not found"})
+		return
+	}
+	if err := validateChannelPattern(req.Pattern); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	binding := models.ChannelTemplateBinding{
+		TemplateID:        template.ID,
+		Pattern:           req.Pattern,
+		AutoRemediate:     req.AutoRemediate,
+		CreatedBy:         user.ID,
+		CreatedByUsername: user.Username,
+	}
+
+	db := database.Get()
+	if err := db.Create(&binding).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create template binding"})
+		return
+	}
+
+	logAction(c, user, "bind_channel_template", map[string]string{
+		"template":       template.Name,
+		"pattern":        binding.Pattern,
+		"auto_remediate": strconv.FormatBool(binding.AutoRemediate),
+	})
+
+	c.JSON(http.StatusCreated, binding)
+}
+
+// UpdateChannelTemplateBinding changes a binding's template, pattern or auto-remediation
+func UpdateChannelTemplateBinding(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var req ChannelTemplateBindingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user := middleware.GetCurrentUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	db := database.Get()
+	var binding models.ChannelTemplateBinding
+	if err := db.First(&binding, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template binding not found"})
+		return
+	}
+
+	template, err := findBindableTemplate(req.TemplateID, user.ID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Channel template not found"})
+		return
+	}
+	if err := validateChannelPattern(req.Pattern); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	binding.TemplateID = template.ID
+	binding.Pattern = req.Pattern
+	binding.AutoRemediate = req.AutoRemediate
+	if err := db.Save(&binding).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update template binding"})
+		return
+	}
+
+	// Drift recorded against the old template no longer applies
+	db.Where("binding_id = ?", binding.ID).Delete(&models.ChannelDrift{})
+
+	logAction(c, user, "update_channel_template_binding", map[string]string{
+		"template":       template.Name,
+		"pattern":        binding.Pattern,
+		"auto_remediate": strconv.FormatBool(binding.AutoRemediate),
+	})
+
+	c.JSON(http.StatusOK, binding)
+}
+
+// DeleteChannelTemplateBinding removes a binding and its recorded drift
+func DeleteChannelTemplateBinding(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	db := database.Get()
+	var binding models.ChannelTemplateBinding
+	if err := db.First(&binding, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template binding not found"})
+		return
+	}
+
+	db.Delete(&binding)
+	db.Where("binding_id = ?", binding.ID).Delete(&models.ChannelDrift{})
+
+	if user := middleware.GetCurrentUser(c); user != nil {
+		logAction(c, user, "unbind_channel_template", map[string]string{
+			"pattern":     binding.Pattern,
+			"template_id": strconv.FormatUint(uint64(binding.TemplateID), 10),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Template binding deleted"})
+}
+
+// GetChannelDrift returns the channels that currently differ from their template
+func GetChannelDrift(c *gin.Context) {
+	db := database.Get()
+	query := db.Order("channel ASC")
+	if channel := c.Query("channel"); channel != "" {
+		query = query.Where("channel = ?", channel)
+	}
+
+	var drift []models.ChannelDrift
+	if err := query.Find(&drift).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch channel drift"})
+		return
+	}
+
+	c.JSON(http.StatusOK, drift)
+}
+
+// CheckChannelDriftNow runs the drift check immediately
+func CheckChannelDriftNow(c *gin.Context) {
+	summary, err := runChannelDriftCheck()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Drift check failed: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, summary)
+}
+
+// RemediateChannelDrift re-applies the template to a drifted channel, whether or not
+// its binding has auto-remediation enabled
+func RemediateChannelDrift(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	drift, remaining, err := remediateRecordedDrift(uint(id))
+	if errors.Is(err, errDriftNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remediate channel: " + err.Error()})
+		return
+	}
+
+	if user := middleware.GetCurrentUser(c); user != nil {
+		logAction(c, user, "remediate_channel_drift", map[string]string{
+			"channel":  drift.Channel,
+			"template": drift.TemplateName,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"channel":     drift.Channel,
+		"converged":   len(remaining) == 0,
+		"differences": remaining,
+	})
+}
+
+// GetChannelDriftExemptions returns the channels exempt from drift checks
+func GetChannelDriftExemptions(c *gin.Context) {
+	db := database.Get()
+	var exemptions []models.ChannelDriftExemption
+	if err := db.Order("channel ASC").Find(&exemptions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch exemptions"})
+		return
+	}
+	c.JSON(http.StatusOK, exemptions)
+}
+
+// CreateChannelDriftExemption exempts a channel from drift checks and remediation
+func CreateChannelDriftExemption(c *gin.Context) {
+	var req ChannelDriftExemptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validateChannelName(req.Channel); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.ExpiresAt != nil && req.ExpiresAt.Before(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_at must be in the future"})
+		return
+	}
+
+	user := middleware.GetCurrentUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	db := database.Get()
+	var existing int64
+	db.Model(&models.ChannelDriftExemption{}).Where("LOWER(channel) = ?", strings.ToLower(req.Channel)).Count(&existing)
+	if existing > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Channel is already exempt"})
+		return
+	}
+
+	exemption := models.ChannelDriftExemption{
+		Channel:           req.Channel,
+		Reason:            req.Reason,
+		ExpiresAt:         req.ExpiresAt,
+		CreatedBy:         user.ID,
+		CreatedByUsername: user.Username,
+	}
+	if err := db.Create(&exemption).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create exemption"})
+		return
+	}
+
+	db.Where("LOWER(channel) = ?", strings.ToLower(req.Channel)).Delete(&models.ChannelDrift{})
+
+	logAction(c, user, "exempt_channel_drift", map[string]string{
+		"channel": exemption.Channel,
+		"reason":  exemption.Reason,
+	})
+
+	c.JSON(http.StatusCreated, exemption)
+}
+
+// DeleteChannelDriftExemption removes a channel's exemption
+func DeleteChannelDriftExemption(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	db := database.Get()
+	var exemption models.ChannelDriftExemption
+	if err := db.First(&exemption, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Exemption not found"})
+		return
+	}
+	db.Delete(&exemption)
+
+	if user := middleware.GetCurrentUser(c); user != nil {
+		logAction(c, user, "remove_channel_drift_exemption", map[string]string{
+			"channel": exemption.Channel,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Exemption removed"})
+}
+
+// CheckChannelTemplateDrift compares every bound channel against its template,
+// records drift, raises CHANNEL_TEMPLATE_DRIFT alerts for new or changed drift and
+// re-converges channels whose binding has auto-remediation enabled. The scheduler
+// runs it every five minutes.
+func CheckChannelTemplateDrift() {
+	summary, err := runChannelDriftCheck()
+	if err != nil {
+		log.Printf("[Drift] Channel template drift check failed: %v", err)
+		return
+	}
+	if summary.Drifted > 0 {
+		log.Printf("[Drift] %d of %d bound channels differ from their template, %d remediated",
+			summary.Drifted, summary.Checked, summary.Remediated)
+	}
+}
+
+// Helper functions
+
+func runChannelDriftCheck() (*DriftCheckSummary, error) {
+	driftCheckMu.Lock()
+	defer driftCheckMu.Unlock()
+
+	db := database.Get()
+	summary := &DriftCheckSummary{}
+
+	var bindings []models.ChannelTemplateBinding
+	if err := db.Find(&bindings).Error; err != nil {
+		return nil, err
+	}
+	if len(bindings) == 0 {
+		db.Where("1 = 1").Delete(&models.ChannelDrift{})
+		return summary, nil
+	}
+
+	templates := make(map[uint]*models.ChannelTemplate)
+	for _, binding := range bindings {
+		if _, ok := templates[binding.TemplateID]; ok {
+			continue
+		}
+		var template models.ChannelTemplate
+		if db.First(&template, binding.TemplateID).Error == nil {
+			templates[binding.TemplateID] = &template
+		}
+	}
+
+	exempt := activeDriftExemptions()
+
+	result, err := rpc.GetManager().WithRetry(func(client *rpc.Client) (interface{}, error) {
+		return client.Channel().GetAll(1)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	seen := []string{}
+	for _, listed := range parseChannelList(result) {
+		binding := bindingForChannel(listed.Name, bindings)
+		if binding == nil {
+			continue
+		}
+		template := templates[binding.TemplateID]
+		if template == nil {
+			continue
+		}
+		if exempt[strings.ToLower(listed.Name)] {
+			summary.Exempt++
+			continue
+		}
+
+		summary.Checked++
+		channel, err := fetchChannelDetails(listed.Name)
+		if err != nil {
+			summary.Errors = append(summary.Errors, listed.Name+": "+err.Error())
+			seen = append(seen, listed.Name) // Keep any drift recorded earlier
+			continue
+		}
+		if channel == nil {
+			continue
+		}
+
+		diffs := compareChannelToTemplate(channel, template)
+		if len(diffs) == 0 {
+			continue
+		}
+
+		remediated := false
+		remediationError := ""
+		if binding.AutoRemediate {
+			if errs := remediateChannel(channel, template, diffs); len(errs) > 0 {
+				remediationError = strings.Join(errs, "; ")
+			}
+			if after, err := fetchChannelDetails(channel.Name); err == nil && after != nil {
+				diffs = compareChannelToTemplate(after, template)
+			}
+			remediated = len(diffs) == 0
+		}
+
+		if remediated {
+			summary.Remediated++
+			db.Where("channel = ?", channel.Name).Delete(&models.ChannelDrift{})
+		} else {
+			summary.Drifted++
+			seen = append(seen, channel.Name)
+		}
+		recordChannelDrift(channel.Name, binding, template, diffs, binding.AutoRemediate, remediated, remediationError)
+	}
+
+	// Channels that match again, were exempted, unbound or no longer exist
+	if len(seen) == 0 {
+		db.Where("1 = 1").Delete(&models.ChannelDrift{})
+	} else {
+		db.Where("channel NOT IN ?", seen).Delete(&models.ChannelDrift{})
+	}
+
+	now := time.Now()
+	db.Model(&models.ChannelTemplateBinding{}).Where("1 = 1").Update("last_checked_at", now)
+
+	return summary, nil
+}
+
+// recordChannelDrift stores the drift and alerts when it is new or has changed.
+// Remediated channels only raise an alert, since they match the template again.
+func recordChannelDrift(channel string, binding *models.ChannelTemplateBinding, template *models.ChannelTemplate,
+	diffs []DriftDifference, attempted, remediated bool, remediationError string) {
+	db := database.Get()
+	now := time.Now()
+	differences, _ := json.Marshal(diffs)
+
+	var drift models.ChannelDrift
+	isNew := db.Where("channel = ?", channel).First(&drift).Error != nil
+	changed := isNew || drift.Differences != string(differences) || drift.TemplateID != template.ID
+
+	if !remediated {
+		drift.Channel = channel
+		drift.BindingID = binding.ID
+		drift.TemplateID = template.ID
+		drift.TemplateName = template.Name
+		drift.Differences = string(differences)
+		drift.LastCheckedAt = now
+		if attempted {
+			drift.RemediatedAt = &now
+			drift.RemediationError = remediationError
+		}
+		db.Save(&drift)
+	}
+
+	if !changed && !remediated {
+		return
+	}
+
+	fields := make([]string, 0, len(diffs))
+	for _, diff := range diffs {
+		fields = append(fields, diff.Field)
+	}
+	msg := fmt.Sprintf("Channel %s differs from template %s (%s)", channel, template.Name, strings.Join(fields, ", "))
+	if remediated {
+		msg = fmt.Sprintf("Channel %s drifted from template %s and was re-converged", channel, template.Name)
+	} else if remediationError != "" {
+		msg += "; remediation failed: " + remediationError
+	}
+
+	raisePanelAlert("CHANNEL_TEMPLATE_DRIFT", "warn", msg, map[string]string{
+		"channel":    channel,
+		"template":   template.Name,
+		"pattern":    binding.Pattern,
+		"fields":     strings.Join(fields, ","),
+		"remediated": strconv.FormatBool(remediated),
+	})
+}
+
+// remediateRecordedDrift re-applies the template of a drift entry and returns what still differs
+func remediateRecordedDrift(id uint) (*models.ChannelDrift, []DriftDifference, error) {
+	db := database.Get()
+	var drift models.ChannelDrift
+	if err := db.First(&drift, id).Error; err != nil {
+		return nil, nil, errDriftNotFound
+	}
+
+	var template models.ChannelTemplate
+	if err := db.First(&template, drift.TemplateID).Error; err != nil {
+		return nil, nil, fmt.Errorf("template %s no longer exists", drift.TemplateName)
+	}
+
+	channel, err := fetchChannelDetails(drift.Channel)
+	if err != nil {
+		return nil, nil, err
+	}
+	if channel == nil {
+		db.Delete(&drift)
+		return &drift, []DriftDifference{}, nil
+	}
+
+	now := time.Now()
+	errs := remediateChannel(channel, &template, compareChannelToTemplate(channel, &template))
+	drift.RemediatedAt = &now
+	drift.RemediationError = strings.Join(errs, "; ")
+
+	remaining := []DriftDifference{}
+	if after, err := fetchChannelDetails(channel.Name); err == nil && after != nil {
+		remaining = compareChannelToTemplate(after, &template)
+	}
+	if len(remaining) == 0 {
+		db.Delete(&drift)
+		return &drift, remaining, nil
+	}
+
+	differences, _ := json.Marshal(remaining)
+	drift.Differences = string(differences)
+	drift.LastCheckedAt = now
+	db.Save(&drift)
+	return &drift, remaining, nil
+}
+
+// compareChannelToTemplate lists how a channel differs from a template. Templates are
+// additive: extra modes the template doesn't unset and extra list entries are not drift.
+// Modes with a parameter, such as the key, limit or +f profile, must have the template's.
+func compareChannelToTemplate(channel *IRCChannel, template *models.ChannelTemplate) []DriftDifference {
+	diffs := []DriftDifference{}
+
+	if letters, _ := splitTemplateModes(template.Modes); letters != "" {
+		live, liveParams := channelModeParams(channel)
+		expectedParams := templateModeParams(template.Modes)
+		drifted := false
+		adding := true
+		for _, r := range letters {
+			switch {
+			case r == '+':
+				adding = true
+			case r == '-':
+				adding = false
+			case adding && !strings.ContainsRune(live, r):
+				drifted = true
+			case !adding && strings.ContainsRune(live, r):
+				drifted = true
+			case adding:
+				if want, ok := expectedParams[string(r)]; ok && liveParams[string(r)] != want {
+					drifted = true
+				}
+			}
+		}
+		if drifted {
+			diffs = append(diffs, DriftDifference{Field: "modes", Expected: template.Modes, Actual: "+" + strings.TrimPrefix(channel.Modes, "+")})
+		}
+	}
+
+	if template.Topic != "" && channel.Topic != template.Topic {
+		diffs = append(diffs, DriftDifference{Field: "topic", Expected: template.Topic, Actual: channel.Topic})
+	}
+
+	for _, list := range []struct{ name, masks string }{
+		{"bans", template.BanList},
+		{"excepts", template.ExceptList},
+		{"invites", template.InviteList},
+	} {
+		if missing := missingListMasks(channel, list.name, list.masks); len(missing) > 0 {
+			diffs = append(diffs, DriftDifference{
+				Field:    list.name,
+				Expected: strings.Join(missing, " "),
+				Actual:   "missing",
+			})
+		}
+	}
+
+	return diffs
+}
+
+// remediateChannel re-applies the parts of a template a channel differs in
+func remediateChannel(live *IRCChannel, template *models.ChannelTemplate, diffs []DriftDifference) []string {
+	manager := rpc.GetManager()
+	channel := live.Name
+	errs := []string{}
+
+	for _, diff := range diffs {
+		switch diff.Field {
+		case "modes":
+			// A different key has to be removed, with the old key, before the new one is set
+			_, liveParams := channelModeParams(live)
+			if key, ok := templateModeParams(template.Modes)["k"]; ok && liveParams["k"] != "" && liveParams["k"] != key {
+				_, err := manager.WithRetry(func(client *rpc.Client) (interface{}, error) {
+					return client.Channel().SetMode(channel, "-k", liveParams["k"])
+				})
+				if err != nil {
+					errs = append(errs, "modes: "+err.Error())
+					continue
+				}
+			}
+			letters, params := splitTemplateModes(template.Modes)
+			_, err := manager.WithRetry(func(client *rpc.Client) (interface{}, error) {
+				return client.Channel().SetMode(channel, letters, params)
+			})
+			if err != nil {
+				errs = append(errs, "modes: "+err.Error())
+			}
+		case "topic":
+			setBy := driftSetBy
+			_, err := manager.WithRetry(func(client *rpc.Client) (interface{}, error) {
+				return client.Channel().SetTopic(channel, template.Topic, &setBy, nil)
+			})
+			if err != nil {
+				errs = append(errs, "topic: "+err.Error())
+			}
+		case "bans", "excepts", "invites":
+			for _, result := range ApplyChannelListEntries(channel, diff.Field, strings.Fields(diff.Expected), true) {
+				if !result.Success {
+					errs = append(errs, diff.Field+" "+result.Mask+": "+result.Error)
+				}
+			}
+		}
+	}
+	return errs
+}
+
+// missingListMasks returns the template masks a channel's list lacks
+func missingListMasks(channel *IRCChannel, list, masksJSON string) []string {
+	masks, err := templateMaskList(masksJSON)
+	if err != nil || len(masks) == 0 {
+		return nil
+	}
+
+	present := make(map[string]bool)
+	for _, entry := range channelListEntries(channel, list) {
+		present[strings.ToLower(entry.Mask)] = true
+	}
+
+	missing := []string{}
+	for _, mask := range masks {
+		mask = strings.TrimSpace(mask)
+		if mask != "" && !present[strings.ToLower(mask)] {
+			missing = append(missing, mask)
+		}
+	}
+	return missing
+}
+
+// bindingForChannel picks the binding for a channel: an exact name beats a pattern,
+// and a longer pattern beats a shorter one
+func bindingForChannel(channel string, bindings []models.ChannelTemplateBinding) *models.ChannelTemplateBinding {
+	var best *models.ChannelTemplateBinding
+	bestScore := -1
+	for i := range bindings {
+		binding := &bindings[i]
+		if !utils.WildcardMatch(binding.Pattern, channel) {
+			continue
+		}
+		score := len(binding.Pattern)
+		if !strings.ContainsAny(binding.Pattern, "*?") {
+			score += 1 << 16
+		}
+		if score > bestScore {
+			best, bestScore = binding, score
+		}
+	}
+	return best
+}
+
+// activeDriftExemptions returns the lowercased names of exempt channels
+func activeDriftExemptions() map[string]bool {
+	db := database.Get()
+	var exemptions []models.ChannelDriftExemption
+	db.Where("expires_at IS NULL OR expires_at > ?", time.Now()).Find(&exemptions)
+
+	exempt := make(map[string]bool, len(exemptions))
+	for _, exemption := range exemptions {
+		exempt[strings.ToLower(exemption.Channel)] = true
+	}
+	return exempt
+}
+
+// findBindableTemplate returns a template the user may use: global ones or their own
+func findBindableTemplate(id, userID uint) (*models.ChannelTemplate, error) {
+	db := database.Get()
+	var template models.ChannelTemplate
+	if err := db.Where("id = ? AND (is_global = ? OR created_by = ?)", id, true, userID).First(&template).Error; err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+// validateChannelPattern accepts channel names and wildcard patterns such as #help*
+func validateChannelPattern(pattern string) error {
+	if !strings.HasPrefix(pattern, "#") || len(pattern) > maxChannelLength ||
+		strings.ContainsAny(pattern, " ,") || hasControlChars(pattern) {
+		return fmt.Errorf("pattern must start with # and be at most %d characters without spaces or commas", maxChannelLength)
+	}
+	return nil
+}
+
+// splitTemplateModes splits template modes such as "+ntl 50" into letters and parameters
+func splitTemplateModes(modes string) (string, string) {
+	fields := strings.Fields(modes)
+	if len(fields) == 0 {
+		return "", ""
+	}
+	return fields[0], strings.Join(fields[1:], " ")
+}
+
+// templateModeParams returns the parameter of each mode a template sets, taking them
+// in order from a mode string such as "+ntkl-s key 10". Only -k takes a parameter when unset.
+func templateModeParams(modes string) map[string]string {
+	letters, rest := splitTemplateModes(modes)
+	values := strings.Fields(rest)
+	params := map[string]string{}
+	adding := true
+	for _, r := range letters {
+		switch {
+		case r == '+':
+			adding = true
+		case r == '-':
+			adding = false
+		case !strings.ContainsRune(channelParamModes, r) || (!adding && r != 'k'):
+		case len(values) > 0:
+			if adding {
+				params[string(r)] = values[0]
+			}
+			values = values[1:]
+		}
+	}
+	return params
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/database/models"
+)
+
+func TestTemplateModeParams(t *testing.T) {
+	tests := []struct {
+		modes string
+		want  map[string]string
+	}{
+		{"+nt", map[string]string{}},
+		{"+ntkl key 10", map[string]string{"k": "key", "l": "10"}},
+		{"+lk 10 key", map[string]string{"l": "10", "k": "key"}},
+		{"+f-s [5j]:10", map[string]string{"f": "[5j]:10"}},
+		{"-k+l old 5", map[string]string{"l": "5"}},
+		{"+nF-l normal", map[string]string{"F": "normal"}},
+		{"+k", map[string]string{}},
+	}
+	for _, tt := range tests {
+		if got := templateModeParams(tt.modes); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("templateModeParams(%q) = %v, want %v", tt.modes, got, tt.want)
+		}
+	}
+}
+
+func TestCompareChannelToTemplateModes(t *testing.T) {
+	tests := []struct {
+		name     string
+		live     string
+		template string
+		drifted  bool
+	}{
+		{"letters match", "+ntk key", "+nt", false},
+		{"missing letter", "+n", "+nt", true},
+		{"unset letter present", "+nts", "+nt-s", true},
+		{"same key", "+ntk key", "+ntk key", false},
+		{"different key", "+ntk other", "+ntk key", true},
+		{"different limit", "+ntl 20", "+ntl 10", true},
+		{"params after a j mode", "+ntjkl 3:5 key 10", "+ntkl key 10", false},
+		{"different flood profile", "+ntF strict", "+ntF normal", true},
+		{"different flood settings", "+ntf [5j]:10", "+ntf [10j]:10", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			channel := &IRCChannel{Name: "#test", Modes: tt.live}
+			template := &models.ChannelTemplate{Modes: tt.template}
+			diffs := compareChannelToTemplate(channel, template)
+			if got := len(diffs) > 0; got != tt.drifted {
+				t.Errorf("compareChannelToTemplate(%q, %q) = %+v, want drifted %v", tt.live, tt.template, diffs, tt.drifted)
+			}
+		})
+	}
+}
+
+func TestChannelModeParamsPrefersModeParams(t *testing.T) {
+	channel := &IRCChannel{
+		Modes:      "+ntkl key 10",
+		ModeParams: map[string]interface{}{"l": float64(25), "x": "ignored", "key": "ignored"},
+	}
+	letters, params := channelModeParams(channel)
+	want := map[string]string{"k": "key", "l": "25"}
+	if letters != "ntkl" || !reflect.DeepEqual(params, want) {
+		t.Errorf("channelModeParams() = %q, %v, want ntkl, %v", letters, params, want)
+	}
+}
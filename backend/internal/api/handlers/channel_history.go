@@ -0,0 +1,452 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/database"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/database/models"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/rpc"
+)
+
+// Channel history resolutions
+const (
+	ChannelHistoryRaw  = "raw"  // One sample per interval
+	ChannelHistoryHour = "hour" // Hourly rollups
+	ChannelHistoryDay  = "day"  // Daily rollups
+)
+
+// Default retention in days of samples, hourly rollups and daily rollups and topics
+const (
+	defaultChannelHistoryRawDays    = 7
+	defaultChannelHistoryHourlyDays = 90
+	defaultChannelHistoryDailyDays  = 365
+)
+
+const maxChannelTopicHistory = 100
+
+// ChannelHistoryPoint is the member count of a channel at one sample or rollup bucket
+type ChannelHistoryPoint struct {
+	Time     time.Time `json:"time"`
+	AvgUsers float64   `json:"avg_users"`
+	MinUsers int       `json:"min_users"`
+	MaxUsers int       `json:"max_users"`
+}
+
+// ChannelGrowth is how a channel's average member count changed over a report's time range
+type ChannelGrowth struct {
+	Channel       string  `json:"channel"`
+	StartUsers    float64 `json:"start_users"`
+	EndUsers      float64 `json:"end_users"`
+	Change        float64 `json:"change"`
+	ChangePercent float64 `json:"change_percent,omitempty"` // Empty for channels that appeared in the range
+}
+
+// channelTopicSeen is the last recorded topic of each channel, keyed by lowercased name
+var channelTopicSeen struct {
+	mu     sync.Mutex
+	topics map[string]models.ChannelTopicChange
+}
+
+// GetChannelHistory returns a channel's member counts and topic changes over a time
+// range (1h, 24h, 7d, 30d, 90d, 365d or custom with start_date and end_date). The
+// resolution is raw samples, hourly or daily rollups; auto picks one by range.
+func GetChannelHistory(c *gin.Context) {
+	name := c.Param("name")
+	start, end := parseTimeRange(c.DefaultQuery("range", "24h"), c.Query("start_date"), c.Query("end_date"))
+
+	resolution := c.DefaultQuery("resolution", "auto")
+	switch resolution {
+	case "auto":
+		resolution = channelHistoryResolution(start, end)
+	case ChannelHistoryRaw, ChannelHistoryHour, ChannelHistoryDay:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "resolution must be auto, raw, hour or day"})
+		return
+	}
+
+	points, err := channelHistoryPoints(name, resolution, start, end)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get channel history: " + err.Error()})
+		return
+	}
+
+	var topics []models.ChannelTopicChange
+	database.Get().Where("LOWER(channel) = ? AND created_at BETWEEN ? AND ?", strings.ToLower(name), start, end).
+		Order("created_at DESC").Limit(maxChannelTopicHistory).Find(&topics)
+
+	summary := gin.H{"samples": len(points)}
+	if len(points) > 0 {
+		first, last := points[0], points[len(points)-1]
+		peak := points[0]
+		for _, point := range points {
+			if point.MaxUsers > peak.MaxUsers {
+				peak = point
+			}
+		}
+		summary["start_users"] = first.AvgUsers
+		summary["end_users"] = last.AvgUsers
+		summary["change"] = last.AvgUsers - first.AvgUsers
+		summary["peak_users"] = peak.MaxUsers
+		summary["peak_at"] = peak.Time
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"channel":    name,
+		"resolution": resolution,
+		"start":      start,
+		"end":        end,
+		"points":     points,
+		"topics":     topics,
+		"summary":    summary,
+	})
+}
+
+// SampleChannelHistory records the member count of every channel and any topic
+// that changed since the last sample. The scheduler runs it every five minutes.
+func SampleChannelHistory() {
+	db := database.Get()
+	if db == nil {
+		return
+	}
+
+	result, err := rpc.GetManager().WithRetry(func(client *rpc.Client) (interface{}, error) {
+		return client.Channel().GetAll(1)
+	})
+	if err != nil {
+		return
+	}
+	channels := parseChannelList(result)
+	if len(channels) == 0 {
+		return
+	}
+
+	now := time.Now().UTC()
+	samples := make([]models.ChannelMemberSample, 0, len(channels))
+	for _, channel := range channels {
+		samples = append(samples, models.ChannelMemberSample{SampledAt: now, Channel: channel.Name, NumUsers: channel.NumUsers})
+	}
+	if err := db.CreateInBatches(samples, 500).Error; err != nil {
+		log.Printf("[ChannelHistory] Failed to record member counts: %v", err)
+	}
+
+	for _, change := range changedChannelTopics(channels) {
+		if err := db.Create(&change).Error; err != nil {
+			log.Printf("[ChannelHistory] Failed to record topic of %s: %v", change.Channel, err)
+		}
+	}
+}
+
+// RollupChannelHistory summarises completed hours of samples into hourly rollups and
+// completed days of hourly rollups into daily rollups. The scheduler runs it every hour.
+func RollupChannelHistory() {
+	if database.Get() == nil {
+		return
+	}
+	for _, period := range []string{ChannelHistoryHour, ChannelHistoryDay} {
+		if err := rollupChannelMembers(period); err != nil {
+			log.Printf("[ChannelHistory] Failed to roll up %s buckets: %v", period, err)
+		}
+	}
+}
+
+// PruneChannelHistory removes samples, rollups and topic changes past their retention
+func PruneChannelHistory() {
+	db := database.Get()
+	rawDays, hourlyDays, dailyDays := channelHistoryRetention()
+	now := time.Now().UTC()
+	db.Where("sampled_at < ?", now.AddDate(0, 0, -rawDays)).Delete(&models.ChannelMemberSample{})
+	db.Where("period = ? AND bucket < ?", ChannelHistoryHour, now.AddDate(0, 0, -hourlyDays)).Delete(&models.ChannelMemberRollup{})
+	db.Where("period = ? AND bucket < ?", ChannelHistoryDay, now.AddDate(0, 0, -dailyDays)).Delete(&models.ChannelMemberRollup{})
+	db.Where("created_at < ?", now.AddDate(0, 0, -dailyDays)).Delete(&models.ChannelTopicChange{})
+}
+
+// Helper functions
+
+// changedChannelTopics returns the topics that differ from the last recorded topic of
+// each channel, and remembers them. A topic set again with a new time counts as a change.
+func changedChannelTopics(channels []IRCChannel) []models.ChannelTopicChange {
+	channelTopicSeen.mu.Lock()
+	defer channelTopicSeen.mu.Unlock()
+
+	if channelTopicSeen.topics == nil {
+		channelTopicSeen.topics = make(map[string]models.ChannelTopicChange)
+		db := database.Get()
+		var latest []models.ChannelTopicChange
+		db.Where("id IN (?)", db.Model(&models.ChannelTopicChange{}).Select("MAX(id)").Group("channel")).Find(&latest)
+		for _, change := range latest {
+			channelTopicSeen.topics[strings.ToLower(change.Channel)] = change
+		}
+	}
+
+	changes := []models.ChannelTopicChange{}
+	for _, channel := range channels {
+		change := models.ChannelTopicChange{Channel: channel.Name, Topic: channel.Topic, SetBy: channel.TopicSetBy}
+		if channel.TopicSetAt > 0 {
+			setAt := time.Unix(channel.TopicSetAt, 0).UTC()
+			change.SetAt = &setAt
+		}
+
+		key := strings.ToLower(channel.Name)
+		previous, seen := channelTopicSeen.topics[key]
+		if !seen && channel.Topic == "" {
+			continue
+		}
+		if seen && previous.Topic == change.Topic && sameTopicTime(previous.SetAt, change.SetAt) {
+			continue
+		}
+		channelTopicSeen.topics[key] = change
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+func sameTopicTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Equal(*b)
+}
+
+// rollupChannelMembers creates the rollups of every completed bucket since the last one.
+// Hourly rollups come from samples, daily rollups from hourly rollups.
+func rollupChannelMembers(period string) error {
+	db := database.Get()
+	size := time.Hour
+	if period == ChannelHistoryDay {
+		size = 24 * time.Hour
+	}
+
+	var start time.Time
+	var last models.ChannelMemberRollup
+	if db.Where("period = ?", period).Order("bucket DESC").First(&last).Error == nil {
+		start = last.Bucket.Add(size)
+	}
+
+	// Skip ahead to the first bucket that has data
+	if period == ChannelHistoryHour {
+		var first models.ChannelMemberSample
+		if db.Where("sampled_at >= ?", start).Order("sampled_at").First(&first).Error != nil {
+			return nil
+		}
+		start = first.SampledAt.UTC().Truncate(size)
+	} else {
+		var first models.ChannelMemberRollup
+		if db.Where("period = ? AND bucket >= ?", ChannelHistoryHour, start).Order("bucket").First(&first).Error != nil {
+			return nil
+		}
+		start = first.Bucket.UTC().Truncate(size)
+	}
+
+	end := time.Now().UTC().Truncate(size)
+	for bucket := start; bucket.Before(end); bucket = bucket.Add(size) {
+		var rows []struct {
+			Channel  string
+			MinUsers int
+			MaxUsers int
+			AvgUsers float64
+			Samples  int
+		}
+		var err error
+		if period == ChannelHistoryHour {
+			err = db.Model(&models.ChannelMemberSample{}).
+				Select("channel, MIN(num_users) AS min_users, MAX(num_users) AS max_users, AVG(num_users) AS avg_users, COUNT(*) AS samples").
+				Where("sampled_at >= ? AND sampled_at < ?", bucket, bucket.Add(size)).
+				Group("channel").Scan(&rows).Error
+		} else {
+			err = db.Model(&models.ChannelMemberRollup{}).
+				Select("channel, MIN(min_users) AS min_users, MAX(max_users) AS max_users, SUM(avg_users * samples) / SUM(samples) AS avg_users, SUM(samples) AS samples").
+				Where("period = ? AND bucket >= ? AND bucket < ?", ChannelHistoryHour, bucket, bucket.Add(size)).
+				Group("channel").Scan(&rows).Error
+		}
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			continue
+		}
+
+		rollups := make([]models.ChannelMemberRollup, 0, len(rows))
+		for _, row := range rows {
+			rollups = append(rollups, models.ChannelMemberRollup{
+				Period:   period,
+				Bucket:   bucket,
+				Channel:  row.Channel,
+				MinUsers: row.MinUsers,
+				MaxUsers: row.MaxUsers,
+				AvgUsers: row.AvgUsers,
+				Samples:  row.Samples,
+			})
+		}
+		if err := db.CreateInBatches(rollups, 500).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// channelHistoryResolution picks the finest resolution that is still kept for the
+// start of the range and gives a reasonable number of points
+func channelHistoryResolution(start, end time.Time) string {
+	rawDays, hourlyDays, _ := channelHistoryRetention()
+	span := end.Sub(start)
+	age := time.Since(start)
+	switch {
+	case span <= 48*time.Hour && age <= time.Duration(rawDays)*24*time.Hour:
+		return ChannelHistoryRaw
+	case span <= 31*24*time.Hour && age <= time.Duration(hourlyDays)*24*time.Hour:
+		return ChannelHistoryHour
+	}
+	return ChannelHistoryDay
+}
+
+// channelHistoryPoints loads a channel's samples or rollups in a time range, oldest first
+func channelHistoryPoints(channel, resolution string, start, end time.Time) ([]ChannelHistoryPoint, error) {
+	db := database.Get()
+	points := []ChannelHistoryPoint{}
+
+	if resolution == ChannelHistoryRaw {
+		var samples []models.ChannelMemberSample
+		if err := db.Where("LOWER(channel) = ? AND sampled_at BETWEEN ? AND ?", strings.ToLower(channel), start.UTC(), end.UTC()).
+			Order("sampled_at").Find(&samples).Error; err != nil {
+			return nil, err
+		}
+		for _, sample := range samples {
+			points = append(points, ChannelHistoryPoint{
+				Time:     sample.SampledAt,
+				AvgUsers: float64(sample.NumUsers),
+				MinUsers: sample.NumUsers,
+				MaxUsers: sample.NumUsers,
+			})
+		}
+		return points, nil
+	}
+
+	var rollups []models.ChannelMemberRollup
+	if err := db.Where("period = ? AND LOWER(channel) = ? AND bucket BETWEEN ? AND ?", resolution, strings.ToLower(channel), start.UTC(), end.UTC()).
+		Order("bucket").Find(&rollups).Error; err != nil {
+		return nil, err
+	}
+	for _, rollup := range rollups {
+		points = append(points, ChannelHistoryPoint{
+			Time:     rollup.Bucket,
+			AvgUsers: rollup.AvgUsers,
+			MinUsers: rollup.MinUsers,
+			MaxUsers: rollup.MaxUsers,
+		})
+	}
+	return points, nil
+}
+
+// channelAverages returns the average member count of every channel between from and to
+func channelAverages(from, to time.Time) map[string]float64 {
+	db := database.Get()
+	var rows []struct {
+		Channel  string
+		AvgUsers float64
+	}
+
+	switch channelHistoryResolution(from, to) {
+	case ChannelHistoryRaw:
+		db.Model(&models.ChannelMemberSample{}).Select("channel, AVG(num_users) AS avg_users").
+			Where("sampled_at BETWEEN ? AND ?", from.UTC(), to.UTC()).Group("channel").Scan(&rows)
+	case ChannelHistoryHour:
+		db.Model(&models.ChannelMemberRollup{}).Select("channel, AVG(avg_users) AS avg_users").
+			Where("period = ? AND bucket BETWEEN ? AND ?", ChannelHistoryHour, from.UTC(), to.UTC()).Group("channel").Scan(&rows)
+	default:
+		db.Model(&models.ChannelMemberRollup{}).Select("channel, AVG(avg_users) AS avg_users").
+			Where("period = ? AND bucket BETWEEN ? AND ?", ChannelHistoryDay, from.UTC(), to.UTC()).Group("channel").Scan(&rows)
+	}
+
+	averages := make(map[string]float64, len(rows))
+	for _, row := range rows {
+		averages[row.Channel] = row.AvgUsers
+	}
+	return averages
+}
+
+// collectChannelGrowthMetrics compares the average member count of each channel at the
+// start of a time range with the end of it and returns the top growing and dying channels
+func collectChannelGrowthMetrics(start, end time.Time, filters ReportFilters) map[string]interface{} {
+	result := make(map[string]interface{})
+
+	// Compare the first and last tenth of the range, but at least an hour, or a day for daily rollups
+	window := end.Sub(start) / 10
+	if minimum := time.Hour; window < minimum {
+		window = minimum
+	}
+	if channelHistoryResolution(start, end) == ChannelHistoryDay && window < 24*time.Hour {
+		window = 24 * time.Hour
+	}
+	first := channelAverages(start, start.Add(window))
+	last := channelAverages(end.Add(-window), end)
+
+	growth := []ChannelGrowth{}
+	for channel := range mergeChannelKeys(first, last) {
+		if filters.ChannelFilter != "" && !strings.Contains(strings.ToLower(channel), strings.ToLower(filters.ChannelFilter)) {
+			continue
+		}
+		entry := ChannelGrowth{Channel: channel, StartUsers: first[channel], EndUsers: last[channel]}
+		if filters.MinUsers > 0 && entry.StartUsers < float64(filters.MinUsers) && entry.EndUsers < float64(filters.MinUsers) {
+			continue
+		}
+		entry.Change = entry.EndUsers - entry.StartUsers
+		if entry.StartUsers > 0 {
+			entry.ChangePercent = entry.Change / entry.StartUsers * 100
+		}
+		growth = append(growth, entry)
+	}
+
+	growing, dying := []ChannelGrowth{}, []ChannelGrowth{}
+	for _, entry := range growth {
+		if entry.Change > 0 {
+			growing = append(growing, entry)
+		} else if entry.Change < 0 {
+			dying = append(dying, entry)
+		}
+	}
+	sort.Slice(growing, func(i, j int) bool { return growing[i].Change > growing[j].Change })
+	sort.Slice(dying, func(i, j int) bool { return dying[i].Change < dying[j].Change })
+	if len(growing) > 10 {
+		growing = growing[:10]
+	}
+	if len(dying) > 10 {
+		dying = dying[:10]
+	}
+
+	result["tracked"] = len(growth)
+	result["top_growing"] = growing
+	result["top_dying"] = dying
+	return result
+}
+
+func mergeChannelKeys(maps ...map[string]float64) map[string]bool {
+	keys := make(map[string]bool)
+	for _, m := range maps {
+		for key := range m {
+			keys[key] = true
+		}
+	}
+	return keys
+}
+
+// channelHistoryRetention returns the days samples, hourly rollups and daily rollups are kept
+func channelHistoryRetention() (int, int, int) {
+	days := []int{defaultChannelHistoryRawDays, defaultChannelHistoryHourlyDays, defaultChannelHistoryDailyDays}
+	db := database.Get()
+	for i, key := range []string{"channel_history_raw_days", "channel_history_hourly_days", "channel_history_daily_days"} {
+		var setting models.Setting
+		if db.Where("key = ?", key).First(&setting).Error == nil {
+			if n, err := strconv.Atoi(setting.Value); err == nil && n > 0 {
+				days[i] = n
+			}
+		}
+	}
+	return days[0], days[1], days[2]
+}
@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/api/middleware"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/rpc"
+)
+
+// maxChannelListMaskLength is the longest mask accepted for +b, +e and +I
+const maxChannelListMaskLength = 255
+
+// maxChannelListBulk is the most masks one bulk request may change
+const maxChannelListBulk = 100
+
+// channelListModes maps the list names used in the API to their channel modes
+var channelListModes = map[string]string{
+	"bans":    "b",
+	"excepts": "e",
+	"invites": "I",
+}
+
+// extbanPattern matches extended bans such as ~account:name, ~a:name or ~time:5:nick!*@*
+var extbanPattern = regexp.MustCompile(`^~[A-Za-z][A-Za-z\-]*:.+$`)
+
+// ChannelListRequest adds or removes +b, +e or +I entries
+type ChannelListRequest struct {
+	Masks []string `json:"masks"`
+	All   bool     `json:"all"` // Remove only: clear the whole list
+}
+
+// ChannelListResult is the outcome of adding or removing one mask
+type ChannelListResult struct {
+	Mask    string `json:"mask"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// GetChannelList returns the bans, excepts or invites of a channel
+func GetChannelList(c *gin.Context) {
+	name := c.Param("name")
+	list := c.Param("list")
+	if _, ok := channelListModes[list]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "List must be bans, excepts or invites"})
+		return
+	}
+
+	channel, err := fetchChannelDetails(name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get channel: " + err.Error()})
+		return
+	}
+	if channel == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found"})
+		return
+	}
+
+	entries := channelListEntries(channel, list)
+	if entries == nil {
+		entries = []ChannelListEntry{}
+	}
+	c.JSON(http.StatusOK, gin.H{"channel": channel.Name, "list": list, "entries": entries})
+}
+
+// AddChannelListEntries adds one or more masks to a channel's bans, excepts or invites
+func AddChannelListEntries(c *gin.Context) {
+	handleChannelListChange(c, true)
+}
+
+// RemoveChannelListEntries removes masks from a channel's bans, excepts or invites, or clears the list with all
+func RemoveChannelListEntries(c *gin.Context) {
+	handleChannelListChange(c, false)
+}
+
+// ApplyChannelListEntries sets or unsets each mask on a channel separately so the
+// result of every entry is known. list is bans, excepts or invites.
+func ApplyChannelListEntries(channel, list string, masks []string, add bool) []ChannelListResult {
+	mode := channelListModes[list]
+	sign := "-"
+	if add {
+		sign = "+"
+	}
+
+	manager := rpc.GetManager()
+	results := make([]ChannelListResult, 0, len(masks))
+	for _, mask := range masks {
+		mask = strings.TrimSpace(mask)
+		result := ChannelListResult{Mask: mask}
+		if err := validateChannelListMask(mask); err != nil {
+			result.Error = err.Error()
+			results = append(results, result)
+			continue
+		}
+
+		_, err := manager.WithRetry(func(client *rpc.Client) (interface{}, error) {
+			return client.Channel().SetMode(channel, sign+mode, mask)
+		})
+		if err != nil {
+			result.Error = err.Error()
+		} else {
+			result.Success = true
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// Helper functions
+
+func handleChannelListChange(c *gin.Context, add bool) {
+	name := c.Param("name")
+	list := c.Param("list")
+	if _, ok := channelListModes[list]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "List must be bans, excepts or invites"})
+		return
+	}
+	if err := validateChannelName(name); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var req ChannelListRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	masks := req.Masks
+	if !add && req.All {
+		channel, err := fetchChannelDetails(name)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get channel: " + err.Error()})
+			return
+		}
+		if channel == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found"})
+			return
+		}
+		masks = nil
+		for _, entry := range channelListEntries(channel, list) {
+			masks = append(masks, entry.Mask)
+		}
+	} else if len(masks) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "At least one mask is required"})
+		return
+	}
+	if len(masks) > maxChannelListBulk && !req.All {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d masks can be changed at once", maxChannelListBulk)})
+		return
+	}
+
+	results := ApplyChannelListEntries(name, list, masks, add)
+	succeeded, failed := []string{}, 0
+	for _, result := range results {
+		if result.Success {
+			succeeded = append(succeeded, result.Mask)
+		} else {
+			failed++
+		}
+	}
+
+	action := "remove_channel_list_entries"
+	if add {
+		action = "add_channel_list_entries"
+	}
+	if currentUser := middleware.GetCurrentUser(c); currentUser != nil && len(succeeded) > 0 {
+		logAction(c, currentUser, action, map[string]string{
+			"channel": name,
+			"list":    list,
+			"masks":   strings.Join(succeeded, " "),
+		})
+	}
+
+	status := http.StatusOK
+	if failed > 0 && len(succeeded) == 0 {
+		status = http.StatusBadRequest
+	}
+	c.JSON(status, gin.H{
+		"channel":   name,
+		"list":      list,
+		"results":   results,
+		"succeeded": len(succeeded),
+		"failed":    failed,
+	})
+}
+
+// fetchChannelDetails gets a channel with its member and mode lists, or nil when it does not exist
+func fetchChannelDetails(name string) (*IRCChannel, error) {
+	result, err := rpc.GetManager().WithRetry(func(client *rpc.Client) (interface{}, error) {
+		return client.Channel().Get(name, 4)
+	})
+	if err != nil || result == nil {
+		return nil, err
+	}
+	return parseChannel(result), nil
+}
+
+func channelListEntries(channel *IRCChannel, list string) []ChannelListEntry {
+	switch list {
+	case "bans":
+		return channel.Bans
+	case "excepts":
+		return channel.Excepts
+	case "invites":
+		return channel.Invites
+	}
+	return nil
+}
+
+// validateChannelListMask accepts nick!user@host masks and extended bans
+func validateChannelListMask(mask string) error {
+	if mask == "" {
+		return fmt.Errorf("mask is empty")
+	}
+	if len(mask) > maxChannelListMaskLength {
+		return fmt.Errorf("mask is longer than %d characters", maxChannelListMaskLength)
+	}
+	if strings.ContainsAny(mask, " ,") || hasControlChars(mask) {
+		return fmt.Errorf("mask may not contain spaces, commas or line breaks")
+	}
+	if strings.HasPrefix(mask, "~") && !extbanPattern.MatchString(mask) {
+		return fmt.Errorf("extended bans look like ~type:value, e.g. ~account:name")
+	}
+	return nil
+}
@@ -0,0 +1,626 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/api/middleware"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/database"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/database/models"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/rpc"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/utils"
+)
+
+// Snapshot triggers
+const (
+	SnapshotTriggerManual     = "manual"
+	SnapshotTriggerScheduled  = "scheduled"
+	SnapshotTriggerPreRestore = "pre_restore"
+)
+
+// Default snapshot retention, overridable with the channel_snapshot_keep and channel_snapshot_days settings
+const (
+	defaultSnapshotKeep = 50 // Snapshots kept per channel
+	defaultSnapshotDays = 30 // Days snapshots are kept
+)
+
+// snapshotOpLevels are the member prefixes kept in the op list, highest first
+const snapshotOpLevels = "qaoh"
+
+// Parts of a channel's state that can be restored
+var snapshotParts = []string{"modes", "topic", "bans", "excepts", "invites", "ops"}
+
+// ChannelState is the part of a channel a snapshot keeps
+type ChannelState struct {
+	Modes      string             `json:"modes"`       // Mode letters, e.g. ntkl
+	ModeParams map[string]string  `json:"mode_params"` // Parameters by mode letter, e.g. k (key) and l (limit)
+	Topic      string             `json:"topic"`
+	TopicSetBy string             `json:"topic_set_by,omitempty"`
+	TopicSetAt int64              `json:"topic_set_at,omitempty"`
+	Bans       []ChannelListEntry `json:"bans"`
+	Excepts    []ChannelListEntry `json:"excepts"`
+	Invites    []ChannelListEntry `json:"invites"`
+	Ops        []ChannelOp        `json:"ops"` // Members with +h or higher
+}
+
+// ChannelOp is a member with channel operator status
+type ChannelOp struct {
+	Nick  string `json:"nick"`
+	Level string `json:"level"` // Prefix modes, e.g. "o" or "qo"
+}
+
+// ChannelSnapshotView is a snapshot with its state decoded
+type ChannelSnapshotView struct {
+	models.ChannelSnapshot
+	State *ChannelState `json:"state"`
+}
+
+// ChannelStateChange is one change needed to turn one channel state into another
+type ChannelStateChange struct {
+	Part    string `json:"part"`   // modes, topic, bans, excepts, invites or ops
+	Action  string `json:"action"` // add, remove or set
+	Value   string `json:"value"`  // Mode letter, topic, mask or nick
+	Param   string `json:"param,omitempty"`
+	Applied bool   `json:"applied,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// RestoreSnapshotRequest selects what to restore
+type RestoreSnapshotRequest struct {
+	DryRun bool     `json:"dry_run"` // Only return the changes
+	Parts  []string `json:"parts"`   // Defaults to all: modes, topic, bans, excepts, invites and ops
+}
+
+// GetChannelSnapshots lists a channel's snapshots, newest first
+func GetChannelSnapshots(c *gin.Context) {
+	db := database.Get()
+	var snapshots []models.ChannelSnapshot
+	if err := db.Omit("state").Where("LOWER(channel) = ?", strings.ToLower(c.Param("name"))).
+		Order("created_at DESC").Find(&snapshots).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch snapshots"})
+		return
+	}
+	c.JSON(http.StatusOK, snapshots)
+}
+
+// GetChannelSnapshot returns one snapshot with its full state
+func GetChannelSnapshot(c *gin.Context) {
+	snapshot, state, ok := loadChannelSnapshot(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, ChannelSnapshotView{ChannelSnapshot: *snapshot, State: state})
+}
+
+// CreateChannelSnapshot takes a snapshot of a channel now
+func CreateChannelSnapshot(c *gin.Context) {
+	name := c.Param("name")
+	createdBy := ""
+	currentUser := middleware.GetCurrentUser(c)
+	if currentUser != nil {
+		createdBy = currentUser.Username
+	}
+
+	snapshot, state, err := TakeChannelSnapshot(name, SnapshotTriggerManual, createdBy)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to take snapshot: " + err.Error()})
+		return
+	}
+	if snapshot == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found"})
+		return
+	}
+
+	if currentUser != nil {
+		logAction(c, currentUser, "snapshot_channel", map[string]string{
+			"channel":  snapshot.Channel,
+			"snapshot": strconv.FormatUint(uint64(snapshot.ID), 10),
+		})
+	}
+
+	c.JSON(http.StatusCreated, ChannelSnapshotView{ChannelSnapshot: *snapshot, State: state})
+}
+
+// DeleteChannelSnapshot deletes a snapshot
+func DeleteChannelSnapshot(c *gin.Context) {
+	snapshot, _, ok := loadChannelSnapshot(c)
+	if !ok {
+		return
+	}
+
+	database.Get().Delete(snapshot)
+
+	if currentUser := middleware.GetCurrentUser(c); currentUser != nil {
+		logAction(c, currentUser, "delete_channel_snapshot", map[string]string{
+			"channel":  snapshot.Channel,
+			"snapshot": strconv.FormatUint(uint64(snapshot.ID), 10),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Snapshot deleted"})
+}
+
+// DiffChannelSnapshots shows the changes from snapshot from to snapshot to. Either
+// may be "live" for the channel's current state; to defaults to live.
+func DiffChannelSnapshots(c *gin.Context) {
+	name := c.Param("name")
+	from, err := channelStateForDiff(name, c.Query("from"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from: " + err.Error()})
+		return
+	}
+	to, err := channelStateForDiff(name, c.DefaultQuery("to", "live"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"channel": name,
+		"from":    c.Query("from"),
+		"to":      c.DefaultQuery("to", "live"),
+		"changes": diffChannelStates(from, to),
+	})
+}
+
+// RestoreChannelSnapshot brings a channel back to a snapshot by applying only the
+// changes between the live state and the snapshot. A pre_restore snapshot is taken first.
+func RestoreChannelSnapshot(c *gin.Context) {
+	snapshot, target, ok := loadChannelSnapshot(c)
+	if !ok {
+		return
+	}
+
+	var req RestoreSnapshotRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+	}
+	parts := req.Parts
+	if len(parts) == 0 {
+		parts = snapshotParts
+	}
+	for _, part := range parts {
+		if !containsString(snapshotParts, part) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown part " + part + "; use " + strings.Join(snapshotParts, ", ")})
+			return
+		}
+	}
+
+	live, err := fetchChannelDetails(snapshot.Channel)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get channel: " + err.Error()})
+		return
+	}
+	if live == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Channel does not exist; it must exist to be restored"})
+		return
+	}
+
+	changes := planChannelRestore(live, target, parts)
+	if req.DryRun || len(changes) == 0 {
+		c.JSON(http.StatusOK, gin.H{"channel": snapshot.Channel, "dry_run": req.DryRun, "changes": changes})
+		return
+	}
+
+	currentUser := middleware.GetCurrentUser(c)
+	createdBy := ""
+	if currentUser != nil {
+		createdBy = currentUser.Username
+	}
+	preRestore, _, err := TakeChannelSnapshot(snapshot.Channel, SnapshotTriggerPreRestore, createdBy)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to take pre-restore snapshot: " + err.Error()})
+		return
+	}
+
+	applyChannelStateChanges(snapshot.Channel, changes, createdBy)
+
+	applied, failed := 0, 0
+	for _, change := range changes {
+		if change.Applied {
+			applied++
+		} else {
+			failed++
+		}
+	}
+
+	if currentUser != nil {
+		logAction(c, currentUser, "restore_channel_snapshot", map[string]string{
+			"channel":  snapshot.Channel,
+			"snapshot": strconv.FormatUint(uint64(snapshot.ID), 10),
+			"parts":    strings.Join(parts, ","),
+			"applied":  strconv.Itoa(applied),
+			"failed":   strconv.Itoa(failed),
+		})
+	}
+
+	response := gin.H{
+		"channel": snapshot.Channel,
+		"changes": changes,
+		"applied": applied,
+		"failed":  failed,
+	}
+	if preRestore != nil {
+		response["pre_restore_snapshot_id"] = preRestore.ID
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// TakeChannelSnapshot stores the current state of a channel and applies the retention
+// policy. It returns nil without an error when the channel does not exist.
+func TakeChannelSnapshot(name, trigger, createdBy string) (*models.ChannelSnapshot, *ChannelState, error) {
+	channel, err := fetchChannelDetails(name)
+	if err != nil || channel == nil {
+		return nil, nil, err
+	}
+
+	state := channelStateFromChannel(channel)
+	data, _ := json.Marshal(state)
+	snapshot := models.ChannelSnapshot{
+		Channel:   channel.Name,
+		Trigger:   trigger,
+		NumUsers:  channel.NumUsers,
+		State:     string(data),
+		CreatedBy: createdBy,
+	}
+
+	db := database.Get()
+	if err := db.Create(&snapshot).Error; err != nil {
+		return nil, nil, err
+	}
+
+	keep, _ := channelSnapshotRetention()
+	var stale []uint
+	db.Model(&models.ChannelSnapshot{}).Where("channel = ?", snapshot.Channel).
+		Order("created_at DESC").Offset(keep).Pluck("id", &stale)
+	if len(stale) > 0 {
+		db.Delete(&models.ChannelSnapshot{}, stale)
+	}
+
+	return &snapshot, state, nil
+}
+
+// TakeChannelSnapshots snapshots every channel matching a name or wildcard pattern;
+// scheduled channel_snapshot commands use it
+func TakeChannelSnapshots(pattern, trigger, createdBy string) (int, error) {
+	if !strings.ContainsAny(pattern, "*?") {
+		snapshot, _, err := TakeChannelSnapshot(pattern, trigger, createdBy)
+		if err != nil {
+			return 0, err
+		}
+		if snapshot == nil {
+			return 0, fmt.Errorf("channel %s not found", pattern)
+		}
+		return 1, nil
+	}
+
+	result, err := rpc.GetManager().WithRetry(func(client *rpc.Client) (interface{}, error) {
+		return client.Channel().GetAll(1)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	taken := 0
+	for _, channel := range parseChannelList(result) {
+		if !utils.WildcardMatch(pattern, channel.Name) {
+			continue
+		}
+		if snapshot, _, err := TakeChannelSnapshot(channel.Name, trigger, createdBy); err != nil {
+			log.Printf("[Snapshots] Failed to snapshot %s: %v", channel.Name, err)
+		} else if snapshot != nil {
+			taken++
+		}
+	}
+	return taken, nil
+}
+
+// PruneChannelSnapshots deletes snapshots older than the retention period
+func PruneChannelSnapshots() {
+	_, days := channelSnapshotRetention()
+	database.Get().Where("created_at < ?", time.Now().AddDate(0, 0, -days)).Delete(&models.ChannelSnapshot{})
+}
+
+// Helper functions
+
+// loadChannelSnapshot loads the :id snapshot of the :name channel and writes an error response when it can't
+func loadChannelSnapshot(c *gin.Context) (*models.ChannelSnapshot, *ChannelState, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return nil, nil, false
+	}
+
+	var snapshot models.ChannelSnapshot
+	if err := database.Get().Where("id = ? AND LOWER(channel) = ?", id, strings.ToLower(c.Param("name"))).
+		First(&snapshot).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Snapshot not found"})
+		return nil, nil, false
+	}
+
+	state := &ChannelState{}
+	json.Unmarshal([]byte(snapshot.State), state)
+	return &snapshot, state, true
+}
+
+// channelStateForDiff loads a snapshot of the channel by ID, or the live state for "live"
+func channelStateForDiff(name, ref string) (*ChannelState, error) {
+	if ref == "live" {
+		channel, err := fetchChannelDetails(name)
+		if err != nil {
+			return nil, err
+		}
+		if channel == nil {
+			return nil, fmt.Errorf("channel not found")
+		}
+		return channelStateFromChannel(channel), nil
+	}
+
+	id, err := strconv.ParseUint(ref, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("must be a snapshot ID or live")
+	}
+	var snapshot models.ChannelSnapshot
+	if err := database.Get().Where("id = ? AND LOWER(channel) = ?", id, strings.ToLower(name)).First(&snapshot).Error; err != nil {
+		return nil, fmt.Errorf("snapshot %d not found", id)
+	}
+	state := &ChannelState{}
+	json.Unmarshal([]byte(snapshot.State), state)
+	return state, nil
+}
+
+// channelStateFromChannel extracts the snapshot state from a channel.get result
+func channelStateFromChannel(channel *IRCChannel) *ChannelState {
+	state := &ChannelState{
+		ModeParams: map[string]string{},
+		Topic:      channel.Topic,
+		TopicSetBy: channel.TopicSetBy,
+		TopicSetAt: channel.TopicSetAt,
+		Bans:       channel.Bans,
+		Excepts:    channel.Excepts,
+		Invites:    channel.Invites,
+		Ops:        []ChannelOp{},
+	}
+
+	state.Modes, state.ModeParams = channelModeParams(channel)
+
+	for _, member := range channel.Members {
+		level := ""
+		for _, r := range snapshotOpLevels {
+			if strings.ContainsRune(member.Level, r) {
+				level += string(r)
+			}
+		}
+		if level != "" {
+			state.Ops = append(state.Ops, ChannelOp{Nick: member.Name, Level: level})
+		}
+	}
+
+	if state.Bans == nil {
+		state.Bans = []ChannelListEntry{}
+	}
+	if state.Excepts == nil {
+		state.Excepts = []ChannelListEntry{}
+	}
+	if state.Invites == nil {
+		state.Invites = []ChannelListEntry{}
+	}
+	return state
+}
+
+// diffChannelStates lists the changes that turn state from into state to
+func diffChannelStates(from, to *ChannelState) []ChannelStateChange {
+	changes := []ChannelStateChange{}
+
+	for _, r := range to.Modes {
+		mode := string(r)
+		switch {
+		case !strings.ContainsRune(from.Modes, r):
+			changes = append(changes, ChannelStateChange{Part: "modes", Action: "add", Value: mode, Param: to.ModeParams[mode]})
+		case r == 'k' && to.ModeParams["k"] != from.ModeParams["k"]:
+			// The old key has to be removed before a new one can be set
+			changes = append(changes,
+				ChannelStateChange{Part: "modes", Action: "remove", Value: mode, Param: from.ModeParams["k"]},
+				ChannelStateChange{Part: "modes", Action: "add", Value: mode, Param: to.ModeParams["k"]})
+		case to.ModeParams[mode] != from.ModeParams[mode]:
+			changes = append(changes, ChannelStateChange{Part: "modes", Action: "set", Value: mode, Param: to.ModeParams[mode]})
+		}
+	}
+	for _, r := range from.Modes {
+		if !strings.ContainsRune(to.Modes, r) {
+			change := ChannelStateChange{Part: "modes", Action: "remove", Value: string(r)}
+			if r == 'k' {
+				change.Param = from.ModeParams["k"] // -k needs the key
+			}
+			changes = append(changes, change)
+		}
+	}
+
+	if from.Topic != to.Topic {
+		changes = append(changes, ChannelStateChange{Part: "topic", Action: "set", Value: to.Topic, Param: to.TopicSetBy})
+	}
+
+	for _, list := range []struct {
+		name     string
+		from, to []ChannelListEntry
+	}{
+		{"bans", from.Bans, to.Bans},
+		{"excepts", from.Excepts, to.Excepts},
+		{"invites", from.Invites, to.Invites},
+	} {
+		fromMasks := listMaskSet(list.from)
+		toMasks := listMaskSet(list.to)
+		for _, entry := range list.to {
+			if !fromMasks[strings.ToLower(entry.Mask)] {
+				changes = append(changes, ChannelStateChange{Part: list.name, Action: "add", Value: entry.Mask})
+			}
+		}
+		for _, entry := range list.from {
+			if !toMasks[strings.ToLower(entry.Mask)] {
+				changes = append(changes, ChannelStateChange{Part: list.name, Action: "remove", Value: entry.Mask})
+			}
+		}
+	}
+
+	fromOps := opLevels(from.Ops)
+	toOps := opLevels(to.Ops)
+	for _, op := range to.Ops {
+		for _, r := range op.Level {
+			if !strings.ContainsRune(fromOps[strings.ToLower(op.Nick)], r) {
+				changes = append(changes, ChannelStateChange{Part: "ops", Action: "add", Value: op.Nick, Param: string(r)})
+			}
+		}
+	}
+	for _, op := range from.Ops {
+		for _, r := range op.Level {
+			if !strings.ContainsRune(toOps[strings.ToLower(op.Nick)], r) {
+				changes = append(changes, ChannelStateChange{Part: "ops", Action: "remove", Value: op.Nick, Param: string(r)})
+			}
+		}
+	}
+
+	return changes
+}
+
+// planChannelRestore returns the changes that bring a live channel back to a snapshot,
+// limited to parts. Ops can only be given to users who are in the channel now.
+func planChannelRestore(live *IRCChannel, target *ChannelState, parts []string) []ChannelStateChange {
+	present := make(map[string]bool, len(live.Members))
+	for _, member := range live.Members {
+		present[strings.ToLower(member.Name)] = true
+	}
+
+	changes := []ChannelStateChange{}
+	for _, change := range diffChannelStates(channelStateFromChannel(live), target) {
+		if !containsString(parts, change.Part) {
+			continue
+		}
+		if change.Part == "ops" && change.Action == "add" && !present[strings.ToLower(change.Value)] {
+			continue
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// applyChannelStateChanges applies changes to a channel and records the result of each.
+// Mode changes are sent together, everything else one at a time.
+func applyChannelStateChanges(channel string, changes []ChannelStateChange, setBy string) {
+	manager := rpc.GetManager()
+
+	modes, params := "", []string{}
+	modeChanges := []int{}
+	for i, change := range changes {
+		if change.Part != "modes" {
+			continue
+		}
+		sign := "+"
+		if change.Action == "remove" {
+			sign = "-"
+		}
+		modes += sign + change.Value
+		if change.Param != "" {
+			params = append(params, change.Param)
+		}
+		modeChanges = append(modeChanges, i)
+	}
+	if len(modeChanges) > 0 {
+		_, err := manager.WithRetry(func(client *rpc.Client) (interface{}, error) {
+			return client.Channel().SetMode(channel, modes, strings.Join(params, " "))
+		})
+		for _, i := range modeChanges {
+			markChange(&changes[i], err)
+		}
+	}
+
+	for i := range changes {
+		change := &changes[i]
+		switch change.Part {
+		case "topic":
+			by := change.Param
+			if by == "" {
+				by = setBy
+			}
+			_, err := manager.WithRetry(func(client *rpc.Client) (interface{}, error) {
+				return client.Channel().SetTopic(channel, change.Value, &by, nil)
+			})
+			markChange(change, err)
+
+		case "bans", "excepts", "invites":
+			result := ApplyChannelListEntries(channel, change.Part, []string{change.Value}, change.Action == "add")[0]
+			change.Applied = result.Success
+			change.Error = result.Error
+
+		case "ops":
+			sign := "+"
+			if change.Action == "remove" {
+				sign = "-"
+			}
+			_, err := manager.WithRetry(func(client *rpc.Client) (interface{}, error) {
+				return client.Channel().SetMode(channel, sign+change.Param, change.Value)
+			})
+			markChange(change, err)
+		}
+	}
+}
+
+func markChange(change *ChannelStateChange, err error) {
+	if err != nil {
+		change.Error = err.Error()
+		return
+	}
+	change.Applied = true
+}
+
+func listMaskSet(entries []ChannelListEntry) map[string]bool {
+	set := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		set[strings.ToLower(entry.Mask)] = true
+	}
+	return set
+}
+
+func opLevels(ops []ChannelOp) map[string]string {
+	levels := make(map[string]string, len(ops))
+	for _, op := range ops {
+		levels[strings.ToLower(op.Nick)] = op.Level
+	}
+	return levels
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// channelSnapshotRetention returns how many snapshots to keep per channel and for how many days
+func channelSnapshotRetention() (int, int) {
+	keep, days := defaultSnapshotKeep, defaultSnapshotDays
+	db := database.Get()
+	var setting models.Setting
+	if db.Where("key = ?", "channel_snapshot_keep").First(&setting).Error == nil {
+		if n, err := strconv.Atoi(setting.Value); err == nil && n > 0 {
+			keep = n
+		}
+	}
+	setting = models.Setting{}
+	if db.Where("key = ?", "channel_snapshot_days").First(&setting).Error == nil {
+		if n, err := strconv.Atoi(setting.Value); err == nil && n > 0 {
+			days = n
+		}
+	}
+	return keep, days
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestChannelStateFromChannelModeParams(t *testing.T) {
+	channel := &IRCChannel{Name: "#test", Modes: "+ntjkl 3:5 key 10"}
+	state := channelStateFromChannel(channel)
+	want := map[string]string{"j": "3:5", "k": "key", "l": "10"}
+	if state.Modes != "ntjkl" || !reflect.DeepEqual(state.ModeParams, want) {
+		t.Errorf("channelStateFromChannel() modes = %q, %v, want ntjkl, %v", state.Modes, state.ModeParams, want)
+	}
+}
+
+func TestDiffChannelStatesModes(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to *ChannelState
+		want     []ChannelStateChange
+	}{
+		{
+			"changed key is removed first",
+			&ChannelState{Modes: "ntk", ModeParams: map[string]string{"k": "old"}},
+			&ChannelState{Modes: "ntk", ModeParams: map[string]string{"k": "new"}},
+			[]ChannelStateChange{
+				{Part: "modes", Action: "remove", Value: "k", Param: "old"},
+				{Part: "modes", Action: "add", Value: "k", Param: "new"},
+			},
+		},
+		{
+			"changed limit is set",
+			&ChannelState{Modes: "ntl", ModeParams: map[string]string{"l": "10"}},
+			&ChannelState{Modes: "ntl", ModeParams: map[string]string{"l": "20"}},
+			[]ChannelStateChange{{Part: "modes", Action: "set", Value: "l", Param: "20"}},
+		},
+		{
+			"removed key carries the old key",
+			&ChannelState{Modes: "ntk", ModeParams: map[string]string{"k": "old"}},
+			&ChannelState{Modes: "nt", ModeParams: map[string]string{}},
+			[]ChannelStateChange{{Part: "modes", Action: "remove", Value: "k", Param: "old"}},
+		},
+		{
+			"unchanged",
+			&ChannelState{Modes: "ntjk", ModeParams: map[string]string{"j": "3:5", "k": "key"}},
+			&ChannelState{Modes: "ntjk", ModeParams: map[string]string{"j": "3:5", "k": "key"}},
+			[]ChannelStateChange{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := diffChannelStates(tt.from, tt.to); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diffChannelStates() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
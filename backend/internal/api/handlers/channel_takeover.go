@@ -0,0 +1,665 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/api/middleware"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/auth"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/database"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/database/models"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/rpc"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/utils"
+)
+
+// Takeover patterns
+const (
+	TakeoverMassDeop       = "mass_deop"
+	TakeoverMassKick       = "mass_kick"
+	TakeoverTopicVandalism = "topic_vandalism"
+	TakeoverChannelLock    = "channel_lock" // +i or +k on a registered (+r) channel
+	TakeoverCloneFlood     = "clone_flood"
+)
+
+// Takeover sensitivities
+const (
+	TakeoverSensitivityOff    = "off"
+	TakeoverSensitivityLow    = "low"
+	TakeoverSensitivityMedium = "medium"
+	TakeoverSensitivityHigh   = "high"
+)
+
+// takeoverDefaultChannel is the settings row that applies to channels without their own
+const takeoverDefaultChannel = "*"
+
+// takeoverActor is the actor recorded for responses the detector takes
+const takeoverActor = "takeover-detector"
+
+const (
+	takeoverWindow          = 10 * time.Second // Kicks and joins are counted over this window
+	takeoverPollWindow      = 5 * time.Minute  // Deops and topic changes seen by the channel poller are counted over this window
+	takeoverCooldown        = time.Minute      // One alert per channel and pattern within this time
+	takeoverSettingsRefresh = 30 * time.Second
+	defaultTakeoverGline    = "1h"
+)
+
+// takeoverDurationPattern matches G-Line durations such as 1h, 30m or 1d12h
+var takeoverDurationPattern = regexp.MustCompile(`^([0-9]+[smhdw]?)+$`)
+
+// takeoverThresholds are the counts at which a pattern is reported
+type takeoverThresholds struct {
+	MassDeop       int  `json:"mass_deop"`       // Ops/halfops removed within five minutes
+	MassKick       int  `json:"mass_kick"`       // Users kicked by one user
+	TopicChanges   int  `json:"topic_changes"`   // Topic changes within five minutes
+	JoinFlood      int  `json:"join_flood"`      // Joins to the channel
+	ClonesPerIP    int  `json:"clones_per_ip"`   // Joins from one IP that mark it as a clone source
+	RegisteredLock bool `json:"registered_lock"` // Report +i/+k on registered channels
+}
+
+var takeoverSensitivities = map[string]takeoverThresholds{
+	TakeoverSensitivityLow:    {MassDeop: 8, MassKick: 8, TopicChanges: 5, JoinFlood: 30, ClonesPerIP: 4},
+	TakeoverSensitivityMedium: {MassDeop: 5, MassKick: 5, TopicChanges: 3, JoinFlood: 20, ClonesPerIP: 3, RegisteredLock: true},
+	TakeoverSensitivityHigh:   {MassDeop: 3, MassKick: 3, TopicChanges: 2, JoinFlood: 10, ClonesPerIP: 2, RegisteredLock: true},
+}
+
+// ChannelTakeoverSettingRequest sets the sensitivity and responses for a channel, or "*" for the defaults
+type ChannelTakeoverSettingRequest struct {
+	Channel        string `json:"channel" binding:"required"`
+	Sensitivity    string `json:"sensitivity"`
+	LockTemplateID *uint  `json:"lock_template_id"`
+	GlineClones    bool   `json:"gline_clones"`
+	GlineDuration  string `json:"gline_duration"`
+}
+
+// takeoverHit is one counted event: a deopped or kicked nick, a topic or a join
+type takeoverHit struct {
+	at     time.Time
+	target string
+	ip     string
+}
+
+// takeoverDetection is a pattern that crossed its threshold
+type takeoverDetection struct {
+	channel string
+	pattern string
+	actor   string
+	count   int
+	details map[string]interface{}
+	clones  []string // IPs behind a clone flood
+}
+
+// takeoverChannelState is what the channel poller remembers of a channel
+type takeoverChannelState struct {
+	members    map[string]string // Lowercased nick to its q, a, o and h levels
+	modes      string            // Mode letters
+	key        string
+	topic      string
+	topicSetBy string
+	topicSetAt int64
+}
+
+// takeoverState holds the sliding windows, alert cooldowns and the last polled channels
+var takeoverState struct {
+	mu       sync.Mutex
+	windows  map[string][]takeoverHit        // channel|pattern|actor
+	alerted  map[string]time.Time            // channel|pattern
+	channels map[string]takeoverChannelState // Lowercased channel, nil before the first poll
+	pruned   time.Time
+	settings map[string]models.ChannelTakeoverSetting // Lowercased channel
+	loadedAt time.Time
+}
+
+// GetChannelTakeoverSettings returns the default and per-channel takeover settings
+func GetChannelTakeoverSettings(c *gin.Context) {
+	var settings []models.ChannelTakeoverSetting
+	if err := database.Get().Order("channel").Find(&settings).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get takeover settings"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"settings":      settings,
+		"sensitivities": takeoverSensitivities,
+	})
+}
+
+// SaveChannelTakeoverSetting creates or updates the takeover settings of a channel
+func SaveChannelTakeoverSetting(c *gin.Context) {
+	var req ChannelTakeoverSettingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Channel != takeoverDefaultChannel {
+		if err := validateChannelName(req.Channel); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.Sensitivity == "" {
+		req.Sensitivity = TakeoverSensitivityMedium
+	}
+	if _, ok := takeoverSensitivities[req.Sensitivity]; !ok && req.Sensitivity != TakeoverSensitivityOff {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Sensitivity must be off, low, medium or high"})
+		return
+	}
+	if req.GlineDuration != "" && !takeoverDurationPattern.MatchString(req.GlineDuration) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid G-Line duration"})
+		return
+	}
+
+	user := middleware.GetCurrentUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+	// G-Lining clones adds network-wide bans, which a channel permission does not cover
+	if req.GlineClones && !auth.UserCan(user, models.PermissionServerBanAdd) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+		return
+	}
+	if req.LockTemplateID != nil {
+		if _, err := findBindableTemplate(*req.LockTemplateID, user.ID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Channel template not found"})
+			return
+		}
+	}
+
+	db := database.Get()
+	var setting models.ChannelTakeoverSetting
+	status := http.StatusOK
+	if err := db.Where("LOWER(channel) = ?", strings.ToLower(req.Channel)).First(&setting).Error; err != nil {
+		setting.Channel = req.Channel
+		status = http.StatusCreated
+	}
+	setting.Sensitivity = req.Sensitivity
+	setting.LockTemplateID = req.LockTemplateID
+	setting.GlineClones = req.GlineClones
+	setting.GlineDuration = req.GlineDuration
+	setting.UpdatedByUsername = user.Username
+	if err := db.Save(&setting).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save takeover settings"})
+		return
+	}
+	invalidateTakeoverSettings()
+
+	logAction(c, user, "update_takeover_settings", map[string]string{
+		"channel":      setting.Channel,
+		"sensitivity":  setting.Sensitivity,
+		"gline_clones": strconv.FormatBool(setting.GlineClones),
+	})
+
+	c.JSON(status, setting)
+}
+
+// DeleteChannelTakeoverSetting removes a channel's settings so the defaults apply again
+func DeleteChannelTakeoverSetting(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	db := database.Get()
+	var setting models.ChannelTakeoverSetting
+	if err := db.First(&setting, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Takeover setting not found"})
+		return
+	}
+	if err := db.Delete(&setting).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete takeover setting"})
+		return
+	}
+	invalidateTakeoverSettings()
+
+	if user := middleware.GetCurrentUser(c); user != nil {
+		logAction(c, user, "delete_takeover_settings", map[string]string{
+			"channel": setting.Channel,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Takeover setting deleted"})
+}
+
+// GetTakeoverIncidents returns detected takeover patterns, newest first
+func GetTakeoverIncidents(c *gin.Context) {
+	limit := 100
+	if l, err := strconv.Atoi(c.Query("limit")); err == nil && l > 0 && l <= 1000 {
+		limit = l
+	}
+
+	query := database.Get().Model(&models.TakeoverIncident{})
+	if channel := c.Query("channel"); channel != "" {
+		query = query.Where("LOWER(channel) = ?", strings.ToLower(channel))
+	}
+	if pattern := c.Query("pattern"); pattern != "" {
+		query = query.Where("pattern = ?", pattern)
+	}
+
+	var incidents []models.TakeoverIncident
+	if err := query.Order("created_at DESC").Limit(limit).Find(&incidents).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get takeover incidents"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"incidents": incidents, "total": len(incidents)})
+}
+
+// IngestTakeoverLogEvent counts kicks and joins per channel and raises a
+// CHANNEL_TAKEOVER alert when one crosses the threshold of the channel's
+// sensitivity. Only the log stream feeds it, so events delivered by webhooks as
+// well are not counted twice. UnrealIRCd does not log ordinary MODE and TOPIC
+// changes; SampleChannelTakeover finds deops, topic changes and locks instead.
+func IngestTakeoverLogEvent(entry map[string]interface{}) {
+	channel := logEntryChannel(entry)
+	if channel == "" {
+		return
+	}
+	setting := takeoverSettingFor(channel)
+	thresholds, ok := takeoverSensitivities[setting.Sensitivity]
+	if !ok {
+		return
+	}
+	if detection := takeoverLogDetection(entry, channel, thresholds); detection != nil {
+		go respondToTakeover(detection, setting)
+	}
+}
+
+// SampleChannelTakeover polls every channel and compares it with the previous poll
+// to find mass deops, repeated topic changes and +i/+k set on registered channels.
+// The scheduler runs it every minute.
+func SampleChannelTakeover() {
+	result, err := rpc.GetManager().WithRetry(func(client *rpc.Client) (interface{}, error) {
+		return client.Channel().GetAll(4)
+	})
+	if err != nil {
+		return
+	}
+	for _, detection := range ingestTakeoverChannels(parseChannelList(result)) {
+		respondToTakeover(detection, takeoverSettingFor(detection.channel))
+	}
+}
+
+// Helper functions
+
+// takeoverLogDetection counts a kick or join log entry and returns a detection when it crosses a threshold
+func takeoverLogDetection(entry map[string]interface{}, channel string, thresholds takeoverThresholds) *takeoverDetection {
+	client := utils.SafeMapGetMap(entry, "client")
+	actor := utils.SafeMapGetString(client, "name")
+	at := time.Now()
+
+	switch utils.SafeMapGetString(entry, "event_id") {
+	case "LOCAL_CLIENT_KICK", "REMOTE_CLIENT_KICK":
+		victim := utils.SafeMapGetMap(entry, "kicked")
+		if victim == nil {
+			victim = utils.SafeMapGetMap(entry, "victim")
+		}
+		if victim == nil || actor == "" {
+			return nil
+		}
+		return countTakeoverHits(channel, TakeoverMassKick, actor,
+			takeoverHit{at: at, target: utils.SafeMapGetString(victim, "name")}, takeoverWindow, thresholds.MassKick)
+
+	case "LOCAL_CLIENT_JOIN", "REMOTE_CLIENT_JOIN":
+		if client == nil {
+			return nil
+		}
+		hit := takeoverHit{at: at, target: actor, ip: utils.SafeMapGetString(client, "ip")}
+		detection := countTakeoverHits(channel, TakeoverCloneFlood, "", hit, takeoverWindow, thresholds.JoinFlood)
+		if detection != nil {
+			detection.clones = takeoverCloneIPs(channel, thresholds.ClonesPerIP)
+			detection.details["clone_ips"] = detection.clones
+		}
+		return detection
+	}
+	return nil
+}
+
+// ingestTakeoverChannels remembers the polled channels and returns the detections
+// found by comparing them with the previous poll. The first poll only remembers.
+func ingestTakeoverChannels(channels []IRCChannel) []*takeoverDetection {
+	current := make(map[string]takeoverChannelState, len(channels))
+	for i := range channels {
+		current[strings.ToLower(channels[i].Name)] = takeoverStateFromChannel(&channels[i])
+	}
+	takeoverState.mu.Lock()
+	previous := takeoverState.channels
+	takeoverState.channels = current
+	takeoverState.mu.Unlock()
+
+	detections := []*takeoverDetection{}
+	if previous == nil {
+		return detections
+	}
+	for _, channel := range channels {
+		key := strings.ToLower(channel.Name)
+		before, seen := previous[key]
+		if !seen {
+			continue
+		}
+		thresholds, ok := takeoverSensitivities[takeoverSettingFor(channel.Name).Sensitivity]
+		if !ok {
+			continue
+		}
+		detections = append(detections, compareTakeoverStates(channel.Name, before, current[key], thresholds)...)
+	}
+	return detections
+}
+
+// takeoverStateFromChannel extracts what the poller compares from a channel.list result
+func takeoverStateFromChannel(channel *IRCChannel) takeoverChannelState {
+	modes, params := channelModeParams(channel)
+	state := takeoverChannelState{
+		members:    make(map[string]string, len(channel.Members)),
+		modes:      modes,
+		key:        params["k"],
+		topic:      channel.Topic,
+		topicSetBy: channel.TopicSetBy,
+		topicSetAt: channel.TopicSetAt,
+	}
+	for _, member := range channel.Members {
+		level := ""
+		for _, r := range snapshotOpLevels {
+			if strings.ContainsRune(member.Level, r) {
+				level += string(r)
+			}
+		}
+		state.members[strings.ToLower(member.Name)] = level
+	}
+	return state
+}
+
+// compareTakeoverStates counts the deops and topic changes between two polls of a
+// channel and reports +i or +k newly set on a registered (+r) channel. The poller
+// cannot tell who removed a status, so deops have no actor.
+func compareTakeoverStates(channel string, before, after takeoverChannelState, thresholds takeoverThresholds) []*takeoverDetection {
+	at := time.Now()
+	detections := []*takeoverDetection{}
+
+	for nick, levels := range before.members {
+		now, present := after.members[nick]
+		if !present {
+			continue // Parted or was kicked
+		}
+		for _, r := range levels {
+			if strings.ContainsRune(now, r) {
+				continue
+			}
+			if d := countTakeoverHits(channel, TakeoverMassDeop, "",
+				takeoverHit{at: at, target: nick}, takeoverPollWindow, thresholds.MassDeop); d != nil {
+				detections = append(detections, d)
+			}
+			break
+		}
+	}
+
+	if after.topic != before.topic || after.topicSetAt != before.topicSetAt {
+		if d := countTakeoverHits(channel, TakeoverTopicVandalism, "",
+			takeoverHit{at: at, target: after.topic}, takeoverPollWindow, thresholds.TopicChanges); d != nil {
+			d.actor = after.topicSetBy
+			detections = append(detections, d)
+		}
+	}
+
+	if thresholds.RegisteredLock && strings.ContainsRune(after.modes, 'r') {
+		locked := []string{}
+		if strings.ContainsRune(after.modes, 'i') && !strings.ContainsRune(before.modes, 'i') {
+			locked = append(locked, "+i")
+		}
+		if after.key != "" && after.key != before.key {
+			locked = append(locked, "+k")
+		}
+		if len(locked) > 0 && takeoverCooldownFree(channel, TakeoverChannelLock) {
+			detections = append(detections, &takeoverDetection{
+				channel: channel,
+				pattern: TakeoverChannelLock,
+				count:   len(locked),
+				details: map[string]interface{}{"modes": strings.Join(locked, " ")},
+			})
+		}
+	}
+	return detections
+}
+
+// invalidateTakeoverSettings makes the detector reload its settings on the next event
+func invalidateTakeoverSettings() {
+	takeoverState.mu.Lock()
+	takeoverState.loadedAt = time.Time{}
+	takeoverState.mu.Unlock()
+}
+
+// takeoverSettingFor returns a channel's settings, the "*" defaults or medium sensitivity without responses
+func takeoverSettingFor(channel string) models.ChannelTakeoverSetting {
+	takeoverState.mu.Lock()
+	defer takeoverState.mu.Unlock()
+
+	if time.Since(takeoverState.loadedAt) > takeoverSettingsRefresh {
+		var rows []models.ChannelTakeoverSetting
+		if db := database.Get(); db != nil && db.Find(&rows).Error == nil {
+			takeoverState.settings = make(map[string]models.ChannelTakeoverSetting, len(rows))
+			for _, row := range rows {
+				takeoverState.settings[strings.ToLower(row.Channel)] = row
+			}
+		}
+		takeoverState.loadedAt = time.Now()
+	}
+
+	if setting, ok := takeoverState.settings[strings.ToLower(channel)]; ok {
+		return setting
+	}
+	if setting, ok := takeoverState.settings[takeoverDefaultChannel]; ok {
+		setting.Channel = channel
+		return setting
+	}
+	return models.ChannelTakeoverSetting{Channel: channel, Sensitivity: TakeoverSensitivityMedium}
+}
+
+// countTakeoverHits adds a hit to a sliding window and returns a detection when the
+// window holds threshold distinct targets and the pattern is not in its cooldown
+func countTakeoverHits(channel, pattern, actor string, hit takeoverHit, window time.Duration, threshold int) *takeoverDetection {
+	if threshold <= 0 {
+		return nil
+	}
+
+	takeoverState.mu.Lock()
+	defer takeoverState.mu.Unlock()
+
+	now := time.Now()
+	if takeoverState.windows == nil {
+		takeoverState.windows = make(map[string][]takeoverHit)
+		takeoverState.alerted = make(map[string]time.Time)
+	}
+	if now.Sub(takeoverState.pruned) > time.Minute {
+		for key, hits := range takeoverState.windows {
+			if len(hits) == 0 || now.Sub(hits[len(hits)-1].at) > takeoverPollWindow {
+				delete(takeoverState.windows, key)
+			}
+		}
+		for key, t := range takeoverState.alerted {
+			if now.Sub(t) > takeoverCooldown {
+				delete(takeoverState.alerted, key)
+			}
+		}
+		takeoverState.pruned = now
+	}
+
+	key := strings.ToLower(channel + "|" + pattern + "|" + actor)
+	hits := []takeoverHit{}
+	for _, h := range takeoverState.windows[key] {
+		if now.Sub(h.at) <= window && h.target != hit.target {
+			hits = append(hits, h)
+		}
+	}
+	hits = append(hits, hit)
+	takeoverState.windows[key] = hits
+
+	alertKey := strings.ToLower(channel + "|" + pattern)
+	if len(hits) < threshold || now.Sub(takeoverState.alerted[alertKey]) < takeoverCooldown {
+		return nil
+	}
+	takeoverState.alerted[alertKey] = now
+
+	targets := make([]string, 0, len(hits))
+	for _, h := range hits {
+		targets = append(targets, h.target)
+	}
+	details := map[string]interface{}{"window_seconds": int(window.Seconds()), "targets": targets}
+	return &takeoverDetection{channel: channel, pattern: pattern, actor: actor, count: len(hits), details: details}
+}
+
+// takeoverCloneIPs returns the IPs with at least perIP joins in a channel's join window
+func takeoverCloneIPs(channel string, perIP int) []string {
+	takeoverState.mu.Lock()
+	defer takeoverState.mu.Unlock()
+
+	counts := make(map[string]int)
+	for _, h := range takeoverState.windows[strings.ToLower(channel+"|"+TakeoverCloneFlood+"|")] {
+		if h.ip != "" {
+			counts[h.ip]++
+		}
+	}
+	clones := []string{}
+	for ip, count := range counts {
+		if perIP > 0 && count >= perIP {
+			clones = append(clones, ip)
+		}
+	}
+	sort.Strings(clones)
+	return clones
+}
+
+// takeoverCooldownFree reports whether a pattern may alert for a channel and starts its cooldown
+func takeoverCooldownFree(channel, pattern string) bool {
+	takeoverState.mu.Lock()
+	defer takeoverState.mu.Unlock()
+
+	if takeoverState.alerted == nil {
+		takeoverState.alerted = make(map[string]time.Time)
+	}
+	key := strings.ToLower(channel + "|" + pattern)
+	if time.Since(takeoverState.alerted[key]) < takeoverCooldown {
+		return false
+	}
+	takeoverState.alerted[key] = time.Now()
+	return true
+}
+
+// respondToTakeover applies the configured response, records the incident and alerts
+func respondToTakeover(detection *takeoverDetection, setting models.ChannelTakeoverSetting) {
+	responses := []string{}
+
+	if setting.LockTemplateID != nil {
+		var template models.ChannelTemplate
+		if err := database.Get().Where("id = ? AND deleted_at IS NULL", *setting.LockTemplateID).First(&template).Error; err != nil {
+			responses = append(responses, "lock template not found")
+		} else {
+			_, errs, _ := applyChannelTemplate(detection.channel, &template, takeoverActor)
+			if len(errs) > 0 {
+				responses = append(responses, "applied template "+template.Name+" with errors: "+strings.Join(errs, "; "))
+			} else {
+				responses = append(responses, "applied template "+template.Name)
+			}
+		}
+	}
+
+	if setting.GlineClones && len(detection.clones) > 0 {
+		duration := setting.GlineDuration
+		if duration == "" {
+			duration = defaultTakeoverGline
+		}
+		reason := "Clone flood on " + detection.channel
+		glined, exempt := []string{}, []string{}
+		exceptions, err := takeoverGlineExceptions()
+		if err != nil {
+			log.Printf("[Takeover] Not G-Lining clones on %s, failed to get ban exceptions: %v", detection.channel, err)
+			responses = append(responses, "no G-Lines, ban exceptions could not be checked")
+			detection.clones = nil
+		}
+		for _, ip := range detection.clones {
+			if takeoverIPExempt(ip, exceptions) {
+				exempt = append(exempt, ip)
+				continue
+			}
+			mask := "*@" + ip
+			_, err := rpc.GetManager().WithRetry(func(client *rpc.Client) (interface{}, error) {
+				return client.ServerBan().Add(mask, "gline", duration, reason)
+			})
+			if err != nil {
+				log.Printf("[Takeover] Failed to G-Line %s: %v", mask, err)
+				continue
+			}
+			recordPanelBanChange(takeoverActor, ledgerActionAdd, "gline", mask, reason, duration)
+			glined = append(glined, mask)
+		}
+		if len(glined) > 0 {
+			responses = append(responses, "G-Lined "+strings.Join(glined, " "))
+		}
+		if len(exempt) > 0 {
+			responses = append(responses, "not G-Lined (ban exception) "+strings.Join(exempt, " "))
+		}
+	}
+
+	response := strings.Join(responses, "; ")
+	detailsJSON, _ := json.Marshal(detection.details)
+	incident := models.TakeoverIncident{
+		Channel:     detection.channel,
+		Pattern:     detection.pattern,
+		Actor:       detection.actor,
+		Count:       detection.count,
+		Sensitivity: setting.Sensitivity,
+		Details:     string(detailsJSON),
+		Response:    response,
+	}
+	if err := database.Get().Create(&incident).Error; err != nil {
+		log.Printf("[Takeover] Failed to record %s on %s: %v", detection.pattern, detection.channel, err)
+	}
+
+	msg := fmt.Sprintf("Possible takeover of %s: %s (%d)", detection.channel, strings.ReplaceAll(detection.pattern, "_", " "), detection.count)
+	if detection.actor != "" {
+		msg += " by " + detection.actor
+	}
+	raisePanelAlert("CHANNEL_TAKEOVER", "warn", msg, map[string]string{
+		"channel":     detection.channel,
+		"pattern":     detection.pattern,
+		"actor":       detection.actor,
+		"count":       strconv.Itoa(detection.count),
+		"sensitivity": setting.Sensitivity,
+		"response":    response,
+	})
+}
+
+// takeoverGlineExceptions returns the ban exceptions that exempt users from G-Lines,
+// such as those added for NAT gateways and web clients that share an IP
+func takeoverGlineExceptions() ([]BanException, error) {
+	result, err := rpc.GetManager().WithRetry(func(client *rpc.Client) (interface{}, error) {
+		return client.ServerBanException().GetAll()
+	})
+	if err != nil {
+		return nil, err
+	}
+	exceptions := []BanException{}
+	for _, exception := range parseBanExceptionList(result) {
+		if strings.ContainsRune(exception.ExceptionTypes, 'G') {
+			exceptions = append(exceptions, exception)
+		}
+	}
+	return exceptions, nil
+}
+
+// takeoverIPExempt reports whether a ban exception covers every user from an IP
+func takeoverIPExempt(ip string, exceptions []BanException) bool {
+	user := &IRCUser{IP: ip, Hostname: ip}
+	for _, exception := range exceptions {
+		if banMaskMatchesUser(exception.Name, user) {
+			return true
+		}
+	}
+	return false
+}
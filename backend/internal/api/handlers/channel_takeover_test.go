@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// takeoverKickEntry is a kick as UnrealIRCd writes it to the JSON log
+const takeoverKickEntry = `{
+	"timestamp": "2026-10-18T12:00:00.123Z",
+	"level": "info",
+	"subsystem": "kick",
+	"event_id": "LOCAL_CLIENT_KICK",
+	"log_source": "hub.example.net",
+	"msg": "User mallory kicked bob from #help (bye)",
+	"client": {"name": "mallory", "id": "001AAAAAM", "ip": "192.0.2.66", "user": {"username": "m"}},
+	"victim": {"name": "bob", "id": "001AAAAAB"},
+	"channel": {"name": "#help"},
+	"reason": "bye"
+}`
+
+func resetTakeoverState() {
+	takeoverState.mu.Lock()
+	takeoverState.windows = nil
+	takeoverState.alerted = nil
+	takeoverState.channels = nil
+	takeoverState.mu.Unlock()
+}
+
+func TestTakeoverLogDetectionMassKick(t *testing.T) {
+	resetTakeoverState()
+	thresholds := takeoverSensitivities[TakeoverSensitivityHigh]
+
+	var detection *takeoverDetection
+	for i, victim := range []string{"bob", "carol", "dave"} {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(takeoverKickEntry), &entry); err != nil {
+			t.Fatal(err)
+		}
+		entry["victim"].(map[string]interface{})["name"] = victim
+		detection = takeoverLogDetection(entry, logEntryChannel(entry), thresholds)
+		if i < thresholds.MassKick-1 && detection != nil {
+			t.Fatalf("kick %d raised %+v", i+1, detection)
+		}
+	}
+	if detection == nil {
+		t.Fatal("no detection after three kicks")
+	}
+	if detection.pattern != TakeoverMassKick || detection.actor != "mallory" || detection.channel != "#help" || detection.count != 3 {
+		t.Errorf("detection = %+v", detection)
+	}
+}
+
+func TestIngestTakeoverChannels(t *testing.T) {
+	resetTakeoverState()
+	members := func(levels ...string) []ChannelMember {
+		nicks := []string{"alice", "bob", "carol", "dave", "erin", "frank"}
+		list := []ChannelMember{}
+		for i, level := range levels {
+			list = append(list, ChannelMember{Name: nicks[i], Level: level})
+		}
+		return list
+	}
+	poll := func(modes, topic string, setAt int64, levels ...string) []*takeoverDetection {
+		return ingestTakeoverChannels([]IRCChannel{{
+			Name:       "#help",
+			Modes:      modes,
+			Topic:      topic,
+			TopicSetBy: "mallory",
+			TopicSetAt: setAt,
+			Members:    members(levels...),
+		}})
+	}
+	patterns := func(detections []*takeoverDetection) map[string]int {
+		found := map[string]int{}
+		for _, d := range detections {
+			found[d.pattern] = d.count
+		}
+		return found
+	}
+
+	// Channels without settings use medium sensitivity: 5 deops, 3 topic changes, locks reported
+	if got := poll("+ntr", "Welcome", 100, "o", "o", "o", "o", "o", "q"); len(got) != 0 {
+		t.Fatalf("first poll = %v, want nothing", patterns(got))
+	}
+	if got := poll("+ntr", "Welcome", 100, "o", "o", "", "", "o", "q"); len(got) != 0 {
+		t.Fatalf("two deops = %v, want nothing", patterns(got))
+	}
+
+	// Three more deops, a parted op that does not count, a topic change and a key on a registered channel
+	got := patterns(poll("+ntrk takeover", "pwned", 200, "", "", "", "", ""))
+	if got[TakeoverMassDeop] != 5 {
+		t.Errorf("mass deop count = %d, want 5 (%v)", got[TakeoverMassDeop], got)
+	}
+	if got[TakeoverChannelLock] != 1 {
+		t.Errorf("channel lock count = %d, want 1 (%v)", got[TakeoverChannelLock], got)
+	}
+	if _, ok := got[TakeoverTopicVandalism]; ok {
+		t.Errorf("one topic change raised topic vandalism")
+	}
+
+	poll("+ntrk takeover", "pwned again", 300)
+	got = patterns(poll("+ntrk takeover", "pwned once more", 400))
+	if got[TakeoverTopicVandalism] != 3 {
+		t.Errorf("topic vandalism count = %d, want 3 (%v)", got[TakeoverTopicVandalism], got)
+	}
+}
+
+func TestTakeoverIPExempt(t *testing.T) {
+	exceptions := []BanException{
+		{Name: "*@192.0.2.10", ExceptionTypes: "G"},
+		{Name: "*@198.51.100.0/24", ExceptionTypes: "Gm"},
+		{Name: "*@2001:db8:*", ExceptionTypes: "G"},
+		{Name: "webirc@203.0.113.5", ExceptionTypes: "G"}, // Only covers one ident
+		{Name: "~account:trusted", ExceptionTypes: "G"},
+	}
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"192.0.2.10", true},
+		{"192.0.2.11", false},
+		{"198.51.100.77", true},
+		{"2001:db8::1", true},
+		{"203.0.113.5", false},
+	}
+	for _, tt := range tests {
+		if got := takeoverIPExempt(tt.ip, exceptions); got != tt.want {
+			t.Errorf("takeoverIPExempt(%s) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
@@ -2,8 +2,10 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/api/middleware"
@@ -215,6 +217,10 @@ func DeleteChannelTemplate(c *gin.Context) {
 	}
 
 	result := db.Where("id = ? AND created_by = ?", id, user.ID).Delete(&models.ChannelTemplate{})
+	if result.Error == nil && result.RowsAffected > 0 {
+		db.Where("template_id = ?", id).Delete(&models.ChannelTemplateBinding{})
+		db.Where("template_id = ?", id).Delete(&models.ChannelDrift{})
+	}
 
 	if result.Error != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete channel template"})
@@ -258,44 +264,23 @@ func ApplyChannelTemplate(c *gin.Context) {
 		return
 	}
 
-	manager := rpc.GetManager()
-	errors := []string{}
-	success := []string{}
-
-	// Apply topic if set
-	if template.Topic != "" {
-		setBy := user.Username
-		_, err := manager.WithRetry(func(client *rpc.Client) (interface{}, error) {
-			return client.Channel().SetTopic(req.Channel, template.Topic, &setBy, nil)
-		})
-		if err != nil {
-			errors = append(errors, "Failed to set topic: "+err.Error())
-		} else {
-			success = append(success, "Topic set")
-		}
-	}
-
-	// Apply modes if set
-	if template.Modes != "" {
-		_, err := manager.WithRetry(func(client *rpc.Client) (interface{}, error) {
-			return client.Channel().SetMode(req.Channel, template.Modes, "")
-		})
-		if err != nil {
-			errors = append(errors, "Failed to set modes: "+err.Error())
-		} else {
-			success = append(success, "Modes set")
-		}
-	}
+	success, errors, listResults := applyChannelTemplate(req.Channel, &template, user.Username)
 
 	// Update template use count
 	template.UseCount++
 	db.Save(&template)
 
+	logAction(c, user, "apply_channel_template", map[string]string{
+		"channel":  req.Channel,
+		"template": template.Name,
+	})
+
 	c.JSON(http.StatusOK, gin.H{
-		"message":  "Template applied",
-		"success":  success,
-		"errors":   errors,
-		"template": template,
+		"message":      "Template applied",
+		"success":      success,
+		"errors":       errors,
+		"list_results": listResults,
+		"template":     template,
 	})
 }
 
@@ -369,3 +354,83 @@ func CreateTemplateFromChannel(c *gin.Context) {
 
 	c.JSON(http.StatusCreated, template)
 }
+
+// Helper functions
+
+// templateMaskList decodes a template's JSON array of masks
+func templateMaskList(raw string) ([]string, error) {
+	var masks []string
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &masks); err != nil {
+		return nil, err
+	}
+	return masks, nil
+}
+
+// applyChannelTemplate sets a template's topic, modes and ban, exception and invite
+// lists on a channel and reports what succeeded and what failed
+func applyChannelTemplate(channel string, template *models.ChannelTemplate, setBy string) (success, errors []string, listResults map[string][]ChannelListResult) {
+	manager := rpc.GetManager()
+	errors = []string{}
+	success = []string{}
+
+	// Apply topic if set
+	if template.Topic != "" {
+		_, err := manager.WithRetry(func(client *rpc.Client) (interface{}, error) {
+			return client.Channel().SetTopic(channel, template.Topic, &setBy, nil)
+		})
+		if err != nil {
+			errors = append(errors, "Failed to set topic: "+err.Error())
+		} else {
+			success = append(success, "Topic set")
+		}
+	}
+
+	// Apply modes if set
+	if template.Modes != "" {
+		letters, params := splitTemplateModes(template.Modes)
+		_, err := manager.WithRetry(func(client *rpc.Client) (interface{}, error) {
+			return client.Channel().SetMode(channel, letters, params)
+		})
+		if err != nil {
+			errors = append(errors, "Failed to set modes: "+err.Error())
+		} else {
+			success = append(success, "Modes set")
+		}
+	}
+
+	// Apply ban, exception and invite lists, one entry at a time
+	listResults = map[string][]ChannelListResult{}
+	for _, list := range []struct{ name, masks string }{
+		{"bans", template.BanList},
+		{"excepts", template.ExceptList},
+		{"invites", template.InviteList},
+	} {
+		masks, err := templateMaskList(list.masks)
+		if err != nil {
+			errors = append(errors, "Invalid "+list.name+" in template: "+err.Error())
+			continue
+		}
+		if len(masks) == 0 {
+			continue
+		}
+
+		results := ApplyChannelListEntries(channel, list.name, masks, true)
+		listResults[list.name] = results
+		applied := 0
+		for _, result := range results {
+			if result.Success {
+				applied++
+			} else {
+				errors = append(errors, "Failed to add "+result.Mask+" to "+list.name+": "+result.Error)
+			}
+		}
+		if applied > 0 {
+			success = append(success, fmt.Sprintf("%d of %d %s added", applied, len(results), list.name))
+		}
+	}
+
+	return success, errors, listResults
+}
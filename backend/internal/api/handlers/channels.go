@@ -2,6 +2,8 @@ package handlers
 
 import (
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/api/middleware"
@@ -40,7 +42,8 @@ type ChannelListEntry struct {
 	SetAt int64  `json:"set_at"`
 }
 
-// GetChannels returns all IRC channels
+// GetChannels returns all IRC channels. With filter, sort, limit or cursor parameters
+// it returns a ListPage instead of the plain array.
 func GetChannels(c *gin.Context) {
 	manager := rpc.GetManager()
 
@@ -64,7 +67,19 @@ func GetChannels(c *gin.Context) {
 	}
 
 	channels := parseChannelList(result)
-	c.JSON(http.StatusOK, channels)
+
+	query, paged := parseListQuery(c)
+	if !paged {
+		c.JSON(http.StatusOK, channels)
+		return
+	}
+
+	page, err := queryChannels(channels, query)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, page)
 }
 
 // GetChannel returns a specific IRC channel
@@ -305,3 +320,40 @@ func parseChannelList2(items []interface{}) []ChannelListEntry {
 	}
 	return entries
 }
+
+// channelParamModes are the channel modes that take a parameter when set: UnrealIRCd's
+// CHANMODES groups B and C (fkL and lFH), and +j from third-party modules
+const channelParamModes = "fkLlFHj"
+
+// channelModeParams splits a channel's modes, which channel.get returns as "ntkl key 10",
+// into the mode letters and the parameter of each mode. Values the server sends in
+// mode_params are preferred over the positional ones.
+func channelModeParams(channel *IRCChannel) (string, map[string]string) {
+	params := map[string]string{}
+	fields := strings.Fields(channel.Modes)
+	if len(fields) == 0 {
+		return "", params
+	}
+
+	letters := strings.TrimPrefix(fields[0], "+")
+	values := fields[1:]
+	for _, r := range letters {
+		if strings.ContainsRune(channelParamModes, r) && len(values) > 0 {
+			params[string(r)] = values[0]
+			values = values[1:]
+		}
+	}
+
+	for mode, value := range channel.ModeParams {
+		if len(mode) != 1 || !strings.Contains(letters, mode) {
+			continue
+		}
+		switch v := value.(type) {
+		case string:
+			params[mode] = v
+		case float64:
+			params[mode] = strconv.FormatFloat(v, 'f', -1, 64)
+		}
+	}
+	return letters, params
+}
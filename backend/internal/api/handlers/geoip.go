@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/services/geoip"
+)
+
+// GetGeoIPStatus returns the state of the GeoIP/ASN databases
+func GetGeoIPStatus(c *gin.Context) {
+	service := geoip.GetService()
+	c.JSON(http.StatusOK, gin.H{
+		"available": service.Available(),
+		"databases": service.Status(),
+	})
+}
+
+// LookupGeoIP returns GeoIP/ASN data for an IP address or ban mask
+func LookupGeoIP(c *gin.Context) {
+	query := c.Query("ip")
+	if query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ip is required"})
+		return
+	}
+
+	info := geoip.LookupMask(query)
+	if info == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No GeoIP data for " + query})
+		return
+	}
+	c.JSON(http.StatusOK, info)
+}
@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/utils"
+)
+
+// Journey event sources, recorded in the details of every ingested event
+const (
+	JourneySourceLog     = "log"
+	JourneySourceWebhook = "webhook"
+)
+
+// journeySeenTTL is how long an ingested event is remembered. The log stream and a
+// webhook deliver the same log entry, with the same event ID and timestamp, within seconds.
+const journeySeenTTL = 5 * time.Minute
+
+// journeySeen remembers recently ingested events and when they were ingested
+var journeySeen struct {
+	mu      sync.Mutex
+	entries map[string]time.Time
+	pruned  time.Time
+}
+
+// journeyLogEvent is a log entry mapped onto the journey timeline
+type journeyLogEvent struct {
+	eventType string
+	client    map[string]interface{} // The user the event is about
+	details   map[string]interface{}
+	dedupOn   string // Extra dedup key part, e.g. the channel
+}
+
+// IngestJourneyLogEvent records connect, quit, nick change, join, part, kick, kill,
+// ban and spamfilter log events on the user journey timeline. source is
+// JourneySourceLog for the RPC log stream and JourneySourceWebhook for webhooks.
+func IngestJourneyLogEvent(entry map[string]interface{}, source string) {
+	event := mapJourneyLogEvent(entry)
+	if event == nil {
+		return
+	}
+
+	nick := utils.SafeMapGetString(event.client, "name")
+	ip := utils.SafeMapGetString(event.client, "ip")
+	account, server := "", ""
+	if user := utils.SafeMapGetMap(event.client, "user"); user != nil {
+		account = utils.SafeMapGetString(user, "account")
+		server = utils.SafeMapGetString(user, "servername")
+	}
+	if server == "" {
+		server = utils.SafeMapGetString(entry, "log_source")
+	}
+
+	// Bans are about a mask rather than a client
+	if event.client == nil {
+		nick = utils.SafeMapGetString(event.details, "nick")
+		ip = utils.SafeMapGetString(event.details, "ip")
+		account = utils.SafeMapGetString(event.details, "account")
+	}
+	if nick == "" && ip == "" && account == "" {
+		return
+	}
+
+	occurredAt := parseLogTimestamp(utils.SafeMapGetString(entry, "timestamp"))
+	id := utils.SafeMapGetString(event.client, "id")
+	if id == "" {
+		id = strings.ToLower(nick + "@" + ip + "/" + account)
+	}
+	if !journeyFirstSeen(journeyDedupKey(entry, id, event.dedupOn)) {
+		return
+	}
+
+	event.details["event_id"] = utils.SafeMapGetString(entry, "event_id")
+	event.details["source"] = source
+	if msg := utils.SafeMapGetString(entry, "msg"); msg != "" {
+		event.details["msg"] = msg
+	}
+
+	if err := recordJourneyEventAt(occurredAt, event.eventType, nick, ip, account, server, event.details); err != nil {
+		log.Printf("[Journey] Failed to record %s of %s: %v", event.eventType, nick, err)
+	}
+}
+
+// Helper functions
+
+// mapJourneyLogEvent maps an UnrealIRCd JSON log entry onto a journey event, or nil
+// when the entry is not part of a user's journey
+func mapJourneyLogEvent(entry map[string]interface{}) *journeyLogEvent {
+	client := utils.SafeMapGetMap(entry, "client")
+	details := make(map[string]interface{})
+
+	switch utils.SafeMapGetString(entry, "event_id") {
+	case "LOCAL_CLIENT_CONNECT", "REMOTE_CLIENT_CONNECT":
+		if client == nil {
+			return nil
+		}
+		details["hostname"] = utils.SafeMapGetString(client, "hostname")
+		if user := utils.SafeMapGetMap(client, "user"); user != nil {
+			details["username"] = utils.SafeMapGetString(user, "username")
+			details["realname"] = utils.SafeMapGetString(user, "realname")
+		}
+		if tls := utils.SafeMapGetMap(client, "tls"); tls != nil {
+			details["tls_cipher"] = utils.SafeMapGetString(tls, "cipher")
+		}
+		return &journeyLogEvent{eventType: "connect", client: client, details: details}
+
+	case "LOCAL_CLIENT_DISCONNECT", "REMOTE_CLIENT_DISCONNECT":
+		if client == nil {
+			return nil
+		}
+		details["reason"] = utils.SafeMapGetString(entry, "reason")
+		return &journeyLogEvent{eventType: "disconnect", client: client, details: details}
+
+	case "LOCAL_NICK_CHANGE", "REMOTE_NICK_CHANGE", "FORCED_NICK_CHANGE":
+		if client == nil {
+			return nil
+		}
+		newNick := utils.SafeMapGetString(entry, "new_nick")
+		details["old_nick"] = utils.SafeMapGetString(client, "name")
+		details["new_nick"] = newNick
+		return &journeyLogEvent{eventType: "nick_change", client: client, details: details, dedupOn: newNick}
+
+	case "LOCAL_CLIENT_JOIN", "REMOTE_CLIENT_JOIN":
+		channel := logEntryChannel(entry)
+		if client == nil || channel == "" {
+			return nil
+		}
+		details["channel"] = channel
+		return &journeyLogEvent{eventType: "join", client: client, details: details, dedupOn: channel}
+
+	case "LOCAL_CLIENT_PART", "REMOTE_CLIENT_PART":
+		channel := logEntryChannel(entry)
+		if client == nil || channel == "" {
+			return nil
+		}
+		details["channel"] = channel
+		details["reason"] = utils.SafeMapGetString(entry, "reason")
+		return &journeyLogEvent{eventType: "part", client: client, details: details, dedupOn: channel}
+
+	case "LOCAL_CLIENT_KICK", "REMOTE_CLIENT_KICK":
+		// The log client is the kicker, the journey belongs to the kicked user
+		victim := utils.SafeMapGetMap(entry, "kicked")
+		if victim == nil {
+			victim = utils.SafeMapGetMap(entry, "victim")
+		}
+		channel := logEntryChannel(entry)
+		if victim == nil {
+			return nil
+		}
+		details["channel"] = channel
+		details["reason"] = utils.SafeMapGetString(entry, "reason")
+		details["by"] = utils.SafeMapGetString(client, "name")
+		return &journeyLogEvent{eventType: "kick", client: victim, details: details, dedupOn: channel}
+
+	case "KILL_COMMAND":
+		target := utils.SafeMapGetMap(entry, "target")
+		if target == nil {
+			return nil
+		}
+		details["reason"] = utils.SafeMapGetString(entry, "reason")
+		details["by"] = utils.SafeMapGetString(client, "name")
+		return &journeyLogEvent{eventType: "kill", client: target, details: details}
+
+	case "TKL_ADD", "TKL_DEL":
+		tkl := utils.SafeMapGetMap(entry, "tkl")
+		if tkl == nil {
+			return nil
+		}
+		mask := utils.SafeMapGetString(tkl, "name")
+		banType := normalizeLedgerBanType(utils.SafeMapGetString(tkl, "type"))
+		if banType == banMetadataTypeException || banType == banMetadataTypeSpamfilter {
+			return nil
+		}
+		details["ban_type"] = banType
+		details["mask"] = mask
+		details["reason"] = utils.SafeMapGetString(tkl, "reason")
+		details["set_by"] = utils.SafeMapGetString(tkl, "set_by")
+		details["duration"] = utils.SafeMapGetString(tkl, "duration_string")
+		for key, value := range banMaskTargets(banType, mask) {
+			details[key] = value
+		}
+
+		eventType := "ban"
+		if utils.SafeMapGetString(entry, "event_id") == "TKL_DEL" {
+			eventType = "unban"
+		}
+		return &journeyLogEvent{eventType: eventType, details: details, dedupOn: mask}
+
+	case "SPAMFILTER_MATCH":
+		if client == nil {
+			return nil
+		}
+		if tkl := utils.SafeMapGetMap(entry, "tkl"); tkl != nil {
+			details["spamfilter"] = utils.SafeMapGetString(tkl, "name")
+			details["action"] = utils.SafeMapGetString(tkl, "ban_action")
+		}
+		details["target"] = utils.SafeMapGetString(entry, "spamfilter_target")
+		details["spamfilter_type"] = utils.SafeMapGetString(entry, "spamfilter_type")
+		return &journeyLogEvent{eventType: "message", client: client, details: details, dedupOn: "spamfilter"}
+	}
+
+	return nil
+}
+
+// logEntryChannel returns the channel name of a log entry, which is either a string or a channel object
+func logEntryChannel(entry map[string]interface{}) string {
+	if channel := utils.SafeMapGetMap(entry, "channel"); channel != nil {
+		return utils.SafeMapGetString(channel, "name")
+	}
+	return utils.SafeMapGetString(entry, "channel")
+}
+
+// banMaskTargets extracts the nick, IP or account a ban applies to, so the ban
+// shows up on the journey of the affected user
+func banMaskTargets(banType, mask string) map[string]string {
+	targets := make(map[string]string)
+	if banType == banMetadataTypeName {
+		if !strings.ContainsAny(mask, "*?") {
+			targets["nick"] = mask
+		}
+		return targets
+	}
+	if strings.HasPrefix(mask, "~account:") || strings.HasPrefix(mask, "~a:") {
+		targets["account"] = mask[strings.Index(mask, ":")+1:]
+		return targets
+	}
+
+	host := mask
+	if at := strings.LastIndex(host, "@"); at >= 0 {
+		host = host[at+1:]
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		targets["ip"] = ip.String()
+	}
+	return targets
+}
+
+// journeyDedupKey identifies one log entry: its own timestamp and event ID plus the
+// client and channel or mask it is about. It is empty when the entry has no timestamp.
+func journeyDedupKey(entry map[string]interface{}, id, dedupOn string) string {
+	timestamp := utils.SafeMapGetString(entry, "timestamp")
+	if timestamp == "" {
+		return ""
+	}
+	return strings.Join([]string{
+		timestamp,
+		utils.SafeMapGetString(entry, "event_id"),
+		id,
+		strings.ToLower(dedupOn),
+	}, "|")
+}
+
+// journeyFirstSeen reports whether no event with the same key was ingested
+// recently, and remembers this one. An empty key is always new.
+func journeyFirstSeen(key string) bool {
+	if key == "" {
+		return true
+	}
+
+	journeySeen.mu.Lock()
+	defer journeySeen.mu.Unlock()
+
+	now := time.Now()
+	if journeySeen.entries == nil {
+		journeySeen.entries = make(map[string]time.Time)
+	}
+	if now.Sub(journeySeen.pruned) > time.Minute {
+		for k, t := range journeySeen.entries {
+			if now.Sub(t) > journeySeenTTL {
+				delete(journeySeen.entries, k)
+			}
+		}
+		journeySeen.pruned = now
+	}
+
+	if _, ok := journeySeen.entries[key]; ok {
+		return false
+	}
+	journeySeen.entries[key] = now
+	return true
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+const journeyJoinEntry = `{
+	"timestamp": "2026-10-18T12:00:00.123Z",
+	"level": "info",
+	"subsystem": "join",
+	"event_id": "LOCAL_CLIENT_JOIN",
+	"log_source": "hub.example.net",
+	"msg": "User alice joined #help",
+	"client": {"name": "alice", "id": "001AAAAAA", "ip": "192.0.2.1"},
+	"channel": {"name": "#Help"}
+}`
+
+func journeyTestEntry(t *testing.T, raw string, changes map[string]interface{}) map[string]interface{} {
+	t.Helper()
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(raw), &entry); err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range changes {
+		entry[k] = v
+	}
+	return entry
+}
+
+func TestJourneyDedupKey(t *testing.T) {
+	key := func(entry map[string]interface{}) string {
+		event := mapJourneyLogEvent(entry)
+		if event == nil {
+			t.Fatal("mapJourneyLogEvent() = nil")
+		}
+		return journeyDedupKey(entry, "001AAAAAA", event.dedupOn)
+	}
+
+	fromLog := key(journeyTestEntry(t, journeyJoinEntry, nil))
+	// A webhook delivers the same log entry
+	fromWebhook := key(journeyTestEntry(t, journeyJoinEntry, nil))
+	if fromLog != fromWebhook {
+		t.Errorf("log key %q != webhook key %q", fromLog, fromWebhook)
+	}
+
+	// A rejoin a moment later is a different event
+	rejoin := key(journeyTestEntry(t, journeyJoinEntry, map[string]interface{}{"timestamp": "2026-10-18T12:00:00.900Z"}))
+	if rejoin == fromLog {
+		t.Errorf("rejoin has the same key %q", rejoin)
+	}
+
+	// The same join seen by another server as a remote join is logged with its own ID
+	remote := key(journeyTestEntry(t, journeyJoinEntry, map[string]interface{}{"event_id": "REMOTE_CLIENT_JOIN"}))
+	if remote == fromLog {
+		t.Errorf("remote join has the same key %q", remote)
+	}
+
+	if got := journeyDedupKey(map[string]interface{}{"event_id": "LOCAL_CLIENT_JOIN"}, "001AAAAAA", "#help"); got != "" {
+		t.Errorf("journeyDedupKey() without a timestamp = %q, want empty", got)
+	}
+}
+
+func TestJourneyFirstSeen(t *testing.T) {
+	journeySeen.mu.Lock()
+	journeySeen.entries = nil
+	journeySeen.mu.Unlock()
+
+	if !journeyFirstSeen("a") {
+		t.Error("first delivery of a was not new")
+	}
+	if journeyFirstSeen("a") {
+		t.Error("second delivery of a was new")
+	}
+	if !journeyFirstSeen("b") {
+		t.Error("first delivery of b was not new")
+	}
+	if !journeyFirstSeen("") || !journeyFirstSeen("") {
+		t.Error("an empty key was not always new")
+	}
+}
@@ -0,0 +1,602 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/utils"
+)
+
+// ListQuery holds the filter, sort and paging parameters for the user and channel lists
+type ListQuery struct {
+	Filter string // Filter expression, e.g. server:hub1 AND tls:false AND ip:10.0.0.0/8
+	Sort   string // Sort key, prefixed with - for descending order
+	Cursor string // next_cursor of the previous page
+	Limit  int
+}
+
+// ListPage is one page of a filtered and sorted list
+type ListPage struct {
+	Items      interface{} `json:"items"`
+	Total      int         `json:"total"` // Number of items matching the filter
+	Limit      int         `json:"limit"`
+	Sort       string      `json:"sort"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+}
+
+const (
+	defaultListLimit = 100
+	maxListLimit     = 1000
+)
+
+var errInvalidCursor = errors.New("invalid cursor for this sort order")
+
+// filterKind decides how a field's values are compared with a condition
+type filterKind int
+
+const (
+	filterExact    filterKind = iota // Case-insensitive equality with * and ? wildcards, comma-separated alternatives
+	filterContains                   // Substring match, or a wildcard match when the value contains * or ?
+	filterIP                         // CIDR range or wildcard
+	filterNumber                     // Numeric comparison with >, <, >=, <=
+	filterDuration                   // Like filterNumber, in seconds, accepting 30m, 2h, 1d, 1w
+	filterBool                       // true/false, yes/no
+	filterModes                      // +o / -i, checked against the mode letters
+)
+
+type userFilterField struct {
+	kind   filterKind
+	values func(u *IRCUser) []string
+}
+
+type channelFilterField struct {
+	kind   filterKind
+	values func(ch *IRCChannel) []string
+}
+
+// userFilterFields are the fields usable in user filter expressions
+var userFilterFields = map[string]userFilterField{
+	"nick":     {filterExact, func(u *IRCUser) []string { return nonEmpty(u.Name) }},
+	"ident":    {filterExact, func(u *IRCUser) []string { return nonEmpty(u.Username) }},
+	"username": {filterExact, func(u *IRCUser) []string { return nonEmpty(u.Username) }},
+	"realname": {filterContains, func(u *IRCUser) []string { return nonEmpty(u.RealName) }},
+	"host":     {filterExact, func(u *IRCUser) []string { return nonEmpty(u.Hostname) }},
+	"vhost":    {filterExact, func(u *IRCUser) []string { return nonEmpty(u.VHost) }},
+	"ip":       {filterIP, func(u *IRCUser) []string { return nonEmpty(u.IP) }},
+	"account":  {filterExact, func(u *IRCUser) []string { return nonEmpty(u.Account) }},
+	"server":   {filterExact, func(u *IRCUser) []string { return nonEmpty(u.ServerName, u.Server) }},
+	"modes":    {filterModes, func(u *IRCUser) []string { return []string{u.Modes} }},
+	"tls": {filterBool, func(u *IRCUser) []string {
+		return []string{strconv.FormatBool(u.TLS != nil)}
+	}},
+	"oper": {filterBool, func(u *IRCUser) []string {
+		return []string{strconv.FormatBool(u.OperLogin != "" || strings.Contains(u.Modes, "o"))}
+	}},
+	"certfp":  {filterExact, func(u *IRCUser) []string { return nonEmpty(utils.SafeMapGetString(u.TLS, "certfp")) }},
+	"channel": {filterExact, userChannelNames},
+	"group":   {filterExact, func(u *IRCUser) []string { return u.SecurityGroups }},
+	"country": {filterExact, func(u *IRCUser) []string {
+		if u.Geo == nil {
+			return nil
+		}
+		return nonEmpty(u.Geo.CountryCode)
+	}},
+	"asn": {filterNumber, func(u *IRCUser) []string {
+		if u.Geo == nil || u.Geo.ASN == 0 {
+			return nil
+		}
+		return []string{strconv.FormatUint(uint64(u.Geo.ASN), 10)}
+	}},
+	"as_org": {filterContains, func(u *IRCUser) []string {
+		if u.Geo == nil {
+			return nil
+		}
+		return nonEmpty(u.Geo.ASOrg)
+	}},
+	"reputation": {filterNumber, func(u *IRCUser) []string { return []string{strconv.Itoa(u.Reputation)} }},
+	"idle":       {filterDuration, func(u *IRCUser) []string { return []string{strconv.FormatInt(u.Idle, 10)} }},
+	"age": {filterDuration, func(u *IRCUser) []string {
+		if u.ConnectedSince == 0 {
+			return nil
+		}
+		return []string{strconv.FormatInt(time.Now().Unix()-u.ConnectedSince, 10)}
+	}},
+	utils.FilterTextField: {filterContains, func(u *IRCUser) []string {
+		return nonEmpty(u.Name, u.Username, u.RealName, u.Hostname, u.VHost, u.IP, u.Account)
+	}},
+}
+
+// channelFilterFields are the fields usable in channel filter expressions
+var channelFilterFields = map[string]channelFilterField{
+	"name":     {filterExact, func(ch *IRCChannel) []string { return nonEmpty(ch.Name) }},
+	"topic":    {filterContains, func(ch *IRCChannel) []string { return nonEmpty(ch.Topic) }},
+	"topic_by": {filterExact, func(ch *IRCChannel) []string { return nonEmpty(ch.TopicSetBy) }},
+	"users":    {filterNumber, func(ch *IRCChannel) []string { return []string{strconv.Itoa(ch.NumUsers)} }},
+	"modes":    {filterModes, func(ch *IRCChannel) []string { return []string{ch.Modes} }},
+	"age": {filterDuration, func(ch *IRCChannel) []string {
+		if ch.CreationTime == 0 {
+			return nil
+		}
+		return []string{strconv.FormatInt(time.Now().Unix()-ch.CreationTime, 10)}
+	}},
+	utils.FilterTextField: {filterContains, func(ch *IRCChannel) []string { return nonEmpty(ch.Name, ch.Topic) }},
+}
+
+// userSortKeys return a string per user that sorts in the wanted order
+var userSortKeys = map[string]func(u *IRCUser) string{
+	"nick":       func(u *IRCUser) string { return strings.ToLower(u.Name) },
+	"ident":      func(u *IRCUser) string { return strings.ToLower(u.Username) },
+	"host":       func(u *IRCUser) string { return strings.ToLower(u.Hostname) },
+	"ip":         func(u *IRCUser) string { return ipSortKey(u.IP) },
+	"account":    func(u *IRCUser) string { return strings.ToLower(u.Account) },
+	"server":     func(u *IRCUser) string { return strings.ToLower(u.ServerName) },
+	"connected":  func(u *IRCUser) string { return numericSortKey(u.ConnectedSince) },
+	"idle":       func(u *IRCUser) string { return numericSortKey(u.Idle) },
+	"reputation": func(u *IRCUser) string { return numericSortKey(int64(u.Reputation)) },
+	"country": func(u *IRCUser) string {
+		if u.Geo == nil {
+			return ""
+		}
+		return u.Geo.CountryCode
+	},
+	"asn": func(u *IRCUser) string {
+		if u.Geo == nil {
+			return numericSortKey(0)
+		}
+		return numericSortKey(int64(u.Geo.ASN))
+	},
+}
+
+// channelSortKeys return a string per channel that sorts in the wanted order
+var channelSortKeys = map[string]func(ch *IRCChannel) string{
+	"name":    func(ch *IRCChannel) string { return strings.ToLower(ch.Name) },
+	"users":   func(ch *IRCChannel) string { return numericSortKey(int64(ch.NumUsers)) },
+	"created": func(ch *IRCChannel) string { return numericSortKey(ch.CreationTime) },
+	"topic":   func(ch *IRCChannel) string { return strings.ToLower(ch.Topic) },
+}
+
+// parseListQuery reads the list parameters. The second return value is false when
+// none were given, in which case the caller keeps returning the plain array.
+func parseListQuery(c *gin.Context) (ListQuery, bool) {
+	query := ListQuery{
+		Filter: c.Query("filter"),
+		Sort:   c.Query("sort"),
+		Cursor: c.Query("cursor"),
+	}
+	limit := c.Query("limit")
+	query.Limit, _ = strconv.Atoi(limit)
+
+	paged := query.Filter != "" || query.Sort != "" || query.Cursor != "" || limit != ""
+	return query, paged
+}
+
+// queryUsers filters, sorts and pages a user list
+func queryUsers(users []IRCUser, query ListQuery) (*ListPage, error) {
+	expr, err := parseUserFilter(query.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	sortKey, desc := splitSortKey(query.Sort, "nick")
+	keyFn, ok := userSortKeys[sortKey]
+	if !ok {
+		return nil, fmt.Errorf("unknown sort key '%s'", sortKey)
+	}
+
+	matched := make([]IRCUser, 0, len(users))
+	entries := make([]listEntry, 0, len(users))
+	for i := range users {
+		if !userMatchesFilter(&users[i], expr) {
+			continue
+		}
+		entries = append(entries, listEntry{
+			index: len(matched),
+			key:   keyFn(&users[i]),
+			tie:   strings.ToLower(users[i].Name) + "\x00" + users[i].ID,
+		})
+		matched = append(matched, users[i])
+	}
+
+	indexes, next, limit, err := pageListEntries(entries, sortKey, desc, query)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]IRCUser, 0, len(indexes))
+	for _, i := range indexes {
+		items = append(items, matched[i])
+	}
+	return &ListPage{Items: items, Total: len(entries), Limit: limit, Sort: query.Sort, NextCursor: next}, nil
+}
+
+// queryChannels filters, sorts and pages a channel list
+func queryChannels(channels []IRCChannel, query ListQuery) (*ListPage, error) {
+	expr, err := parseChannelFilter(query.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	sortKey, desc := splitSortKey(query.Sort, "name")
+	keyFn, ok := channelSortKeys[sortKey]
+	if !ok {
+		return nil, fmt.Errorf("unknown sort key '%s'", sortKey)
+	}
+
+	matched := make([]IRCChannel, 0, len(channels))
+	entries := make([]listEntry, 0, len(channels))
+	for i := range channels {
+		if !channelMatchesFilter(&channels[i], expr) {
+			continue
+		}
+		entries = append(entries, listEntry{
+			index: len(matched),
+			key:   keyFn(&channels[i]),
+			tie:   strings.ToLower(channels[i].Name),
+		})
+		matched = append(matched, channels[i])
+	}
+
+	indexes, next, limit, err := pageListEntries(entries, sortKey, desc, query)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]IRCChannel, 0, len(indexes))
+	for _, i := range indexes {
+		items = append(items, matched[i])
+	}
+	return &ListPage{Items: items, Total: len(entries), Limit: limit, Sort: query.Sort, NextCursor: next}, nil
+}
+
+// parseUserFilter parses a user filter expression and checks its fields and values
+func parseUserFilter(filter string) (*utils.FilterExpr, error) {
+	expr, err := utils.ParseFilter(filter)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter: %v", err)
+	}
+	for _, cond := range expr.Conditions() {
+		field, ok := userFilterFields[cond.Field]
+		if !ok {
+			return nil, fmt.Errorf("unknown user filter field '%s'", cond.Field)
+		}
+		if err := validateFilterCondition(field.kind, cond); err != nil {
+			return nil, err
+		}
+	}
+	return expr, nil
+}
+
+// parseChannelFilter parses a channel filter expression and checks its fields and values
+func parseChannelFilter(filter string) (*utils.FilterExpr, error) {
+	expr, err := utils.ParseFilter(filter)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter: %v", err)
+	}
+	for _, cond := range expr.Conditions() {
+		field, ok := channelFilterFields[cond.Field]
+		if !ok {
+			return nil, fmt.Errorf("unknown channel filter field '%s'", cond.Field)
+		}
+		if err := validateFilterCondition(field.kind, cond); err != nil {
+			return nil, err
+		}
+	}
+	return expr, nil
+}
+
+func userMatchesFilter(user *IRCUser, expr *utils.FilterExpr) bool {
+	return expr.Eval(func(cond utils.FilterCondition) bool {
+		field := userFilterFields[cond.Field]
+		return matchFilterCondition(field.kind, field.values(user), cond)
+	})
+}
+
+func channelMatchesFilter(channel *IRCChannel, expr *utils.FilterExpr) bool {
+	return expr.Eval(func(cond utils.FilterCondition) bool {
+		field := channelFilterFields[cond.Field]
+		return matchFilterCondition(field.kind, field.values(channel), cond)
+	})
+}
+
+// Helper functions
+
+func validateFilterCondition(kind filterKind, cond utils.FilterCondition) error {
+	if cond.Op != "" && cond.Op != "=" && kind != filterNumber && kind != filterDuration {
+		return fmt.Errorf("'%s' does not support '%s'", cond.Field, cond.Op)
+	}
+
+	switch kind {
+	case filterNumber:
+		if _, err := parseFilterNumber(cond.Value); err != nil {
+			return fmt.Errorf("'%s' needs a number, got '%s'", cond.Field, cond.Value)
+		}
+	case filterDuration:
+		if _, err := parseFilterSeconds(cond.Value); err != nil {
+			return fmt.Errorf("'%s' needs a duration such as 30m or 2h, got '%s'", cond.Field, cond.Value)
+		}
+	case filterBool:
+		if _, err := parseFilterBool(cond.Value); err != nil {
+			return fmt.Errorf("'%s' needs true or false, got '%s'", cond.Field, cond.Value)
+		}
+	case filterIP:
+		if strings.Contains(cond.Value, "/") {
+			if _, _, err := net.ParseCIDR(cond.Value); err != nil {
+				return fmt.Errorf("invalid CIDR range '%s'", cond.Value)
+			}
+		}
+	case filterModes:
+		if strings.Trim(cond.Value, "+-") == "" {
+			return fmt.Errorf("'%s' needs modes such as +o or -i", cond.Field)
+		}
+	}
+	return nil
+}
+
+func matchFilterCondition(kind filterKind, values []string, cond utils.FilterCondition) bool {
+	switch kind {
+	case filterExact:
+		for _, want := range strings.Split(cond.Value, ",") {
+			want = strings.TrimSpace(want)
+			for _, value := range values {
+				if utils.WildcardMatch(want, value) {
+					return true
+				}
+			}
+		}
+		return false
+
+	case filterContains:
+		for _, value := range values {
+			if strings.ContainsAny(cond.Value, "*?") {
+				if utils.WildcardMatch(cond.Value, value) {
+					return true
+				}
+			} else if strings.Contains(strings.ToLower(value), strings.ToLower(cond.Value)) {
+				return true
+			}
+		}
+		return false
+
+	case filterIP:
+		for _, value := range values {
+			if strings.Contains(cond.Value, "/") {
+				_, network, err := net.ParseCIDR(cond.Value)
+				ip := net.ParseIP(value)
+				if err == nil && ip != nil && network.Contains(ip) {
+					return true
+				}
+			} else if utils.WildcardMatch(cond.Value, value) {
+				return true
+			}
+		}
+		return false
+
+	case filterNumber, filterDuration:
+		var want float64
+		if kind == filterDuration {
+			seconds, _ := parseFilterSeconds(cond.Value)
+			want = float64(seconds)
+		} else {
+			want, _ = parseFilterNumber(cond.Value)
+		}
+		for _, value := range values {
+			got, err := strconv.ParseFloat(value, 64)
+			if err == nil && compareFilterNumber(got, cond.Op, want) {
+				return true
+			}
+		}
+		return false
+
+	case filterBool:
+		want, _ := parseFilterBool(cond.Value)
+		for _, value := range values {
+			if value == strconv.FormatBool(want) {
+				return true
+			}
+		}
+		return false
+
+	case filterModes:
+		modes := strings.Join(values, "")
+		adding := true
+		for _, r := range cond.Value {
+			switch r {
+			case '+':
+				adding = true
+			case '-':
+				adding = false
+			default:
+				if strings.ContainsRune(modes, r) != adding {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func compareFilterNumber(got float64, op string, want float64) bool {
+	switch op {
+	case ">":
+		return got > want
+	case "<":
+		return got < want
+	case ">=":
+		return got >= want
+	case "<=":
+		return got <= want
+	}
+	return got == want
+}
+
+func parseFilterNumber(s string) (float64, error) {
+	s = strings.TrimPrefix(strings.ToUpper(s), "AS")
+	return strconv.ParseFloat(s, 64)
+}
+
+// parseFilterSeconds parses a plain number of seconds or a duration such as 30m, 2h, 1d or 1w
+func parseFilterSeconds(s string) (int64, error) {
+	multipliers := map[byte]int64{'s': 1, 'm': 60, 'h': 3600, 'd': 86400, 'w': 604800}
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return 0, errors.New("empty duration")
+	}
+
+	multiplier := int64(1)
+	if m, ok := multipliers[s[len(s)-1]]; ok {
+		multiplier = m
+		s = s[:len(s)-1]
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return n * multiplier, nil
+}
+
+func parseFilterBool(s string) (bool, error) {
+	switch strings.ToLower(s) {
+	case "true", "yes", "1":
+		return true, nil
+	case "false", "no", "0":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid boolean '%s'", s)
+}
+
+func nonEmpty(values ...string) []string {
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		if v != "" {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// userChannelNames returns the user's channels without membership prefixes
+func userChannelNames(u *IRCUser) []string {
+	names := make([]string, 0, len(u.Channels))
+	for _, ch := range u.Channels {
+		names = append(names, strings.TrimLeft(ch, "~&@%+!"))
+	}
+	return names
+}
+
+func splitSortKey(sortParam, defaultKey string) (string, bool) {
+	if sortParam == "" {
+		return defaultKey, false
+	}
+	if strings.HasPrefix(sortParam, "-") {
+		return sortParam[1:], true
+	}
+	return sortParam, false
+}
+
+// numericSortKey formats a number so that string order matches numeric order
+func numericSortKey(n int64) string {
+	if n < 0 {
+		n = 0
+	}
+	return fmt.Sprintf("%020d", n)
+}
+
+// ipSortKey orders IPv4 before IPv6 and both numerically
+func ipSortKey(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return "z" + ip
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return fmt.Sprintf("4%x", []byte(v4))
+	}
+	return fmt.Sprintf("6%x", []byte(parsed.To16()))
+}
+
+// listEntry is an item position with its sort key; tie makes the order total
+type listEntry struct {
+	index int
+	key   string
+	tie   string
+}
+
+// pageListEntries sorts the entries and returns the indexes of the requested page.
+// Cursors hold the sort key and tie-breaker of the last item, so pages stay stable
+// while users connect and quit between requests.
+func pageListEntries(entries []listEntry, sortKey string, desc bool, query ListQuery) ([]int, string, int, error) {
+	limit := query.Limit
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	if limit > maxListLimit {
+		limit = maxListLimit
+	}
+
+	less := func(a, b listEntry) bool {
+		if a.key != b.key {
+			return a.key < b.key
+		}
+		return a.tie < b.tie
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if desc {
+			return less(entries[j], entries[i])
+		}
+		return less(entries[i], entries[j])
+	})
+
+	start := 0
+	if query.Cursor != "" {
+		after, err := decodeListCursor(query.Cursor, query.Sort)
+		if err != nil {
+			return nil, "", limit, err
+		}
+		start = sort.Search(len(entries), func(i int) bool {
+			if desc {
+				return less(entries[i], after)
+			}
+			return less(after, entries[i])
+		})
+	}
+
+	end := start + limit
+	if end > len(entries) {
+		end = len(entries)
+	}
+
+	indexes := make([]int, 0, end-start)
+	for _, e := range entries[start:end] {
+		indexes = append(indexes, e.index)
+	}
+
+	next := ""
+	if end < len(entries) {
+		next = encodeListCursor(query.Sort, entries[end-1])
+	}
+	return indexes, next, limit, nil
+}
+
+func encodeListCursor(sortParam string, last listEntry) string {
+	raw := sortParam + "\x01" + last.key + "\x01" + last.tie
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeListCursor(cursor, sortParam string) (listEntry, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return listEntry{}, errInvalidCursor
+	}
+	parts := strings.SplitN(string(raw), "\x01", 3)
+	if len(parts) != 3 || parts[0] != sortParam {
+		return listEntry{}, errInvalidCursor
+	}
+	return listEntry{key: parts[1], tie: parts[2]}, nil
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"reflect"
+	"testing"
+)
+
+func TestListCursorRoundTrip(t *testing.T) {
+	tests := []struct {
+		sort string
+		last listEntry
+	}{
+		{"", listEntry{key: "alice", tie: "alice\x00001AAAAAA"}},
+		{"-idle", listEntry{key: numericSortKey(300), tie: "bob\x00001AAAAAB"}},
+		{"ip", listEntry{key: ipSortKey("2001:db8::1"), tie: "carol"}},
+		{"topic", listEntry{key: "welcome", tie: "#chan\x01with separator"}},
+		{"name", listEntry{}},
+	}
+
+	for _, tt := range tests {
+		cursor := encodeListCursor(tt.sort, tt.last)
+		got, err := decodeListCursor(cursor, tt.sort)
+		if err != nil {
+			t.Fatalf("decodeListCursor(%q, %q) error = %v", cursor, tt.sort, err)
+		}
+		if got.key != tt.last.key || got.tie != tt.last.tie {
+			t.Errorf("round trip of %+v with sort %q = %+v", tt.last, tt.sort, got)
+		}
+	}
+}
+
+func TestDecodeListCursorInvalid(t *testing.T) {
+	valid := encodeListCursor("nick", listEntry{key: "alice", tie: "alice"})
+	tests := []struct {
+		name   string
+		cursor string
+		sort   string
+	}{
+		{"not base64", "!!!", "nick"},
+		{"different sort", valid, "-nick"},
+		{"missing parts", base64.RawURLEncoding.EncodeToString([]byte("nick\x01alice")), "nick"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodeListCursor(tt.cursor, tt.sort); err != errInvalidCursor {
+				t.Errorf("decodeListCursor() error = %v, want %v", err, errInvalidCursor)
+			}
+		})
+	}
+}
+
+func TestPageListEntries(t *testing.T) {
+	newEntries := func() []listEntry {
+		return []listEntry{
+			{index: 0, key: "b", tie: "2"},
+			{index: 1, key: "a", tie: "1"},
+			{index: 2, key: "b", tie: "1"},
+			{index: 3, key: "c", tie: "1"},
+			{index: 4, key: "a", tie: "2"},
+		}
+	}
+
+	tests := []struct {
+		name  string
+		sort  string
+		desc  bool
+		limit int
+		want  [][]int
+	}{
+		{"ascending in pages of two", "key", false, 2, [][]int{{1, 4}, {2, 0}, {3}}},
+		{"descending in pages of two", "-key", true, 2, [][]int{{3, 0}, {2, 4}, {1}}},
+		{"single page", "key", false, 10, [][]int{{1, 4, 2, 0, 3}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := ListQuery{Sort: tt.sort, Limit: tt.limit}
+			var pages [][]int
+			for {
+				indexes, next, _, err := pageListEntries(newEntries(), "key", tt.desc, query)
+				if err != nil {
+					t.Fatalf("pageListEntries() error = %v", err)
+				}
+				pages = append(pages, indexes)
+				if next == "" {
+					break
+				}
+				query.Cursor = next
+			}
+			if !reflect.DeepEqual(pages, tt.want) {
+				t.Errorf("pages = %v, want %v", pages, tt.want)
+			}
+		})
+	}
+
+	// A cursor stays valid when the item it points at disappears between requests
+	query := ListQuery{Sort: "key", Limit: 2}
+	_, next, _, _ := pageListEntries(newEntries(), "key", false, query)
+	entries := newEntries()
+	entries = append(entries[:4], entries[5:]...) // Drop {a 2}, the last item of the first page
+	query.Cursor = next
+	indexes, _, _, err := pageListEntries(entries, "key", false, query)
+	if err != nil {
+		t.Fatalf("pageListEntries() error = %v", err)
+	}
+	if !reflect.DeepEqual(indexes, []int{2, 0}) {
+		t.Errorf("page after a removed item = %v, want [2 0]", indexes)
+	}
+
+	// Limits are clamped
+	_, _, limit, _ := pageListEntries(newEntries(), "key", false, ListQuery{Limit: maxListLimit + 1})
+	if limit != maxListLimit {
+		t.Errorf("limit = %d, want %d", limit, maxListLimit)
+	}
+	_, _, limit, _ = pageListEntries(newEntries(), "key", false, ListQuery{})
+	if limit != defaultListLimit {
+		t.Errorf("limit = %d, want %d", limit, defaultListLimit)
+	}
+}
+
+func TestNumericAndIPSortKeys(t *testing.T) {
+	if !(numericSortKey(9) < numericSortKey(10)) || numericSortKey(-5) != numericSortKey(0) {
+		t.Error("numericSortKey does not order numerically")
+	}
+	ordered := []string{"9.0.0.1", "10.0.0.1", "2001:db8::1", "not-an-ip"}
+	for i := 1; i < len(ordered); i++ {
+		if !(ipSortKey(ordered[i-1]) < ipSortKey(ordered[i])) {
+			t.Errorf("ipSortKey(%s) >= ipSortKey(%s)", ordered[i-1], ordered[i])
+		}
+	}
+}
@@ -0,0 +1,702 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/api/middleware"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/auth"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/database"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/database/models"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/rpc"
+	"gorm.io/gorm"
+)
+
+// Message types
+const (
+	messageTypeNotice  = "notice"  // Notice to a nick or the members of a channel
+	messageTypePrivmsg = "privmsg" // Private message to a nick or the members of a channel
+	messageTypeGlobal  = "global"  // Notice to every user on the network
+	messageTypeWallops = "wallops" // Notice to users with user mode +w
+	messageTypeGlobops = "globops" // Notice to IRC operators
+)
+
+// maxMessageLines is the most lines a single send may contain
+const maxMessageLines = 10
+
+// maxPreviewRecipients is how many recipient nicks a preview lists
+const maxPreviewRecipients = 50
+
+// messageJobThreshold is the number of recipients above which a message is
+// delivered to each of them in a background job
+const messageJobThreshold = 20
+
+// How a message was delivered
+const (
+	messageDeliveryDirect    = "direct"    // To a nick, or to each recipient before the request returned
+	messageDeliveryBroadcast = "broadcast" // Once to the channel or network
+	messageDeliveryJob       = "job"       // To each recipient in a background job
+)
+
+// messageBroadcastMethods send a network message in one call. Servers without
+// them answer with an error and the message goes to each recipient instead.
+var messageBroadcastMethods = map[string]string{
+	messageTypeGlobal:  "message.send_global",
+	messageTypeWallops: "message.send_wallops",
+	messageTypeGlobops: "message.send_globops",
+}
+
+// MessageRequest represents a request to send or preview a message
+type MessageRequest struct {
+	Type       string            `json:"type"`        // notice, privmsg, global, wallops or globops; defaults to the template's type
+	Target     string            `json:"target"`      // Nick or channel for notice and privmsg
+	Message    string            `json:"message"`     // Message text, may contain {variables}
+	TemplateID *uint             `json:"template_id"` // Use a message template instead of message
+	Variables  map[string]string `json:"variables"`   // Values for template variables
+}
+
+// MessageTemplateRequest represents a request to create/update a message template
+type MessageTemplateRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+	Type        string `json:"type"`
+	Body        string `json:"body" binding:"required"`
+}
+
+// MessageResult is the outcome of sending a message
+type MessageResult struct {
+	Type       string   `json:"type"`
+	Target     string   `json:"target,omitempty"`
+	Delivery   string   `json:"delivery"`         // direct, broadcast or job
+	Recipients int      `json:"recipients"`       // Not counted for broadcasts
+	Sent       int      `json:"sent"`
+	Failed     []string `json:"failed,omitempty"` // Nicks the message could not be delivered to
+	Lines      []string `json:"lines"`            // The message as sent, with {nick} left for each recipient
+	LinesSent  int      `json:"lines_sent"`       // Lines of a broadcast that went out
+	Error      string   `json:"error,omitempty"`  // Why a broadcast stopped before its last line
+	JobID      *uint    `json:"job_id,omitempty"` // The background job delivering the message
+}
+
+var (
+	errMessageType     = errors.New("type must be notice, privmsg, global, wallops or globops")
+	errMessageTarget   = errors.New("a nick or channel target is required for notice and privmsg")
+	errMessageEmpty    = errors.New("message is empty")
+	errMessageTooLong  = fmt.Errorf("a message may have at most %d lines", maxMessageLines)
+	errMessageTemplate = errors.New("message template not found")
+	errMessageJob      = errors.New("failed to start the delivery job")
+)
+
+// SendMessage sends a notice or privmsg to a nick or channel, or a global notice,
+// wallops or globops to the network
+func SendMessage(c *gin.Context) {
+	user := middleware.GetCurrentUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Not authenticated"})
+		return
+	}
+
+	var req MessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Resolve the template first, it may set the message type
+	if _, _, err := resolveMessageText(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if isNetworkMessage(req.Type) && !auth.UserCan(user, models.PermissionSendGlobal) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+		return
+	}
+
+	result, err := DeliverMessage(&req, user.Username)
+	if err != nil {
+		status := http.StatusBadRequest
+		if result != nil {
+			status = http.StatusInternalServerError
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	details := map[string]string{
+		"type":       result.Type,
+		"target":     result.Target,
+		"delivery":   result.Delivery,
+		"recipients": strconv.Itoa(result.Recipients),
+		"sent":       strconv.Itoa(result.Sent),
+	}
+	if result.JobID != nil {
+		details["job_id"] = strconv.FormatUint(uint64(*result.JobID), 10)
+	}
+	if result.Error != "" {
+		details["error"] = result.Error
+	}
+	if req.TemplateID != nil {
+		details["template_id"] = strconv.FormatUint(uint64(*req.TemplateID), 10)
+	}
+	logAction(c, user, "send_message", details)
+
+	c.JSON(http.StatusOK, result)
+}
+
+// PreviewMessage renders a message and counts its recipients without sending it
+func PreviewMessage(c *gin.Context) {
+	var req MessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	currentUser := middleware.GetCurrentUser(c)
+	if currentUser == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Not authenticated"})
+		return
+	}
+
+	text, _, err := resolveMessageText(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !isMessageType(req.Type) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errMessageType.Error()})
+		return
+	}
+	// Previews list the recipients, so they need the same permission as sending
+	if isNetworkMessage(req.Type) && !auth.UserCan(currentUser, models.PermissionSendGlobal) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+		return
+	}
+	lines, missing, err := renderMessageLines(text, req.Target, currentUser.Username, req.Variables)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	recipients, err := messageRecipients(req.Type, req.Target)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sample := recipients
+	if len(sample) > maxPreviewRecipients {
+		sample = sample[:maxPreviewRecipients]
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"type":       req.Type,
+		"target":     req.Target,
+		"recipients": len(recipients),
+		"sample":     sample,
+		"lines":      lines,
+		"missing":    missing,
+	})
+}
+
+// GetMessageTemplates returns all message templates
+func GetMessageTemplates(c *gin.Context) {
+	db := database.Get()
+
+	var templates []models.MessageTemplate
+	if err := db.Order("use_count DESC, name ASC").Find(&templates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch message templates"})
+		return
+	}
+
+	c.JSON(http.StatusOK, templates)
+}
+
+// CreateMessageTemplate creates a new message template
+func CreateMessageTemplate(c *gin.Context) {
+	user := middleware.GetCurrentUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Not authenticated"})
+		return
+	}
+
+	var req MessageTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Type != "" && !isMessageType(req.Type) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errMessageType.Error()})
+		return
+	}
+	if isNetworkMessage(req.Type) && !auth.UserCan(user, models.PermissionSendGlobal) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+		return
+	}
+
+	template := models.MessageTemplate{
+		Name:              req.Name,
+		Description:       req.Description,
+		Type:              req.Type,
+		Body:              req.Body,
+		CreatedBy:         user.ID,
+		CreatedByUsername: user.Username,
+	}
+
+	db := database.Get()
+	if err := db.Create(&template).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create message template"})
+		return
+	}
+
+	logAction(c, user, "create_message_template", map[string]string{
+		"name": req.Name,
+	})
+
+	c.JSON(http.StatusCreated, template)
+}
+
+// UpdateMessageTemplate updates an existing message template
+func UpdateMessageTemplate(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	db := database.Get()
+
+	var template models.MessageTemplate
+	if err := db.First(&template, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message template not found"})
+		return
+	}
+
+	var req MessageTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Type != "" && !isMessageType(req.Type) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errMessageType.Error()})
+		return
+	}
+	// Editing a network template changes what its scheduled uses broadcast
+	currentUser := middleware.GetCurrentUser(c)
+	if (isNetworkMessage(req.Type) || isNetworkMessage(template.Type)) && !auth.UserCan(currentUser, models.PermissionSendGlobal) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+		return
+	}
+
+	template.Name = req.Name
+	template.Description = req.Description
+	template.Type = req.Type
+	template.Body = req.Body
+
+	if err := db.Save(&template).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update message template"})
+		return
+	}
+
+	if currentUser != nil {
+		logAction(c, currentUser, "update_message_template", map[string]string{
+			"id":   c.Param("id"),
+			"name": req.Name,
+		})
+	}
+
+	c.JSON(http.StatusOK, template)
+}
+
+// DeleteMessageTemplate deletes a message template
+func DeleteMessageTemplate(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	db := database.Get()
+	result := db.Delete(&models.MessageTemplate{}, id)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete message template"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message template not found"})
+		return
+	}
+
+	currentUser := middleware.GetCurrentUser(c)
+	if currentUser != nil {
+		logAction(c, currentUser, "delete_message_template", map[string]string{
+			"id": c.Param("id"),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Message template deleted"})
+}
+
+// GetMessageJobs returns the most recent background message deliveries
+func GetMessageJobs(c *gin.Context) {
+	var jobs []models.MessageJob
+	if err := database.Get().Order("created_at DESC").Limit(50).Find(&jobs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch message jobs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, jobs)
+}
+
+// GetMessageJob returns a background message delivery and its progress
+func GetMessageJob(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var job models.MessageJob
+	if err := database.Get().First(&job, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message job not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// DeliverMessage renders and sends a message on behalf of sender. It is used by the
+// API and by scheduled "message" commands. Nicks get the message directly; channels
+// and the network get it in one call per line when the server supports that, and
+// otherwise each recipient gets it, in a background job when there are many.
+// A nil result means the request was invalid; otherwise the error reports that
+// nothing could be delivered.
+func DeliverMessage(req *MessageRequest, sender string) (*MessageResult, error) {
+	text, template, err := resolveMessageText(req)
+	if err != nil {
+		return nil, err
+	}
+	if !isMessageType(req.Type) {
+		return nil, errMessageType
+	}
+	req.Target = strings.TrimSpace(req.Target)
+	if !isNetworkMessage(req.Type) && req.Target == "" {
+		return nil, errMessageTarget
+	}
+	lines, _, err := renderMessageLines(text, req.Target, sender, req.Variables)
+	if err != nil {
+		return nil, err
+	}
+
+	if template != nil {
+		database.Get().Model(template).UpdateColumn("use_count", gorm.Expr("use_count + 1"))
+	}
+
+	result := &MessageResult{Type: req.Type, Target: req.Target, Lines: lines, Delivery: messageDeliveryDirect}
+	method := messageSendMethod(req.Type)
+
+	if !isNetworkMessage(req.Type) && !strings.HasPrefix(req.Target, "#") {
+		result.Recipients = 1
+		if err := sendMessageLines(method, req.Target, "", lines); err != nil {
+			result.Failed = []string{req.Target}
+			return result, err
+		}
+		result.Sent = 1
+		return result, nil
+	}
+
+	// A broadcast cannot fill in {nick} for each recipient
+	if !messageMentionsNick(lines) {
+		if sent, err := broadcastMessage(req.Type, req.Target, lines); sent > 0 {
+			result.Delivery = messageDeliveryBroadcast
+			result.LinesSent = sent
+			if err != nil {
+				result.Error = fmt.Sprintf("stopped after %d of %d lines: %v", sent, len(lines), err)
+			}
+			return result, nil
+		}
+	}
+
+	recipients, err := messageRecipients(req.Type, req.Target)
+	if err != nil {
+		return nil, err
+	}
+	result.Recipients = len(recipients)
+	prefix := messagePrefix(req.Type, req.Target)
+
+	if len(recipients) > messageJobThreshold {
+		job := models.MessageJob{
+			Type:                req.Type,
+			Target:              req.Target,
+			Lines:               strings.Join(lines, "\n"),
+			Status:              "running",
+			Recipients:          len(recipients),
+			RequestedByUsername: sender,
+		}
+		if err := database.Get().Create(&job).Error; err != nil {
+			return result, errMessageJob
+		}
+		go processMessageJob(job.ID, method, prefix, lines, recipients)
+
+		result.Delivery = messageDeliveryJob
+		result.JobID = &job.ID
+		return result, nil
+	}
+
+	var lastErr error
+	for _, nick := range recipients {
+		if err := sendMessageLines(method, nick, prefix, lines); err != nil {
+			lastErr = err
+			result.Failed = append(result.Failed, nick)
+			continue
+		}
+		result.Sent++
+	}
+
+	if result.Sent == 0 && lastErr != nil {
+		return result, lastErr
+	}
+	return result, nil
+}
+
+// Helper functions
+
+func isMessageType(messageType string) bool {
+	switch messageType {
+	case messageTypeNotice, messageTypePrivmsg, messageTypeGlobal, messageTypeWallops, messageTypeGlobops:
+		return true
+	}
+	return false
+}
+
+// isNetworkMessage reports whether a message type goes to many users at once
+func isNetworkMessage(messageType string) bool {
+	return messageType == messageTypeGlobal || messageType == messageTypeWallops || messageType == messageTypeGlobops
+}
+
+// resolveMessageText returns the message text, loading the template when one is given
+func resolveMessageText(req *MessageRequest) (string, *models.MessageTemplate, error) {
+	if req.TemplateID == nil {
+		if strings.TrimSpace(req.Message) == "" {
+			return "", nil, errMessageEmpty
+		}
+		return req.Message, nil, nil
+	}
+
+	var template models.MessageTemplate
+	if err := database.Get().First(&template, *req.TemplateID).Error; err != nil {
+		return "", nil, errMessageTemplate
+	}
+	if req.Type == "" {
+		req.Type = template.Type
+	}
+	return template.Body, &template, nil
+}
+
+// renderMessageLines fills in the template variables and splits the text into lines.
+// {nick} is kept so it can be filled in for each recipient.
+func renderMessageLines(text, target, sender string, variables map[string]string) ([]string, []string, error) {
+	now := time.Now()
+	values := map[string]string{
+		"target": target,
+		"sender": sender,
+		"date":   now.Format("2006-01-02"),
+		"time":   now.Format("15:04 MST"),
+		"nick":   "{nick}",
+	}
+	for key, value := range variables {
+		if key != "nick" {
+			values[key] = value
+		}
+	}
+
+	rendered, missing := renderPlaceholders(text, values)
+
+	lines := make([]string, 0)
+	for _, line := range strings.Split(rendered, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) == 0 {
+		return nil, missing, errMessageEmpty
+	}
+	if len(lines) > maxMessageLines {
+		return nil, missing, errMessageTooLong
+	}
+	return lines, missing, nil
+}
+
+// messageSendMethod returns the RPC method delivering a message to a single recipient
+func messageSendMethod(messageType string) string {
+	if messageType == messageTypePrivmsg {
+		return "message.send_privmsg"
+	}
+	return "message.send_notice"
+}
+
+// sendMessageLines sends every line to a nick, filling in {nick}. The lines go
+// over one connection so the recipient gets them in order.
+func sendMessageLines(method, nick, prefix string, lines []string) error {
+	_, err := rpc.GetManager().WithRetry(func(client *rpc.Client) (interface{}, error) {
+		for _, line := range lines {
+			text, _ := renderPlaceholders(line, map[string]string{"nick": nick})
+			if _, err := client.Query(method, map[string]interface{}{
+				"nick":    nick,
+				"message": prefix + text,
+			}, false); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	})
+	return err
+}
+
+// broadcastMessage sends the lines to a channel or the network in one call per
+// line and returns how many went out. It stops at the first failed line, since the
+// rest would arrive out of context. Nothing is sent when the server cannot broadcast:
+// message.send_notice and message.send_privmsg only address nicks on servers
+// that answer "not found" for a channel, and older servers lack the network methods.
+func broadcastMessage(messageType, target string, lines []string) (int, error) {
+	method, params := messageSendMethod(messageType), map[string]interface{}{"nick": target}
+	if isNetworkMessage(messageType) {
+		method, params = messageBroadcastMethods[messageType], map[string]interface{}{}
+	}
+
+	client, err := rpc.GetManager().GetActive()
+	if err != nil {
+		return 0, err
+	}
+	for i, line := range lines {
+		params["message"] = line
+		if _, err := client.Query(method, params, false); err != nil {
+			if i > 0 {
+				log.Printf("[Messages] %s to %s failed after %d of %d lines: %v", messageType, target, i, len(lines), err)
+			}
+			return i, err
+		}
+	}
+	return len(lines), nil
+}
+
+// processMessageJob delivers a message to each recipient, saving the progress as it goes
+func processMessageJob(jobID uint, method, prefix string, lines, recipients []string) {
+	db := database.Get()
+	var job models.MessageJob
+	if err := db.First(&job, jobID).Error; err != nil {
+		log.Printf("[Messages] Job %d not found: %v", jobID, err)
+		return
+	}
+
+	failed := make([]string, 0)
+	lastSave := time.Now()
+	for i, nick := range recipients {
+		if err := sendMessageLines(method, nick, prefix, lines); err != nil {
+			failed = append(failed, nick)
+			job.Failed++
+			job.Error = err.Error()
+		} else {
+			job.Sent++
+		}
+		if time.Since(lastSave) > time.Second || i == len(recipients)-1 {
+			job.FailedNicks = strings.Join(failed, " ")
+			db.Save(&job)
+			lastSave = time.Now()
+		}
+	}
+
+	now := time.Now()
+	job.CompletedAt = &now
+	job.Status = "completed"
+	if job.Sent == 0 && job.Failed > 0 {
+		job.Status = "failed"
+	}
+	job.FailedNicks = strings.Join(failed, " ")
+	db.Save(&job)
+}
+
+// messageMentionsNick reports whether any line still has a {nick} placeholder
+func messageMentionsNick(lines []string) bool {
+	for _, line := range lines {
+		if strings.Contains(line, "{nick}") {
+			return true
+		}
+	}
+	return false
+}
+
+// messagePrefix marks messages that are delivered to each recipient individually
+func messagePrefix(messageType, target string) string {
+	switch messageType {
+	case messageTypeGlobal:
+		return "[Global] "
+	case messageTypeWallops:
+		return "[Wallops] "
+	case messageTypeGlobops:
+		return "[Globops] "
+	}
+	if strings.HasPrefix(target, "#") {
+		return "[" + target + "] "
+	}
+	return ""
+}
+
+// messageRecipients returns the nicks a message goes to when it is delivered to each
+// of them: the members of a channel, or every matching user except services.
+func messageRecipients(messageType, target string) ([]string, error) {
+	switch messageType {
+	case messageTypeNotice, messageTypePrivmsg:
+		target = strings.TrimSpace(target)
+		if target == "" {
+			return nil, errMessageTarget
+		}
+		if !strings.HasPrefix(target, "#") {
+			return []string{target}, nil
+		}
+
+		result, err := rpc.GetManager().WithRetry(func(client *rpc.Client) (interface{}, error) {
+			return client.Channel().Get(target, 2)
+		})
+		if err != nil {
+			return nil, err
+		}
+		if result == nil {
+			return nil, fmt.Errorf("channel %s not found", target)
+		}
+		channel := parseChannel(result)
+		nicks := make([]string, 0, len(channel.Members))
+		for _, member := range channel.Members {
+			nicks = append(nicks, member.Name)
+		}
+		return nicks, nil
+
+	case messageTypeGlobal, messageTypeWallops, messageTypeGlobops:
+		list, err := fetchFullUserList()
+		if err != nil {
+			return nil, err
+		}
+		nicks := make([]string, 0, len(list))
+		for _, item := range list {
+			u := parseUser(item)
+			if u == nil || strings.Contains(u.Modes, "S") {
+				continue
+			}
+			if messageType == messageTypeWallops && !strings.Contains(u.Modes, "w") {
+				continue
+			}
+			if messageType == messageTypeGlobops && !strings.Contains(u.Modes, "o") {
+				continue
+			}
+			nicks = append(nicks, u.Name)
+		}
+		return nicks, nil
+	}
+
+	return nil, errMessageType
+}
@@ -0,0 +1,296 @@
+package handlers
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/api/middleware"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/auth"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/database"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/database/models"
+)
+
+// NoteRequest represents a request to create or update a note
+type NoteRequest struct {
+	Nick        string `json:"nick"`
+	IP          string `json:"ip"`
+	Account     string `json:"account"`
+	Note        string `json:"note" binding:"required"`
+	BanType     string `json:"ban_type"`
+	BanMask     string `json:"ban_mask"`
+	WatchlistID *uint  `json:"watchlist_id"`
+}
+
+var (
+	errNoteTarget    = errors.New("a note needs a nick, IP, account, ban mask or watch list entry")
+	errNoteWatchlist = errors.New("watch list entry not found")
+)
+
+// GetNotes returns notes, optionally filtered by nick, IP, account, ban mask or watch list entry
+func GetNotes(c *gin.Context) {
+	db := database.Get()
+	query := db.Model(&models.Note{})
+
+	// Identifiers match any of nick, IP or account, like the user dossier
+	conditions := []string{}
+	args := []interface{}{}
+	if nick := c.Query("nick"); nick != "" {
+		conditions = append(conditions, "nick = ?")
+		args = append(args, nick)
+	}
+	if ip := c.Query("ip"); ip != "" {
+		conditions = append(conditions, "ip = ?")
+		args = append(args, ip)
+	}
+	if account := c.Query("account"); account != "" {
+		conditions = append(conditions, "account = ?")
+		args = append(args, account)
+	}
+	if len(conditions) > 0 {
+		query = query.Where(strings.Join(conditions, " OR "), args...)
+	}
+
+	if mask := c.Query("ban_mask"); mask != "" {
+		query = query.Where("ban_mask = ?", mask)
+	}
+	if watchlistID := c.Query("watchlist_id"); watchlistID != "" {
+		query = query.Where("watchlist_id = ?", watchlistID)
+	}
+	if search := c.Query("q"); search != "" {
+		query = query.Where("note LIKE ?", "%"+search+"%")
+	}
+	if author := c.Query("created_by"); author != "" {
+		query = query.Where("created_by = ?", author)
+	}
+
+	limit := 200
+	if l, err := strconv.Atoi(c.Query("limit")); err == nil && l > 0 && l <= 1000 {
+		limit = l
+	}
+
+	var notes []models.Note
+	if err := query.Order("created_at DESC").Limit(limit).Find(&notes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, notes)
+}
+
+// GetNote returns a single note
+func GetNote(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var note models.Note
+	if err := database.Get().First(&note, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, note)
+}
+
+// CreateNote creates a note on a nick, IP or account, optionally attached to a ban or watch list entry
+func CreateNote(c *gin.Context) {
+	user := middleware.GetCurrentUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Not authenticated"})
+		return
+	}
+
+	var req NoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	note := models.Note{CreatedBy: user.Username}
+	if err := applyNoteRequest(&note, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := database.Get().Create(&note).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create note"})
+		return
+	}
+
+	logAction(c, user, "create_note", map[string]string{
+		"note_id": strconv.FormatUint(uint64(note.ID), 10),
+		"nick":    note.Nick,
+		"ip":      note.IP,
+		"account": note.Account,
+	})
+
+	c.JSON(http.StatusCreated, note)
+}
+
+// UpdateNote updates a note; only its author or users with manage_users may do so
+func UpdateNote(c *gin.Context) {
+	user := middleware.GetCurrentUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Not authenticated"})
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	db := database.Get()
+	var note models.Note
+	if err := db.First(&note, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"})
+		return
+	}
+
+	if !canEditNote(user, &note) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the author or a user manager can edit this note"})
+		return
+	}
+
+	var req NoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := applyNoteRequest(&note, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	note.UpdatedBy = user.Username
+
+	if err := db.Save(&note).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update note"})
+		return
+	}
+
+	logAction(c, user, "update_note", map[string]string{
+		"note_id": strconv.FormatUint(uint64(note.ID), 10),
+	})
+
+	c.JSON(http.StatusOK, note)
+}
+
+// DeleteNote deletes a note; only its author or users with manage_users may do so
+func DeleteNote(c *gin.Context) {
+	user := middleware.GetCurrentUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Not authenticated"})
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	db := database.Get()
+	var note models.Note
+	if err := db.First(&note, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"})
+		return
+	}
+
+	if !canEditNote(user, &note) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the author or a user manager can delete this note"})
+		return
+	}
+
+	if err := db.Delete(&note).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete note"})
+		return
+	}
+
+	logAction(c, user, "delete_note", map[string]string{
+		"note_id":    strconv.FormatUint(uint64(note.ID), 10),
+		"created_by": note.CreatedBy,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Note deleted"})
+}
+
+// Helper functions
+
+func canEditNote(user *models.User, note *models.Note) bool {
+	return note.CreatedBy == user.Username || auth.UserCan(user, models.PermissionManageUsers)
+}
+
+// applyNoteRequest copies a request onto a note. Notes attached from a ban on an
+// IP or from a watch list entry inherit the IP/nick/account so they show up on the live user.
+func applyNoteRequest(note *models.Note, req *NoteRequest) error {
+	note.Nick = strings.TrimSpace(req.Nick)
+	note.IP = strings.TrimSpace(req.IP)
+	note.Account = strings.TrimSpace(req.Account)
+	note.Note = req.Note
+	note.BanType = req.BanType
+	note.BanMask = strings.TrimSpace(req.BanMask)
+	note.WatchlistID = req.WatchlistID
+
+	if note.BanMask != "" && note.IP == "" {
+		host := note.BanMask
+		if at := strings.LastIndex(host, "@"); at >= 0 {
+			host = host[at+1:]
+		}
+		if ip := net.ParseIP(host); ip != nil {
+			note.IP = ip.String()
+		}
+	}
+
+	if note.WatchlistID != nil {
+		var watched models.WatchedUser
+		if err := database.Get().First(&watched, *note.WatchlistID).Error; err != nil {
+			return errNoteWatchlist
+		}
+		if note.Nick == "" && !strings.ContainsAny(watched.Nick, "*?") {
+			note.Nick = watched.Nick
+		}
+		if note.IP == "" && net.ParseIP(watched.IP) != nil {
+			note.IP = watched.IP
+		}
+		if note.Account == "" {
+			note.Account = watched.Account
+		}
+	}
+
+	if note.Nick == "" && note.IP == "" && note.Account == "" && note.BanMask == "" && note.WatchlistID == nil {
+		return errNoteTarget
+	}
+	return nil
+}
+
+// findUserNotes returns notes whose nick, IP or account matches a live user
+func findUserNotes(nick, ip, account string) []models.Note {
+	conditions := []string{}
+	args := []interface{}{}
+	if nick != "" {
+		conditions = append(conditions, "nick = ?")
+		args = append(args, nick)
+	}
+	if ip != "" {
+		conditions = append(conditions, "ip = ?")
+		args = append(args, ip)
+	}
+	if account != "" {
+		conditions = append(conditions, "account = ?")
+		args = append(args, account)
+	}
+	if len(conditions) == 0 {
+		return nil
+	}
+
+	var notes []models.Note
+	database.Get().Where(strings.Join(conditions, " OR "), args...).Order("created_at DESC").Find(&notes)
+	return notes
+}
@@ -337,6 +337,7 @@ func GetAvailableHooks(c *gin.Context) {
 		{"type": hooks.HookAPIRequest, "name": "HookAPIRequest", "category": "API", "description": "Called before API requests"},
 		{"type": hooks.HookAPIResponse, "name": "HookAPIResponse", "category": "API", "description": "Called after API responses"},
 		{"type": hooks.HookWebhookReceived, "name": "HookWebhookReceived", "category": "Webhooks", "description": "Called when a webhook is received from UnrealIRCd"},
+		{"type": hooks.HookLogEvent, "name": "HookLogEvent", "category": "Webhooks", "description": "Called for each log event received over the RPC log subscription"},
 	}
 
 	c.JSON(http.StatusOK, availableHooks)
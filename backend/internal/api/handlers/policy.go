@@ -0,0 +1,485 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/api/middleware"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/database"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/database/models"
+	"gopkg.in/yaml.v3"
+)
+
+// Policy plan actions
+const (
+	policyActionCreate = "create"
+	policyActionUpdate = "update"
+	policyActionDelete = "delete"
+)
+
+// policyKindChannelTemplate is the plan kind used for channel templates
+const policyKindChannelTemplate = "channel_template"
+
+// configSetBy is the set_by value UnrealIRCd uses for entries from the config file;
+// those can't be removed over RPC so they are never pruned
+const configSetBy = "-config-"
+
+// PolicyDocument is the desired network state
+type PolicyDocument struct {
+	ServerBans       []BanTransferItem       `json:"server_bans" yaml:"server_bans"`
+	NameBans         []BanTransferItem       `json:"name_bans" yaml:"name_bans"`
+	BanExceptions    []BanTransferItem       `json:"ban_exceptions" yaml:"ban_exceptions"`
+	Spamfilters      []BanTransferItem       `json:"spamfilters" yaml:"spamfilters"`
+	ChannelTemplates []PolicyChannelTemplate `json:"channel_templates" yaml:"channel_templates"`
+}
+
+// PolicyChannelTemplate is the desired state of a ChannelTemplate, matched by name
+type PolicyChannelTemplate struct {
+	Name        string                 `json:"name" yaml:"name"`
+	Description string                 `json:"description,omitempty" yaml:"description,omitempty"`
+	Modes       string                 `json:"modes,omitempty" yaml:"modes,omitempty"`
+	Topic       string                 `json:"topic,omitempty" yaml:"topic,omitempty"`
+	BanList     []string               `json:"ban_list,omitempty" yaml:"ban_list,omitempty"`
+	ExceptList  []string               `json:"except_list,omitempty" yaml:"except_list,omitempty"`
+	InviteList  []string               `json:"invite_list,omitempty" yaml:"invite_list,omitempty"`
+	Settings    map[string]interface{} `json:"settings,omitempty" yaml:"settings,omitempty"`
+	IsGlobal    bool                   `json:"is_global" yaml:"is_global"`
+}
+
+// PolicyRequest represents a plan or apply request
+type PolicyRequest struct {
+	Document string `json:"document" binding:"required"`
+	Format   string `json:"format"` // yaml or json, detected when empty
+	Prune    bool   `json:"prune"`  // Delete live entries that aren't in the document
+}
+
+// PolicyChange is a single step of a plan
+type PolicyChange struct {
+	Kind    string      `json:"kind"`   // server_ban, name_ban, ban_exception, spamfilter, channel_template
+	Action  string      `json:"action"` // create, update, delete
+	Key     string      `json:"key"`    // Mask or template name
+	Desired interface{} `json:"desired,omitempty"`
+	Current interface{} `json:"current,omitempty"`
+	Status  string      `json:"status,omitempty"` // Set after apply: applied or failed
+	Error   string      `json:"error,omitempty"`
+}
+
+// PolicyPlan is the set of changes needed to reach the desired state
+type PolicyPlan struct {
+	DocumentHash string         `json:"document_hash"`
+	Changes      []PolicyChange `json:"changes"`
+	Unchanged    int            `json:"unchanged"`
+	Unmanaged    int            `json:"unmanaged"` // Live entries not in the document (deleted when pruning)
+	Prune        bool           `json:"prune"`
+}
+
+// PlanPolicy computes the changes needed to reach the desired state without applying them
+func PlanPolicy(c *gin.Context) {
+	var req PolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+
+	doc, err := parsePolicyDocument(req.Document, req.Format)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	plan, err := computePolicyPlan(doc, req.Prune)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute plan: " + err.Error()})
+		return
+	}
+	plan.DocumentHash = policyDocumentHash(req.Document)
+
+	c.JSON(http.StatusOK, plan)
+}
+
+// ApplyPolicy computes the plan and applies each change independently
+func ApplyPolicy(c *gin.Context) {
+	var req PolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+
+	currentUser := middleware.GetCurrentUser(c)
+	if currentUser == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Not authenticated"})
+		return
+	}
+
+	doc, err := parsePolicyDocument(req.Document, req.Format)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	plan, err := computePolicyPlan(doc, req.Prune)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute plan: " + err.Error()})
+		return
+	}
+	plan.DocumentHash = policyDocumentHash(req.Document)
+
+	applied, failed := 0, 0
+	for i := range plan.Changes {
+		if err := applyPolicyChange(currentUser, &plan.Changes[i]); err != nil {
+			plan.Changes[i].Status = "failed"
+			plan.Changes[i].Error = err.Error()
+			failed++
+		} else {
+			plan.Changes[i].Status = "applied"
+			applied++
+		}
+	}
+
+	logAction(c, currentUser, "apply_policy", map[string]string{
+		"document_hash": plan.DocumentHash,
+		"prune":         strconv.FormatBool(req.Prune),
+		"changes":       strconv.Itoa(len(plan.Changes)),
+		"applied":       strconv.Itoa(applied),
+		"failed":        strconv.Itoa(failed),
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"plan":    plan,
+		"applied": applied,
+		"failed":  failed,
+	})
+}
+
+// ExportPolicy returns the current live state as a desired-state document
+func ExportPolicy(c *gin.Context) {
+	doc, err := currentPolicyDocument()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch current state: " + err.Error()})
+		return
+	}
+
+	if c.DefaultQuery("format", "yaml") == "json" {
+		c.JSON(http.StatusOK, doc)
+		return
+	}
+
+	data, err := yaml.Marshal(doc)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode document"})
+		return
+	}
+	c.Data(http.StatusOK, "application/yaml; charset=utf-8", data)
+}
+
+// Helper functions
+
+func policyDocumentHash(document string) string {
+	sum := sha256.Sum256([]byte(document))
+	return hex.EncodeToString(sum[:])
+}
+
+func parsePolicyDocument(document, format string) (*PolicyDocument, error) {
+	if format == "" {
+		if strings.HasPrefix(strings.TrimSpace(document), "{") {
+			format = "json"
+		} else {
+			format = "yaml"
+		}
+	}
+
+	var doc PolicyDocument
+	switch format {
+	case "json":
+		if err := json.Unmarshal([]byte(document), &doc); err != nil {
+			return nil, fmt.Errorf("invalid JSON document: %v", err)
+		}
+	case "yaml", "yml":
+		if err := yaml.Unmarshal([]byte(document), &doc); err != nil {
+			return nil, fmt.Errorf("invalid YAML document: %v", err)
+		}
+	default:
+		return nil, fmt.Errorf("invalid format, use yaml or json")
+	}
+
+	// The section an entry is listed under determines its kind
+	sections := []struct {
+		kind  string
+		items []BanTransferItem
+	}{
+		{transferKindServerBan, doc.ServerBans},
+		{transferKindNameBan, doc.NameBans},
+		{transferKindException, doc.BanExceptions},
+		{transferKindSpamfilter, doc.Spamfilters},
+	}
+	for _, section := range sections {
+		for i := range section.items {
+			section.items[i].Kind = section.kind
+			if err := validateTransferItem(&section.items[i]); err != nil {
+				return nil, fmt.Errorf("%s entry %d (%s): %v", section.kind, i+1, section.items[i].Mask, err)
+			}
+		}
+	}
+
+	seen := make(map[string]bool)
+	for i, tmpl := range doc.ChannelTemplates {
+		if tmpl.Name == "" {
+			return nil, fmt.Errorf("channel template %d: name is required", i+1)
+		}
+		if seen[tmpl.Name] {
+			return nil, fmt.Errorf("channel template '%s' is listed more than once", tmpl.Name)
+		}
+		seen[tmpl.Name] = true
+	}
+
+	return &doc, nil
+}
+
+func computePolicyPlan(doc *PolicyDocument, prune bool) (*PolicyPlan, error) {
+	plan := &PolicyPlan{Changes: make([]PolicyChange, 0), Prune: prune}
+
+	desired := make([]BanTransferItem, 0)
+	desired = append(desired, doc.ServerBans...)
+	desired = append(desired, doc.NameBans...)
+	desired = append(desired, doc.BanExceptions...)
+	desired = append(desired, doc.Spamfilters...)
+
+	live, err := fetchLiveTransferItems(parseTransferKinds(""))
+	if err != nil {
+		return nil, err
+	}
+
+	liveByKey := make(map[string]BanTransferItem, len(live))
+	for _, item := range live {
+		liveByKey[transferItemKey(item)] = item
+	}
+	desiredKeys := make(map[string]bool, len(desired))
+
+	for _, item := range desired {
+		key := transferItemKey(item)
+		if desiredKeys[key] {
+			continue
+		}
+		desiredKeys[key] = true
+
+		current, exists := liveByKey[key]
+		switch {
+		case !exists:
+			plan.Changes = append(plan.Changes, PolicyChange{Kind: item.Kind, Action: policyActionCreate, Key: item.Mask, Desired: item})
+		case !transferItemsEqual(item, current):
+			plan.Changes = append(plan.Changes, PolicyChange{Kind: item.Kind, Action: policyActionUpdate, Key: item.Mask, Desired: item, Current: current})
+		default:
+			plan.Unchanged++
+		}
+	}
+
+	for _, item := range live {
+		if desiredKeys[transferItemKey(item)] {
+			continue
+		}
+		plan.Unmanaged++
+		if prune && item.SetBy != configSetBy {
+			plan.Changes = append(plan.Changes, PolicyChange{Kind: item.Kind, Action: policyActionDelete, Key: item.Mask, Current: item})
+		}
+	}
+
+	// Channel templates live in the panel database
+	var templates []models.ChannelTemplate
+	if err := database.Get().Find(&templates).Error; err != nil {
+		return nil, err
+	}
+	templatesByName := make(map[string]models.ChannelTemplate, len(templates))
+	for _, tmpl := range templates {
+		templatesByName[tmpl.Name] = tmpl
+	}
+
+	for _, tmpl := range doc.ChannelTemplates {
+		current, exists := templatesByName[tmpl.Name]
+		switch {
+		case !exists:
+			plan.Changes = append(plan.Changes, PolicyChange{Kind: policyKindChannelTemplate, Action: policyActionCreate, Key: tmpl.Name, Desired: tmpl})
+		case !reflect.DeepEqual(normalizePolicyTemplate(tmpl), policyTemplateFromModel(current)):
+			plan.Changes = append(plan.Changes, PolicyChange{Kind: policyKindChannelTemplate, Action: policyActionUpdate, Key: tmpl.Name, Desired: tmpl, Current: policyTemplateFromModel(current)})
+		default:
+			plan.Unchanged++
+		}
+	}
+
+	desiredTemplates := make(map[string]bool, len(doc.ChannelTemplates))
+	for _, tmpl := range doc.ChannelTemplates {
+		desiredTemplates[tmpl.Name] = true
+	}
+	for _, tmpl := range templates {
+		if desiredTemplates[tmpl.Name] {
+			continue
+		}
+		plan.Unmanaged++
+		if prune {
+			plan.Changes = append(plan.Changes, PolicyChange{Kind: policyKindChannelTemplate, Action: policyActionDelete, Key: tmpl.Name, Current: policyTemplateFromModel(tmpl)})
+		}
+	}
+
+	return plan, nil
+}
+
+// applyPolicyChange applies one change. Ban updates are a delete followed by an add;
+// if the add fails the previous entry is restored so each change is all-or-nothing.
+func applyPolicyChange(user *models.User, change *PolicyChange) error {
+	if change.Kind == policyKindChannelTemplate {
+		return applyPolicyTemplateChange(user, change)
+	}
+
+	switch change.Action {
+	case policyActionCreate:
+		item := change.Desired.(BanTransferItem)
+		if err := addTransferItem(item); err != nil {
+			return err
+		}
+		recordPanelBanChange(user.Username, ledgerActionAdd, transferLedgerType(item), item.Mask, item.Reason, item.Duration)
+	case policyActionUpdate:
+		item := change.Desired.(BanTransferItem)
+		current := change.Current.(BanTransferItem)
+		if err := removeTransferItem(current); err != nil {
+			return err
+		}
+		if err := addTransferItem(item); err != nil {
+			if restoreErr := addTransferItem(current); restoreErr != nil {
+				return fmt.Errorf("%v (restoring previous entry also failed: %v)", err, restoreErr)
+			}
+			return err
+		}
+		recordPanelBanChange(user.Username, ledgerActionDelete, transferLedgerType(current), current.Mask, current.Reason, "")
+		recordPanelBanChange(user.Username, ledgerActionAdd, transferLedgerType(item), item.Mask, item.Reason, item.Duration)
+	case policyActionDelete:
+		current := change.Current.(BanTransferItem)
+		if err := removeTransferItem(current); err != nil {
+			return err
+		}
+		recordPanelBanChange(user.Username, ledgerActionDelete, transferLedgerType(current), current.Mask, current.Reason, "")
+	}
+	return nil
+}
+
+func applyPolicyTemplateChange(user *models.User, change *PolicyChange) error {
+	db := database.Get()
+
+	switch change.Action {
+	case policyActionCreate:
+		tmpl := normalizePolicyTemplate(change.Desired.(PolicyChannelTemplate))
+		model := models.ChannelTemplate{
+			CreatedBy:         user.ID,
+			CreatedByUsername: user.Username,
+		}
+		fillTemplateFromPolicy(&model, tmpl)
+		return db.Create(&model).Error
+	case policyActionUpdate:
+		tmpl := normalizePolicyTemplate(change.Desired.(PolicyChannelTemplate))
+		var model models.ChannelTemplate
+		if err := db.Where("name = ?", tmpl.Name).First(&model).Error; err != nil {
+			return err
+		}
+		fillTemplateFromPolicy(&model, tmpl)
+		return db.Save(&model).Error
+	case policyActionDelete:
+		return db.Where("name = ?", change.Key).Delete(&models.ChannelTemplate{}).Error
+	}
+	return nil
+}
+
+func fillTemplateFromPolicy(model *models.ChannelTemplate, tmpl PolicyChannelTemplate) {
+	banListJSON, _ := json.Marshal(tmpl.BanList)
+	exceptListJSON, _ := json.Marshal(tmpl.ExceptList)
+	inviteListJSON, _ := json.Marshal(tmpl.InviteList)
+	settingsJSON, _ := json.Marshal(tmpl.Settings)
+
+	model.Name = tmpl.Name
+	model.Description = tmpl.Description
+	model.Modes = tmpl.Modes
+	model.Topic = tmpl.Topic
+	model.BanList = string(banListJSON)
+	model.ExceptList = string(exceptListJSON)
+	model.InviteList = string(inviteListJSON)
+	model.Settings = string(settingsJSON)
+	model.IsGlobal = tmpl.IsGlobal
+}
+
+// normalizePolicyTemplate replaces nil lists and maps with empty ones so comparisons are stable
+func normalizePolicyTemplate(tmpl PolicyChannelTemplate) PolicyChannelTemplate {
+	if tmpl.BanList == nil {
+		tmpl.BanList = []string{}
+	}
+	if tmpl.ExceptList == nil {
+		tmpl.ExceptList = []string{}
+	}
+	if tmpl.InviteList == nil {
+		tmpl.InviteList = []string{}
+	}
+	if tmpl.Settings == nil {
+		tmpl.Settings = map[string]interface{}{}
+	}
+	// Round-trip settings through JSON so numbers compare the same way as stored ones
+	data, _ := json.Marshal(tmpl.Settings)
+	settings := map[string]interface{}{}
+	json.Unmarshal(data, &settings)
+	tmpl.Settings = settings
+	return tmpl
+}
+
+func policyTemplateFromModel(model models.ChannelTemplate) PolicyChannelTemplate {
+	tmpl := PolicyChannelTemplate{
+		Name:        model.Name,
+		Description: model.Description,
+		Modes:       model.Modes,
+		Topic:       model.Topic,
+		IsGlobal:    model.IsGlobal,
+	}
+	json.Unmarshal([]byte(model.BanList), &tmpl.BanList)
+	json.Unmarshal([]byte(model.ExceptList), &tmpl.ExceptList)
+	json.Unmarshal([]byte(model.InviteList), &tmpl.InviteList)
+	json.Unmarshal([]byte(model.Settings), &tmpl.Settings)
+	return normalizePolicyTemplate(tmpl)
+}
+
+// currentPolicyDocument builds a desired-state document from the live state
+func currentPolicyDocument() (*PolicyDocument, error) {
+	live, err := fetchLiveTransferItems(parseTransferKinds(""))
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &PolicyDocument{}
+	for _, item := range live {
+		if item.SetBy == configSetBy {
+			continue
+		}
+		kind := item.Kind
+		item.Kind = ""
+		switch kind {
+		case transferKindServerBan:
+			doc.ServerBans = append(doc.ServerBans, item)
+		case transferKindNameBan:
+			doc.NameBans = append(doc.NameBans, item)
+		case transferKindException:
+			doc.BanExceptions = append(doc.BanExceptions, item)
+		case transferKindSpamfilter:
+			doc.Spamfilters = append(doc.Spamfilters, item)
+		}
+	}
+
+	var templates []models.ChannelTemplate
+	if err := database.Get().Order("name ASC").Find(&templates).Error; err != nil {
+		return nil, err
+	}
+	for _, tmpl := range templates {
+		doc.ChannelTemplates = append(doc.ChannelTemplates, policyTemplateFromModel(tmpl))
+	}
+
+	return doc, nil
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"sort"
 	"strings"
 	"time"
 
@@ -33,6 +34,8 @@ type ReportFilters struct {
 	UserFilter    string `json:"user_filter,omitempty"`
 	ChannelFilter string `json:"channel_filter,omitempty"`
 	MinUsers      int    `json:"min_users,omitempty"`
+	Country       string `json:"country,omitempty"` // Comma-separated ISO country codes
+	ASN           string `json:"asn,omitempty"`     // Comma-separated AS numbers
 }
 
 // ReportResult represents the generated report data
@@ -58,6 +61,12 @@ func GetAvailableMetrics(c *gin.Context) {
 			"description": "Channel counts, top channels by users",
 			"fields":      []string{"total", "top_by_users", "modes_distribution"},
 		},
+		{
+			"id":          "channel_growth",
+			"name":        "Growing & Dying Channels",
+			"description": "Channels whose member count grew or shrank most over the time range",
+			"fields":      []string{"tracked", "top_growing", "top_dying"},
+		},
 		{
 			"id":          "servers",
 			"name":        "Server Statistics",
@@ -70,6 +79,18 @@ func GetAvailableMetrics(c *gin.Context) {
 			"description": "Server bans, name bans, spamfilters",
 			"fields":      []string{"server_bans", "name_bans", "ban_exceptions", "spamfilters"},
 		},
+		{
+			"id":          "geo",
+			"name":        "Users by Country/ASN",
+			"description": "Connected users grouped by country and autonomous system",
+			"fields":      []string{"by_country", "by_asn", "unknown"},
+		},
+		{
+			"id":          "listed_users",
+			"name":        "Listed Users",
+			"description": "Connected users on DNS blocklists or local reputation lists",
+			"fields":      []string{"total", "by_list", "by_confidence", "users"},
+		},
 		{
 			"id":          "activity",
 			"name":        "Activity Metrics",
@@ -124,6 +145,10 @@ func GenerateReport(c *gin.Context) {
 				result.Summary["total_channels"] = total
 			}
 
+		case "channel_growth":
+			growthData := collectChannelGrowthMetrics(startTime, endTime, config.Filters)
+			result.Data["channel_growth"] = growthData
+
 		case "servers":
 			serverData := collectServerMetrics(manager, config.Filters)
 			result.Data["servers"] = serverData
@@ -135,6 +160,20 @@ func GenerateReport(c *gin.Context) {
 			banData := collectBanMetrics(manager)
 			result.Data["bans"] = banData
 
+		case "geo":
+			geoData := collectGeoMetrics(config.Filters)
+			result.Data["geo"] = geoData
+			if countries, ok := geoData["countries"].(int); ok {
+				result.Summary["total_countries"] = countries
+			}
+
+		case "listed_users":
+			listedData := collectListedUserMetrics()
+			result.Data["listed_users"] = listedData
+			if total, ok := listedData["total"].(int); ok {
+				result.Summary["listed_users"] = total
+			}
+
 		case "activity":
 			activityData := collectActivityMetrics(db, startTime, endTime, config.GroupBy)
 			result.Data["activity"] = activityData
@@ -186,10 +225,17 @@ func GetReportPreview(c *gin.Context) {
 		preview = collectUserMetrics(manager, ReportFilters{})
 	case "channels":
 		preview = collectChannelMetrics(manager, ReportFilters{})
+	case "channel_growth":
+		start, end := parseTimeRange("7d", "", "")
+		preview = collectChannelGrowthMetrics(start, end, ReportFilters{})
 	case "servers":
 		preview = collectServerMetrics(manager, ReportFilters{})
 	case "bans":
 		preview = collectBanMetrics(manager)
+	case "geo":
+		preview = collectGeoMetrics(ReportFilters{})
+	case "listed_users":
+		preview = collectListedUserMetrics()
 	default:
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown metric"})
 		return
@@ -218,6 +264,12 @@ func parseTimeRange(timeRange, startDate, endDate string) (time.Time, time.Time)
 	case "30d":
 		start = now.AddDate(0, 0, -30)
 		end = now
+	case "90d":
+		start = now.AddDate(0, 0, -90)
+		end = now
+	case "365d":
+		start = now.AddDate(-1, 0, 0)
+		end = now
 	case "custom":
 		if s, err := time.Parse("2006-01-02", startDate); err == nil {
 			start = s
@@ -268,6 +320,11 @@ func collectUserMetrics(manager *rpc.Manager, filters ReportFilters) map[string]
 				continue
 			}
 		}
+		if filters.Country != "" || filters.ASN != "" {
+			if user := parseUser(uMap); user == nil || !userMatchesGeo(user.Geo, filters.Country, filters.ASN, "") {
+				continue
+			}
+		}
 
 		// Server
 		if srvData := utils.InterfaceToMap(uMap["server"]); srvData != nil {
@@ -299,6 +356,95 @@ func collectUserMetrics(manager *rpc.Manager, filters ReportFilters) map[string]
 	return result
 }
 
+func collectGeoMetrics(filters ReportFilters) map[string]interface{} {
+	result := make(map[string]interface{})
+
+	list, err := fetchFullUserList()
+	if err != nil {
+		return result
+	}
+
+	type asnCount struct {
+		ASN   uint   `json:"asn"`
+		Org   string `json:"org"`
+		Users int    `json:"users"`
+	}
+
+	byCountry := make(map[string]int)
+	byASN := make(map[uint]*asnCount)
+	unknown := 0
+	total := 0
+
+	for _, item := range list {
+		user := parseUser(item)
+		if user == nil {
+			continue
+		}
+		if filters.ServerFilter != "" && user.ServerName != filters.ServerFilter && user.Server != filters.ServerFilter {
+			continue
+		}
+		if (filters.Country != "" || filters.ASN != "") && !userMatchesGeo(user.Geo, filters.Country, filters.ASN, "") {
+			continue
+		}
+		total++
+
+		if user.Geo == nil {
+			unknown++
+			continue
+		}
+		if user.Geo.CountryCode != "" {
+			byCountry[user.Geo.CountryCode]++
+		}
+		if user.Geo.ASN != 0 {
+			if byASN[user.Geo.ASN] == nil {
+				byASN[user.Geo.ASN] = &asnCount{ASN: user.Geo.ASN, Org: user.Geo.ASOrg}
+			}
+			byASN[user.Geo.ASN].Users++
+		}
+	}
+
+	asns := make([]asnCount, 0, len(byASN))
+	for _, entry := range byASN {
+		asns = append(asns, *entry)
+	}
+	sort.Slice(asns, func(i, j int) bool {
+		return asns[i].Users > asns[j].Users
+	})
+
+	result["total"] = total
+	result["countries"] = len(byCountry)
+	result["by_country"] = byCountry
+	result["by_asn"] = asns
+	result["unknown"] = unknown
+
+	return result
+}
+
+func collectListedUserMetrics() map[string]interface{} {
+	result := make(map[string]interface{})
+
+	users, err := collectListedUsers("")
+	if err != nil {
+		return result
+	}
+
+	byList := make(map[string]int)
+	byConfidence := make(map[string]int)
+	for _, user := range users {
+		byConfidence[user.Confidence]++
+		for _, listing := range user.Listings {
+			byList[listing.List]++
+		}
+	}
+
+	result["total"] = len(users)
+	result["by_list"] = byList
+	result["by_confidence"] = byConfidence
+	result["users"] = users
+
+	return result
+}
+
 func collectChannelMetrics(manager *rpc.Manager, filters ReportFilters) map[string]interface{} {
 	result := make(map[string]interface{})
 
@@ -552,6 +698,17 @@ func GetSavedReports(c *gin.Context) {
 				GroupBy:   "day",
 			},
 		},
+		{
+			"id":          "channel_trends",
+			"name":        "Channel Trends",
+			"description": "Top channels and the channels that grew or shrank most this month",
+			"config": ReportConfig{
+				Name:      "Channel Trends",
+				Metrics:   []string{"channels", "channel_growth"},
+				TimeRange: "30d",
+				Format:    "json",
+			},
+		},
 		{
 			"id":          "security_audit",
 			"name":        "Security Audit",
@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/rpc"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/services/audit"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/services/reputation"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/utils"
+)
+
+// autoGlineActor is recorded as the actor in the ban ledger for automatic G-Lines
+const autoGlineActor = "reputation-policy"
+
+// ListedUser is a connected user whose IP is on at least one list
+type ListedUser struct {
+	Nick       string               `json:"nick"`
+	IP         string               `json:"ip"`
+	Hostname   string               `json:"hostname"`
+	Account    string               `json:"account,omitempty"`
+	Server     string               `json:"server,omitempty"`
+	Confidence string               `json:"confidence"` // Highest confidence of the listings
+	Listings   []reputation.Listing `json:"listings"`
+}
+
+// GetReputationStatus returns the configured blocklists and auto-gline policy
+func GetReputationStatus(c *gin.Context) {
+	service := reputation.GetService()
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":    service.Enabled(),
+		"lists":      service.Status(),
+		"auto_gline": service.AutoGline(),
+	})
+}
+
+// CheckIPReputation checks a single IP against every list
+func CheckIPReputation(c *gin.Context) {
+	ip := c.Query("ip")
+	if ip == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ip is required"})
+		return
+	}
+
+	result, err := reputation.GetService().Check(ip)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// ClearReputationCache forgets cached results so the next checks query the lists again
+func ClearReputationCache(c *gin.Context) {
+	reputation.GetService().ClearCache()
+	c.JSON(http.StatusOK, gin.H{"message": "Reputation cache cleared"})
+}
+
+// GetListedUsers returns connected users whose IP is listed, highest confidence first
+func GetListedUsers(c *gin.Context) {
+	users, err := collectListedUsers(c.Query("confidence"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get users: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"users": users,
+		"total": len(users),
+	})
+}
+
+// CheckConnectingClient checks a client from a connect log event and places an
+// automatic G-Line when the policy is enabled and the IP is on a high confidence list
+func CheckConnectingClient(entry map[string]interface{}) {
+	eventID := utils.SafeMapGetString(entry, "event_id")
+	if eventID != "LOCAL_CLIENT_CONNECT" && eventID != "REMOTE_CLIENT_CONNECT" {
+		return
+	}
+
+	service := reputation.GetService()
+	if !service.Enabled() {
+		return
+	}
+
+	client := utils.SafeMapGetMap(entry, "client")
+	if client == nil {
+		return
+	}
+	ip := utils.SafeMapGetString(client, "ip")
+	nick := utils.SafeMapGetString(client, "name")
+	if ip == "" {
+		return
+	}
+
+	result, err := service.Check(ip)
+	if err != nil || !result.Listed {
+		return
+	}
+
+	policy := service.AutoGline()
+	listing := result.AutoGlineListing()
+	if !policy.Enabled || listing == nil {
+		return
+	}
+
+	mask := "*@" + result.IP
+	reason := policy.Reason
+	if reason == "" {
+		reason = "Your IP is listed on {list}"
+	}
+	reason = strings.ReplaceAll(reason, "{list}", listing.List)
+
+	_, err = rpc.GetManager().WithRetry(func(client *rpc.Client) (interface{}, error) {
+		return client.ServerBan().Add(mask, "gline", policy.Duration, reason)
+	})
+	if err != nil {
+		log.Printf("[Reputation] Failed to G-Line %s (%s): %v", mask, nick, err)
+		return
+	}
+
+	recordPanelBanChange(autoGlineActor, ledgerActionAdd, "gline", mask, reason, policy.Duration)
+	audit.LogAutoGline(nick, mask, listing.List)
+}
+
+// Helper functions
+
+// collectListedUsers checks every connected user and returns the listed ones.
+// minConfidence (low, medium or high) drops listings below that level.
+func collectListedUsers(minConfidence string) ([]ListedUser, error) {
+	list, err := fetchFullUserList()
+	if err != nil {
+		return nil, err
+	}
+
+	users := make([]*IRCUser, 0, len(list))
+	ips := make([]string, 0, len(list))
+	seen := make(map[string]bool)
+	for _, item := range list {
+		user := parseUser(item)
+		if user == nil || user.IP == "" {
+			continue
+		}
+		users = append(users, user)
+		if !seen[user.IP] {
+			seen[user.IP] = true
+			ips = append(ips, user.IP)
+		}
+	}
+
+	results := reputation.GetService().CheckMany(ips)
+
+	listed := make([]ListedUser, 0)
+	for _, user := range users {
+		result := results[user.IP]
+		if result == nil || !result.Listed {
+			continue
+		}
+
+		listings := make([]reputation.Listing, 0, len(result.Listings))
+		for _, l := range result.Listings {
+			if confidenceAtLeast(l.Confidence, minConfidence) {
+				listings = append(listings, l)
+			}
+		}
+		if len(listings) == 0 {
+			continue
+		}
+
+		filtered := reputation.Result{Listings: listings}
+		listed = append(listed, ListedUser{
+			Nick:       user.Name,
+			IP:         user.IP,
+			Hostname:   user.Hostname,
+			Account:    user.Account,
+			Server:     user.ServerName,
+			Confidence: filtered.HighestConfidence(),
+			Listings:   listings,
+		})
+	}
+
+	sort.Slice(listed, func(i, j int) bool {
+		if listed[i].Confidence != listed[j].Confidence {
+			return confidenceAtLeast(listed[i].Confidence, listed[j].Confidence)
+		}
+		return len(listed[i].Listings) > len(listed[j].Listings)
+	})
+
+	return listed, nil
+}
+
+// reputationAlertData returns the alert condition fields for an IP
+func reputationAlertData(ip string) map[string]string {
+	data := map[string]string{"listed": "false"}
+	if ip == "" {
+		return data
+	}
+
+	result, err := reputation.GetService().Check(ip)
+	if err != nil || !result.Listed {
+		return data
+	}
+
+	names := make([]string, 0, len(result.Listings))
+	for _, l := range result.Listings {
+		names = append(names, l.List)
+	}
+	data["listed"] = "true"
+	data["listed_on"] = strings.Join(names, ",")
+	data["listing_confidence"] = result.HighestConfidence()
+	return data
+}
+
+func confidenceAtLeast(confidence, minimum string) bool {
+	rank := map[string]int{"": 0, reputation.ConfidenceLow: 1, reputation.ConfidenceMedium: 2, reputation.ConfidenceHigh: 3}
+	return rank[confidence] >= rank[minimum]
+}
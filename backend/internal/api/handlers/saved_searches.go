@@ -1,14 +1,19 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/api/middleware"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/auth"
 	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/database"
 	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/database/models"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/rpc"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/utils"
 )
 
 // SavedSearchRequest represents a request to create/update a saved search
@@ -85,6 +90,11 @@ func CreateSavedSearch(c *gin.Context) {
 		return
 	}
 
+	if err := validateSavedSearchFilters(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	db := database.Get()
 
 	// Check for duplicate name for this user on this page
@@ -138,6 +148,10 @@ func UpdateSavedSearch(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := validateSavedSearchFilters(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	search.Name = req.Name
 	search.Page = req.Page
@@ -216,3 +230,132 @@ func UseSavedSearch(c *gin.Context) {
 
 	c.JSON(http.StatusOK, search)
 }
+
+// ExecuteSavedSearch runs a users or channels saved search on the server and returns
+// a ListPage. sort, limit and cursor query parameters work as on the list endpoints.
+func ExecuteSavedSearch(c *gin.Context) {
+	user := middleware.GetCurrentUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Not authenticated"})
+		return
+	}
+
+	db := database.Get()
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	var search models.SavedSearch
+	if err := db.Where("id = ? AND (user_id = ? OR is_global = ?)", id, user.ID, true).First(&search).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Saved search not found"})
+		return
+	}
+
+	if isLegacySavedSearchFilters(search.Filters) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This saved search uses the old filter format; save it again with a filter expression"})
+		return
+	}
+
+	query, _ := parseListQuery(c)
+	query.Filter = savedSearchExpression(&search)
+
+	var page *ListPage
+	switch search.Page {
+	case "users":
+		if !auth.UserCan(user, models.PermissionViewUsers) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+			return
+		}
+		list, err := fetchFullUserList()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get users: " + err.Error()})
+			return
+		}
+		users := make([]IRCUser, 0, len(list))
+		for _, item := range list {
+			if u := parseUser(item); u != nil {
+				users = append(users, *u)
+			}
+		}
+		page, err = queryUsers(users, query)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	case "channels":
+		if !auth.UserCan(user, models.PermissionViewChannels) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+			return
+		}
+		result, err := rpc.GetManager().WithRetry(func(client *rpc.Client) (interface{}, error) {
+			return client.Channel().GetAll(1)
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get channels: " + err.Error()})
+			return
+		}
+		page, err = queryChannels(parseChannelList(result), query)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only users and channels saved searches can be executed"})
+		return
+	}
+
+	now := time.Now()
+	db.Model(&search).Updates(map[string]interface{}{
+		"use_count": search.UseCount + 1,
+		"last_used": now,
+	})
+
+	c.JSON(http.StatusOK, page)
+}
+
+// Helper functions
+
+// isLegacySavedSearchFilters reports whether filters is the JSON object older panels stored
+func isLegacySavedSearchFilters(filters string) bool {
+	return strings.HasPrefix(strings.TrimSpace(filters), "{")
+}
+
+// savedSearchFilterQuoter escapes text for a quoted filter value
+var savedSearchFilterQuoter = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// savedSearchExpression combines the free-text query and the filter expression. The
+// query is plain search text, so it becomes one quoted text:= condition; the '=' keeps
+// a leading '-', '>' or field name in the text from being read as filter syntax.
+func savedSearchExpression(search *models.SavedSearch) string {
+	parts := make([]string, 0, 2)
+	if strings.TrimSpace(search.Filters) != "" {
+		parts = append(parts, "("+search.Filters+")")
+	}
+	if query := strings.TrimSpace(search.Query); query != "" {
+		parts = append(parts, utils.FilterTextField+`:="`+savedSearchFilterQuoter.Replace(query)+`"`)
+	}
+	return strings.Join(parts, " AND ")
+}
+
+// validateSavedSearchFilters checks the filter expression of users and channels searches
+func validateSavedSearchFilters(req *SavedSearchRequest) error {
+	if isLegacySavedSearchFilters(req.Filters) {
+		return nil
+	}
+
+	search := models.SavedSearch{Query: req.Query, Filters: req.Filters}
+	var err error
+	switch req.Page {
+	case "users":
+		_, err = parseUserFilter(savedSearchExpression(&search))
+	case "channels":
+		_, err = parseChannelFilter(savedSearchExpression(&search))
+	}
+	if err != nil {
+		return fmt.Errorf("filters: %v", err)
+	}
+	return nil
+}
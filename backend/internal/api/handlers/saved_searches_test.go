@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/database/models"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/utils"
+)
+
+func TestSavedSearchExpression(t *testing.T) {
+	tests := []struct {
+		name    string
+		search  models.SavedSearch
+		want    []utils.FilterCondition
+		matches bool
+	}{
+		{
+			name:    "filters only",
+			search:  models.SavedSearch{Filters: "server:hub1 OR tls:false"},
+			want:    []utils.FilterCondition{{Field: "server", Value: "hub1"}, {Field: "tls", Value: "false"}},
+			matches: true,
+		},
+		{
+			name:    "plain query",
+			search:  models.SavedSearch{Query: "alice"},
+			want:    []utils.FilterCondition{{Field: "text", Op: "=", Value: "alice"}},
+			matches: true,
+		},
+		{
+			name:    "query with quotes and parentheses",
+			search:  models.SavedSearch{Query: `say "hi") (x`},
+			want:    []utils.FilterCondition{{Field: "text", Op: "=", Value: `say "hi") (x`}},
+			matches: true,
+		},
+		{
+			name:   "query with filter syntax is literal",
+			search: models.SavedSearch{Filters: "account:*", Query: `-nick:bob OR \`},
+			want: []utils.FilterCondition{
+				{Field: "account", Value: "*"},
+				{Field: "text", Op: "=", Value: `-nick:bob OR \`},
+			},
+		},
+		{
+			name:    "query with a comparison",
+			search:  models.SavedSearch{Query: ">5"},
+			want:    []utils.FilterCondition{{Field: "text", Op: "=", Value: ">5"}},
+			matches: false,
+		},
+	}
+
+	user := &IRCUser{Name: "alice", RealName: `I say "hi") (x`, Account: "alice", Server: "hub1"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := parseUserFilter(savedSearchExpression(&tt.search))
+			if err != nil {
+				t.Fatalf("parseUserFilter(%q) error = %v", savedSearchExpression(&tt.search), err)
+			}
+			if got := expr.Conditions(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("conditions = %+v, want %+v", got, tt.want)
+			}
+			if got := userMatchesFilter(user, expr); got != tt.matches {
+				t.Errorf("userMatchesFilter() = %v, want %v", got, tt.matches)
+			}
+		})
+	}
+}
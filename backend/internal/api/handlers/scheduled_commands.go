@@ -2,12 +2,15 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/api/middleware"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/auth"
 	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/database"
 	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/database/models"
 	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/rpc"
@@ -70,6 +73,15 @@ func CreateScheduledCommand(c *gin.Context) {
 		return
 	}
 
+	if perm := scheduledCommandPermission(req.Command, req.Params); perm != "" && !auth.UserCan(user, perm) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+		return
+	}
+	if err := validateScheduledCommandParams(req.Command, req.Params); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Validate schedule
 	if req.Schedule == "once" && req.RunAt == nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "RunAt is required for one-time commands"})
@@ -134,6 +146,17 @@ func UpdateScheduledCommand(c *gin.Context) {
 		return
 	}
 
+	if perm := scheduledCommandPermission(req.Command, req.Params); perm != "" {
+		if currentUser := middleware.GetCurrentUser(c); currentUser == nil || !auth.UserCan(currentUser, perm) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+			return
+		}
+	}
+	if err := validateScheduledCommandParams(req.Command, req.Params); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	paramsJSON := ""
 	if req.Params != nil {
 		paramsBytes, _ := json.Marshal(req.Params)
@@ -238,7 +261,7 @@ func RunScheduledCommandNow(c *gin.Context) {
 		return
 	}
 
-	result, err := executeCommand(&command)
+	result, err := ExecuteScheduledCommand(&command)
 	now := time.Now()
 	command.LastRun = &now
 	command.RunCount++
@@ -267,8 +290,8 @@ func RunScheduledCommandNow(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Command executed", "result": result, "command": command})
 }
 
-// executeCommand runs the IRC command via RPC
-func executeCommand(cmd *models.ScheduledCommand) (string, error) {
+// ExecuteScheduledCommand runs the IRC command via RPC; it is shared by "run now" and the scheduler
+func ExecuteScheduledCommand(cmd *models.ScheduledCommand) (string, error) {
 	manager := rpc.GetManager()
 
 	var params map[string]interface{}
@@ -307,17 +330,71 @@ func executeCommand(cmd *models.ScheduledCommand) (string, error) {
 		}
 		return "G-Line added for " + cmd.Target, nil
 
+	case "kline":
+		reason := "Scheduled ban"
+		duration := "1d"
+		if r, ok := params["reason"].(string); ok && r != "" {
+			reason = r
+		}
+		if d, ok := params["duration"].(string); ok && d != "" {
+			duration = d
+		}
+		_, err := manager.WithRetry(func(client *rpc.Client) (interface{}, error) {
+			return client.ServerBan().Add(cmd.Target, "kline", duration, reason)
+		})
+		if err != nil {
+			return "", err
+		}
+		return "K-Line added for " + cmd.Target, nil
+
 	case "message":
-		message := ""
+		req := MessageRequest{
+			Type:   messageTypeNotice,
+			Target: cmd.Target,
+		}
+		if t, ok := params["type"].(string); ok && t != "" {
+			req.Type = t
+		}
 		if m, ok := params["message"].(string); ok {
-			message = m
+			req.Message = m
+		}
+		if id, ok := params["template_id"].(float64); ok && id > 0 {
+			templateID := uint(id)
+			req.TemplateID = &templateID
+			if _, ok := params["type"].(string); !ok {
+				req.Type = ""
+			}
+		}
+		if vars, ok := params["variables"].(map[string]interface{}); ok {
+			req.Variables = make(map[string]string, len(vars))
+			for k, v := range vars {
+				req.Variables[k] = fmt.Sprintf("%v", v)
+			}
+		}
+		// The template may have become a network message since it was scheduled
+		if _, _, err := resolveMessageText(&req); err != nil {
+			return "", err
+		}
+		if isNetworkMessage(req.Type) && !scheduledCommandCreatorCan(cmd, models.PermissionSendGlobal) {
+			return "", fmt.Errorf("%s may not send %s messages", cmd.CreatedByUsername, req.Type)
+		}
+		result, err := DeliverMessage(&req, cmd.CreatedByUsername)
+		if err != nil {
+			return "", err
 		}
-		if message == "" {
-			return "", nil
+		switch result.Delivery {
+		case messageDeliveryBroadcast:
+			if result.Error != "" {
+				return "", fmt.Errorf("message %s", result.Error)
+			}
+			if result.Target == "" {
+				return "Message sent to the network", nil
+			}
+			return "Message sent to " + result.Target, nil
+		case messageDeliveryJob:
+			return fmt.Sprintf("Message queued for %d recipients as job %d", result.Recipients, *result.JobID), nil
 		}
-		// Note: UnrealIRCd RPC doesn't have a direct message method
-		// This would need to be implemented via a custom module or PRIVMSG
-		return "Message sent to " + cmd.Target, nil
+		return fmt.Sprintf("Message sent to %d of %d recipients", result.Sent, result.Recipients), nil
 
 	case "rehash":
 		_, err := manager.WithRetry(func(client *rpc.Client) (interface{}, error) {
@@ -330,9 +407,88 @@ func executeCommand(cmd *models.ScheduledCommand) (string, error) {
 		}
 		return "Server " + cmd.Target + " rehashed", nil
 
+	case "server_connect", "server_disconnect":
+		reason, _ := params["reason"].(string)
+		if strings.TrimSpace(reason) == "" {
+			return "", fmt.Errorf("a reason is required to %s a server", strings.TrimPrefix(cmd.Command, "server_"))
+		}
+		if cmd.Command == "server_connect" {
+			via, _ := params["via"].(string)
+			if err := ConnectServerLink(cmd.Target, via, reason, cmd.CreatedByUsername); err != nil {
+				return "", err
+			}
+			return "Connect to " + cmd.Target + " initiated", nil
+		}
+		if err := DisconnectServerLink(cmd.Target, reason, cmd.CreatedByUsername); err != nil {
+			return "", err
+		}
+		return "Server " + cmd.Target + " disconnected", nil
+
+	case "channel_snapshot":
+		taken, err := TakeChannelSnapshots(cmd.Target, SnapshotTriggerScheduled, cmd.CreatedByUsername)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Took %d channel snapshots", taken), nil
+
 	default:
-		return "", nil
+		if _, ok := userOperations[cmd.Command]; ok {
+			return RunUserOperation(cmd.Command, cmd.Target, userOperationFromParams(params))
+		}
+		return "", fmt.Errorf("unknown command type: %s", cmd.Command)
+	}
+}
+
+// scheduledCommandCreatorCan reports whether the user who scheduled a command still has a permission
+func scheduledCommandCreatorCan(cmd *models.ScheduledCommand, permission string) bool {
+	var user models.User
+	if err := database.Get().Preload("Role").Preload("Role.Permissions").First(&user, cmd.CreatedBy).Error; err != nil {
+		return false
+	}
+	return auth.UserCan(&user, permission)
+}
+
+// scheduledCommandPermission returns the permission needed, on top of ban_users, to schedule a command
+func scheduledCommandPermission(command string, params map[string]interface{}) string {
+	switch command {
+	case "message":
+		messageType, _ := params["type"].(string)
+		if id, ok := params["template_id"].(float64); ok && messageType == "" {
+			var template models.MessageTemplate
+			if database.Get().First(&template, uint(id)).Error == nil {
+				messageType = template.Type
+			}
+		}
+		if isNetworkMessage(messageType) {
+			return models.PermissionSendGlobal
+		}
+		return models.PermissionSendMessages
+	case "channel_snapshot":
+		return models.PermissionEditChannel
+	case "server_connect", "server_disconnect":
+		return models.PermissionServerLink
+	}
+	if op, ok := userOperations[command]; ok {
+		return op.permission
+	}
+	return ""
+}
+
+// validateScheduledCommandParams checks the parameters a command type cannot run without,
+// so a bad user operation is rejected when it is scheduled rather than when it runs
+func validateScheduledCommandParams(command string, params map[string]interface{}) error {
+	switch command {
+	case "server_connect", "server_disconnect":
+		if reason, _ := params["reason"].(string); strings.TrimSpace(reason) == "" {
+			return fmt.Errorf("a reason is required to connect or disconnect a server")
+		}
+	}
+	if op, ok := userOperations[command]; ok {
+		if _, err := op.params(userOperationFromParams(params)); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
 // calculateNextRun calculates the next run time based on a simple schedule
@@ -0,0 +1,302 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/database"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/database/models"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/rpc"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/utils"
+)
+
+// Server drift kinds
+const (
+	ServerDriftModuleMissing     = "module_missing"     // Module loaded on some servers but not others
+	ServerDriftModuleVersion     = "module_version"     // Third-party module loaded in different versions
+	ServerDriftUnrealIRCdVersion = "unrealircd_version" // Servers run different UnrealIRCd versions
+)
+
+// serverConsistencyMu keeps the scheduled and on-demand checks from running at the same time
+var serverConsistencyMu sync.Mutex
+
+// ServerModuleSummary is what the consistency check found on one server
+type ServerModuleSummary struct {
+	Name       string   `json:"name"`
+	Version    string   `json:"version"`
+	Modules    int      `json:"modules"`
+	ThirdParty []string `json:"third_party"`
+	Error      string   `json:"error,omitempty"` // The module list could not be fetched; the server is left out of the comparison
+}
+
+// ConsistencyFinding is one way the servers differ
+type ConsistencyFinding struct {
+	Kind       string              `json:"kind"`
+	Subject    string              `json:"subject"`
+	ThirdParty bool                `json:"third_party,omitempty"`
+	LoadedOn   []string            `json:"loaded_on,omitempty"`
+	MissingOn  []string            `json:"missing_on,omitempty"`
+	Versions   map[string][]string `json:"versions,omitempty"` // Version -> servers
+	Since      *time.Time          `json:"since,omitempty"`    // When the drift was first detected
+}
+
+// ServerConsistencyReport compares the modules and versions of all servers
+type ServerConsistencyReport struct {
+	CheckedAt         time.Time             `json:"checked_at"`
+	Consistent        bool                  `json:"consistent"`
+	Servers           []ServerModuleSummary `json:"servers"`
+	Findings          []ConsistencyFinding  `json:"findings"`
+	ThirdPartyModules map[string][]string   `json:"third_party_modules"` // Module -> servers loading it
+}
+
+// GetServerConsistency compares module lists and UnrealIRCd versions across all servers.
+// The findings are recorded the same way as the scheduled check.
+func GetServerConsistency(c *gin.Context) {
+	report, err := runServerConsistencyCheck()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Consistency check failed: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// GetServerDrift returns the recorded module and version drift
+func GetServerDrift(c *gin.Context) {
+	var drift []models.ServerDrift
+	if err := database.Get().Order("kind ASC, subject ASC").Find(&drift).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch server drift"})
+		return
+	}
+	c.JSON(http.StatusOK, drift)
+}
+
+// CheckServerConsistency compares all servers and raises a SERVER_MODULE_DRIFT alert when
+// drift appears or changes. The scheduler runs it every ten minutes.
+func CheckServerConsistency() {
+	report, err := runServerConsistencyCheck()
+	if err != nil {
+		log.Printf("[Consistency] Server consistency check failed: %v", err)
+		return
+	}
+	if !report.Consistent {
+		log.Printf("[Consistency] %d module/version differences across %d servers", len(report.Findings), len(report.Servers))
+	}
+}
+
+// Helper functions
+
+func runServerConsistencyCheck() (*ServerConsistencyReport, error) {
+	serverConsistencyMu.Lock()
+	defer serverConsistencyMu.Unlock()
+
+	manager := rpc.GetManager()
+	result, err := manager.WithRetry(func(client *rpc.Client) (interface{}, error) {
+		return client.Server().GetAll()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ServerConsistencyReport{
+		CheckedAt:         time.Now(),
+		Servers:           []ServerModuleSummary{},
+		Findings:          []ConsistencyFinding{},
+		ThirdPartyModules: map[string][]string{},
+	}
+
+	// module -> server -> module version, for the servers whose module list was fetched
+	loaded := map[string]map[string]string{}
+	thirdParty := map[string]bool{}
+	versions := map[string][]string{}
+	compared := []string{}
+	incomplete := false
+
+	servers := parseServerList(result)
+	sort.Slice(servers, func(i, j int) bool { return servers[i].Name < servers[j].Name })
+	for _, server := range servers {
+		if serverULined(server) {
+			continue // Services do not answer server.module_list
+		}
+		summary := ServerModuleSummary{Name: server.Name, Version: serverSoftware(server), ThirdParty: []string{}}
+
+		modules, err := manager.WithRetry(func(client *rpc.Client) (interface{}, error) {
+			return client.Query("server.module_list", map[string]interface{}{
+				"server": server.Name,
+			}, false)
+		})
+		if err != nil {
+			summary.Error = err.Error()
+			incomplete = true
+			report.Servers = append(report.Servers, summary)
+			continue
+		}
+
+		for _, item := range utils.SafeMapGetSlice(utils.InterfaceToMap(modules), "list") {
+			module := utils.InterfaceToMap(item)
+			name := utils.SafeMapGetString(module, "name")
+			if name == "" {
+				continue
+			}
+			if loaded[name] == nil {
+				loaded[name] = map[string]string{}
+			}
+			loaded[name][server.Name] = utils.SafeMapGetString(module, "version")
+			summary.Modules++
+			if utils.SafeMapGetBool(module, "third_party") {
+				thirdParty[name] = true
+				summary.ThirdParty = append(summary.ThirdParty, name)
+				report.ThirdPartyModules[name] = append(report.ThirdPartyModules[name], server.Name)
+			}
+		}
+		sort.Strings(summary.ThirdParty)
+
+		if summary.Version != "" {
+			versions[summary.Version] = append(versions[summary.Version], server.Name)
+		}
+		compared = append(compared, server.Name)
+		report.Servers = append(report.Servers, summary)
+	}
+
+	if len(versions) > 1 {
+		report.Findings = append(report.Findings, ConsistencyFinding{
+			Kind:     ServerDriftUnrealIRCdVersion,
+			Subject:  "unrealircd",
+			Versions: versions,
+		})
+	}
+
+	names := make([]string, 0, len(loaded))
+	for name := range loaded {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		on := loaded[name]
+		if len(on) < len(compared) {
+			finding := ConsistencyFinding{
+				Kind:       ServerDriftModuleMissing,
+				Subject:    name,
+				ThirdParty: thirdParty[name],
+				LoadedOn:   []string{},
+				MissingOn:  []string{},
+			}
+			for _, server := range compared {
+				if _, ok := on[server]; ok {
+					finding.LoadedOn = append(finding.LoadedOn, server)
+				} else {
+					finding.MissingOn = append(finding.MissingOn, server)
+				}
+			}
+			report.Findings = append(report.Findings, finding)
+		}
+
+		// Bundled modules carry the UnrealIRCd version, which is compared above
+		if !thirdParty[name] {
+			continue
+		}
+		moduleVersions := map[string][]string{}
+		for _, server := range compared {
+			if version, ok := on[server]; ok {
+				moduleVersions[version] = append(moduleVersions[version], server)
+			}
+		}
+		if len(moduleVersions) > 1 {
+			report.Findings = append(report.Findings, ConsistencyFinding{
+				Kind:       ServerDriftModuleVersion,
+				Subject:    name,
+				ThirdParty: true,
+				Versions:   moduleVersions,
+			})
+		}
+	}
+
+	report.Consistent = len(report.Findings) == 0
+	recordServerDrift(report, incomplete)
+	return report, nil
+}
+
+// recordServerDrift stores the findings, removes drift that is gone and raises one alert
+// for the findings that are new or have changed. When a server could not be checked,
+// earlier drift is kept rather than treated as resolved.
+func recordServerDrift(report *ServerConsistencyReport, incomplete bool) {
+	db := database.Get()
+	now := time.Now()
+	changed := []string{}
+	seen := map[string]bool{}
+
+	for i := range report.Findings {
+		finding := &report.Findings[i]
+		details, _ := json.Marshal(finding)
+		seen[finding.Kind+"\x00"+finding.Subject] = true
+
+		var drift models.ServerDrift
+		isNew := db.Where("kind = ? AND subject = ?", finding.Kind, finding.Subject).First(&drift).Error != nil
+		if isNew || drift.Details != string(details) {
+			changed = append(changed, serverDriftDescription(finding))
+		}
+		drift.Kind = finding.Kind
+		drift.Subject = finding.Subject
+		drift.Details = string(details)
+		drift.LastCheckedAt = now
+		if err := db.Save(&drift).Error; err != nil {
+			log.Printf("[Consistency] Failed to record drift for %s: %v", finding.Subject, err)
+		}
+		since := drift.CreatedAt
+		finding.Since = &since
+	}
+
+	if !incomplete {
+		var recorded []models.ServerDrift
+		db.Find(&recorded)
+		for _, drift := range recorded {
+			if !seen[drift.Kind+"\x00"+drift.Subject] {
+				db.Delete(&drift)
+			}
+		}
+	}
+
+	if len(changed) == 0 {
+		return
+	}
+	msg := fmt.Sprintf("Server module/version drift detected: %s", strings.Join(changed, "; "))
+	if len(changed) > 5 {
+		msg = fmt.Sprintf("Server module/version drift detected: %s; and %d more", strings.Join(changed[:5], "; "), len(changed)-5)
+	}
+	raisePanelAlert("SERVER_MODULE_DRIFT", "warn", msg, map[string]string{
+		"findings": strconv.Itoa(len(report.Findings)),
+		"changed":  strconv.Itoa(len(changed)),
+	})
+}
+
+// serverDriftDescription describes a finding in a few words for the alert message
+func serverDriftDescription(finding *ConsistencyFinding) string {
+	switch finding.Kind {
+	case ServerDriftModuleMissing:
+		return fmt.Sprintf("%s missing on %s", finding.Subject, strings.Join(finding.MissingOn, ", "))
+	case ServerDriftModuleVersion:
+		return fmt.Sprintf("%s loaded in %d versions", finding.Subject, len(finding.Versions))
+	default:
+		return fmt.Sprintf("servers run %d UnrealIRCd versions", len(finding.Versions))
+	}
+}
+
+// serverULined reports whether the server is a U-Lined (services) server
+func serverULined(server IRCServer) bool {
+	return utils.SafeMapGetBool(server.Server, "ulined")
+}
+
+// serverSoftware returns the software version a server reports, e.g. UnrealIRCd-6.1.2
+func serverSoftware(server IRCServer) string {
+	if software := utils.SafeMapGetString(server.Features, "software"); software != "" {
+		return software
+	}
+	return utils.SafeMapGetString(utils.SafeMapGetMap(server.Server, "features"), "software")
+}
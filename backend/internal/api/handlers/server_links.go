@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/api/middleware"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/database"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/database/models"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/rpc"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/utils"
+)
+
+// Server link attempt statuses
+const (
+	LinkStatusPending      = "pending"
+	LinkStatusLinked       = "linked"
+	LinkStatusFailed       = "failed"
+	LinkStatusDisconnected = "disconnected"
+)
+
+const (
+	linkPendingTimeout = 10 * time.Minute // Pending attempts older than this are not shown
+	linkFailedShownFor = time.Hour        // Failed attempts are shown in the topology this long
+)
+
+// ServerLinkRequest connects or disconnects a server. Confirm must repeat the server name.
+type ServerLinkRequest struct {
+	Reason  string `json:"reason"`
+	Confirm string `json:"confirm"`
+	Via     string `json:"via"` // Connect only: the server that makes the connection
+}
+
+// ConnectServer asks a server to connect to the link block :name (server.connect)
+func ConnectServer(c *gin.Context) {
+	handleServerLinkRequest(c, "connect")
+}
+
+// DisconnectServer disconnects (SQUITs) the server :name from the network (server.disconnect)
+func DisconnectServer(c *gin.Context) {
+	handleServerLinkRequest(c, "disconnect")
+}
+
+// GetServerLinkAttempts returns recent link attempts and link events, newest first
+func GetServerLinkAttempts(c *gin.Context) {
+	limit := 100
+	if l, err := strconv.Atoi(c.Query("limit")); err == nil && l > 0 && l <= 1000 {
+		limit = l
+	}
+
+	query := database.Get().Model(&models.ServerLinkAttempt{})
+	if server := c.Query("server"); server != "" {
+		query = query.Where("LOWER(server) = ?", strings.ToLower(server))
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var attempts []models.ServerLinkAttempt
+	if err := query.Order("updated_at DESC").Limit(limit).Find(&attempts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get link attempts"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"attempts": attempts, "total": len(attempts)})
+}
+
+// ConnectServerLink calls server.connect for a link block and records a pending attempt.
+// via, if set, is the server asked to make the connection.
+func ConnectServerLink(link, via, reason, requestedBy string) error {
+	params := map[string]interface{}{"link": link}
+	if via != "" {
+		params["server"] = via
+	}
+	_, err := rpc.GetManager().WithRetry(func(client *rpc.Client) (interface{}, error) {
+		return client.Query("server.connect", params, false)
+	})
+
+	attempt := models.ServerLinkAttempt{
+		Server:      link,
+		Via:         via,
+		Action:      "connect",
+		Status:      LinkStatusPending,
+		Reason:      reason,
+		RequestedBy: requestedBy,
+	}
+	if err != nil {
+		attempt.Status = LinkStatusFailed
+		attempt.Message = err.Error()
+	}
+	database.Get().Create(&attempt)
+	return err
+}
+
+// DisconnectServerLink calls server.disconnect to SQUIT a server
+func DisconnectServerLink(server, reason, requestedBy string) error {
+	_, err := rpc.GetManager().WithRetry(func(client *rpc.Client) (interface{}, error) {
+		return client.Query("server.disconnect", map[string]interface{}{
+			"link":   server,
+			"reason": reason,
+		}, false)
+	})
+
+	attempt := models.ServerLinkAttempt{
+		Server:      server,
+		Action:      "disconnect",
+		Status:      LinkStatusDisconnected,
+		Reason:      reason,
+		RequestedBy: requestedBy,
+	}
+	if err != nil {
+		attempt.Status = LinkStatusFailed
+		attempt.Message = err.Error()
+	}
+	database.Get().Create(&attempt)
+	return err
+}
+
+// IngestServerLinkLogEvent tracks link attempts from link log events: LINK_CONNECTING
+// marks an attempt pending, SERVER_LINKED completes it and LINK_ERROR_*, LINK_DENIED_*
+// and timeouts fail it. SQUITs and lost links are recorded as disconnected.
+func IngestServerLinkLogEvent(entry map[string]interface{}) {
+	eventID := utils.SafeMapGetString(entry, "event_id")
+	status := serverLinkEventStatus(eventID)
+	if status == "" {
+		return
+	}
+
+	server := ""
+	if linkBlock := utils.SafeMapGetMap(entry, "link_block"); linkBlock != nil {
+		server = utils.SafeMapGetString(linkBlock, "name")
+	}
+	if server == "" {
+		server = utils.SafeMapGetString(utils.SafeMapGetMap(entry, "client"), "name")
+	}
+	if server == "" {
+		return
+	}
+
+	db := database.Get()
+	if db == nil {
+		return
+	}
+	msg := utils.SafeMapGetString(entry, "msg")
+
+	// Update the open attempt for the server, if any, so that an attempt and the
+	// retries of an autoconnect link block show up as one row
+	if status != LinkStatusDisconnected {
+		var attempt models.ServerLinkAttempt
+		err := db.Where("LOWER(server) = ? AND action = ? AND status IN ? AND updated_at > ?",
+			strings.ToLower(server), "connect", []string{LinkStatusPending, LinkStatusFailed}, time.Now().Add(-linkPendingTimeout)).
+			Order("updated_at DESC").First(&attempt).Error
+		if err == nil {
+			attempt.Status = status
+			attempt.EventID = eventID
+			attempt.Message = msg
+			if err := db.Save(&attempt).Error; err != nil {
+				log.Printf("[Links] Failed to update link attempt for %s: %v", server, err)
+			}
+			return
+		}
+		if status == LinkStatusLinked {
+			return // Linked without an attempt, e.g. during a netmerge; the topology shows it
+		}
+	} else {
+		// The SQUIT of a disconnect made through the panel completes that row
+		var attempt models.ServerLinkAttempt
+		err := db.Where("LOWER(server) = ? AND action = ? AND status = ? AND event_id = ? AND updated_at > ?",
+			strings.ToLower(server), "disconnect", LinkStatusDisconnected, "", time.Now().Add(-time.Minute)).
+			Order("updated_at DESC").First(&attempt).Error
+		if err == nil {
+			attempt.EventID = eventID
+			attempt.Message = msg
+			db.Save(&attempt)
+			return
+		}
+	}
+
+	attempt := models.ServerLinkAttempt{
+		Server:  server,
+		Action:  "connect",
+		Status:  status,
+		EventID: eventID,
+		Message: msg,
+	}
+	if status == LinkStatusDisconnected {
+		attempt.Action = "disconnect"
+	}
+	if err := db.Create(&attempt).Error; err != nil {
+		log.Printf("[Links] Failed to record link event for %s: %v", server, err)
+	}
+}
+
+// Helper functions
+
+func handleServerLinkRequest(c *gin.Context, action string) {
+	name := c.Param("name")
+	var req ServerLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A reason is required"})
+		return
+	}
+	if !strings.EqualFold(strings.TrimSpace(req.Confirm), name) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Type the server name in confirm to " + action + " it"})
+		return
+	}
+	if hasControlChars(req.Reason) || hasControlChars(name) || hasControlChars(req.Via) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Server names and reason may not contain line breaks"})
+		return
+	}
+
+	currentUser := middleware.GetCurrentUser(c)
+	if currentUser == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	var err error
+	if action == "connect" {
+		err = ConnectServerLink(name, strings.TrimSpace(req.Via), req.Reason, currentUser.Username)
+	} else {
+		err = DisconnectServerLink(name, req.Reason, currentUser.Username)
+	}
+
+	details := map[string]string{
+		"server": name,
+		"reason": req.Reason,
+	}
+	if req.Via != "" && action == "connect" {
+		details["via"] = req.Via
+	}
+	if err != nil {
+		details["error"] = err.Error()
+	}
+	logAction(c, currentUser, action+"_server", details)
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to %s %s: %s", action, name, err.Error())})
+		return
+	}
+	if action == "connect" {
+		c.JSON(http.StatusOK, gin.H{"message": "Connect to " + name + " initiated; watch the topology for the result"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Server " + name + " disconnected"})
+}
+
+// serverLinkEventStatus maps a link log event onto an attempt status, or "" for other events
+func serverLinkEventStatus(eventID string) string {
+	switch {
+	case eventID == "LINK_CONNECTING":
+		return LinkStatusPending
+	case eventID == "LINK_CONNECTED", strings.HasPrefix(eventID, "SERVER_LINKED"):
+		return LinkStatusLinked
+	case strings.HasPrefix(eventID, "LINK_ERROR"), strings.HasPrefix(eventID, "LINK_DENIED"),
+		strings.HasSuffix(eventID, "_TIMEOUT") && strings.HasPrefix(eventID, "LINK_"):
+		return LinkStatusFailed
+	case eventID == "SERVER_SQUIT", eventID == "LINK_DISCONNECTED", eventID == "SQUIT_COMMAND":
+		return LinkStatusDisconnected
+	}
+	return ""
+}
+
+// activeLinkAttempts returns the pending and recently failed link attempts shown in the topology
+func activeLinkAttempts() []models.ServerLinkAttempt {
+	attempts := []models.ServerLinkAttempt{}
+	db := database.Get()
+	if db == nil {
+		return attempts
+	}
+	now := time.Now()
+	db.Where("(status = ? AND updated_at > ?) OR (status = ? AND updated_at > ?)",
+		LinkStatusPending, now.Add(-linkPendingTimeout), LinkStatusFailed, now.Add(-linkFailedShownFor)).
+		Order("updated_at DESC").Find(&attempts)
+	return attempts
+}
@@ -0,0 +1,472 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/database"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/database/models"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/rpc"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/utils"
+)
+
+// Default retention in days and user loss alert thresholds
+const (
+	defaultServerMetricsDays      = 30
+	defaultServerUserLossPercent  = 25
+	defaultServerUserLossMinUsers = 20
+)
+
+const (
+	serverRestartSlack     = time.Minute     // Boot times closer than this are the same boot
+	serverUserLossWindow   = 5 * time.Minute // User counts are only compared with a sample this recent
+	maxServerMetricsPoints = 300
+	serverLagTimeout       = 5 * time.Second // A probe that takes longer counts as no answer
+	serverLagWorkers       = 4               // Servers probed at once, each over its own connection
+)
+
+// ServerMetricsPoint is a server's user count, lag and uptime over one chart bucket
+type ServerMetricsPoint struct {
+	Time          time.Time `json:"time"`
+	AvgUsers      float64   `json:"avg_users"`
+	MinUsers      int       `json:"min_users"`
+	MaxUsers      int       `json:"max_users"`
+	AvgLagMS      *float64  `json:"avg_lag_ms,omitempty"` // Empty if the server did not answer in the bucket
+	MaxLagMS      *int      `json:"max_lag_ms,omitempty"`
+	UptimeSeconds int64     `json:"uptime_seconds"` // At the last sample of the bucket; 0 if the boot time is unknown
+	Samples       int       `json:"samples"`
+}
+
+// ServerStatus is the latest sample of a server
+type ServerStatus struct {
+	Server        string     `json:"server"`
+	SampledAt     time.Time  `json:"sampled_at"`
+	NumUsers      int        `json:"num_users"`
+	BootTime      *time.Time `json:"boot_time,omitempty"`
+	UptimeSeconds int64      `json:"uptime_seconds,omitempty"`
+	Synced        bool       `json:"synced"`
+	LagMS         *int       `json:"lag_ms,omitempty"`
+}
+
+// serverSampleSeen is the last sample of each server, keyed by lowercased name
+var serverSampleSeen struct {
+	mu      sync.Mutex
+	samples map[string]models.ServerSample
+}
+
+// serverLagClients are dedicated RPC connections used only for lag probes, so a slow or
+// failing probe never reconnects the shared client. Each worker of a sampler run owns one
+// slot; a connection is closed after a failed probe and opened again on the next run.
+var serverLagClients struct {
+	mu      sync.Mutex
+	clients [serverLagWorkers]*rpc.Client
+}
+
+// GetServersMetrics returns the latest sample of every server
+func GetServersMetrics(c *gin.Context) {
+	db := database.Get()
+	var samples []models.ServerSample
+	if err := db.Where("id IN (?)", db.Model(&models.ServerSample{}).Select("MAX(id)").Group("server")).
+		Order("server").Find(&samples).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get server metrics"})
+		return
+	}
+
+	statuses := make([]ServerStatus, 0, len(samples))
+	for _, sample := range samples {
+		statuses = append(statuses, serverStatus(sample))
+	}
+	c.JSON(http.StatusOK, statuses)
+}
+
+// GetServerMetrics returns a server's user count, lag and uptime over a time range
+// (1h, 24h, 7d, 30d or custom with start_date and end_date), with the restarts in it.
+// Samples are averaged into at most 300 points.
+func GetServerMetrics(c *gin.Context) {
+	name := c.Param("name")
+	start, end := parseTimeRange(c.DefaultQuery("range", "24h"), c.Query("start_date"), c.Query("end_date"))
+
+	var samples []models.ServerSample
+	if err := database.Get().Where("LOWER(server) = ? AND sampled_at BETWEEN ? AND ?", strings.ToLower(name), start.UTC(), end.UTC()).
+		Order("sampled_at").Find(&samples).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get server metrics: " + err.Error()})
+		return
+	}
+
+	restarts := []ServerStatus{}
+	summary := gin.H{"samples": len(samples)}
+	lagTotal, lagSamples, maxLag := 0, 0, 0
+	minUsers, maxUsers := 0, 0
+	for i, sample := range samples {
+		if sample.Restarted {
+			restarts = append(restarts, serverStatus(sample))
+		}
+		if sample.LagMS != nil {
+			lagTotal += *sample.LagMS
+			lagSamples++
+			if *sample.LagMS > maxLag {
+				maxLag = *sample.LagMS
+			}
+		}
+		if i == 0 || sample.NumUsers < minUsers {
+			minUsers = sample.NumUsers
+		}
+		if sample.NumUsers > maxUsers {
+			maxUsers = sample.NumUsers
+		}
+	}
+	summary["restarts"] = len(restarts)
+	if lagSamples > 0 {
+		summary["avg_lag_ms"] = float64(lagTotal) / float64(lagSamples)
+		summary["max_lag_ms"] = maxLag
+	}
+	if len(samples) > 0 {
+		summary["min_users"] = minUsers
+		summary["max_users"] = maxUsers
+		summary["current"] = serverStatus(samples[len(samples)-1])
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"server":   name,
+		"start":    start,
+		"end":      end,
+		"points":   serverMetricsPoints(samples, end.Sub(start)),
+		"restarts": restarts,
+		"summary":  summary,
+	})
+}
+
+// SampleServerMetrics records the user count, boot time, sync state and lag of every
+// server. A boot time that moved forward raises SERVER_RESTARTED and a large drop in
+// users raises SERVER_USER_LOSS. The scheduler runs it every minute.
+func SampleServerMetrics() {
+	db := database.Get()
+	if db == nil {
+		return
+	}
+
+	result, err := rpc.GetManager().WithRetry(func(client *rpc.Client) (interface{}, error) {
+		return client.Server().GetAll()
+	})
+	if err != nil {
+		return
+	}
+	servers := parseServerList(result)
+	if len(servers) == 0 {
+		return
+	}
+
+	probed := []string{}
+	for _, server := range servers {
+		if server.Name != "" && !serverULined(server) {
+			probed = append(probed, server.Name)
+		}
+	}
+	lags := measureServerLags(probed)
+
+	now := time.Now().UTC()
+	samples := make([]models.ServerSample, 0, len(servers))
+	for _, server := range servers {
+		if server.Name == "" {
+			continue
+		}
+		sample := models.ServerSample{
+			SampledAt: now,
+			Server:    server.Name,
+			NumUsers:  serverUserCount(server),
+			BootTime:  serverBootTime(server),
+			Synced:    serverSynced(server),
+			LagMS:     lags[server.Name],
+		}
+		samples = append(samples, sample)
+	}
+
+	compareServerSamples(samples)
+	if err := db.CreateInBatches(samples, 100).Error; err != nil {
+		log.Printf("[ServerMetrics] Failed to record server samples: %v", err)
+	}
+}
+
+// PruneServerMetrics removes server samples past their retention
+func PruneServerMetrics() {
+	days, _, _ := serverMetricsSettings()
+	database.Get().Where("sampled_at < ?", time.Now().UTC().AddDate(0, 0, -days)).Delete(&models.ServerSample{})
+}
+
+// Helper functions
+
+// compareServerSamples marks the samples of servers that restarted since their previous
+// sample, raises the restart and user loss alerts and remembers the samples
+func compareServerSamples(samples []models.ServerSample) {
+	serverSampleSeen.mu.Lock()
+	defer serverSampleSeen.mu.Unlock()
+
+	if serverSampleSeen.samples == nil {
+		serverSampleSeen.samples = make(map[string]models.ServerSample)
+		db := database.Get()
+		var latest []models.ServerSample
+		db.Where("id IN (?)", db.Model(&models.ServerSample{}).Select("MAX(id)").Group("server")).Find(&latest)
+		for _, sample := range latest {
+			serverSampleSeen.samples[strings.ToLower(sample.Server)] = sample
+		}
+	}
+
+	_, lossPercent, lossMinUsers := serverMetricsSettings()
+	for i := range samples {
+		sample := &samples[i]
+		key := strings.ToLower(sample.Server)
+		previous, seen := serverSampleSeen.samples[key]
+		if seen {
+			if sample.BootTime != nil && previous.BootTime != nil && sample.BootTime.Sub(*previous.BootTime) > serverRestartSlack {
+				sample.Restarted = true
+				uptime := previous.SampledAt.Sub(*previous.BootTime).Round(time.Minute)
+				raisePanelAlert("SERVER_RESTARTED", "warn",
+					fmt.Sprintf("Server %s restarted at %s (it had been up %s)", sample.Server, sample.BootTime.UTC().Format(time.RFC3339), uptime),
+					map[string]string{
+						"server":          sample.Server,
+						"boot_time":       sample.BootTime.UTC().Format(time.RFC3339),
+						"previous_uptime": strconv.FormatInt(int64(uptime.Seconds()), 10),
+					})
+			}
+
+			lost := previous.NumUsers - sample.NumUsers
+			if sample.SampledAt.Sub(previous.SampledAt) <= serverUserLossWindow &&
+				previous.NumUsers >= lossMinUsers && lost*100 >= previous.NumUsers*lossPercent {
+				raisePanelAlert("SERVER_USER_LOSS", "warn",
+					fmt.Sprintf("Server %s lost %d of its %d users (%d%%)", sample.Server, lost, previous.NumUsers, lost*100/previous.NumUsers),
+					map[string]string{
+						"server":         sample.Server,
+						"users_before":   strconv.Itoa(previous.NumUsers),
+						"users_after":    strconv.Itoa(sample.NumUsers),
+						"users_lost":     strconv.Itoa(lost),
+						"restarted":      strconv.FormatBool(sample.Restarted),
+						"sample_seconds": strconv.Itoa(int(sample.SampledAt.Sub(previous.SampledAt).Seconds())),
+					})
+			}
+		}
+		serverSampleSeen.samples[key] = *sample
+	}
+}
+
+// measureServerLags probes the lag of servers, serverLagWorkers at a time. Servers that
+// did not answer are missing from the result. When the previous run is still probing,
+// nothing is measured.
+func measureServerLags(names []string) map[string]*int {
+	lags := make(map[string]*int, len(names))
+	if len(names) == 0 || !serverLagClients.mu.TryLock() {
+		return lags
+	}
+	defer serverLagClients.mu.Unlock()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	queue := make(chan string)
+	for slot := 0; slot < serverLagWorkers && slot < len(names); slot++ {
+		wg.Add(1)
+		go func(slot int) {
+			defer wg.Done()
+			for name := range queue {
+				if lag := measureServerLag(slot, name); lag != nil {
+					mu.Lock()
+					lags[name] = lag
+					mu.Unlock()
+				}
+			}
+		}(slot)
+	}
+	for _, name := range names {
+		queue <- name
+	}
+	close(queue)
+	wg.Wait()
+	return lags
+}
+
+// measureServerLag times a server.module_list request that the server the panel is
+// connected to forwards to the server, minus a local rpc.info request on the same
+// connection, so the round trip between the panel and its RPC server is not counted.
+// It returns nil when either request failed or took longer than serverLagTimeout.
+func measureServerLag(slot int, name string) *int {
+	client := serverLagClients.clients[slot]
+	if client != nil {
+		// Follow a switch of the active RPC server
+		if active, err := rpc.GetManager().GetActive(); err == nil && active.ServerName() != client.ServerName() {
+			client.Close()
+			client = nil
+		}
+	}
+	if client == nil {
+		var err error
+		if client, err = rpc.GetManager().NewDedicatedClient(); err != nil {
+			serverLagClients.clients[slot] = nil
+			return nil
+		}
+	}
+	serverLagClients.clients[slot] = client
+
+	local, err := timeServerLagQuery(client, "rpc.info", map[string]interface{}{})
+	var forwarded time.Duration
+	if err == nil {
+		forwarded, err = timeServerLagQuery(client, "server.module_list", map[string]interface{}{"server": name})
+	}
+	if err != nil {
+		client.Close()
+		serverLagClients.clients[slot] = nil
+		return nil
+	}
+
+	lag := int((forwarded - local).Milliseconds())
+	if lag < 0 {
+		lag = 0
+	}
+	return &lag
+}
+
+// timeServerLagQuery times one request, giving up after serverLagTimeout. The caller
+// closes the connection after a timeout, which ends the abandoned request.
+func timeServerLagQuery(client *rpc.Client, method string, params map[string]interface{}) (time.Duration, error) {
+	type reply struct {
+		elapsed time.Duration
+		err     error
+	}
+	done := make(chan reply, 1)
+	go func() {
+		started := time.Now()
+		_, err := client.Query(method, params, false)
+		done <- reply{time.Since(started), err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.elapsed, r.err
+	case <-time.After(serverLagTimeout):
+		return 0, errors.New(method + " timed out")
+	}
+}
+
+// serverMetricsPoints averages samples into buckets so that the range has at most
+// maxServerMetricsPoints points. Buckets without samples are left out.
+func serverMetricsPoints(samples []models.ServerSample, span time.Duration) []ServerMetricsPoint {
+	size := (span / maxServerMetricsPoints).Truncate(time.Minute)
+	if size < time.Minute {
+		size = time.Minute
+	}
+
+	points := []ServerMetricsPoint{}
+	var point *ServerMetricsPoint
+	usersTotal, lagTotal, lagSamples := 0, 0, 0
+	finish := func() {
+		if point == nil {
+			return
+		}
+		point.AvgUsers = float64(usersTotal) / float64(point.Samples)
+		if lagSamples > 0 {
+			avg := float64(lagTotal) / float64(lagSamples)
+			point.AvgLagMS = &avg
+		}
+		points = append(points, *point)
+	}
+
+	for _, sample := range samples {
+		bucket := sample.SampledAt.UTC().Truncate(size)
+		if point == nil || !point.Time.Equal(bucket) {
+			finish()
+			point = &ServerMetricsPoint{Time: bucket, MinUsers: sample.NumUsers, MaxUsers: sample.NumUsers}
+			usersTotal, lagTotal, lagSamples = 0, 0, 0
+		}
+		point.Samples++
+		usersTotal += sample.NumUsers
+		if sample.NumUsers < point.MinUsers {
+			point.MinUsers = sample.NumUsers
+		}
+		if sample.NumUsers > point.MaxUsers {
+			point.MaxUsers = sample.NumUsers
+		}
+		if sample.LagMS != nil {
+			lagTotal += *sample.LagMS
+			lagSamples++
+			if point.MaxLagMS == nil || *sample.LagMS > *point.MaxLagMS {
+				lag := *sample.LagMS
+				point.MaxLagMS = &lag
+			}
+		}
+		point.UptimeSeconds = serverStatus(sample).UptimeSeconds
+	}
+	finish()
+	return points
+}
+
+func serverStatus(sample models.ServerSample) ServerStatus {
+	status := ServerStatus{
+		Server:    sample.Server,
+		SampledAt: sample.SampledAt,
+		NumUsers:  sample.NumUsers,
+		BootTime:  sample.BootTime,
+		Synced:    sample.Synced,
+		LagMS:     sample.LagMS,
+	}
+	if sample.BootTime != nil && sample.SampledAt.After(*sample.BootTime) {
+		status.UptimeSeconds = int64(sample.SampledAt.Sub(*sample.BootTime).Seconds())
+	}
+	return status
+}
+
+// serverMetricsSettings returns the sample retention in days and the user loss alert
+// thresholds: the share of users lost in percent and the users a server must have had
+func serverMetricsSettings() (int, int, int) {
+	values := []int{defaultServerMetricsDays, defaultServerUserLossPercent, defaultServerUserLossMinUsers}
+	db := database.Get()
+	for i, key := range []string{"server_metrics_days", "server_user_loss_percent", "server_user_loss_min_users"} {
+		var setting models.Setting
+		if db.Where("key = ?", key).First(&setting).Error == nil {
+			if n, err := strconv.Atoi(setting.Value); err == nil && n > 0 {
+				values[i] = n
+			}
+		}
+	}
+	return values[0], values[1], values[2]
+}
+
+// serverUserCount returns the number of users on a server
+func serverUserCount(server IRCServer) int {
+	if server.NumUsers > 0 {
+		return server.NumUsers
+	}
+	return utils.SafeMapGetInt(server.Server, "num_users")
+}
+
+// serverBootTime returns when a server booted. UnrealIRCd reports boot_time as an
+// ISO 8601 string; older versions send a Unix timestamp.
+func serverBootTime(server IRCServer) *time.Time {
+	var boot time.Time
+	if value, ok := server.Server["boot_time"].(string); ok {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil
+		}
+		boot = parsed.UTC()
+	} else if unix := int64(utils.SafeMapGetInt(server.Server, "boot_time")); unix > 0 {
+		boot = time.Unix(unix, 0).UTC()
+	} else if server.Boot > 0 {
+		boot = time.Unix(server.Boot, 0).UTC()
+	} else {
+		return nil
+	}
+	return &boot
+}
+
+// serverSynced reports whether a server has finished syncing with the network.
+// Servers that do not report it are taken to be synced.
+func serverSynced(server IRCServer) bool {
+	if synced, ok := server.Server["synced"].(bool); ok {
+		return synced
+	}
+	return true
+}
@@ -0,0 +1,466 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/api/middleware"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/database"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/database/models"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/rpc"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/utils"
+)
+
+// Rehash run modes
+const (
+	RehashModeSequential = "sequential"
+	RehashModeWaves      = "waves"
+)
+
+// Rehash run and per-server statuses
+const (
+	RehashRunRunning   = "running"
+	RehashRunCompleted = "completed"
+	RehashRunFailed    = "failed"  // Finished, but at least one server failed
+	RehashRunStopped   = "stopped" // Stopped on the first failure
+
+	RehashServerPending     = "pending"
+	RehashServerSuccess     = "success"
+	RehashServerUnconfirmed = "unconfirmed" // Rehash sent, but no CONFIG_LOADED/CONFIG_NOT_LOADED seen in time
+	RehashServerFailed      = "failed"
+	RehashServerSkipped     = "skipped"
+)
+
+const (
+	defaultRehashWaveSize = 3
+	maxRehashWaveSize     = 50
+	defaultRehashWait     = 30 * time.Second // How long to wait for a server to report its config was (not) loaded
+	maxRehashWait         = 5 * time.Minute
+)
+
+// RehashAllRequest starts a network-wide rehash
+type RehashAllRequest struct {
+	Mode          string   `json:"mode"`            // sequential (default) or waves
+	WaveSize      int      `json:"wave_size"`       // Servers rehashed at once in waves mode
+	StopOnFailure bool     `json:"stop_on_failure"` // Skip the remaining servers after a wave with a failed or unconfirmed server
+	Servers       []string `json:"servers"`         // Servers to rehash, in order; defaults to all non U-Lined servers
+	WaitSeconds   int      `json:"wait_seconds"`    // Per-server wait for the config result
+}
+
+// rehashCollector gathers the config log events of one server while it rehashes
+type rehashCollector struct {
+	mu       sync.Mutex
+	warnings []string
+	errors   []string
+	done     chan bool // Receives whether the configuration was loaded
+	finished bool
+}
+
+var (
+	rehashCollectorsMu sync.Mutex
+	rehashCollectors   = map[string]*rehashCollector{}
+
+	rehashRunMu     sync.Mutex
+	rehashRunActive bool
+)
+
+// RehashAll rehashes all servers (or the given ones) one by one or in waves and stores a report
+func RehashAll(c *gin.Context) {
+	var req RehashAllRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	switch req.Mode {
+	case "", RehashModeSequential:
+		req.Mode = RehashModeSequential
+		req.WaveSize = 1
+	case RehashModeWaves:
+		if req.WaveSize <= 0 {
+			req.WaveSize = defaultRehashWaveSize
+		}
+		if req.WaveSize > maxRehashWaveSize {
+			req.WaveSize = maxRehashWaveSize
+		}
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Mode must be sequential or waves"})
+		return
+	}
+
+	wait := defaultRehashWait
+	if req.WaitSeconds > 0 {
+		wait = time.Duration(req.WaitSeconds) * time.Second
+		if wait > maxRehashWait {
+			wait = maxRehashWait
+		}
+	}
+
+	servers, err := rehashTargets(req.Servers)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get servers: " + err.Error()})
+		return
+	}
+	if len(servers) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No servers to rehash"})
+		return
+	}
+
+	currentUser := middleware.GetCurrentUser(c)
+	if currentUser == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	rehashRunMu.Lock()
+	if rehashRunActive {
+		rehashRunMu.Unlock()
+		c.JSON(http.StatusConflict, gin.H{"error": "A network-wide rehash is already running"})
+		return
+	}
+	rehashRunActive = true
+	rehashRunMu.Unlock()
+
+	run := models.RehashRun{
+		Mode:                req.Mode,
+		WaveSize:            req.WaveSize,
+		StopOnFailure:       req.StopOnFailure,
+		Status:              RehashRunRunning,
+		Total:               len(servers),
+		RequestedBy:         currentUser.ID,
+		RequestedByUsername: currentUser.Username,
+	}
+	for i, server := range servers {
+		run.Results = append(run.Results, models.RehashServerResult{
+			Server:   server,
+			Wave:     i/req.WaveSize + 1,
+			Status:   RehashServerPending,
+			Warnings: "[]",
+			Errors:   "[]",
+		})
+	}
+	if err := database.Get().Create(&run).Error; err != nil {
+		rehashRunMu.Lock()
+		rehashRunActive = false
+		rehashRunMu.Unlock()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create rehash run"})
+		return
+	}
+
+	logAction(c, currentUser, "rehash_all", map[string]string{
+		"run_id":          strconv.FormatUint(uint64(run.ID), 10),
+		"mode":            run.Mode,
+		"servers":         strings.Join(servers, ","),
+		"stop_on_failure": strconv.FormatBool(run.StopOnFailure),
+	})
+
+	go processRehashRun(run.ID, wait)
+
+	c.JSON(http.StatusAccepted, run)
+}
+
+// GetRehashRuns returns the stored rehash reports, newest first, without per-server results
+func GetRehashRuns(c *gin.Context) {
+	limit := 50
+	if l, err := strconv.Atoi(c.Query("limit")); err == nil && l > 0 && l <= 500 {
+		limit = l
+	}
+
+	var runs []models.RehashRun
+	if err := database.Get().Order("created_at DESC").Limit(limit).Find(&runs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get rehash runs"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"runs": runs, "total": len(runs)})
+}
+
+// GetRehashRun returns one rehash report with the outcome for each server
+func GetRehashRun(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return
+	}
+
+	run, err := loadRehashRun(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Rehash run not found"})
+		return
+	}
+	c.JSON(http.StatusOK, run)
+}
+
+// IngestRehashLogEvent hands config warnings, errors and the CONFIG_LOADED/CONFIG_NOT_LOADED
+// result of a server to the rehash waiting on it, if any
+func IngestRehashLogEvent(entry map[string]interface{}) {
+	source := strings.ToLower(utils.SafeMapGetString(entry, "log_source"))
+	if source == "" {
+		return
+	}
+	rehashCollectorsMu.Lock()
+	collector := rehashCollectors[source]
+	rehashCollectorsMu.Unlock()
+	if collector == nil {
+		return
+	}
+
+	eventID := utils.SafeMapGetString(entry, "event_id")
+	if utils.SafeMapGetString(entry, "subsystem") != "config" && !strings.HasPrefix(eventID, "CONFIG_") {
+		return
+	}
+
+	switch eventID {
+	case "CONFIG_LOADED":
+		collector.finish(true)
+		return
+	case "CONFIG_NOT_LOADED":
+		collector.finish(false)
+		return
+	}
+	collector.add(utils.SafeMapGetString(entry, "level"), utils.SafeMapGetString(entry, "msg"))
+}
+
+// Helper functions
+
+func (rc *rehashCollector) add(level, msg string) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	switch level {
+	case "warn", "warning":
+		rc.warnings = append(rc.warnings, msg)
+	case "error", "fatal":
+		rc.errors = append(rc.errors, msg)
+	}
+}
+
+func (rc *rehashCollector) finish(loaded bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.finished {
+		return
+	}
+	rc.finished = true
+	rc.done <- loaded
+}
+
+func (rc *rehashCollector) messages() ([]string, []string) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return append([]string{}, rc.warnings...), append([]string{}, rc.errors...)
+}
+
+// loadRehashRun loads a rehash run with its per-server results in rehash order
+func loadRehashRun(id uint) (*models.RehashRun, error) {
+	db := database.Get()
+	var run models.RehashRun
+	if err := db.First(&run, id).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Where("run_id = ?", run.ID).Order("wave ASC, id ASC").Find(&run.Results).Error; err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// rehashTargets returns the requested servers, or all servers except U-Lined ones sorted by name
+func rehashTargets(requested []string) ([]string, error) {
+	if len(requested) > 0 {
+		servers := []string{}
+		seen := map[string]bool{}
+		for _, s := range requested {
+			s = strings.TrimSpace(s)
+			if s == "" || seen[strings.ToLower(s)] || hasControlChars(s) {
+				continue
+			}
+			seen[strings.ToLower(s)] = true
+			servers = append(servers, s)
+		}
+		return servers, nil
+	}
+
+	result, err := rpc.GetManager().WithRetry(func(client *rpc.Client) (interface{}, error) {
+		return client.Server().GetAll()
+	})
+	if err != nil {
+		return nil, err
+	}
+	servers := []string{}
+	for _, srv := range utils.InterfaceToSlice(result) {
+		srvMap := utils.InterfaceToMap(srv)
+		if srvMap == nil {
+			continue
+		}
+		name := utils.SafeMapGetString(srvMap, "name")
+		ulined, _ := srvMap["ulined"].(bool)
+		if info := utils.InterfaceToMap(srvMap["server"]); info != nil && !ulined {
+			ulined, _ = info["ulined"].(bool)
+		}
+		if name != "" && !ulined {
+			servers = append(servers, name)
+		}
+	}
+	sort.Strings(servers)
+	return servers, nil
+}
+
+// processRehashRun rehashes the servers of a run wave by wave and records each outcome
+func processRehashRun(runID uint, wait time.Duration) {
+	defer func() {
+		rehashRunMu.Lock()
+		rehashRunActive = false
+		rehashRunMu.Unlock()
+	}()
+
+	db := database.Get()
+	run, err := loadRehashRun(runID)
+	if err != nil {
+		log.Printf("[Rehash] Failed to load rehash run %d: %v", runID, err)
+		return
+	}
+
+	stopped := false
+	for start := 0; start < len(run.Results); {
+		wave := run.Results[start].Wave
+		end := start
+		for end < len(run.Results) && run.Results[end].Wave == wave {
+			end++
+		}
+
+		if stopped {
+			for i := start; i < end; i++ {
+				run.Results[i].Status = RehashServerSkipped
+				db.Save(&run.Results[i])
+				run.Skipped++
+			}
+			start = end
+			continue
+		}
+
+		var wg sync.WaitGroup
+		for i := start; i < end; i++ {
+			wg.Add(1)
+			go func(result *models.RehashServerResult) {
+				defer wg.Done()
+				rehashServerForRun(result, wait)
+				db.Save(result)
+			}(&run.Results[i])
+		}
+		wg.Wait()
+
+		for i := start; i < end; i++ {
+			result := run.Results[i]
+			switch result.Status {
+			case RehashServerFailed:
+				run.Failed++
+			case RehashServerUnconfirmed:
+				run.Unconfirmed++
+			default:
+				run.Succeeded++
+			}
+			var warnings []string
+			json.Unmarshal([]byte(result.Warnings), &warnings)
+			run.Warnings += len(warnings)
+		}
+		// A server that did not confirm its rehash may not have loaded the config
+		if run.StopOnFailure && run.Failed+run.Unconfirmed > 0 {
+			stopped = true
+		}
+		start = end
+	}
+
+	now := time.Now()
+	run.CompletedAt = &now
+	switch {
+	case stopped && run.Skipped > 0:
+		run.Status = RehashRunStopped
+	case run.Failed > 0:
+		run.Status = RehashRunFailed
+	default:
+		run.Status = RehashRunCompleted
+	}
+	if err := db.Omit("Results").Save(run).Error; err != nil {
+		log.Printf("[Rehash] Failed to save rehash run %d: %v", run.ID, err)
+	}
+
+	if run.Failed+run.Unconfirmed > 0 {
+		raisePanelAlert("REHASH_FAILED", "warn",
+			fmt.Sprintf("Network-wide rehash #%d: %d of %d servers failed to rehash, %d unconfirmed", run.ID, run.Failed, run.Total, run.Unconfirmed),
+			map[string]string{"run_id": strconv.FormatUint(uint64(run.ID), 10), "status": run.Status})
+	}
+}
+
+// rehashServerForRun rehashes one server and fills in its result. The RPC server answers a
+// rehash of itself with the outcome and the config log; for other servers the outcome comes
+// from the CONFIG_LOADED/CONFIG_NOT_LOADED log event they send when done.
+func rehashServerForRun(result *models.RehashServerResult, wait time.Duration) {
+	started := time.Now()
+	result.StartedAt = &started
+
+	key := strings.ToLower(result.Server)
+	collector := &rehashCollector{done: make(chan bool, 1)}
+	rehashCollectorsMu.Lock()
+	rehashCollectors[key] = collector
+	rehashCollectorsMu.Unlock()
+	defer func() {
+		rehashCollectorsMu.Lock()
+		if rehashCollectors[key] == collector {
+			delete(rehashCollectors, key)
+		}
+		rehashCollectorsMu.Unlock()
+	}()
+
+	reply, err := rpc.GetManager().WithRetry(func(client *rpc.Client) (interface{}, error) {
+		return client.Query("server.rehash", map[string]interface{}{
+			"server": result.Server,
+		}, false)
+	})
+
+	var loaded *bool
+	if err != nil {
+		result.Error = err.Error()
+	} else if replyMap := utils.InterfaceToMap(reply); replyMap != nil {
+		if success, ok := replyMap["success"].(bool); ok {
+			loaded = &success
+			for _, l := range utils.InterfaceToSlice(replyMap["log"]) {
+				if entry := utils.InterfaceToMap(l); entry != nil {
+					collector.add(utils.SafeMapGetString(entry, "level"), utils.SafeMapGetString(entry, "msg"))
+				}
+			}
+		}
+	}
+	if err == nil && loaded == nil {
+		select {
+		case l := <-collector.done:
+			loaded = &l
+		case <-time.After(wait):
+		}
+	}
+
+	warnings, errors := collector.messages()
+	warningsJSON, _ := json.Marshal(warnings)
+	errorsJSON, _ := json.Marshal(errors)
+	result.Warnings = string(warningsJSON)
+	result.Errors = string(errorsJSON)
+
+	switch {
+	case err != nil:
+		result.Status = RehashServerFailed
+	case loaded == nil:
+		result.Status = RehashServerUnconfirmed
+	case *loaded:
+		result.Status = RehashServerSuccess
+	default:
+		result.Status = RehashServerFailed
+		result.Error = "Configuration not loaded"
+	}
+	finished := time.Now()
+	result.FinishedAt = &finished
+}
@@ -847,8 +847,15 @@ func logAction(c *gin.Context, user *models.User, action string, details map[str
 
 // SystemSettingsResponse represents the system settings
 type SystemSettingsResponse struct {
-	HIBPEnabled bool `json:"hibp_enabled"`
-	DebugMode   bool `json:"debug_mode"`
+	HIBPEnabled         bool   `json:"hibp_enabled"`
+	DebugMode           bool   `json:"debug_mode"`
+	BanContact          string `json:"ban_contact"`
+	ChannelSnapshotKeep int    `json:"channel_snapshot_keep"` // Snapshots kept per channel
+	ChannelSnapshotDays int    `json:"channel_snapshot_days"` // Days snapshots are kept
+	// Days channel member samples, hourly rollups and daily rollups (and topic changes) are kept
+	ChannelHistoryRawDays    int `json:"channel_history_raw_days"`
+	ChannelHistoryHourlyDays int `json:"channel_history_hourly_days"`
+	ChannelHistoryDailyDays  int `json:"channel_history_daily_days"`
 }
 
 // GetSystemSettings returns the system settings
@@ -872,13 +879,24 @@ func GetSystemSettings(c *gin.Context) {
 		settings.DebugMode = debugSetting.Value == "true"
 	}
 
+	settings.BanContact = GetBanContact()
+	settings.ChannelSnapshotKeep, settings.ChannelSnapshotDays = channelSnapshotRetention()
+	settings.ChannelHistoryRawDays, settings.ChannelHistoryHourlyDays, settings.ChannelHistoryDailyDays = channelHistoryRetention()
+
 	c.JSON(http.StatusOK, settings)
 }
 
 // UpdateSystemSettingsRequest represents an update settings request
 type UpdateSystemSettingsRequest struct {
-	HIBPEnabled *bool `json:"hibp_enabled"`
-	DebugMode   *bool `json:"debug_mode"`
+	HIBPEnabled         *bool   `json:"hibp_enabled"`
+	DebugMode           *bool   `json:"debug_mode"`
+	BanContact          *string `json:"ban_contact"`
+	ChannelSnapshotKeep *int    `json:"channel_snapshot_keep"`
+	ChannelSnapshotDays *int    `json:"channel_snapshot_days"`
+	// Channel history retention in days
+	ChannelHistoryRawDays    *int `json:"channel_history_raw_days"`
+	ChannelHistoryHourlyDays *int `json:"channel_history_hourly_days"`
+	ChannelHistoryDailyDays  *int `json:"channel_history_daily_days"`
 }
 
 // UpdateSystemSettings updates the system settings
@@ -889,6 +907,24 @@ func UpdateSystemSettings(c *gin.Context) {
 		return
 	}
 
+	// Validate retention before writing anything, so a bad value doesn't leave a partial update
+	retention := []struct {
+		key   string
+		value *int
+	}{
+		{"channel_snapshot_keep", req.ChannelSnapshotKeep},
+		{"channel_snapshot_days", req.ChannelSnapshotDays},
+		{"channel_history_raw_days", req.ChannelHistoryRawDays},
+		{"channel_history_hourly_days", req.ChannelHistoryHourlyDays},
+		{"channel_history_daily_days", req.ChannelHistoryDailyDays},
+	}
+	for _, setting := range retention {
+		if setting.value != nil && *setting.value < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": setting.key + " must be at least 1"})
+			return
+		}
+	}
+
 	db := database.Get()
 
 	// Update HIBP setting if provided
@@ -911,6 +947,20 @@ func UpdateSystemSettings(c *gin.Context) {
 		db.Model(&models.Setting{}).Where("key = ?", "debug_mode").Update("value", value)
 	}
 
+	// Update ban contact (used for the {contact} placeholder in ban reasons) if provided
+	if req.BanContact != nil {
+		db.Where("key = ?", "ban_contact").FirstOrCreate(&models.Setting{Key: "ban_contact"})
+		db.Model(&models.Setting{}).Where("key = ?", "ban_contact").Update("value", *req.BanContact)
+	}
+
+	// Update channel snapshot and history retention if provided
+	for _, setting := range retention {
+		if setting.value != nil {
+			db.Where("key = ?", setting.key).FirstOrCreate(&models.Setting{Key: setting.key})
+			db.Model(&models.Setting{}).Where("key = ?", setting.key).Update("value", strconv.Itoa(*setting.value))
+		}
+	}
+
 	// Log the change
 	currentUser := middleware.GetCurrentUser(c)
 	if currentUser != nil {
@@ -929,3 +979,13 @@ func IsHIBPEnabled() bool {
 	}
 	return setting.Value == "true"
 }
+
+// GetBanContact returns the contact address shown in ban reasons via {contact}
+func GetBanContact() string {
+	db := database.Get()
+	var setting models.Setting
+	if err := db.Where("key = ?", "ban_contact").First(&setting).Error; err != nil {
+		return ""
+	}
+	return setting.Value
+}
@@ -5,7 +5,6 @@ import (
 	"sort"
 
 	"github.com/gin-gonic/gin"
-	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/rpc"
 	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/utils"
 )
 
@@ -40,19 +39,13 @@ type CertFPGroup struct {
 
 // GetTLSStats returns TLS usage statistics
 func GetTLSStats(c *gin.Context) {
-	manager := rpc.GetManager()
-
 	// Get all users
-	result, err := manager.WithRetry(func(client *rpc.Client) (interface{}, error) {
-		return client.User().GetAll(4)
-	})
+	users, err := fetchFullUserList()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get users: " + err.Error()})
 		return
 	}
 
-	users := utils.InterfaceToSlice(result)
-
 	stats := TLSStats{
 		CipherUsage: make(map[string]int),
 	}
@@ -94,20 +87,15 @@ func GetTLSStats(c *gin.Context) {
 
 // GetTLSUsers returns users with TLS information
 func GetTLSUsers(c *gin.Context) {
-	manager := rpc.GetManager()
 	tlsOnly := c.Query("tls_only") == "true"
 	plainOnly := c.Query("plain_only") == "true"
 
 	// Get all users
-	result, err := manager.WithRetry(func(client *rpc.Client) (interface{}, error) {
-		return client.User().GetAll(4)
-	})
+	users, err := fetchFullUserList()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get users: " + err.Error()})
 		return
 	}
-
-	users := utils.InterfaceToSlice(result)
 	var tlsUsers []TLSUserInfo
 
 	for _, u := range users {
@@ -157,18 +145,12 @@ func GetTLSUsers(c *gin.Context) {
 
 // GetCertFPGroups returns users grouped by certificate fingerprint
 func GetCertFPGroups(c *gin.Context) {
-	manager := rpc.GetManager()
-
 	// Get all users
-	result, err := manager.WithRetry(func(client *rpc.Client) (interface{}, error) {
-		return client.User().GetAll(4)
-	})
+	users, err := fetchFullUserList()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get users: " + err.Error()})
 		return
 	}
-
-	users := utils.InterfaceToSlice(result)
 	fpGroups := make(map[string][]TLSUserInfo)
 
 	for _, u := range users {
@@ -219,19 +201,13 @@ func GetCertFPGroups(c *gin.Context) {
 
 // GetCipherStats returns detailed cipher usage statistics
 func GetCipherStats(c *gin.Context) {
-	manager := rpc.GetManager()
-
 	// Get all users
-	result, err := manager.WithRetry(func(client *rpc.Client) (interface{}, error) {
-		return client.User().GetAll(4)
-	})
+	users, err := fetchFullUserList()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get users: " + err.Error()})
 		return
 	}
 
-	users := utils.InterfaceToSlice(result)
-
 	type CipherInfo struct {
 		Cipher string   `json:"cipher"`
 		Count  int      `json:"count"`
@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/database/models"
 	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/rpc"
 	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/utils"
 )
@@ -31,9 +32,19 @@ type TopologyLink struct {
 
 // TopologyResponse represents the entire network topology
 type TopologyResponse struct {
-	Nodes []TopologyNode `json:"nodes"`
-	Links []TopologyLink `json:"links"`
-	Stats TopologyStats  `json:"stats"`
+	Nodes        []TopologyNode             `json:"nodes"`
+	Links        []TopologyLink             `json:"links"`
+	Stats        TopologyStats              `json:"stats"`
+	LinkAttempts []models.ServerLinkAttempt `json:"link_attempts"` // Pending and recently failed link attempts
+}
+
+// TopologyServer is one server as the topology sees it; topology snapshots store these
+type TopologyServer struct {
+	Name   string `json:"name"`
+	Uplink string `json:"uplink,omitempty"`
+	ULined bool   `json:"ulined"`
+	Users  int    `json:"users"`
+	Info   string `json:"info,omitempty"`
 }
 
 // TopologyStats represents network-wide statistics
@@ -79,80 +90,19 @@ func GetNetworkTopology(c *gin.Context) {
 		}
 	}
 
-	// Build topology - Initialize as empty slices (not nil) so JSON returns [] not null
-	nodes := []TopologyNode{}
-	links := []TopologyLink{}
-	serverNames := make(map[string]bool)
-
-	servers := utils.InterfaceToSlice(serverResult)
-	for _, srv := range servers {
-		srvMap := utils.InterfaceToMap(srv)
-		if srvMap == nil {
-			continue
-		}
-
-		// Debug: log raw server data to see what fields are available
-		log.Printf("[Topology] Server data: %+v", srvMap)
-
-		name := utils.SafeMapGetString(srvMap, "name")
-		uplink := utils.SafeMapGetString(srvMap, "uplink")
-
-		// Also try "server" nested object for uplink
-		if uplink == "" {
-			if serverInfo := utils.InterfaceToMap(srvMap["server"]); serverInfo != nil {
-				uplink = utils.SafeMapGetString(serverInfo, "uplink")
-			}
-		}
-
-		log.Printf("[Topology] Server %s has uplink: '%s'", name, uplink)
-		ulined := false
-		if u, ok := srvMap["ulined"].(bool); ok {
-			ulined = u
-		}
-
-		serverNames[name] = true
-
-		// Determine node type
-		nodeType := "leaf"
-		if ulined {
-			nodeType = "services"
-		} else if uplink == "" {
-			nodeType = "hub"
-		}
-
-		// Count users per server
-		users := utils.SafeMapGetInt(srvMap, "num_users")
-
-		node := TopologyNode{
-			ID:     name,
-			Name:   name,
-			Type:   nodeType,
-			Users:  users,
-			Uplink: uplink,
-			ULined: ulined,
-			Info:   utils.SafeMapGetString(srvMap, "server_info"),
-			Online: true,
-		}
-		nodes = append(nodes, node)
-
-		// Create link to uplink if exists
-		if uplink != "" {
-			link := TopologyLink{
-				Source: uplink,
-				Target: name,
-				Type:   nodeType,
-			}
-			links = append(links, link)
-			log.Printf("[Topology] Created link: %s -> %s", uplink, name)
-		}
+	servers := parseTopologyServers(serverResult)
+	for _, server := range servers {
+		log.Printf("[Topology] Server %s has uplink: '%s'", server.Name, server.Uplink)
 	}
+	nodes, links := buildTopologyGraph(servers)
 
 	log.Printf("[Topology] Total nodes: %d, Total links: %d", len(nodes), len(links))
 
 	response := TopologyResponse{
-		Nodes: nodes,
-		Links: links,
-		Stats: stats,
+		Nodes:        nodes,
+		Links:        links,
+		Stats:        stats,
+		LinkAttempts: activeLinkAttempts(),
 	}
 
 	c.JSON(http.StatusOK, response)
@@ -228,3 +178,72 @@ func GetServerDetails(c *gin.Context) {
 
 	c.JSON(http.StatusOK, details)
 }
+
+// Helper functions
+
+// parseTopologyServers extracts the fields the topology needs from a server.list result
+func parseTopologyServers(result interface{}) []TopologyServer {
+	servers := []TopologyServer{}
+	for _, srv := range utils.InterfaceToSlice(result) {
+		srvMap := utils.InterfaceToMap(srv)
+		if srvMap == nil {
+			continue
+		}
+
+		uplink := utils.SafeMapGetString(srvMap, "uplink")
+		if uplink == "" {
+			// Also try "server" nested object for uplink
+			uplink = utils.SafeMapGetString(utils.SafeMapGetMap(srvMap, "server"), "uplink")
+		}
+
+		servers = append(servers, TopologyServer{
+			Name:   utils.SafeMapGetString(srvMap, "name"),
+			Uplink: uplink,
+			ULined: utils.SafeMapGetBool(srvMap, "ulined"),
+			Users:  utils.SafeMapGetInt(srvMap, "num_users"),
+			Info:   utils.SafeMapGetString(srvMap, "server_info"),
+		})
+	}
+	return servers
+}
+
+// buildTopologyGraph turns a server list into graph nodes and uplink links.
+// Slices are never nil so that JSON returns [] rather than null.
+func buildTopologyGraph(servers []TopologyServer) ([]TopologyNode, []TopologyLink) {
+	nodes := []TopologyNode{}
+	links := []TopologyLink{}
+	for _, server := range servers {
+		nodeType := topologyNodeType(server)
+		nodes = append(nodes, TopologyNode{
+			ID:     server.Name,
+			Name:   server.Name,
+			Type:   nodeType,
+			Users:  server.Users,
+			Uplink: server.Uplink,
+			ULined: server.ULined,
+			Info:   server.Info,
+			Online: true,
+		})
+
+		// Create link to uplink if exists
+		if server.Uplink != "" {
+			links = append(links, TopologyLink{
+				Source: server.Uplink,
+				Target: server.Name,
+				Type:   nodeType,
+			})
+		}
+	}
+	return nodes, links
+}
+
+// topologyNodeType returns hub, leaf or services, the same way the topology graph does
+func topologyNodeType(server TopologyServer) string {
+	switch {
+	case server.ULined:
+		return "services"
+	case server.Uplink == "":
+		return "hub"
+	}
+	return "leaf"
+}
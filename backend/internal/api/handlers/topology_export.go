@@ -0,0 +1,451 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/rpc"
+)
+
+// TopologySplitImpact is what the network loses when one server splits off or goes down.
+// The part with the root server (the one without an uplink) stays; when the root itself
+// goes down, the part with the most users does.
+type TopologySplitImpact struct {
+	Server        string   `json:"server"`
+	UsersStranded int      `json:"users_stranded"`  // Users on the server and on the servers cut off with it
+	ServersCutOff []string `json:"servers_cut_off"` // The server itself and the servers behind it
+}
+
+// TopologyAnalysis describes how redundant the server tree is
+type TopologyAnalysis struct {
+	RecordedAt            *time.Time            `json:"recorded_at,omitempty"` // Set when analysing a past topology (?at=)
+	Servers               int                   `json:"servers"`
+	Links                 int                   `json:"links"`
+	Redundant             bool                  `json:"redundant"`                // No server is a single point of failure
+	SinglePointsOfFailure []TopologySplitImpact `json:"single_points_of_failure"` // Articulation points, most users stranded first
+	MaxLeafHops           int                   `json:"max_leaf_hops"`            // Longest path between two leaf servers
+	FarthestLeaves        []string              `json:"farthest_leaves"`
+	Impact                []TopologySplitImpact `json:"impact"`          // Every server, most users stranded first
+	Split                 *TopologySplitImpact  `json:"split,omitempty"` // The server asked for with ?server=
+}
+
+// topologyGraph is the undirected server graph the analysis works on
+type topologyGraph struct {
+	root  string // Server without an uplink, if any
+	names []string
+	users map[string]int
+	adj   map[string][]string
+	links int
+}
+
+// ExportTopology exports the topology as Graphviz DOT, GraphML or JSON Graph Format.
+// ?at= exports the topology as it was at that time.
+func ExportTopology(c *gin.Context) {
+	format := c.DefaultQuery("format", "dot")
+	servers, _, status, err := topologyServersFor(c.Query("at"))
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	sort.Slice(servers, func(i, j int) bool { return servers[i].Name < servers[j].Name })
+
+	filename := fmt.Sprintf("topology_%s", time.Now().Format("20060102_150405"))
+
+	switch format {
+	case "dot":
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.dot", filename))
+		c.Data(http.StatusOK, "text/vnd.graphviz; charset=utf-8", []byte(writeTopologyDOT(servers)))
+	case "graphml":
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.graphml", filename))
+		c.Data(http.StatusOK, "application/graphml+xml; charset=utf-8", []byte(writeTopologyGraphML(servers)))
+	case "jgf", "json":
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.json", filename))
+		c.JSON(http.StatusOK, buildTopologyJGF(servers))
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid format. Use dot, graphml or jgf"})
+	}
+}
+
+// GetTopologyAnalysis finds single points of failure (articulation points), the longest
+// path between leaf servers and the users stranded when each server splits.
+// ?server= picks one server's split impact, ?at= analyses the topology as it was at that time.
+func GetTopologyAnalysis(c *gin.Context) {
+	servers, recordedAt, status, err := topologyServersFor(c.Query("at"))
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	analysis := analyseTopology(servers)
+	analysis.RecordedAt = recordedAt
+
+	if server := c.Query("server"); server != "" {
+		for i := range analysis.Impact {
+			if strings.EqualFold(analysis.Impact[i].Server, server) {
+				analysis.Split = &analysis.Impact[i]
+				break
+			}
+		}
+		if analysis.Split == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Server not found in the topology"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, analysis)
+}
+
+// Helper functions
+
+// topologyServersFor returns the live servers, or those of the last snapshot at or before at
+// (RFC 3339) along with when it was recorded. On error it also returns the HTTP status to use.
+func topologyServersFor(at string) ([]TopologyServer, *time.Time, int, error) {
+	if at == "" {
+		result, err := rpc.GetManager().WithRetry(func(client *rpc.Client) (interface{}, error) {
+			return client.Server().GetAll()
+		})
+		if err != nil {
+			return nil, nil, http.StatusInternalServerError, errors.New("Failed to get servers: " + err.Error())
+		}
+		return parseTopologyServers(result), nil, 0, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, at)
+	if err != nil {
+		return nil, nil, http.StatusBadRequest, errors.New("at must be an RFC 3339 time")
+	}
+	snapshot, servers, err := topologySnapshotAt(t)
+	if err != nil {
+		return nil, nil, http.StatusNotFound, errors.New("No topology was recorded before that time")
+	}
+	return servers, &snapshot.CreatedAt, 0, nil
+}
+
+func newTopologyGraph(servers []TopologyServer) *topologyGraph {
+	g := &topologyGraph{users: map[string]int{}, adj: map[string][]string{}}
+	for _, server := range servers {
+		g.names = append(g.names, server.Name)
+		g.users[server.Name] = server.Users
+		g.adj[server.Name] = nil
+	}
+	sort.Strings(g.names)
+	for _, server := range servers {
+		if server.Uplink == "" && (g.root == "" || server.Name < g.root) {
+			g.root = server.Name
+		}
+	}
+	seen := map[string]bool{}
+	for _, server := range servers {
+		if _, ok := g.adj[server.Uplink]; !ok || server.Uplink == server.Name {
+			continue
+		}
+		a, b := server.Name, server.Uplink
+		if a > b {
+			a, b = b, a
+		}
+		if seen[a+"\x00"+b] {
+			continue
+		}
+		seen[a+"\x00"+b] = true
+		g.adj[a] = append(g.adj[a], b)
+		g.adj[b] = append(g.adj[b], a)
+		g.links++
+	}
+	for name := range g.adj {
+		sort.Strings(g.adj[name])
+	}
+	return g
+}
+
+func analyseTopology(servers []TopologyServer) *TopologyAnalysis {
+	g := newTopologyGraph(servers)
+	analysis := &TopologyAnalysis{
+		Servers:               len(g.names),
+		Links:                 g.links,
+		SinglePointsOfFailure: []TopologySplitImpact{},
+		FarthestLeaves:        []string{},
+		Impact:                []TopologySplitImpact{},
+	}
+
+	articulation := g.articulationPoints()
+	for _, name := range g.names {
+		impact := g.splitImpact(name)
+		analysis.Impact = append(analysis.Impact, impact)
+		if articulation[name] {
+			analysis.SinglePointsOfFailure = append(analysis.SinglePointsOfFailure, impact)
+		}
+	}
+	sortSplitImpact(analysis.Impact)
+	sortSplitImpact(analysis.SinglePointsOfFailure)
+	analysis.Redundant = len(analysis.SinglePointsOfFailure) == 0
+
+	ulined := map[string]bool{}
+	for _, server := range servers {
+		ulined[server.Name] = server.ULined
+	}
+	leaves := []string{}
+	for _, name := range g.names {
+		if len(g.adj[name]) == 1 && !ulined[name] {
+			leaves = append(leaves, name)
+		}
+	}
+	for i, from := range leaves {
+		dist := g.hops(from)
+		for _, to := range leaves[i+1:] {
+			if d, ok := dist[to]; ok && d > analysis.MaxLeafHops {
+				analysis.MaxLeafHops = d
+				analysis.FarthestLeaves = []string{from, to}
+			}
+		}
+	}
+	return analysis
+}
+
+// articulationPoints finds the servers whose loss disconnects the graph (Tarjan's algorithm)
+func (g *topologyGraph) articulationPoints() map[string]bool {
+	points := map[string]bool{}
+	disc := map[string]int{}
+	low := map[string]int{}
+	timer := 0
+
+	var visit func(node, parent string)
+	visit = func(node, parent string) {
+		timer++
+		disc[node] = timer
+		low[node] = timer
+		children := 0
+		for _, next := range g.adj[node] {
+			if next == parent {
+				continue
+			}
+			if _, seen := disc[next]; seen {
+				if disc[next] < low[node] {
+					low[node] = disc[next]
+				}
+				continue
+			}
+			children++
+			visit(next, node)
+			if low[next] < low[node] {
+				low[node] = low[next]
+			}
+			if parent != "" && low[next] >= disc[node] {
+				points[node] = true
+			}
+		}
+		if parent == "" && children > 1 {
+			points[node] = true
+		}
+	}
+
+	for _, name := range g.names {
+		if _, seen := disc[name]; !seen {
+			visit(name, "")
+		}
+	}
+	return points
+}
+
+// splitImpact removes a server and counts the users outside the part that stays
+func (g *topologyGraph) splitImpact(server string) TopologySplitImpact {
+	impact := TopologySplitImpact{
+		Server:        server,
+		UsersStranded: g.users[server],
+		ServersCutOff: []string{server},
+	}
+
+	visited := map[string]bool{server: true}
+	var parts [][]string
+	for _, name := range g.names {
+		if visited[name] {
+			continue
+		}
+		part := []string{}
+		queue := []string{name}
+		visited[name] = true
+		for len(queue) > 0 {
+			node := queue[0]
+			queue = queue[1:]
+			part = append(part, node)
+			for _, next := range g.adj[node] {
+				if !visited[next] {
+					visited[next] = true
+					queue = append(queue, next)
+				}
+			}
+		}
+		parts = append(parts, part)
+	}
+
+	users := func(part []string) int {
+		total := 0
+		for _, name := range part {
+			total += g.users[name]
+		}
+		return total
+	}
+	kept := -1
+	for i, part := range parts {
+		for _, name := range part {
+			if name == g.root {
+				kept = i
+			}
+		}
+	}
+	if kept < 0 {
+		// The root went down, or there is none: the part with the most users stays
+		for i, part := range parts {
+			if kept < 0 || users(part) > users(parts[kept]) ||
+				(users(part) == users(parts[kept]) && len(part) > len(parts[kept])) {
+				kept = i
+			}
+		}
+	}
+	for i, part := range parts {
+		if i == kept {
+			continue
+		}
+		impact.UsersStranded += users(part)
+		impact.ServersCutOff = append(impact.ServersCutOff, part...)
+	}
+	sort.Strings(impact.ServersCutOff[1:])
+	return impact
+}
+
+// hops returns the number of links from one server to every server it can reach
+func (g *topologyGraph) hops(from string) map[string]int {
+	dist := map[string]int{from: 0}
+	queue := []string{from}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		for _, next := range g.adj[node] {
+			if _, ok := dist[next]; !ok {
+				dist[next] = dist[node] + 1
+				queue = append(queue, next)
+			}
+		}
+	}
+	return dist
+}
+
+func sortSplitImpact(impacts []TopologySplitImpact) {
+	sort.SliceStable(impacts, func(i, j int) bool {
+		if impacts[i].UsersStranded != impacts[j].UsersStranded {
+			return impacts[i].UsersStranded > impacts[j].UsersStranded
+		}
+		return len(impacts[i].ServersCutOff) > len(impacts[j].ServersCutOff)
+	})
+}
+
+func writeTopologyDOT(servers []TopologyServer) string {
+	escape := func(s string) string {
+		return strings.ReplaceAll(strings.ReplaceAll(s, `\`, `\\`), `"`, `\"`)
+	}
+	quote := func(s string) string {
+		return `"` + escape(s) + `"`
+	}
+	shapes := map[string]string{"hub": "doubleoctagon", "leaf": "box", "services": "ellipse"}
+	known := map[string]bool{}
+	for _, server := range servers {
+		known[server.Name] = true
+	}
+
+	var b strings.Builder
+	b.WriteString("graph \"network\" {\n")
+	b.WriteString("\tnode [fontname=\"Helvetica\"];\n")
+	for _, server := range servers {
+		label := fmt.Sprintf("%s\\n%d users", escape(server.Name), server.Users)
+		fmt.Fprintf(&b, "\t%s [label=\"%s\", shape=%s];\n", quote(server.Name), label, shapes[topologyNodeType(server)])
+	}
+	for _, server := range servers {
+		if server.Uplink != "" && known[server.Uplink] {
+			fmt.Fprintf(&b, "\t%s -- %s;\n", quote(server.Uplink), quote(server.Name))
+		}
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func writeTopologyGraphML(servers []TopologyServer) string {
+	escape := func(s string) string {
+		var b strings.Builder
+		xml.EscapeText(&b, []byte(s))
+		return b.String()
+	}
+	known := map[string]bool{}
+	for _, server := range servers {
+		known[server.Name] = true
+	}
+
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<graphml xmlns="http://graphml.graphdrawing.org/xmlns">` + "\n")
+	b.WriteString(`  <key id="type" for="node" attr.name="type" attr.type="string"/>` + "\n")
+	b.WriteString(`  <key id="users" for="node" attr.name="users" attr.type="int"/>` + "\n")
+	b.WriteString(`  <key id="ulined" for="node" attr.name="ulined" attr.type="boolean"/>` + "\n")
+	b.WriteString(`  <key id="info" for="node" attr.name="info" attr.type="string"/>` + "\n")
+	b.WriteString(`  <graph id="network" edgedefault="undirected">` + "\n")
+	for _, server := range servers {
+		fmt.Fprintf(&b, "    <node id=\"%s\">\n", escape(server.Name))
+		fmt.Fprintf(&b, "      <data key=\"type\">%s</data>\n", topologyNodeType(server))
+		fmt.Fprintf(&b, "      <data key=\"users\">%d</data>\n", server.Users)
+		fmt.Fprintf(&b, "      <data key=\"ulined\">%s</data>\n", strconv.FormatBool(server.ULined))
+		if server.Info != "" {
+			fmt.Fprintf(&b, "      <data key=\"info\">%s</data>\n", escape(server.Info))
+		}
+		b.WriteString("    </node>\n")
+	}
+	for i, server := range servers {
+		if server.Uplink != "" && known[server.Uplink] {
+			fmt.Fprintf(&b, "    <edge id=\"e%d\" source=\"%s\" target=\"%s\"/>\n", i, escape(server.Uplink), escape(server.Name))
+		}
+	}
+	b.WriteString("  </graph>\n</graphml>\n")
+	return b.String()
+}
+
+// buildTopologyJGF builds a JSON Graph Format (v2) document
+func buildTopologyJGF(servers []TopologyServer) gin.H {
+	known := map[string]bool{}
+	for _, server := range servers {
+		known[server.Name] = true
+	}
+	nodes := gin.H{}
+	edges := []gin.H{}
+	for _, server := range servers {
+		nodes[server.Name] = gin.H{
+			"label": server.Name,
+			"metadata": gin.H{
+				"type":   topologyNodeType(server),
+				"users":  server.Users,
+				"ulined": server.ULined,
+				"info":   server.Info,
+			},
+		}
+		if server.Uplink != "" && known[server.Uplink] {
+			edges = append(edges, gin.H{
+				"source":   server.Uplink,
+				"target":   server.Name,
+				"relation": "uplink",
+			})
+		}
+	}
+	return gin.H{
+		"graph": gin.H{
+			"id":       "network",
+			"label":    "IRC network",
+			"type":     "irc-network",
+			"directed": false,
+			"nodes":    nodes,
+			"edges":    edges,
+		},
+	}
+}
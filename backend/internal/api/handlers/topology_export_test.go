@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testTopology is a tree with two hubs:
+//
+//	leaf1 - hub1 - hub2 - leaf2
+//	         |       |
+//	     services  leaf3
+func testTopology() []TopologyServer {
+	return []TopologyServer{
+		{Name: "hub1.example.net"},
+		{Name: "leaf1.example.net", Uplink: "hub1.example.net", Users: 10},
+		{Name: "hub2.example.net", Uplink: "hub1.example.net", Users: 5},
+		{Name: "leaf2.example.net", Uplink: "hub2.example.net", Users: 20},
+		{Name: "leaf3.example.net", Uplink: "hub2.example.net", Users: 30},
+		{Name: "services.example.net", Uplink: "hub1.example.net", ULined: true},
+	}
+}
+
+func TestArticulationPoints(t *testing.T) {
+	tests := []struct {
+		name    string
+		servers []TopologyServer
+		want    map[string]bool
+	}{
+		{"tree", testTopology(), map[string]bool{"hub1.example.net": true, "hub2.example.net": true}},
+		{"single server", []TopologyServer{{Name: "a"}}, map[string]bool{}},
+		{"two servers", []TopologyServer{{Name: "a"}, {Name: "b", Uplink: "a"}}, map[string]bool{}},
+		{
+			// Uplinks taken at different moments can form a ring, which has no single point of failure
+			"ring",
+			[]TopologyServer{{Name: "a", Uplink: "c"}, {Name: "b", Uplink: "a"}, {Name: "c", Uplink: "b"}},
+			map[string]bool{},
+		},
+		{
+			"ring with a tail",
+			[]TopologyServer{{Name: "a", Uplink: "c"}, {Name: "b", Uplink: "a"}, {Name: "c", Uplink: "b"}, {Name: "d", Uplink: "c"}},
+			map[string]bool{"c": true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newTopologyGraph(tt.servers).articulationPoints(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("articulationPoints() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitImpact(t *testing.T) {
+	g := newTopologyGraph(testTopology())
+	tests := []struct {
+		server   string
+		stranded int
+		cutOff   []string
+	}{
+		{"leaf3.example.net", 30, []string{"leaf3.example.net"}},
+		{"hub2.example.net", 55, []string{"hub2.example.net", "leaf2.example.net", "leaf3.example.net"}},
+		// Without the root, the part with the most users stays
+		{"hub1.example.net", 10, []string{"hub1.example.net", "leaf1.example.net", "services.example.net"}},
+	}
+	for _, tt := range tests {
+		got := g.splitImpact(tt.server)
+		if got.UsersStranded != tt.stranded || !reflect.DeepEqual(got.ServersCutOff, tt.cutOff) {
+			t.Errorf("splitImpact(%s) = %d %v, want %d %v", tt.server, got.UsersStranded, got.ServersCutOff, tt.stranded, tt.cutOff)
+		}
+	}
+}
+
+func TestAnalyseTopology(t *testing.T) {
+	analysis := analyseTopology(testTopology())
+	if analysis.Servers != 6 || analysis.Links != 5 || analysis.Redundant {
+		t.Errorf("servers, links, redundant = %d, %d, %v", analysis.Servers, analysis.Links, analysis.Redundant)
+	}
+	// U-Lined servers are not counted as leaves
+	if analysis.MaxLeafHops != 3 || !reflect.DeepEqual(analysis.FarthestLeaves, []string{"leaf1.example.net", "leaf2.example.net"}) {
+		t.Errorf("max leaf hops = %d %v, want 3 [leaf1 leaf2]", analysis.MaxLeafHops, analysis.FarthestLeaves)
+	}
+	points := []string{}
+	for _, impact := range analysis.SinglePointsOfFailure {
+		points = append(points, impact.Server)
+	}
+	if !reflect.DeepEqual(points, []string{"hub2.example.net", "hub1.example.net"}) {
+		t.Errorf("single points of failure = %v, want hub2 then hub1", points)
+	}
+	if first := analysis.Impact[0]; first.Server != "hub2.example.net" || first.UsersStranded != 55 {
+		t.Errorf("largest impact = %+v", first)
+	}
+
+	ring := analyseTopology([]TopologyServer{{Name: "a", Uplink: "c"}, {Name: "b", Uplink: "a"}, {Name: "c", Uplink: "b"}})
+	if !ring.Redundant || ring.MaxLeafHops != 0 {
+		t.Errorf("ring redundant, max leaf hops = %v, %d", ring.Redundant, ring.MaxLeafHops)
+	}
+}
+
+func TestWriteTopologyDOTEscaping(t *testing.T) {
+	dot := writeTopologyDOT([]TopologyServer{
+		{Name: `hub"1\x`, Users: 3},
+		{Name: "leaf", Uplink: `hub"1\x`},
+		{Name: "orphan", Uplink: "gone"},
+	})
+	for _, want := range []string{
+		`"hub\"1\\x" [label="hub\"1\\x\n3 users", shape=doubleoctagon];`,
+		`"hub\"1\\x" -- "leaf";`,
+	} {
+		if !strings.Contains(dot, want) {
+			t.Errorf("DOT output does not contain %s:\n%s", want, dot)
+		}
+	}
+	if strings.Contains(dot, `"gone"`) {
+		t.Errorf("DOT output links to an unknown uplink:\n%s", dot)
+	}
+}
+
+func TestWriteTopologyGraphMLEscaping(t *testing.T) {
+	servers := []TopologyServer{
+		{Name: "a&b", Info: `<Hub> "main" & 'backup'`},
+		{Name: "c<d", Uplink: "a&b"},
+	}
+	var doc struct {
+		Graph struct {
+			Nodes []struct {
+				ID   string `xml:"id,attr"`
+				Data []struct {
+					Key   string `xml:"key,attr"`
+					Value string `xml:",chardata"`
+				} `xml:"data"`
+			} `xml:"node"`
+			Edges []struct {
+				Source string `xml:"source,attr"`
+				Target string `xml:"target,attr"`
+			} `xml:"edge"`
+		} `xml:"graph"`
+	}
+	if err := xml.Unmarshal([]byte(writeTopologyGraphML(servers)), &doc); err != nil {
+		t.Fatalf("GraphML output is not valid XML: %v", err)
+	}
+	if len(doc.Graph.Nodes) != 2 || doc.Graph.Nodes[0].ID != "a&b" || doc.Graph.Nodes[1].ID != "c<d" {
+		t.Fatalf("nodes = %+v", doc.Graph.Nodes)
+	}
+	info := ""
+	for _, data := range doc.Graph.Nodes[0].Data {
+		if data.Key == "info" {
+			info = data.Value
+		}
+	}
+	if info != servers[0].Info {
+		t.Errorf("info = %q, want %q", info, servers[0].Info)
+	}
+	if len(doc.Graph.Edges) != 1 || doc.Graph.Edges[0].Source != "a&b" || doc.Graph.Edges[0].Target != "c<d" {
+		t.Errorf("edges = %+v", doc.Graph.Edges)
+	}
+}
@@ -0,0 +1,362 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/database"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/database/models"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/rpc"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/utils"
+)
+
+// Topology change kinds
+const (
+	TopologySplit  = "split"
+	TopologyJoin   = "join"
+	TopologyRelink = "relink"
+)
+
+// topologyEventDelay gives a netjoin time to finish syncing before the event-triggered snapshot
+const topologyEventDelay = 10 * time.Second
+
+var (
+	// topologySnapshotMu keeps the poller and event-triggered snapshots from racing
+	topologySnapshotMu sync.Mutex
+	// lastTopologyServers is the last polled server list; snapshots are only stored on changes,
+	// so their user counts can be old by the time a server splits
+	lastTopologyServers []TopologyServer
+
+	topologyEventMu      sync.Mutex
+	topologyEventPending bool
+)
+
+// TopologyTimelineEntry groups the changes seen in one snapshot: the servers that split off
+// together in a netsplit, joined together or moved to another uplink
+type TopologyTimelineEntry struct {
+	Time            time.Time `json:"time"`
+	SnapshotID      uint      `json:"snapshot_id"`
+	Kind            string    `json:"kind"`
+	Servers         []string  `json:"servers"`
+	Uplink          string    `json:"uplink,omitempty"` // Where a split happened or a join connected
+	UsersAffected   int       `json:"users_affected"`
+	DurationSeconds int       `json:"duration_seconds,omitempty"` // Split: until the last server came back, or so far; join: how long the servers were split
+	Ongoing         bool      `json:"ongoing,omitempty"`          // Split only: not all servers are back yet
+}
+
+// TopologyAsOfResponse is the topology recorded at or before a point in time
+type TopologyAsOfResponse struct {
+	At         time.Time      `json:"at"`
+	SnapshotID uint           `json:"snapshot_id"`
+	RecordedAt time.Time      `json:"recorded_at"` // When the network took this shape
+	Nodes      []TopologyNode `json:"nodes"`
+	Links      []TopologyLink `json:"links"`
+	Stats      TopologyStats  `json:"stats"`
+}
+
+// GetTopologyTimeline returns splits, joins and relinks over a time range
+// (1h, 24h, 7d, 30d, 90d, 365d or custom with start_date and end_date), newest first
+func GetTopologyTimeline(c *gin.Context) {
+	start, end := parseTimeRange(c.DefaultQuery("range", "7d"), c.Query("start_date"), c.Query("end_date"))
+
+	query := database.Get().Where("created_at >= ? AND created_at < ?", start, end)
+	if server := c.Query("server"); server != "" {
+		query = query.Where("LOWER(server) = ?", strings.ToLower(server))
+	}
+	if kind := c.Query("kind"); kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+
+	var changes []models.TopologyChange
+	if err := query.Order("created_at DESC, id ASC").Find(&changes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get topology timeline"})
+		return
+	}
+
+	entries := groupTopologyChanges(changes, time.Now())
+	c.JSON(http.StatusOK, gin.H{
+		"start":   start,
+		"end":     end,
+		"entries": entries,
+		"changes": changes,
+	})
+}
+
+// GetTopologyAsOf returns the topology as it was at ?at= (RFC 3339), from the last snapshot before it
+func GetTopologyAsOf(c *gin.Context) {
+	at := time.Now()
+	if s := c.Query("at"); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "at must be an RFC 3339 time"})
+			return
+		}
+		at = t
+	}
+
+	snapshot, servers, err := topologySnapshotAt(at)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No topology was recorded before that time"})
+		return
+	}
+	nodes, links := buildTopologyGraph(servers)
+
+	c.JSON(http.StatusOK, TopologyAsOfResponse{
+		At:         at,
+		SnapshotID: snapshot.ID,
+		RecordedAt: snapshot.CreatedAt,
+		Nodes:      nodes,
+		Links:      links,
+		Stats: TopologyStats{
+			TotalServers: snapshot.NumServers,
+			TotalUsers:   snapshot.NumUsers,
+		},
+	})
+}
+
+// RecordTopologySnapshot polls the server list and records a snapshot when the servers or
+// their uplinks differ from the last one. The scheduler runs it every minute.
+func RecordTopologySnapshot() {
+	recordTopologySnapshot("poll")
+}
+
+// IngestTopologyLogEvent records a snapshot shortly after a server links or splits, so that
+// changes between polls are caught with an accurate time
+func IngestTopologyLogEvent(entry map[string]interface{}) {
+	switch serverLinkEventStatus(utils.SafeMapGetString(entry, "event_id")) {
+	case LinkStatusLinked, LinkStatusDisconnected:
+	default:
+		return
+	}
+
+	topologyEventMu.Lock()
+	defer topologyEventMu.Unlock()
+	if topologyEventPending {
+		return
+	}
+	topologyEventPending = true
+	time.AfterFunc(topologyEventDelay, func() {
+		topologyEventMu.Lock()
+		topologyEventPending = false
+		topologyEventMu.Unlock()
+		recordTopologySnapshot("event")
+	})
+}
+
+// Helper functions
+
+func recordTopologySnapshot(trigger string) {
+	topologySnapshotMu.Lock()
+	defer topologySnapshotMu.Unlock()
+
+	db := database.Get()
+	if db == nil {
+		return
+	}
+	result, err := rpc.GetManager().WithRetry(func(client *rpc.Client) (interface{}, error) {
+		return client.Server().GetAll()
+	})
+	if err != nil {
+		return
+	}
+	servers := parseTopologyServers(result)
+	if len(servers) == 0 {
+		return
+	}
+	sort.Slice(servers, func(i, j int) bool { return servers[i].Name < servers[j].Name })
+	signature := topologySignature(servers)
+	before := lastTopologyServers
+	lastTopologyServers = servers
+
+	var previous models.TopologySnapshot
+	hasPrevious := db.Order("created_at DESC, id DESC").First(&previous).Error == nil
+	if hasPrevious && previous.Signature == signature {
+		return
+	}
+
+	serversJSON, _ := json.Marshal(servers)
+	snapshot := models.TopologySnapshot{
+		Trigger:    trigger,
+		Signature:  signature,
+		NumServers: len(servers),
+		Servers:    string(serversJSON),
+	}
+	for _, server := range servers {
+		snapshot.NumUsers += server.Users
+	}
+	if err := db.Create(&snapshot).Error; err != nil {
+		log.Printf("[Topology] Failed to record topology snapshot: %v", err)
+		return
+	}
+	if !hasPrevious {
+		return // Nothing to compare the first snapshot with
+	}
+
+	if before == nil || topologySignature(before) != previous.Signature {
+		before = nil
+		json.Unmarshal([]byte(previous.Servers), &before)
+	}
+	changes := diffTopology(before, servers)
+	for i := range changes {
+		change := &changes[i]
+		change.SnapshotID = snapshot.ID
+		change.CreatedAt = snapshot.CreatedAt
+		if change.Kind == TopologyJoin {
+			closeTopologySplit(change, snapshot.CreatedAt)
+		}
+		if err := db.Create(change).Error; err != nil {
+			log.Printf("[Topology] Failed to record %s of %s: %v", change.Kind, change.Server, err)
+		}
+	}
+	if len(changes) > 0 {
+		log.Printf("[Topology] Recorded %d topology changes (%s)", len(changes), trigger)
+	}
+}
+
+// topologySnapshotAt returns the last snapshot recorded at or before a time and its servers
+func topologySnapshotAt(at time.Time) (*models.TopologySnapshot, []TopologyServer, error) {
+	var snapshot models.TopologySnapshot
+	if err := database.Get().Where("created_at <= ?", at).Order("created_at DESC, id DESC").First(&snapshot).Error; err != nil {
+		return nil, nil, err
+	}
+	var servers []TopologyServer
+	if err := json.Unmarshal([]byte(snapshot.Servers), &servers); err != nil {
+		return nil, nil, err
+	}
+	return &snapshot, servers, nil
+}
+
+// diffTopology compares two server lists. Users affected by a split are the users the
+// server had before it; by a join or relink, the users it has now.
+func diffTopology(before, after []TopologyServer) []models.TopologyChange {
+	old := make(map[string]TopologyServer, len(before))
+	for _, server := range before {
+		old[strings.ToLower(server.Name)] = server
+	}
+	current := make(map[string]bool, len(after))
+	changes := []models.TopologyChange{}
+
+	for _, server := range after {
+		key := strings.ToLower(server.Name)
+		current[key] = true
+		prev, existed := old[key]
+		switch {
+		case !existed:
+			changes = append(changes, models.TopologyChange{
+				Kind:          TopologyJoin,
+				Server:        server.Name,
+				Uplink:        server.Uplink,
+				UsersAffected: server.Users,
+			})
+		case !strings.EqualFold(prev.Uplink, server.Uplink):
+			changes = append(changes, models.TopologyChange{
+				Kind:           TopologyRelink,
+				Server:         server.Name,
+				Uplink:         server.Uplink,
+				PreviousUplink: prev.Uplink,
+				UsersAffected:  server.Users,
+			})
+		}
+	}
+	for _, server := range before {
+		if !current[strings.ToLower(server.Name)] {
+			changes = append(changes, models.TopologyChange{
+				Kind:          TopologySplit,
+				Server:        server.Name,
+				Uplink:        server.Uplink,
+				UsersAffected: server.Users,
+			})
+		}
+	}
+	return changes
+}
+
+// closeTopologySplit ends the open split of a server that joined again and notes on the
+// join how long the server was gone
+func closeTopologySplit(join *models.TopologyChange, at time.Time) {
+	db := database.Get()
+	var split models.TopologyChange
+	err := db.Where("LOWER(server) = ? AND kind = ? AND ended_at IS NULL", strings.ToLower(join.Server), TopologySplit).
+		Order("created_at DESC").First(&split).Error
+	if err != nil {
+		return
+	}
+	duration := int(at.Sub(split.CreatedAt).Seconds())
+	split.EndedAt = &at
+	split.DurationSeconds = duration
+	db.Save(&split)
+	join.DurationSeconds = duration
+}
+
+// groupTopologyChanges turns changes into timeline entries, one per snapshot and kind.
+// Changes must be ordered newest first.
+func groupTopologyChanges(changes []models.TopologyChange, now time.Time) []TopologyTimelineEntry {
+	entries := []TopologyTimelineEntry{}
+	index := map[string]int{}
+	members := map[int]map[string]bool{}
+
+	for _, change := range changes {
+		key := change.Kind + "\x00" + strconv.FormatUint(uint64(change.SnapshotID), 10)
+		i, ok := index[key]
+		if !ok {
+			i = len(entries)
+			index[key] = i
+			members[i] = map[string]bool{}
+			entries = append(entries, TopologyTimelineEntry{
+				Time:       change.CreatedAt,
+				SnapshotID: change.SnapshotID,
+				Kind:       change.Kind,
+				Servers:    []string{},
+			})
+		}
+		entry := &entries[i]
+		entry.Servers = append(entry.Servers, change.Server)
+		entry.UsersAffected += change.UsersAffected
+		members[i][strings.ToLower(change.Server)] = true
+
+		switch change.Kind {
+		case TopologySplit:
+			duration := change.DurationSeconds
+			if change.EndedAt == nil {
+				entry.Ongoing = true
+				duration = int(now.Sub(change.CreatedAt).Seconds())
+			}
+			if duration > entry.DurationSeconds {
+				entry.DurationSeconds = duration
+			}
+		case TopologyJoin:
+			if change.DurationSeconds > entry.DurationSeconds {
+				entry.DurationSeconds = change.DurationSeconds
+			}
+		}
+	}
+
+	// The uplink of a group is the one outside it: where the split happened or the join connected
+	for _, change := range changes {
+		i := index[change.Kind+"\x00"+strconv.FormatUint(uint64(change.SnapshotID), 10)]
+		if change.Uplink != "" && !members[i][strings.ToLower(change.Uplink)] {
+			entries[i].Uplink = change.Uplink
+		}
+	}
+	return entries
+}
+
+// topologySignature hashes the server names and uplinks; user counts do not count as a change
+func topologySignature(servers []TopologyServer) string {
+	lines := make([]string, 0, len(servers))
+	for _, server := range servers {
+		lines = append(lines, strings.ToLower(server.Name)+">"+strings.ToLower(server.Uplink))
+	}
+	sort.Strings(lines)
+	sum := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	return hex.EncodeToString(sum[:])
+}
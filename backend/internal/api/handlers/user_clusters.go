@@ -0,0 +1,562 @@
+package handlers
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/api/middleware"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/database"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/database/models"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/rpc"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/utils"
+)
+
+// Cluster types, in the order they are reported
+const (
+	clusterTypeIP          = "ip"
+	clusterTypeIPv4Prefix  = "ipv4_24"
+	clusterTypeIPv6Prefix  = "ipv6_64"
+	clusterTypeCertFP      = "certfp"
+	clusterTypeIdent       = "ident"
+	clusterTypeRealname    = "realname"
+	clusterTypeNickPattern = "nick_pattern"
+)
+
+// clusterTypeWeights is how suspicious a shared attribute is on its own
+var clusterTypeWeights = map[string]int{
+	clusterTypeIP:          25,
+	clusterTypeIPv6Prefix:  20,
+	clusterTypeIPv4Prefix:  15,
+	clusterTypeCertFP:      25,
+	clusterTypeIdent:       10,
+	clusterTypeRealname:    15,
+	clusterTypeNickPattern: 10,
+}
+
+var clusterTypeOrder = []string{
+	clusterTypeIP,
+	clusterTypeIPv4Prefix,
+	clusterTypeIPv6Prefix,
+	clusterTypeCertFP,
+	clusterTypeIdent,
+	clusterTypeRealname,
+	clusterTypeNickPattern,
+}
+
+// clusterConnectWindow is how close together connect times must be to count as a connect burst
+const clusterConnectWindow = 5 * time.Minute
+
+// ClusterMember is a user in a cluster
+type ClusterMember struct {
+	Nick           string `json:"nick"`
+	Username       string `json:"username"`
+	Realname       string `json:"realname"`
+	IP             string `json:"ip"`
+	Hostname       string `json:"hostname"`
+	Account        string `json:"account,omitempty"`
+	Server         string `json:"server,omitempty"`
+	ConnectedSince int64  `json:"connected_since"`
+	Reputation     int    `json:"reputation"`
+}
+
+// SuggestedAction is a one-click action for a cluster, ready to post to the action endpoint
+type SuggestedAction struct {
+	Action      string   `json:"action"` // gline, kill or watchlist
+	Description string   `json:"description"`
+	Mask        string   `json:"mask,omitempty"`        // gline
+	Nicks       []string `json:"nicks,omitempty"`       // kill
+	WatchField  string   `json:"watch_field,omitempty"` // watchlist: nick, ip, host, realname
+	WatchValue  string   `json:"watch_value,omitempty"` // watchlist
+}
+
+// UserCluster is a group of users sharing an attribute
+type UserCluster struct {
+	Type      string            `json:"type"`
+	Key       string            `json:"key"`
+	Size      int               `json:"size"`
+	Score     int               `json:"score"`    // 0-100
+	Severity  string            `json:"severity"` // low, medium, high
+	Reasons   []string          `json:"reasons"`
+	Members   []ClusterMember   `json:"members"`
+	Suggested []SuggestedAction `json:"suggested_actions"`
+}
+
+// ClusterActionRequest represents a mass action on a cluster
+type ClusterActionRequest struct {
+	Action     string   `json:"action" binding:"required"` // gline, kill or watchlist
+	Mask       string   `json:"mask"`
+	BanType    string   `json:"ban_type"` // Defaults to gline
+	Duration   string   `json:"duration"` // gline: required, "0" for permanent
+	Nicks      []string `json:"nicks"`
+	WatchField string   `json:"watch_field"`
+	WatchValue string   `json:"watch_value"`
+	Reason     string   `json:"reason" binding:"required"`
+}
+
+// GetUserClusters groups connected users by shared attributes and scores suspicious groups
+func GetUserClusters(c *gin.Context) {
+	minSize, _ := strconv.Atoi(c.DefaultQuery("min_size", "3"))
+	if minSize < 2 {
+		minSize = 2
+	}
+	minScore, _ := strconv.Atoi(c.DefaultQuery("min_score", "0"))
+
+	types := make(map[string]bool)
+	if t := c.Query("types"); t != "" {
+		for _, name := range strings.Split(t, ",") {
+			types[strings.TrimSpace(name)] = true
+		}
+	} else {
+		for _, name := range clusterTypeOrder {
+			types[name] = true
+		}
+	}
+
+	list, err := fetchFullUserList()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get users: " + err.Error()})
+		return
+	}
+
+	groups := make(map[string]map[string][]ClusterMember)
+	for _, name := range clusterTypeOrder {
+		groups[name] = make(map[string][]ClusterMember)
+	}
+
+	analyzed := 0
+	for _, item := range list {
+		user := parseUser(item)
+		if user == nil || user.IP == "" || user.OperLogin != "" {
+			continue
+		}
+		ip := net.ParseIP(user.IP)
+		if ip == nil || ip.IsLoopback() {
+			continue
+		}
+		analyzed++
+
+		member := ClusterMember{
+			Nick:           user.Name,
+			Username:       user.Username,
+			Realname:       user.RealName,
+			IP:             user.IP,
+			Hostname:       user.Hostname,
+			Account:        user.Account,
+			Server:         user.Server,
+			ConnectedSince: user.ConnectedSince,
+			Reputation:     user.Reputation,
+		}
+
+		groups[clusterTypeIP][ip.String()] = append(groups[clusterTypeIP][ip.String()], member)
+		if ip4 := ip.To4(); ip4 != nil {
+			prefix := (&net.IPNet{IP: ip4.Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}).String()
+			groups[clusterTypeIPv4Prefix][prefix] = append(groups[clusterTypeIPv4Prefix][prefix], member)
+		} else {
+			prefix := (&net.IPNet{IP: ip.Mask(net.CIDRMask(64, 128)), Mask: net.CIDRMask(64, 128)}).String()
+			groups[clusterTypeIPv6Prefix][prefix] = append(groups[clusterTypeIPv6Prefix][prefix], member)
+		}
+		if certfp := certFPFromTLS(user.TLS); certfp != "" {
+			groups[clusterTypeCertFP][certfp] = append(groups[clusterTypeCertFP][certfp], member)
+		}
+		if ident := normalizeClusterText(strings.TrimPrefix(user.Username, "~")); ident != "" {
+			groups[clusterTypeIdent][ident] = append(groups[clusterTypeIdent][ident], member)
+		}
+		if realname := normalizeClusterText(user.RealName); realname != "" {
+			groups[clusterTypeRealname][realname] = append(groups[clusterTypeRealname][realname], member)
+		}
+		if shape := nickShape(user.Name); shape != "" {
+			groups[clusterTypeNickPattern][shape] = append(groups[clusterTypeNickPattern][shape], member)
+		}
+	}
+
+	clusters := make([]UserCluster, 0)
+	seen := make(map[string]bool)
+	for _, clusterType := range clusterTypeOrder {
+		if !types[clusterType] {
+			continue
+		}
+		for key, members := range groups[clusterType] {
+			if len(members) < minSize {
+				continue
+			}
+
+			// A broader grouping with exactly the same members adds nothing
+			signature := clusterSignature(members)
+			if seen[signature] {
+				continue
+			}
+			seen[signature] = true
+
+			cluster := scoreUserCluster(clusterType, key, members)
+			if cluster.Score < minScore {
+				continue
+			}
+			clusters = append(clusters, cluster)
+		}
+	}
+
+	sort.Slice(clusters, func(i, j int) bool {
+		if clusters[i].Score != clusters[j].Score {
+			return clusters[i].Score > clusters[j].Score
+		}
+		return clusters[i].Size > clusters[j].Size
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"clusters":       clusters,
+		"total":          len(clusters),
+		"users_analyzed": analyzed,
+	})
+}
+
+// ApplyClusterAction performs a mass action suggested for a cluster
+func ApplyClusterAction(c *gin.Context) {
+	var req ClusterActionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+
+	currentUser := middleware.GetCurrentUser(c)
+	if currentUser == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Not authenticated"})
+		return
+	}
+
+	manager := rpc.GetManager()
+
+	switch req.Action {
+	case "gline":
+		if req.Mask == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Mask is required"})
+			return
+		}
+		if !utils.ValidBanDuration(req.Duration) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A valid duration is required, e.g. 1d or 0 for permanent"})
+			return
+		}
+		banType := req.BanType
+		if banType == "" {
+			banType = "gline"
+		}
+		_, err := manager.WithRetry(func(client *rpc.Client) (interface{}, error) {
+			return client.ServerBan().Add(req.Mask, banType, req.Duration, req.Reason)
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add server ban: " + err.Error()})
+			return
+		}
+		recordPanelBanChange(currentUser.Username, ledgerActionAdd, banType, req.Mask, req.Reason, req.Duration)
+		logAction(c, currentUser, "cluster_gline", map[string]string{
+			"mask":     req.Mask,
+			"type":     banType,
+			"duration": req.Duration,
+			"reason":   req.Reason,
+		})
+		c.JSON(http.StatusOK, gin.H{"message": "Server ban added", "mask": req.Mask})
+
+	case "kill":
+		if len(req.Nicks) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No nicks given"})
+			return
+		}
+		results := make([]gin.H, 0, len(req.Nicks))
+		killed := 0
+		for _, nick := range req.Nicks {
+			_, err := manager.WithRetry(func(client *rpc.Client) (interface{}, error) {
+				return client.User().Kill(nick, req.Reason)
+			})
+			if err != nil {
+				results = append(results, gin.H{"nick": nick, "success": false, "error": err.Error()})
+				continue
+			}
+			killed++
+			results = append(results, gin.H{"nick": nick, "success": true})
+		}
+		logAction(c, currentUser, "cluster_kill", map[string]string{
+			"nicks":  strings.Join(req.Nicks, ","),
+			"killed": strconv.Itoa(killed),
+			"reason": req.Reason,
+		})
+		c.JSON(http.StatusOK, gin.H{"results": results, "killed": killed, "failed": len(req.Nicks) - killed})
+
+	case "watchlist":
+		watched := models.WatchedUser{
+			Reason:          req.Reason,
+			AddedBy:         currentUser.ID,
+			AddedByUsername: currentUser.Username,
+		}
+		switch req.WatchField {
+		case "nick":
+			watched.Nick = req.WatchValue
+		case "ip":
+			watched.IP = req.WatchValue
+		case "host":
+			watched.Host = req.WatchValue
+		case "realname":
+			watched.Realname = req.WatchValue
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid watch field, use nick, ip, host or realname"})
+			return
+		}
+		if req.WatchValue == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Watch value is required"})
+			return
+		}
+		if err := database.Get().Create(&watched).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create watched user"})
+			return
+		}
+		logAction(c, currentUser, "cluster_watch", map[string]string{
+			"field":  req.WatchField,
+			"value":  req.WatchValue,
+			"reason": req.Reason,
+		})
+		c.JSON(http.StatusCreated, watched)
+
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid action, use gline, kill or watchlist"})
+	}
+}
+
+// Helper functions
+
+func scoreUserCluster(clusterType, key string, members []ClusterMember) UserCluster {
+	sort.Slice(members, func(i, j int) bool {
+		return strings.ToLower(members[i].Nick) < strings.ToLower(members[j].Nick)
+	})
+
+	cluster := UserCluster{
+		Type:    clusterType,
+		Key:     key,
+		Size:    len(members),
+		Members: members,
+		Reasons: make([]string, 0),
+	}
+
+	score := clusterTypeWeights[clusterType]
+	cluster.Reasons = append(cluster.Reasons, fmt.Sprintf("%d users share %s %s", len(members), clusterTypeLabel(clusterType), key))
+
+	// Bigger groups are more suspicious, up to a point
+	sizeScore := len(members) * 8
+	if sizeScore > 40 {
+		sizeScore = 40
+	}
+	score += sizeScore
+
+	// Connect bursts: most members connected within a few minutes of each other
+	connectTimes := make([]int64, 0, len(members))
+	for _, m := range members {
+		if m.ConnectedSince > 0 {
+			connectTimes = append(connectTimes, m.ConnectedSince)
+		}
+	}
+	if burst := largestConnectBurst(connectTimes); burst >= 2 {
+		fraction := float64(burst) / float64(len(members))
+		score += int(fraction * 20)
+		if fraction >= 0.5 {
+			cluster.Reasons = append(cluster.Reasons, fmt.Sprintf("%d connected within %s of each other", burst, clusterConnectWindow))
+		}
+	}
+
+	// Bots rarely identify to services
+	withoutAccount := 0
+	reputation := 0
+	for _, m := range members {
+		if m.Account == "" {
+			withoutAccount++
+		}
+		reputation += m.Reputation
+	}
+	if withoutAccount == len(members) {
+		score += 10
+		cluster.Reasons = append(cluster.Reasons, "none are logged in to an account")
+	}
+	if reputation/len(members) < 10 {
+		score += 5
+		cluster.Reasons = append(cluster.Reasons, fmt.Sprintf("low average reputation (%d)", reputation/len(members)))
+	}
+
+	if score > 100 {
+		score = 100
+	}
+	cluster.Score = score
+	switch {
+	case score >= 70:
+		cluster.Severity = "high"
+	case score >= 40:
+		cluster.Severity = "medium"
+	default:
+		cluster.Severity = "low"
+	}
+
+	cluster.Suggested = suggestClusterActions(clusterType, key, members)
+	return cluster
+}
+
+func suggestClusterActions(clusterType, key string, members []ClusterMember) []SuggestedAction {
+	nicks := make([]string, 0, len(members))
+	for _, m := range members {
+		nicks = append(nicks, m.Nick)
+	}
+
+	actions := make([]SuggestedAction, 0, 3)
+	switch clusterType {
+	case clusterTypeIP, clusterTypeIPv4Prefix, clusterTypeIPv6Prefix:
+		actions = append(actions, SuggestedAction{
+			Action:      "gline",
+			Description: "G-Line " + key,
+			Mask:        "*@" + key,
+		})
+	}
+
+	actions = append(actions, SuggestedAction{
+		Action:      "kill",
+		Description: fmt.Sprintf("Kill all %d members", len(nicks)),
+		Nicks:       nicks,
+	})
+
+	switch clusterType {
+	case clusterTypeIP, clusterTypeIPv4Prefix, clusterTypeIPv6Prefix:
+		actions = append(actions, SuggestedAction{Action: "watchlist", Description: "Watch " + key, WatchField: "ip", WatchValue: key})
+	case clusterTypeRealname:
+		pattern := strings.ReplaceAll(key, "#", "?")
+		actions = append(actions, SuggestedAction{Action: "watchlist", Description: "Watch realname " + pattern, WatchField: "realname", WatchValue: pattern})
+	default:
+		if prefix := commonNickPrefix(nicks); len(prefix) >= 3 {
+			actions = append(actions, SuggestedAction{Action: "watchlist", Description: "Watch nicks " + prefix + "*", WatchField: "nick", WatchValue: prefix + "*"})
+		}
+	}
+
+	return actions
+}
+
+func clusterTypeLabel(clusterType string) string {
+	switch clusterType {
+	case clusterTypeIP:
+		return "IP"
+	case clusterTypeIPv4Prefix, clusterTypeIPv6Prefix:
+		return "prefix"
+	case clusterTypeCertFP:
+		return "certificate"
+	case clusterTypeIdent:
+		return "ident pattern"
+	case clusterTypeRealname:
+		return "realname pattern"
+	case clusterTypeNickPattern:
+		return "nick shape"
+	}
+	return clusterType
+}
+
+func clusterSignature(members []ClusterMember) string {
+	nicks := make([]string, 0, len(members))
+	for _, m := range members {
+		nicks = append(nicks, strings.ToLower(m.Nick))
+	}
+	sort.Strings(nicks)
+	return strings.Join(nicks, ",")
+}
+
+// largestConnectBurst returns the largest number of connect times within clusterConnectWindow
+func largestConnectBurst(times []int64) int {
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+	window := int64(clusterConnectWindow / time.Second)
+	best, start := 0, 0
+	for end := range times {
+		for times[end]-times[start] > window {
+			start++
+		}
+		if n := end - start + 1; n > best {
+			best = n
+		}
+	}
+	return best
+}
+
+// normalizeClusterText lowercases and replaces digits with # so "bot123" and "bot456" match
+func normalizeClusterText(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	var sb strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			sb.WriteByte('#')
+		} else {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// nickShape describes how a nick was generated as runs of character classes,
+// e.g. "Guest12345" is "A1a4d5". Nicks made of a single run are too common to be useful.
+func nickShape(nick string) string {
+	var sb strings.Builder
+	runs := 0
+	var last byte
+	count := 0
+	flush := func() {
+		if count > 0 {
+			sb.WriteByte(last)
+			sb.WriteString(strconv.Itoa(count))
+			runs++
+		}
+	}
+	for _, r := range nick {
+		var class byte
+		switch {
+		case r >= 'a' && r <= 'z':
+			class = 'a'
+		case r >= 'A' && r <= 'Z':
+			class = 'A'
+		case r >= '0' && r <= '9':
+			class = 'd'
+		default:
+			class = 's'
+		}
+		if class != last {
+			flush()
+			last = class
+			count = 0
+		}
+		count++
+	}
+	flush()
+
+	if runs < 2 || !strings.Contains(sb.String(), "d") {
+		return ""
+	}
+	return sb.String()
+}
+
+func commonNickPrefix(nicks []string) string {
+	if len(nicks) == 0 {
+		return ""
+	}
+	prefix := nicks[0]
+	for _, nick := range nicks[1:] {
+		for !strings.HasPrefix(strings.ToLower(nick), strings.ToLower(prefix)) {
+			prefix = prefix[:len(prefix)-1]
+			if prefix == "" {
+				return ""
+			}
+		}
+	}
+	return prefix
+}
+
+func certFPFromTLS(tls map[string]interface{}) string {
+	if tls == nil {
+		return ""
+	}
+	if fp, ok := tls["certfp"].(string); ok {
+		return fp
+	}
+	return ""
+}
@@ -0,0 +1,429 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/api/middleware"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/auth"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/database"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/database/models"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/rpc"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/services/geoip"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/services/reputation"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/utils"
+)
+
+// maxDossierJourneyEvents is how many journey events a dossier includes
+const maxDossierJourneyEvents = 500
+
+var unsafeFilenameChars = regexp.MustCompile(`[^A-Za-z0-9_\-]`)
+
+// UserDossier combines live and historical data about one user
+type UserDossier struct {
+	Nick           string                    `json:"nick"`
+	Online         bool                      `json:"online"`
+	GeneratedAt    time.Time                 `json:"generated_at"`
+	GeneratedBy    string                    `json:"generated_by,omitempty"`
+	IP             string                    `json:"ip,omitempty"`      // Live IP, or the last one seen in the journey history
+	Account        string                    `json:"account,omitempty"` // Live account, or the last one seen
+	CertFP         string                    `json:"certfp,omitempty"`
+	User           *IRCUser                  `json:"user,omitempty"` // Live user.get data
+	Geo            *geoip.Info               `json:"geo,omitempty"`
+	Reputation     *reputation.Result        `json:"reputation,omitempty"`
+	Journey        []models.UserJourneyEvent `json:"journey"` // Events for the nick, IP or account, newest first
+	SpamfilterHits []models.UserJourneyEvent `json:"spamfilter_hits"`
+	Watchlist      []models.WatchedUser      `json:"watchlist"` // Watch list entries matching the user
+	Notes          []models.Note             `json:"notes"`
+	ServerBans     []ServerBan               `json:"server_bans"`    // Server bans that currently match the user; null without view_bans
+	BanExceptions  []BanException            `json:"ban_exceptions"` // Ban exceptions that currently match the user; null without view_bans
+	SharedIP       []DossierPeer             `json:"shared_ip"`      // Other connected users on the same IP
+	SharedCertFP   []DossierPeer             `json:"shared_certfp"`  // Other connected users with the same certificate
+	Errors         []string                  `json:"errors,omitempty"`
+}
+
+// DossierPeer is another connected user related to the dossier subject
+type DossierPeer struct {
+	Nick     string `json:"nick"`
+	IP       string `json:"ip"`
+	Hostname string `json:"hostname"`
+	Account  string `json:"account,omitempty"`
+	Server   string `json:"server,omitempty"`
+}
+
+// GetUserDossier returns live data, history, notes, matching bans and related users for a nick
+func GetUserDossier(c *gin.Context) {
+	nick := c.Param("nick")
+	if nick == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nick is required"})
+		return
+	}
+
+	currentUser := middleware.GetCurrentUser(c)
+	dossier := buildUserDossier(nick, auth.UserCan(currentUser, models.PermissionViewBans))
+	if !dossier.Online && len(dossier.Journey) == 0 && len(dossier.Notes) == 0 && len(dossier.Watchlist) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User is not online and has no history"})
+		return
+	}
+
+	if currentUser != nil {
+		dossier.GeneratedBy = currentUser.Username
+	}
+	c.JSON(http.StatusOK, dossier)
+}
+
+// ExportUserDossier downloads a dossier as evidence, as JSON or plain text.
+// The SHA-256 of the file is returned in the X-Evidence-SHA256 header and the export is audited.
+func ExportUserDossier(c *gin.Context) {
+	nick := c.Param("nick")
+	if nick == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nick is required"})
+		return
+	}
+
+	currentUser := middleware.GetCurrentUser(c)
+	if currentUser == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Not authenticated"})
+		return
+	}
+
+	dossier := buildUserDossier(nick, auth.UserCan(currentUser, models.PermissionViewBans))
+	dossier.GeneratedBy = currentUser.Username
+
+	format := c.DefaultQuery("format", "json")
+	var data []byte
+	var contentType string
+	switch format {
+	case "json":
+		data, _ = json.MarshalIndent(dossier, "", "  ")
+		contentType = "application/json"
+	case "txt":
+		data = []byte(writeDossierText(dossier))
+		contentType = "text/plain; charset=utf-8"
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid format. Use json or txt"})
+		return
+	}
+
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+
+	logAction(c, currentUser, "export_user_dossier", map[string]string{
+		"nick":   nick,
+		"format": format,
+		"sha256": hash,
+	})
+
+	filename := fmt.Sprintf("dossier_%s_%s.%s", unsafeFilenameChars.ReplaceAllString(nick, "_"), dossier.GeneratedAt.Format("20060102_150405"), format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+	c.Header("X-Evidence-SHA256", hash)
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// Helper functions
+
+// buildUserDossier gathers everything known about a nick. Parts that fail to load are
+// listed in Errors so the rest of the dossier is still usable. Matching bans and
+// exceptions are only looked up with withBans; otherwise they are left nil.
+func buildUserDossier(nick string, withBans bool) *UserDossier {
+	dossier := &UserDossier{
+		Nick:           nick,
+		GeneratedAt:    time.Now().UTC(),
+		Journey:        []models.UserJourneyEvent{},
+		SpamfilterHits: []models.UserJourneyEvent{},
+		Watchlist:      []models.WatchedUser{},
+		Notes:          []models.Note{},
+		SharedIP:       []DossierPeer{},
+		SharedCertFP:   []DossierPeer{},
+	}
+	manager := rpc.GetManager()
+
+	// Live data
+	result, err := manager.WithRetry(func(client *rpc.Client) (interface{}, error) {
+		return client.User().Get(nick, 4)
+	})
+	if err == nil && result != nil {
+		if user := parseUser(result); user != nil {
+			dossier.Online = true
+			dossier.User = user
+			dossier.IP = user.IP
+			dossier.Account = user.Account
+			dossier.CertFP = utils.SafeMapGetString(user.TLS, "certfp")
+			dossier.Geo = user.Geo
+		}
+	}
+
+	db := database.Get()
+
+	// Offline users are identified by the last IP and account in their history
+	if !dossier.Online {
+		var last models.UserJourneyEvent
+		if db.Where("nick = ? AND (ip != '' OR account != '')", nick).Order("created_at DESC").First(&last).Error == nil {
+			dossier.IP = last.IP
+			dossier.Account = last.Account
+		}
+		dossier.Geo = geoip.Lookup(dossier.IP)
+	}
+
+	// Journey history for the nick, IP and account
+	conditions := []string{"nick = ?"}
+	args := []interface{}{nick}
+	if dossier.IP != "" {
+		conditions = append(conditions, "ip = ?")
+		args = append(args, dossier.IP)
+	}
+	if dossier.Account != "" {
+		conditions = append(conditions, "account = ?")
+		args = append(args, dossier.Account)
+	}
+	if err := db.Where(strings.Join(conditions, " OR "), args...).
+		Order("created_at DESC").Limit(maxDossierJourneyEvents).Find(&dossier.Journey).Error; err != nil {
+		dossier.Errors = append(dossier.Errors, "journey: "+err.Error())
+	}
+	enrichJourneyEvents(dossier.Journey)
+	for _, event := range dossier.Journey {
+		if event.EventType == "message" && strings.Contains(event.Details, `"spamfilter"`) {
+			dossier.SpamfilterHits = append(dossier.SpamfilterHits, event)
+		}
+	}
+
+	// Notes and watch list entries
+	if notes := findUserNotes(nick, dossier.IP, dossier.Account); notes != nil {
+		dossier.Notes = notes
+	}
+	subject := map[string]interface{}{
+		"name":    nick,
+		"ip":      dossier.IP,
+		"account": dossier.Account,
+	}
+	if dossier.User != nil {
+		subject["hostname"] = dossier.User.Hostname
+		subject["realname"] = dossier.User.RealName
+	}
+	var watched []models.WatchedUser
+	if err := db.Find(&watched).Error; err != nil {
+		dossier.Errors = append(dossier.Errors, "watchlist: "+err.Error())
+	}
+	for i := range watched {
+		if checkUserMatch(&watched[i], subject) {
+			dossier.Watchlist = append(dossier.Watchlist, watched[i])
+		}
+	}
+
+	// Reputation
+	if dossier.IP != "" && reputation.GetService().Enabled() {
+		dossier.Reputation, _ = reputation.GetService().Check(dossier.IP)
+	}
+
+	// Bans and exceptions that currently match
+	if withBans {
+		addDossierBans(dossier)
+	}
+
+	// Other users on the same IP or certificate
+	if dossier.IP != "" || dossier.CertFP != "" {
+		list, err := fetchFullUserList()
+		if err != nil {
+			dossier.Errors = append(dossier.Errors, "users: "+err.Error())
+		}
+		for _, item := range list {
+			u := parseUser(item)
+			if u == nil || strings.EqualFold(u.Name, nick) {
+				continue
+			}
+			peer := DossierPeer{Nick: u.Name, IP: u.IP, Hostname: u.Hostname, Account: u.Account, Server: u.ServerName}
+			if dossier.IP != "" && u.IP == dossier.IP {
+				dossier.SharedIP = append(dossier.SharedIP, peer)
+			}
+			if dossier.CertFP != "" && strings.EqualFold(utils.SafeMapGetString(u.TLS, "certfp"), dossier.CertFP) {
+				dossier.SharedCertFP = append(dossier.SharedCertFP, peer)
+			}
+		}
+		sort.Slice(dossier.SharedIP, func(i, j int) bool { return dossier.SharedIP[i].Nick < dossier.SharedIP[j].Nick })
+		sort.Slice(dossier.SharedCertFP, func(i, j int) bool { return dossier.SharedCertFP[i].Nick < dossier.SharedCertFP[j].Nick })
+	}
+
+	return dossier
+}
+
+// addDossierBans adds the server bans and ban exceptions that currently match the user
+func addDossierBans(dossier *UserDossier) {
+	dossier.ServerBans = []ServerBan{}
+	dossier.BanExceptions = []BanException{}
+	manager := rpc.GetManager()
+	target := dossierBanTarget(dossier)
+	if bans, err := manager.WithRetry(func(client *rpc.Client) (interface{}, error) {
+		return client.ServerBan().GetAll()
+	}); err != nil {
+		dossier.Errors = append(dossier.Errors, "server bans: "+err.Error())
+	} else {
+		for _, ban := range parseServerBanList(bans) {
+			if banMaskMatchesUser(ban.Name, target) {
+				dossier.ServerBans = append(dossier.ServerBans, ban)
+			}
+		}
+	}
+	if exceptions, err := manager.WithRetry(func(client *rpc.Client) (interface{}, error) {
+		return client.ServerBanException().GetAll()
+	}); err != nil {
+		dossier.Errors = append(dossier.Errors, "ban exceptions: "+err.Error())
+	} else {
+		for _, exc := range parseBanExceptionList(exceptions) {
+			if banMaskMatchesUser(exc.Name, target) {
+				dossier.BanExceptions = append(dossier.BanExceptions, exc)
+			}
+		}
+	}
+}
+
+// dossierBanTarget returns the live user, or what the history tells us about an offline one
+func dossierBanTarget(dossier *UserDossier) *IRCUser {
+	if dossier.User != nil {
+		return dossier.User
+	}
+	return &IRCUser{Name: dossier.Nick, IP: dossier.IP, Hostname: dossier.IP, Account: dossier.Account}
+}
+
+// banMaskMatchesUser checks a server ban or exception mask (user@host, CIDR or an
+// ~account, ~certfp or ~realname extban) against a user
+func banMaskMatchesUser(mask string, user *IRCUser) bool {
+	if strings.HasPrefix(mask, "~") {
+		colon := strings.Index(mask, ":")
+		if colon < 0 {
+			return false
+		}
+		value := mask[colon+1:]
+		switch mask[1:colon] {
+		case "account", "a":
+			return user.Account != "" && utils.WildcardMatch(value, user.Account)
+		case "certfp", "S":
+			certfp := utils.SafeMapGetString(user.TLS, "certfp")
+			return certfp != "" && strings.EqualFold(value, certfp)
+		case "realname", "r":
+			return utils.WildcardMatch(value, user.RealName)
+		}
+		return false
+	}
+
+	ident, host := "*", mask
+	if at := strings.LastIndex(mask, "@"); at >= 0 {
+		ident, host = mask[:at], mask[at+1:]
+	}
+	if ident != "*" && !utils.WildcardMatch(ident, user.Username) {
+		return false
+	}
+
+	if strings.Contains(host, "/") {
+		_, network, err := net.ParseCIDR(host)
+		ip := net.ParseIP(user.IP)
+		return err == nil && ip != nil && network.Contains(ip)
+	}
+	for _, candidate := range []string{user.Hostname, user.IP} {
+		if candidate != "" && utils.WildcardMatch(host, candidate) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeDossierText renders a dossier as a plain text report
+func writeDossierText(d *UserDossier) string {
+	var sb strings.Builder
+	line := func(format string, args ...interface{}) {
+		sb.WriteString(fmt.Sprintf(format, args...))
+		sb.WriteString("\n")
+	}
+	section := func(title string, count int) {
+		line("")
+		line("== %s (%d) ==", title, count)
+	}
+
+	line("User dossier: %s", d.Nick)
+	line("Generated:    %s by %s", d.GeneratedAt.Format(time.RFC3339), d.GeneratedBy)
+	line("Online:       %t", d.Online)
+	line("IP:           %s", d.IP)
+	line("Account:      %s", d.Account)
+	if d.CertFP != "" {
+		line("Cert FP:      %s", d.CertFP)
+	}
+	if d.User != nil {
+		line("Mask:         %s!%s@%s (%s)", d.User.Name, d.User.Username, d.User.Hostname, d.User.RealName)
+		line("Server:       %s", d.User.ServerName)
+		if d.User.ConnectedSince > 0 {
+			line("Connected:    %s", time.Unix(d.User.ConnectedSince, 0).UTC().Format(time.RFC3339))
+		}
+		line("Channels:     %s", strings.Join(d.User.Channels, " "))
+	}
+	if d.Geo != nil {
+		line("Location:     %s %s, AS%d %s", d.Geo.CountryCode, d.Geo.CountryName, d.Geo.ASN, d.Geo.ASOrg)
+	}
+	if d.Reputation != nil && d.Reputation.Listed {
+		lists := make([]string, 0, len(d.Reputation.Listings))
+		for _, l := range d.Reputation.Listings {
+			lists = append(lists, l.List+" ("+l.Confidence+")")
+		}
+		line("Listed on:    %s", strings.Join(lists, ", "))
+	}
+
+	section("Notes", len(d.Notes))
+	for _, n := range d.Notes {
+		line("%s  %s: %s", n.CreatedAt.UTC().Format(time.RFC3339), n.CreatedBy, n.Note)
+	}
+
+	section("Watch list", len(d.Watchlist))
+	for _, w := range d.Watchlist {
+		line("#%d nick=%s ip=%s host=%s account=%s: %s", w.ID, w.Nick, w.IP, w.Host, w.Account, w.Reason)
+	}
+
+	if d.ServerBans != nil {
+		section("Matching server bans", len(d.ServerBans))
+		for _, b := range d.ServerBans {
+			line("%s %s by %s: %s", b.Type, b.Name, b.SetBy, b.Reason)
+		}
+	}
+
+	if d.BanExceptions != nil {
+		section("Matching ban exceptions", len(d.BanExceptions))
+		for _, e := range d.BanExceptions {
+			line("%s by %s: %s", e.Name, e.SetBy, e.Reason)
+		}
+	}
+
+	section("Users on the same IP", len(d.SharedIP))
+	for _, p := range d.SharedIP {
+		line("%s (%s) on %s", p.Nick, p.Hostname, p.Server)
+	}
+
+	section("Users with the same certificate", len(d.SharedCertFP))
+	for _, p := range d.SharedCertFP {
+		line("%s (%s) on %s", p.Nick, p.IP, p.Server)
+	}
+
+	section("Spamfilter hits", len(d.SpamfilterHits))
+	for _, e := range d.SpamfilterHits {
+		line("%s  %s %s", e.CreatedAt.UTC().Format(time.RFC3339), e.Nick, e.Details)
+	}
+
+	section("Journey", len(d.Journey))
+	for _, e := range d.Journey {
+		line("%s  %-12s %s %s %s %s", e.CreatedAt.UTC().Format(time.RFC3339), e.EventType, e.Nick, e.IP, e.Server, e.Details)
+	}
+
+	if len(d.Errors) > 0 {
+		section("Incomplete data", len(d.Errors))
+		for _, e := range d.Errors {
+			line("%s", e)
+		}
+	}
+
+	return sb.String()
+}
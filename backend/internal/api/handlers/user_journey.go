@@ -4,11 +4,13 @@ import (
 	"encoding/json"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/database"
 	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/database/models"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/services/geoip"
 )
 
 // GetUserJourney returns timeline events for a specific user
@@ -77,6 +79,7 @@ func GetUserJourney(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user journey"})
 		return
 	}
+	enrichJourneyEvents(events)
 
 	c.JSON(http.StatusOK, events)
 }
@@ -141,11 +144,17 @@ func GetUserJourneyStats(c *gin.Context) {
 
 // RecordJourneyEvent records a new journey event (called internally or from webhooks)
 func RecordJourneyEvent(eventType, nick, ip, account, server string, details map[string]interface{}) error {
+	return recordJourneyEventAt(time.Now(), eventType, nick, ip, account, server, details)
+}
+
+// recordJourneyEventAt records a journey event that happened at the given time
+func recordJourneyEventAt(at time.Time, eventType, nick, ip, account, server string, details map[string]interface{}) error {
 	db := database.Get()
 
 	detailsJSON, _ := json.Marshal(details)
 
 	event := models.UserJourneyEvent{
+		CreatedAt: at,
 		Nick:      nick,
 		IP:        ip,
 		Account:   account,
@@ -153,10 +162,29 @@ func RecordJourneyEvent(eventType, nick, ip, account, server string, details map
 		Details:   string(detailsJSON),
 		Server:    server,
 	}
+	if info := geoip.Lookup(ip); info != nil {
+		event.Country = info.CountryCode
+		event.ASN = info.ASN
+		event.ASOrg = info.ASOrg
+	}
 
 	return db.Create(&event).Error
 }
 
+// enrichJourneyEvents fills in GeoIP data for events recorded before the databases were available
+func enrichJourneyEvents(events []models.UserJourneyEvent) {
+	for i := range events {
+		if events[i].IP == "" || events[i].Country != "" || events[i].ASN != 0 {
+			continue
+		}
+		if info := geoip.Lookup(events[i].IP); info != nil {
+			events[i].Country = info.CountryCode
+			events[i].ASN = info.ASN
+			events[i].ASOrg = info.ASOrg
+		}
+	}
+}
+
 // SearchJourneyEvents searches journey events by criteria
 func SearchJourneyEvents(c *gin.Context) {
 	db := database.Get()
@@ -169,6 +197,8 @@ func SearchJourneyEvents(c *gin.Context) {
 		StartTime  string   `json:"start_time"`
 		EndTime    string   `json:"end_time"`
 		Server     string   `json:"server"`
+		Country    string   `json:"country"`
+		ASN        uint     `json:"asn"`
 		Limit      int      `json:"limit"`
 	}
 
@@ -204,6 +234,12 @@ func SearchJourneyEvents(c *gin.Context) {
 	if req.Server != "" {
 		query = query.Where("server = ?", req.Server)
 	}
+	if req.Country != "" {
+		query = query.Where("country = ?", strings.ToUpper(req.Country))
+	}
+	if req.ASN != 0 {
+		query = query.Where("asn = ?", req.ASN)
+	}
 
 	limit := 500
 	if req.Limit > 0 && req.Limit <= 2000 {
@@ -215,6 +251,7 @@ func SearchJourneyEvents(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search journey events"})
 		return
 	}
+	enrichJourneyEvents(events)
 
 	c.JSON(http.StatusOK, events)
 }
@@ -0,0 +1,327 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/api/middleware"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/database/models"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/rpc"
+)
+
+// Limits from UnrealIRCd's defaults (USERLEN, REALLEN, CHANNELLEN)
+const (
+	maxUsernameLength = 10
+	maxRealnameLength = 50
+	maxChannelLength  = 32
+	maxReasonLength   = 300
+)
+
+var (
+	usernamePattern = regexp.MustCompile(`^~?[A-Za-z0-9_.\-]+$`)
+	snomaskPattern  = regexp.MustCompile(`^[+-]?[A-Za-z+-]*[A-Za-z][A-Za-z+-]*$`)
+	operNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.\-]+$`)
+)
+
+// UserOperationRequest holds the parameters of the user.* operations; each operation uses a subset
+type UserOperationRequest struct {
+	Username    string `json:"username"`     // set_username
+	Realname    string `json:"realname"`     // set_realname
+	Channel     string `json:"channel"`      // join, part
+	Key         string `json:"key"`          // join
+	Reason      string `json:"reason"`       // part, quit
+	Force       bool   `json:"force"`        // join, part: bypass bans, limits and keys
+	OperAccount string `json:"oper_account"` // set_oper
+	OperClass   string `json:"oper_class"`   // set_oper
+	Class       string `json:"class"`        // set_oper: connection class
+	Modes       string `json:"modes"`        // set_oper: user modes to set
+	Snomask     string `json:"snomask"`      // set_oper, set_snomask
+	VHost       string `json:"vhost"`        // set_oper
+	Hidden      bool   `json:"hidden"`       // set_snomask: don't tell the user
+}
+
+// userOperation describes a user.* RPC method
+type userOperation struct {
+	method     string // RPC method
+	permission string // Permission needed from the panel and for scheduling
+	action     string // Audit log action
+	done       string // Success message
+	params     func(req *UserOperationRequest) (map[string]interface{}, error)
+}
+
+// userOperations are the user.* operations keyed by the scheduled command type that runs them
+var userOperations = map[string]userOperation{
+	"set_username": {
+		method:     "user.set_username",
+		permission: models.PermissionEditUser,
+		action:     "set_user_username",
+		done:       "Username changed",
+		params: func(req *UserOperationRequest) (map[string]interface{}, error) {
+			if req.Username == "" || len(req.Username) > maxUsernameLength || !usernamePattern.MatchString(req.Username) {
+				return nil, fmt.Errorf("username must be 1-%d letters, digits, '_', '.' or '-'", maxUsernameLength)
+			}
+			return map[string]interface{}{"username": req.Username}, nil
+		},
+	},
+	"set_realname": {
+		method:     "user.set_realname",
+		permission: models.PermissionEditUser,
+		action:     "set_user_realname",
+		done:       "Realname changed",
+		params: func(req *UserOperationRequest) (map[string]interface{}, error) {
+			if strings.TrimSpace(req.Realname) == "" || len(req.Realname) > maxRealnameLength || hasControlChars(req.Realname) {
+				return nil, fmt.Errorf("realname must be 1-%d characters without control characters", maxRealnameLength)
+			}
+			return map[string]interface{}{"realname": req.Realname}, nil
+		},
+	},
+	"join": {
+		method:     "user.join",
+		permission: models.PermissionForceJoinPart,
+		action:     "force_join_user",
+		done:       "User joined to channel",
+		params: func(req *UserOperationRequest) (map[string]interface{}, error) {
+			if err := validateChannelName(req.Channel); err != nil {
+				return nil, err
+			}
+			params := map[string]interface{}{"channel": req.Channel, "force": req.Force}
+			if req.Key != "" {
+				if strings.ContainsAny(req.Key, " ,") || hasControlChars(req.Key) {
+					return nil, fmt.Errorf("invalid channel key")
+				}
+				params["key"] = req.Key
+			}
+			return params, nil
+		},
+	},
+	"part": {
+		method:     "user.part",
+		permission: models.PermissionForceJoinPart,
+		action:     "force_part_user",
+		done:       "User parted from channel",
+		params: func(req *UserOperationRequest) (map[string]interface{}, error) {
+			if err := validateChannelName(req.Channel); err != nil {
+				return nil, err
+			}
+			if err := validateReason(req.Reason, false); err != nil {
+				return nil, err
+			}
+			params := map[string]interface{}{"channel": req.Channel, "force": req.Force}
+			if req.Reason != "" {
+				params["reason"] = req.Reason
+			}
+			return params, nil
+		},
+	},
+	"quit": {
+		method:     "user.quit",
+		permission: models.PermissionBanUsers,
+		action:     "quit_user",
+		done:       "User disconnected",
+		params: func(req *UserOperationRequest) (map[string]interface{}, error) {
+			if err := validateReason(req.Reason, true); err != nil {
+				return nil, err
+			}
+			return map[string]interface{}{"reason": req.Reason}, nil
+		},
+	},
+	"set_oper": {
+		method:     "user.set_oper",
+		permission: models.PermissionSetOper,
+		action:     "set_user_oper",
+		done:       "Oper status set",
+		params: func(req *UserOperationRequest) (map[string]interface{}, error) {
+			if !operNamePattern.MatchString(req.OperAccount) || !operNamePattern.MatchString(req.OperClass) {
+				return nil, fmt.Errorf("oper_account and oper_class are required and may not contain spaces")
+			}
+			params := map[string]interface{}{
+				"oper_account": req.OperAccount,
+				"oper_class":   req.OperClass,
+			}
+			if req.Class != "" {
+				if !operNamePattern.MatchString(req.Class) {
+					return nil, fmt.Errorf("invalid connection class")
+				}
+				params["class"] = req.Class
+			}
+			if req.Modes != "" {
+				if !snomaskPattern.MatchString(req.Modes) {
+					return nil, fmt.Errorf("invalid user modes")
+				}
+				params["modes"] = req.Modes
+			}
+			if req.Snomask != "" {
+				if !snomaskPattern.MatchString(req.Snomask) {
+					return nil, fmt.Errorf("invalid snomask")
+				}
+				params["snomask"] = req.Snomask
+			}
+			if req.VHost != "" {
+				if strings.ContainsAny(req.VHost, " @!") || hasControlChars(req.VHost) {
+					return nil, fmt.Errorf("invalid vhost")
+				}
+				params["vhost"] = req.VHost
+			}
+			return params, nil
+		},
+	},
+	"set_snomask": {
+		method:     "user.set_snomask",
+		permission: models.PermissionSetOper,
+		action:     "set_user_snomask",
+		done:       "Snomask changed",
+		params: func(req *UserOperationRequest) (map[string]interface{}, error) {
+			if !snomaskPattern.MatchString(req.Snomask) {
+				return nil, fmt.Errorf("snomask must be mode letters such as +cF or -s")
+			}
+			return map[string]interface{}{"snomask": req.Snomask, "hidden": req.Hidden}, nil
+		},
+	},
+}
+
+// SetUserUsername changes a user's username (ident)
+func SetUserUsername(c *gin.Context) {
+	handleUserOperation(c, "set_username")
+}
+
+// SetUserRealname changes a user's realname (gecos)
+func SetUserRealname(c *gin.Context) {
+	handleUserOperation(c, "set_realname")
+}
+
+// ForceJoinUser makes a user join a channel
+func ForceJoinUser(c *gin.Context) {
+	handleUserOperation(c, "join")
+}
+
+// ForcePartUser makes a user leave a channel
+func ForcePartUser(c *gin.Context) {
+	handleUserOperation(c, "part")
+}
+
+// QuitUser disconnects a user with a quit message, unlike kill which shows who killed them
+func QuitUser(c *gin.Context) {
+	handleUserOperation(c, "quit")
+}
+
+// SetUserOper gives a user IRC operator status
+func SetUserOper(c *gin.Context) {
+	handleUserOperation(c, "set_oper")
+}
+
+// SetUserSnomask changes an oper's server notice mask
+func SetUserSnomask(c *gin.Context) {
+	handleUserOperation(c, "set_snomask")
+}
+
+// RunUserOperation validates and runs a user.* operation; scheduled commands use it too
+func RunUserOperation(name, nick string, req *UserOperationRequest) (string, error) {
+	op, ok := userOperations[name]
+	if !ok {
+		return "", fmt.Errorf("unknown user operation: %s", name)
+	}
+	if strings.TrimSpace(nick) == "" {
+		return "", fmt.Errorf("nick is required")
+	}
+
+	params, err := op.params(req)
+	if err != nil {
+		return "", err
+	}
+	params["nick"] = nick
+
+	_, err = rpc.GetManager().WithRetry(func(client *rpc.Client) (interface{}, error) {
+		return client.Query(op.method, params, false)
+	})
+	if err != nil {
+		return "", err
+	}
+	return op.done + " for " + nick, nil
+}
+
+// Helper functions
+
+func handleUserOperation(c *gin.Context, name string) {
+	nick := c.Param("nick")
+	if nick == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nick is required"})
+		return
+	}
+
+	var req UserOperationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	op := userOperations[name]
+	params, err := op.params(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	message, err := RunUserOperation(name, nick, &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to run " + op.method + ": " + err.Error()})
+		return
+	}
+
+	currentUser := middleware.GetCurrentUser(c)
+	if currentUser != nil {
+		details := map[string]string{"nick": nick}
+		for key, value := range params {
+			switch v := value.(type) {
+			case string:
+				details[key] = v
+			case bool:
+				details[key] = strconv.FormatBool(v)
+			}
+		}
+		logAction(c, currentUser, op.action, details)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": message})
+}
+
+// userOperationFromParams converts scheduled command params into a request
+func userOperationFromParams(params map[string]interface{}) *UserOperationRequest {
+	var req UserOperationRequest
+	if data, err := json.Marshal(params); err == nil {
+		json.Unmarshal(data, &req)
+	}
+	return &req
+}
+
+func validateChannelName(channel string) error {
+	if !strings.HasPrefix(channel, "#") || len(channel) < 2 || len(channel) > maxChannelLength ||
+		strings.ContainsAny(channel, " ,") || hasControlChars(channel) {
+		return fmt.Errorf("channel must start with # and be at most %d characters without spaces or commas", maxChannelLength)
+	}
+	return nil
+}
+
+func validateReason(reason string, required bool) error {
+	if required && strings.TrimSpace(reason) == "" {
+		return fmt.Errorf("reason is required")
+	}
+	if len(reason) > maxReasonLength || hasControlChars(reason) {
+		return fmt.Errorf("reason must be at most %d characters without line breaks", maxReasonLength)
+	}
+	return nil
+}
+
+// hasControlChars reports line breaks and other control characters; IRC formatting codes are allowed
+func hasControlChars(s string) bool {
+	for _, r := range s {
+		if r == '\r' || r == '\n' || r == 0 {
+			return true
+		}
+	}
+	return false
+}
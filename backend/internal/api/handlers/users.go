@@ -3,11 +3,16 @@ package handlers
 import (
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/api/middleware"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/database/models"
 	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/rpc"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/services/geoip"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/services/reputation"
 	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/utils"
 )
 
@@ -31,12 +36,16 @@ type IRCUser struct {
 	Account        string                 `json:"account,omitempty"`
 	TLS            map[string]interface{} `json:"tls,omitempty"`
 	GeoIP          map[string]interface{} `json:"geoip,omitempty"`
+	Geo            *geoip.Info            `json:"geo,omitempty"`        // Country/ASN from the panel's GeoIP databases
+	Blocklists     *reputation.Result     `json:"blocklists,omitempty"` // DNSBL and local list results, user details only
+	Notes          []models.Note          `json:"notes,omitempty"`      // Staff notes on the nick, IP or account, user details only
 	ClientInfo     map[string]interface{} `json:"client_info,omitempty"`
 	SecurityGroups []string               `json:"security_groups,omitempty"`
 	Reputation     int                    `json:"reputation,omitempty"`
 }
 
-// GetUsers returns all IRC users
+// GetUsers returns all IRC users. With filter, sort, limit or cursor parameters
+// it returns a ListPage instead of the plain array.
 func GetUsers(c *gin.Context) {
 	manager := rpc.GetManager()
 
@@ -61,8 +70,20 @@ func GetUsers(c *gin.Context) {
 		return
 	}
 
-	users := parseUserList(result)
-	c.JSON(http.StatusOK, users)
+	users := filterUsersByGeo(parseUserList(result), c.Query("country"), c.Query("asn"), c.Query("as_org"))
+
+	query, paged := parseListQuery(c)
+	if !paged {
+		c.JSON(http.StatusOK, users)
+		return
+	}
+
+	page, err := queryUsers(users, query)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, page)
 }
 
 // GetUser returns a specific IRC user
@@ -94,6 +115,12 @@ func GetUser(c *gin.Context) {
 
 	user := parseUser(result)
 	log.Printf("[GetUser] Parsed user: %+v", user)
+	if user != nil && user.IP != "" && reputation.GetService().Enabled() {
+		user.Blocklists, _ = reputation.GetService().Check(user.IP)
+	}
+	if user != nil {
+		user.Notes = findUserNotes(user.Name, user.IP, user.Account)
+	}
 	c.JSON(http.StatusOK, user)
 }
 
@@ -302,6 +329,17 @@ func BanUser(c *gin.Context) {
 
 // Helper functions
 
+// fetchFullUserList returns every connected user at full detail (level 4)
+func fetchFullUserList() ([]interface{}, error) {
+	result, err := rpc.GetManager().WithRetry(func(client *rpc.Client) (interface{}, error) {
+		return client.User().GetAll(4)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return utils.InterfaceToSlice(result), nil
+}
+
 func parseUserList(result interface{}) []IRCUser {
 	users := make([]IRCUser, 0)
 
@@ -451,6 +489,81 @@ func parseUser(result interface{}) *IRCUser {
 	if clientInfo := utils.SafeMapGetMap(m, "client_info"); clientInfo != nil {
 		user.ClientInfo = clientInfo
 	}
+	user.Geo = userGeo(user)
 
 	return user
 }
+
+// userGeo looks the user's IP up in the panel's GeoIP databases, falling back
+// to the geoip object UnrealIRCd includes when no local database is loaded
+func userGeo(user *IRCUser) *geoip.Info {
+	if info := geoip.Lookup(user.IP); info != nil {
+		return info
+	}
+	if user.GeoIP == nil {
+		return nil
+	}
+	info := &geoip.Info{
+		CountryCode: utils.SafeMapGetString(user.GeoIP, "country_code"),
+		ASN:         uint(utils.SafeMapGetInt(user.GeoIP, "asn")),
+		ASOrg:       utils.SafeMapGetString(user.GeoIP, "asname"),
+	}
+	if info.Empty() {
+		return nil
+	}
+	return info
+}
+
+// filterUsersByGeo keeps users matching any of the comma-separated countries and
+// ASNs, and whose AS organisation contains asOrg. Empty filters match everything.
+func filterUsersByGeo(users []IRCUser, countries, asns, asOrg string) []IRCUser {
+	if countries == "" && asns == "" && asOrg == "" {
+		return users
+	}
+
+	filtered := make([]IRCUser, 0, len(users))
+	for _, user := range users {
+		if userMatchesGeo(user.Geo, countries, asns, asOrg) {
+			filtered = append(filtered, user)
+		}
+	}
+	return filtered
+}
+
+func userMatchesGeo(info *geoip.Info, countries, asns, asOrg string) bool {
+	if info == nil {
+		info = &geoip.Info{}
+	}
+	if countries != "" && !containsFold(strings.Split(countries, ","), info.CountryCode) {
+		return false
+	}
+	if asns != "" {
+		asn := strconv.FormatUint(uint64(info.ASN), 10)
+		matched := false
+		for _, want := range strings.Split(asns, ",") {
+			if strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(want)), "AS") == asn {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if asOrg != "" && !strings.Contains(strings.ToLower(info.ASOrg), strings.ToLower(asOrg)) {
+		return false
+	}
+	return true
+}
+
+func containsFold(list []string, value string) bool {
+	if value == "" {
+		return false
+	}
+	for _, item := range list {
+		if strings.EqualFold(strings.TrimSpace(item), value) {
+			return true
+		}
+	}
+	return false
+}
@@ -438,6 +438,12 @@ func ReceiveWebhook(c *gin.Context) {
 	// Process alert rules asynchronously
 	go ProcessAlertRules(&event, body)
 
+	// Add the event to the user journey timeline
+	var entry map[string]interface{}
+	if err := json.Unmarshal(body, &entry); err == nil {
+		go IngestJourneyLogEvent(entry, JourneySourceWebhook)
+	}
+
 	// Return success
 	c.JSON(http.StatusOK, gin.H{"status": "received"})
 }
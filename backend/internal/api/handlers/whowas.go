@@ -0,0 +1,322 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/database"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/database/models"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/rpc"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/utils"
+)
+
+// Whowas sources
+const (
+	WhowasSourceRPC     = "rpc"     // whowas.get, UnrealIRCd 6.1 and later
+	WhowasSourceJourney = "journey" // The panel's user journey history
+)
+
+const (
+	defaultWhowasLimit = 50
+	maxWhowasLimit     = 500
+)
+
+// WhowasEntry is the last known state of a user who disconnected or changed nick
+type WhowasEntry struct {
+	Nick       string     `json:"nick"`
+	Event      string     `json:"event"` // quit, nick-change or server-terminating
+	Username   string     `json:"username,omitempty"`
+	Hostname   string     `json:"hostname,omitempty"`
+	IP         string     `json:"ip,omitempty"`
+	VHost      string     `json:"vhost,omitempty"`
+	Realname   string     `json:"realname,omitempty"`
+	Account    string     `json:"account,omitempty"`
+	Server     string     `json:"server,omitempty"`
+	QuitReason string     `json:"quit_reason,omitempty"`
+	LogonTime  *time.Time `json:"logon_time,omitempty"`
+	LogoffTime *time.Time `json:"logoff_time,omitempty"`
+	Source     string     `json:"source"`
+}
+
+// GetWhowas searches for disconnected users by nick, IP or account. It uses
+// whowas.get when the server supports it and the journey history otherwise,
+// or when the server no longer remembers the user.
+func GetWhowas(c *gin.Context) {
+	nick := strings.TrimSpace(c.Query("nick"))
+	ip := strings.TrimSpace(c.Query("ip"))
+	account := strings.TrimSpace(c.Query("account"))
+	if nick == "" && ip == "" && account == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "nick, ip or account is required"})
+		return
+	}
+
+	limit := defaultWhowasLimit
+	if l, err := strconv.Atoi(c.Query("limit")); err == nil && l > 0 {
+		limit = l
+	}
+	if limit > maxWhowasLimit {
+		limit = maxWhowasLimit
+	}
+
+	response := gin.H{}
+	entries, err := fetchWhowas(nick, ip, account)
+	if err != nil {
+		response["rpc_error"] = err.Error()
+	}
+
+	source := WhowasSourceRPC
+	if err != nil || len(entries) == 0 {
+		source = WhowasSourceJourney
+		entries, err = journeyWhowas(nick, ip, account, limit)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search history: " + err.Error()})
+			return
+		}
+	} else {
+		fillWhowasQuitReasons(entries)
+	}
+
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+
+	response["source"] = source
+	response["entries"] = entries
+	response["total"] = len(entries)
+	c.JSON(http.StatusOK, response)
+}
+
+// Helper functions
+
+// fetchWhowas queries whowas.get. Servers before UnrealIRCd 6.1 return an error.
+// whowas.get filters on nick and IP itself; account is matched here.
+func fetchWhowas(nick, ip, account string) ([]WhowasEntry, error) {
+	params := map[string]interface{}{"object_detail_level": 2}
+	if nick != "" {
+		params["nick"] = nick
+	}
+	if ip != "" {
+		params["ip"] = ip
+	}
+
+	result, err := rpc.GetManager().WithRetry(func(client *rpc.Client) (interface{}, error) {
+		return client.Query("whowas.get", params, false)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	list := utils.InterfaceToSlice(result)
+	if m := utils.InterfaceToMap(result); m != nil {
+		list = utils.SafeMapGetSlice(m, "list")
+	}
+
+	entries := make([]WhowasEntry, 0, len(list))
+	for _, item := range list {
+		m := utils.InterfaceToMap(item)
+		if m == nil {
+			continue
+		}
+		entry := WhowasEntry{
+			Nick:     utils.SafeMapGetString(m, "name"),
+			Event:    utils.SafeMapGetString(m, "event"),
+			Hostname: utils.SafeMapGetString(m, "hostname"),
+			IP:       utils.SafeMapGetString(m, "ip"),
+			Source:   WhowasSourceRPC,
+		}
+		if user := utils.SafeMapGetMap(m, "user"); user != nil {
+			entry.Username = utils.SafeMapGetString(user, "username")
+			entry.Realname = utils.SafeMapGetString(user, "realname")
+			entry.VHost = utils.SafeMapGetString(user, "vhost")
+			entry.Account = utils.SafeMapGetString(user, "account")
+			entry.Server = utils.SafeMapGetString(user, "servername")
+		}
+		if t, ok := parseLedgerTime(utils.SafeMapGetString(m, "logon_time")); ok {
+			entry.LogonTime = &t
+		}
+		if t, ok := parseLedgerTime(utils.SafeMapGetString(m, "logoff_time")); ok {
+			entry.LogoffTime = &t
+		}
+		if account != "" && !utils.WildcardMatch(account, entry.Account) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return whowasSortTime(entries[i]).After(whowasSortTime(entries[j]))
+	})
+	return entries, nil
+}
+
+// journeyWhowas rebuilds whowas entries from disconnect and nick change events,
+// taking the host, username and realname from the connect event before them
+func journeyWhowas(nick, ip, account string, limit int) ([]WhowasEntry, error) {
+	db := database.Get()
+	query := db.Where("event_type IN ?", []string{"disconnect", "nick_change"})
+	for column, value := range map[string]string{"nick": nick, "ip": ip, "account": account} {
+		if value == "" {
+			continue
+		}
+		if strings.ContainsAny(value, "*?") {
+			query = query.Where(column+" LIKE ? ESCAPE ?", wildcardLikePattern(value), likeEscape)
+		} else {
+			query = query.Where(column+" = ?", value)
+		}
+	}
+
+	var events []models.UserJourneyEvent
+	if err := query.Order("created_at DESC").Limit(limit).Find(&events).Error; err != nil {
+		return nil, err
+	}
+
+	connects, err := whowasConnects(events)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]WhowasEntry, 0, len(events))
+	for _, event := range events {
+		details := journeyEventDetails(event)
+		logoff := event.CreatedAt
+		entry := WhowasEntry{
+			Nick:       event.Nick,
+			Event:      "quit",
+			IP:         event.IP,
+			Account:    event.Account,
+			Server:     event.Server,
+			LogoffTime: &logoff,
+			Source:     WhowasSourceJourney,
+		}
+		if event.EventType == "nick_change" {
+			entry.Event = "nick-change"
+		} else {
+			entry.QuitReason = utils.SafeMapGetString(details, "reason")
+		}
+
+		// The latest connect of the same nick (and IP, when known) before the event
+		for _, connect := range connects[event.Nick] {
+			if connect.CreatedAt.After(event.CreatedAt) || (event.IP != "" && connect.IP != event.IP) {
+				continue
+			}
+			connectDetails := journeyEventDetails(connect)
+			logon := connect.CreatedAt
+			entry.LogonTime = &logon
+			entry.Hostname = utils.SafeMapGetString(connectDetails, "hostname")
+			entry.Username = utils.SafeMapGetString(connectDetails, "username")
+			entry.Realname = utils.SafeMapGetString(connectDetails, "realname")
+			if entry.Account == "" {
+				entry.Account = connect.Account
+			}
+			break
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// whowasConnects loads the connect events of the events' nicks in one query,
+// grouped by nick with the newest first
+func whowasConnects(events []models.UserJourneyEvent) (map[string][]models.UserJourneyEvent, error) {
+	connects := make(map[string][]models.UserJourneyEvent)
+	if len(events) == 0 {
+		return connects, nil
+	}
+
+	nicks := make([]string, 0, len(events))
+	latest := events[0].CreatedAt
+	for _, event := range events {
+		if _, ok := connects[event.Nick]; !ok {
+			connects[event.Nick] = nil
+			nicks = append(nicks, event.Nick)
+		}
+		if event.CreatedAt.After(latest) {
+			latest = event.CreatedAt
+		}
+	}
+
+	var rows []models.UserJourneyEvent
+	err := database.Get().Where("event_type = ? AND nick IN ? AND created_at <= ?", "connect", nicks, latest).
+		Order("created_at DESC").Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		connects[row.Nick] = append(connects[row.Nick], row)
+	}
+	return connects, nil
+}
+
+// fillWhowasQuitReasons adds quit reasons, which whowas.get does not return,
+// from disconnect events recorded around the logoff time
+func fillWhowasQuitReasons(entries []WhowasEntry) {
+	nicks := make([]string, 0, len(entries))
+	var first, last time.Time
+	for _, entry := range entries {
+		if entry.Event != "quit" || entry.LogoffTime == nil {
+			continue
+		}
+		nicks = append(nicks, entry.Nick)
+		if first.IsZero() || entry.LogoffTime.Before(first) {
+			first = *entry.LogoffTime
+		}
+		if entry.LogoffTime.After(last) {
+			last = *entry.LogoffTime
+		}
+	}
+	if len(nicks) == 0 {
+		return
+	}
+
+	var events []models.UserJourneyEvent
+	err := database.Get().Where("event_type = ? AND nick IN ? AND created_at BETWEEN ? AND ?", "disconnect", nicks,
+		first.Add(-time.Minute), last.Add(time.Minute)).
+		Order("created_at DESC").Find(&events).Error
+	if err != nil {
+		return
+	}
+
+	for i := range entries {
+		entry := &entries[i]
+		if entry.Event != "quit" || entry.LogoffTime == nil {
+			continue
+		}
+		for _, event := range events {
+			if event.Nick == entry.Nick && !event.CreatedAt.Before(entry.LogoffTime.Add(-time.Minute)) &&
+				!event.CreatedAt.After(entry.LogoffTime.Add(time.Minute)) {
+				entry.QuitReason = utils.SafeMapGetString(journeyEventDetails(event), "reason")
+				break
+			}
+		}
+	}
+}
+
+func journeyEventDetails(event models.UserJourneyEvent) map[string]interface{} {
+	var details map[string]interface{}
+	if event.Details != "" {
+		json.Unmarshal([]byte(event.Details), &details)
+	}
+	return details
+}
+
+// likeEscape is the LIKE escape character. It is bound as a parameter because a
+// '\' literal is an unterminated string in MySQL.
+const likeEscape = `\`
+
+// wildcardLikePattern turns a * and ? wildcard mask into a LIKE pattern for use
+// with ESCAPE likeEscape, escaping the characters LIKE itself treats as special
+func wildcardLikePattern(mask string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`, "*", "%", "?", "_").Replace(mask)
+}
+
+func whowasSortTime(entry WhowasEntry) time.Time {
+	if entry.LogoffTime != nil {
+		return *entry.LogoffTime
+	}
+	return time.Time{}
+}
@@ -0,0 +1,20 @@
+package handlers
+
+import "testing"
+
+func TestWildcardLikePattern(t *testing.T) {
+	tests := []struct {
+		mask string
+		want string
+	}{
+		{"bob*", "bob%"},
+		{"b?b", "b_b"},
+		{"100%_*", `100\%\_%`},
+		{`back\slash?`, `back\\slash_`},
+	}
+	for _, tt := range tests {
+		if got := wildcardLikePattern(tt.mask); got != tt.want {
+			t.Errorf("wildcardLikePattern(%q) = %q, want %q", tt.mask, got, tt.want)
+		}
+	}
+}
@@ -74,12 +74,42 @@ func SetupRoutes(r *gin.Engine) {
 			users.Use(middleware.PermissionMiddleware(models.PermissionViewUsers))
 			{
 				users.GET("", handlers.GetUsers)
+				users.GET("/clusters", handlers.GetUserClusters)
+				users.GET("/whowas", handlers.GetWhowas)
+				users.POST("/clusters/action", middleware.PermissionMiddleware(models.PermissionBanUsers), handlers.ApplyClusterAction)
 				users.GET("/:nick", handlers.GetUser)
+				users.GET("/:nick/dossier", handlers.GetUserDossier)
+				users.GET("/:nick/dossier/export", handlers.ExportUserDossier)
 				users.POST("/:nick/kill", middleware.PermissionMiddleware(models.PermissionBanUsers), handlers.KillUser)
 				users.POST("/:nick/nick", middleware.PermissionMiddleware(models.PermissionEditUser), handlers.SetUserNick)
 				users.POST("/:nick/mode", middleware.PermissionMiddleware(models.PermissionEditUser), handlers.SetUserMode)
 				users.POST("/:nick/vhost", middleware.PermissionMiddleware(models.PermissionEditUser), handlers.SetUserVhost)
 				users.POST("/:nick/ban", middleware.PermissionMiddleware(models.PermissionBanUsers), handlers.BanUser)
+				users.POST("/:nick/username", middleware.PermissionMiddleware(models.PermissionEditUser), handlers.SetUserUsername)
+				users.POST("/:nick/realname", middleware.PermissionMiddleware(models.PermissionEditUser), handlers.SetUserRealname)
+				users.POST("/:nick/join", middleware.PermissionMiddleware(models.PermissionForceJoinPart), handlers.ForceJoinUser)
+				users.POST("/:nick/part", middleware.PermissionMiddleware(models.PermissionForceJoinPart), handlers.ForcePartUser)
+				users.POST("/:nick/quit", middleware.PermissionMiddleware(models.PermissionBanUsers), handlers.QuitUser)
+				users.POST("/:nick/oper", middleware.PermissionMiddleware(models.PermissionSetOper), handlers.SetUserOper)
+				users.POST("/:nick/snomask", middleware.PermissionMiddleware(models.PermissionSetOper), handlers.SetUserSnomask)
+			}
+
+			// GeoIP/ASN databases
+			geo := protected.Group("/geoip")
+			geo.Use(middleware.PermissionMiddleware(models.PermissionViewUsers))
+			{
+				geo.GET("/status", handlers.GetGeoIPStatus)
+				geo.GET("/lookup", handlers.LookupGeoIP)
+			}
+
+			// DNSBL and local IP reputation lists
+			reputation := protected.Group("/reputation")
+			reputation.Use(middleware.PermissionMiddleware(models.PermissionViewUsers))
+			{
+				reputation.GET("/status", handlers.GetReputationStatus)
+				reputation.GET("/check", handlers.CheckIPReputation)
+				reputation.GET("/listed", handlers.GetListedUsers)
+				reputation.DELETE("/cache", middleware.PermissionMiddleware(models.PermissionManageSettings), handlers.ClearReputationCache)
 			}
 
 			// IRC Channels
@@ -91,6 +121,16 @@ func SetupRoutes(r *gin.Engine) {
 				channels.PUT("/:name/topic", middleware.PermissionMiddleware(models.PermissionEditChannel), handlers.SetChannelTopic)
 				channels.POST("/:name/mode", middleware.PermissionMiddleware(models.PermissionEditChannel), handlers.SetChannelMode)
 				channels.POST("/:name/kick", middleware.PermissionMiddleware(models.PermissionEditChannelUser), handlers.KickUser)
+				channels.GET("/:name/lists/:list", handlers.GetChannelList)
+				channels.POST("/:name/lists/:list", middleware.PermissionMiddleware(models.PermissionEditChannelUser), handlers.AddChannelListEntries)
+				channels.POST("/:name/lists/:list/remove", middleware.PermissionMiddleware(models.PermissionEditChannelUser), handlers.RemoveChannelListEntries)
+				channels.GET("/:name/history", handlers.GetChannelHistory)
+				channels.GET("/:name/snapshots", handlers.GetChannelSnapshots)
+				channels.POST("/:name/snapshots", middleware.PermissionMiddleware(models.PermissionEditChannel), handlers.CreateChannelSnapshot)
+				channels.GET("/:name/snapshots/diff", handlers.DiffChannelSnapshots)
+				channels.GET("/:name/snapshots/:id", handlers.GetChannelSnapshot)
+				channels.DELETE("/:name/snapshots/:id", middleware.PermissionMiddleware(models.PermissionEditChannel), handlers.DeleteChannelSnapshot)
+				channels.POST("/:name/snapshots/:id/restore", middleware.PermissionMiddleware(models.PermissionEditChannel), handlers.RestoreChannelSnapshot)
 			}
 
 			// IRC Servers
@@ -98,9 +138,24 @@ func SetupRoutes(r *gin.Engine) {
 			servers.Use(middleware.PermissionMiddleware(models.PermissionViewServers))
 			{
 				servers.GET("", handlers.GetServers)
+				servers.GET("/consistency", handlers.GetServerConsistency)
+				servers.GET("/drift", handlers.GetServerDrift)
+				servers.GET("/metrics", handlers.GetServersMetrics)
 				servers.GET("/:name", handlers.GetServer)
 				servers.POST("/:name/rehash", middleware.PermissionMiddleware(models.PermissionRehash), handlers.RehashServer)
 				servers.GET("/:name/modules", handlers.GetServerModules)
+				servers.GET("/:name/metrics", handlers.GetServerMetrics)
+				servers.POST("/:name/connect", middleware.PermissionMiddleware(models.PermissionServerLink), handlers.ConnectServer)
+				servers.POST("/:name/disconnect", middleware.PermissionMiddleware(models.PermissionServerLink), handlers.DisconnectServer)
+			}
+
+			// Network-wide rehash
+			rehash := protected.Group("/rehash")
+			rehash.Use(middleware.PermissionMiddleware(models.PermissionViewServers))
+			{
+				rehash.POST("/all", middleware.PermissionMiddleware(models.PermissionRehash), handlers.RehashAll)
+				rehash.GET("/runs", handlers.GetRehashRuns)
+				rehash.GET("/runs/:id", handlers.GetRehashRun)
 			}
 
 			// Server Bans
@@ -126,6 +181,36 @@ func SetupRoutes(r *gin.Engine) {
 				bans.GET("/spamfilter", handlers.GetSpamfilters)
 				bans.POST("/spamfilter", middleware.PermissionMiddleware(models.PermissionSpamfilterAdd), handlers.AddSpamfilter)
 				bans.DELETE("/spamfilter", middleware.PermissionMiddleware(models.PermissionSpamfilterDel), handlers.DeleteSpamfilter)
+
+				// Panel-side ban metadata (case references, evidence, internal notes)
+				banEditors := middleware.MultiPermissionMiddleware(models.PermissionServerBanAdd, models.PermissionNameBanAdd, models.PermissionSpamfilterAdd)
+				bans.GET("/metadata", handlers.GetBanMetadata)
+				bans.PUT("/metadata", banEditors, handlers.SaveBanMetadata)
+				bans.DELETE("/metadata/:id", banEditors, handlers.DeleteBanMetadata)
+
+				// Ban reason templates
+				bans.GET("/reason-templates", handlers.GetBanReasonTemplates)
+				bans.POST("/reason-templates", banEditors, handlers.CreateBanReasonTemplate)
+				bans.PUT("/reason-templates/:id", banEditors, handlers.UpdateBanReasonTemplate)
+				bans.DELETE("/reason-templates/:id", banEditors, handlers.DeleteBanReasonTemplate)
+				bans.POST("/reason-templates/:id/preview", handlers.PreviewBanReasonTemplate)
+
+				// Ban history ledger (adds, removals and expiries from the panel and IRC)
+				bans.GET("/ledger", handlers.GetBanLedger)
+
+				// Import/export as UnrealIRCd config, JSON or CSV
+				bans.GET("/export", handlers.ExportBans)
+				bans.POST("/import/preview", banEditors, handlers.PreviewBanImport)
+				bans.POST("/import/apply", banEditors, handlers.ApplyBanImport)
+			}
+
+			// Desired-state policy (bans, spamfilters and channel templates)
+			policy := protected.Group("/policy")
+			policy.Use(middleware.PermissionMiddleware(models.PermissionViewBans))
+			{
+				policy.GET("/export", handlers.ExportPolicy)
+				policy.POST("/plan", handlers.PlanPolicy)
+				policy.POST("/apply", middleware.PermissionMiddleware(models.PermissionApplyPolicy), handlers.ApplyPolicy)
 			}
 
 			// Watch List
@@ -139,6 +224,31 @@ func SetupRoutes(r *gin.Engine) {
 				watchlist.DELETE("/:id", middleware.PermissionMiddleware(models.PermissionBanUsers), handlers.DeleteWatchedUser)
 			}
 
+			// Staff notes on nicks, IPs and accounts
+			notes := protected.Group("/notes")
+			notes.Use(middleware.PermissionMiddleware(models.PermissionViewUsers))
+			{
+				notes.GET("", handlers.GetNotes)
+				notes.GET("/:id", handlers.GetNote)
+				notes.POST("", handlers.CreateNote)
+				notes.PUT("/:id", handlers.UpdateNote)
+				notes.DELETE("/:id", handlers.DeleteNote)
+			}
+
+			// Messages: notices and privmsgs to nicks and channels, global notices, wallops and globops
+			messages := protected.Group("/messages")
+			messages.Use(middleware.PermissionMiddleware(models.PermissionSendMessages))
+			{
+				messages.POST("/send", handlers.SendMessage)
+				messages.POST("/preview", handlers.PreviewMessage)
+				messages.GET("/jobs", handlers.GetMessageJobs)
+				messages.GET("/jobs/:id", handlers.GetMessageJob)
+				messages.GET("/templates", handlers.GetMessageTemplates)
+				messages.POST("/templates", handlers.CreateMessageTemplate)
+				messages.PUT("/templates/:id", handlers.UpdateMessageTemplate)
+				messages.DELETE("/templates/:id", handlers.DeleteMessageTemplate)
+			}
+
 			// Saved Searches
 			savedSearches := protected.Group("/saved-searches")
 			{
@@ -148,6 +258,7 @@ func SetupRoutes(r *gin.Engine) {
 				savedSearches.PUT("/:id", handlers.UpdateSavedSearch)
 				savedSearches.DELETE("/:id", handlers.DeleteSavedSearch)
 				savedSearches.POST("/:id/use", handlers.UseSavedSearch)
+				savedSearches.POST("/:id/execute", handlers.ExecuteSavedSearch)
 			}
 
 			// Scheduled Commands
@@ -277,6 +388,29 @@ func SetupRoutes(r *gin.Engine) {
 				channelTemplates.DELETE("/:id", middleware.PermissionMiddleware(models.PermissionEditChannel), handlers.DeleteChannelTemplate)
 				channelTemplates.POST("/:id/apply", middleware.PermissionMiddleware(models.PermissionEditChannel), handlers.ApplyChannelTemplate)
 				channelTemplates.POST("/from-channel", middleware.PermissionMiddleware(models.PermissionEditChannel), handlers.CreateTemplateFromChannel)
+
+				// Bindings keep channels in line with a template; drift is checked every 5 minutes
+				templateEditors := middleware.PermissionMiddleware(models.PermissionEditChannel)
+				channelTemplates.GET("/bindings", handlers.GetChannelTemplateBindings)
+				channelTemplates.POST("/bindings", templateEditors, handlers.CreateChannelTemplateBinding)
+				channelTemplates.PUT("/bindings/:id", templateEditors, handlers.UpdateChannelTemplateBinding)
+				channelTemplates.DELETE("/bindings/:id", templateEditors, handlers.DeleteChannelTemplateBinding)
+				channelTemplates.GET("/drift", handlers.GetChannelDrift)
+				channelTemplates.POST("/drift/check", templateEditors, handlers.CheckChannelDriftNow)
+				channelTemplates.POST("/drift/:id/remediate", templateEditors, handlers.RemediateChannelDrift)
+				channelTemplates.GET("/drift-exemptions", handlers.GetChannelDriftExemptions)
+				channelTemplates.POST("/drift-exemptions", templateEditors, handlers.CreateChannelDriftExemption)
+				channelTemplates.DELETE("/drift-exemptions/:id", templateEditors, handlers.DeleteChannelDriftExemption)
+			}
+
+			// Channel takeover detection: per-channel sensitivity and responses, detected incidents
+			takeover := protected.Group("/takeover")
+			takeover.Use(middleware.PermissionMiddleware(models.PermissionViewChannels))
+			{
+				takeover.GET("/settings", handlers.GetChannelTakeoverSettings)
+				takeover.POST("/settings", middleware.PermissionMiddleware(models.PermissionEditChannel), handlers.SaveChannelTakeoverSetting)
+				takeover.DELETE("/settings/:id", middleware.PermissionMiddleware(models.PermissionEditChannel), handlers.DeleteChannelTakeoverSetting)
+				takeover.GET("/incidents", handlers.GetTakeoverIncidents)
 			}
 
 			// User Journey Timeline
@@ -309,6 +443,11 @@ func SetupRoutes(r *gin.Engine) {
 			{
 				topology.GET("", handlers.GetNetworkTopology)
 				topology.GET("/server/:name", handlers.GetServerDetails)
+				topology.GET("/link-attempts", handlers.GetServerLinkAttempts)
+				topology.GET("/timeline", handlers.GetTopologyTimeline)
+				topology.GET("/history", handlers.GetTopologyAsOf)
+				topology.GET("/export", handlers.ExportTopology)
+				topology.GET("/analysis", handlers.GetTopologyAnalysis)
 			}
 
 			// TLS/SSL Statistics
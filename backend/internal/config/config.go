@@ -8,11 +8,13 @@ import (
 
 // Config holds all application configuration
 type Config struct {
-	Server   ServerConfig   `json:"server"`
-	Database DatabaseConfig `json:"database"`
-	Auth     AuthConfig     `json:"auth"`
-	RPC      []RPCServer    `json:"rpc_servers"`
-	Plugins  []string       `json:"plugins"`
+	Server     ServerConfig     `json:"server"`
+	Database   DatabaseConfig   `json:"database"`
+	Auth       AuthConfig       `json:"auth"`
+	RPC        []RPCServer      `json:"rpc_servers"`
+	Plugins    []string         `json:"plugins"`
+	GeoIP      GeoIPConfig      `json:"geoip"`
+	Reputation ReputationConfig `json:"reputation"`
 }
 
 // ServerConfig holds HTTP server configuration
@@ -36,6 +38,48 @@ type AuthConfig struct {
 	EncryptionKey  string `json:"encryption_key"`
 }
 
+// GeoIPConfig holds the locations of the MaxMind-format (mmdb) databases
+type GeoIPConfig struct {
+	CountryDB      string `json:"country_db"`      // defaults to data/GeoLite2-Country.mmdb
+	ASNDB          string `json:"asn_db"`          // defaults to data/GeoLite2-ASN.mmdb
+	ReloadInterval int    `json:"reload_interval"` // seconds between checks for replaced files
+}
+
+// ReputationConfig holds the DNS blocklists and local IP lists users are checked against
+type ReputationConfig struct {
+	Resolver       string            `json:"resolver"`        // host:port of the DNS server, empty for the system resolver
+	TimeoutMs      int               `json:"timeout_ms"`      // per-query timeout
+	CacheTTL       int               `json:"cache_ttl"`       // seconds to cache results
+	ReloadInterval int               `json:"reload_interval"` // seconds between checks for changed local lists
+	DNSBLs         []DNSBLConfig     `json:"dnsbls"`
+	LocalLists     []LocalListConfig `json:"local_lists"`
+	AutoGline      AutoGlineConfig   `json:"auto_gline"`
+}
+
+// DNSBLConfig is a DNS blocklist zone
+type DNSBLConfig struct {
+	Name       string   `json:"name"`
+	Zone       string   `json:"zone"`       // e.g. dnsbl.dronebl.org
+	Codes      []string `json:"codes"`      // answers that count as listed, empty for any 127.0.0.0/8 answer
+	Confidence string   `json:"confidence"` // low, medium or high
+	AutoGline  bool     `json:"auto_gline"` // only honoured for high confidence lists
+}
+
+// LocalListConfig is a file of IP addresses and CIDR ranges, one per line
+type LocalListConfig struct {
+	Name       string `json:"name"`
+	Path       string `json:"path"`
+	Confidence string `json:"confidence"`
+	AutoGline  bool   `json:"auto_gline"`
+}
+
+// AutoGlineConfig controls automatic G-Lines for users on high confidence lists
+type AutoGlineConfig struct {
+	Enabled  bool   `json:"enabled"`
+	Duration string `json:"duration"` // e.g. 1d
+	Reason   string `json:"reason"`   // {list} is replaced with the list name
+}
+
 // RPCServer holds UnrealIRCd RPC server configuration
 type RPCServer struct {
 	Name          string `json:"name"`
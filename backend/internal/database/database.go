@@ -76,6 +76,22 @@ func Migrate() error {
 		&models.ScheduledCommand{},
 		&models.AlertRule{},
 		&models.ChannelTemplate{},
+		&models.ChannelTemplateBinding{},
+		&models.ChannelDrift{},
+		&models.ChannelDriftExemption{},
+		&models.ChannelSnapshot{},
+		&models.ChannelTakeoverSetting{},
+		&models.TakeoverIncident{},
+		&models.ChannelMemberSample{},
+		&models.ChannelMemberRollup{},
+		&models.ChannelTopicChange{},
+		&models.ServerLinkAttempt{},
+		&models.RehashRun{},
+		&models.RehashServerResult{},
+		&models.ServerDrift{},
+		&models.TopologySnapshot{},
+		&models.TopologyChange{},
+		&models.ServerSample{},
 		&models.UserJourneyEvent{},
 		&models.ComplianceReport{},
 		&models.Feedback{},
@@ -84,6 +100,11 @@ func Migrate() error {
 		&models.DigestSettings{},
 		&models.DigestHistory{},
 		&models.InstalledPlugin{},
+		&models.BanMetadata{},
+		&models.BanReasonTemplate{},
+		&models.MessageTemplate{},
+		&models.MessageJob{},
+		&models.BanLedgerEntry{},
 	)
 }
 
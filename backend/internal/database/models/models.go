@@ -64,13 +64,18 @@ type Setting struct {
 
 // Note represents a user/ip/account note
 type Note struct {
-	ID        uint      `gorm:"primarykey" json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	CreatedBy string    `gorm:"size:64" json:"created_by"`
-	Nick      string    `gorm:"size:64;index" json:"nick,omitempty"`
-	IP        string    `gorm:"size:64;index" json:"ip,omitempty"`
-	Account   string    `gorm:"size:64;index" json:"account,omitempty"`
-	Note      string    `gorm:"type:text" json:"note"`
+	ID          uint      `gorm:"primarykey" json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	CreatedBy   string    `gorm:"size:64" json:"created_by"`
+	UpdatedBy   string    `gorm:"size:64" json:"updated_by,omitempty"`
+	Nick        string    `gorm:"size:64;index" json:"nick,omitempty"`
+	IP          string    `gorm:"size:64;index" json:"ip,omitempty"`
+	Account     string    `gorm:"size:64;index" json:"account,omitempty"`
+	Note        string    `gorm:"type:text" json:"note"`
+	BanType     string    `gorm:"size:32" json:"ban_type,omitempty"`        // Set when attached from a ban
+	BanMask     string    `gorm:"size:255;index" json:"ban_mask,omitempty"` // Set when attached from a ban
+	WatchlistID *uint     `gorm:"index" json:"watchlist_id,omitempty"`      // Set when attached from a watch list entry
 }
 
 // Fail2Ban represents a failed login attempt
@@ -291,7 +296,7 @@ type SavedSearch struct {
 	Name      string         `gorm:"size:128" json:"name"`           // Display name for the search
 	Page      string         `gorm:"size:64" json:"page"`            // Which page this search applies to (users, channels, bans, etc.)
 	Query     string         `gorm:"size:512" json:"query"`          // The search query string
-	Filters   string         `gorm:"type:text" json:"filters"`       // JSON object of additional filters
+	Filters   string         `gorm:"type:text" json:"filters"`       // Filter expression, e.g. server:hub1 AND tls:false (older entries hold a JSON object)
 	IsGlobal  bool           `gorm:"default:false" json:"is_global"` // If true, visible to all users
 	UseCount  uint           `gorm:"default:0" json:"use_count"`     // How many times this search has been used
 	LastUsed  *time.Time     `json:"last_used,omitempty"`            // When search was last used
@@ -373,6 +378,210 @@ type ChannelTemplate struct {
 	CreatedByUsername string         `gorm:"size:64" json:"created_by_username"` // Creator username
 }
 
+// ChannelTemplateBinding keeps channels matching a name or wildcard pattern in line with a template
+type ChannelTemplateBinding struct {
+	ID                uint           `gorm:"primarykey" json:"id"`
+	CreatedAt         time.Time      `json:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at"`
+	DeletedAt         gorm.DeletedAt `gorm:"index" json:"-"`
+	TemplateID        uint           `gorm:"index" json:"template_id"`            // Template the channels should match
+	Pattern           string         `gorm:"size:255;index" json:"pattern"`       // Channel name or wildcard pattern, e.g. #help*
+	AutoRemediate     bool           `gorm:"default:false" json:"auto_remediate"` // Re-apply the template when drift is found
+	LastCheckedAt     *time.Time     `json:"last_checked_at,omitempty"`           // When the drift check last ran
+	CreatedBy         uint           `json:"created_by"`                          // Creator
+	CreatedByUsername string         `gorm:"size:64" json:"created_by_username"`  // Creator username
+}
+
+// ChannelDrift records how a bound channel differs from its template; rows are removed once it matches again
+type ChannelDrift struct {
+	ID               uint       `gorm:"primarykey" json:"id"`
+	CreatedAt        time.Time  `json:"created_at"` // When the drift was first detected
+	UpdatedAt        time.Time  `json:"updated_at"`
+	Channel          string     `gorm:"size:64;uniqueIndex" json:"channel"`
+	BindingID        uint       `gorm:"index" json:"binding_id"`
+	TemplateID       uint       `gorm:"index" json:"template_id"`
+	TemplateName     string     `gorm:"size:128" json:"template_name"`
+	Differences      string     `gorm:"type:text" json:"differences"` // JSON array of {field, expected, actual}
+	LastCheckedAt    time.Time  `json:"last_checked_at"`              // When the drift was last confirmed
+	RemediatedAt     *time.Time `json:"remediated_at,omitempty"`      // When the template was last re-applied
+	RemediationError string     `gorm:"type:text" json:"remediation_error,omitempty"`
+}
+
+// ChannelDriftExemption excludes a channel from drift checks and remediation
+type ChannelDriftExemption struct {
+	ID                uint       `gorm:"primarykey" json:"id"`
+	CreatedAt         time.Time  `json:"created_at"`
+	Channel           string     `gorm:"size:64;uniqueIndex" json:"channel"`
+	Reason            string     `gorm:"type:text" json:"reason"`
+	ExpiresAt         *time.Time `json:"expires_at,omitempty"` // Exempt forever when empty
+	CreatedBy         uint       `json:"created_by"`
+	CreatedByUsername string     `gorm:"size:64" json:"created_by_username"`
+}
+
+// ChannelSnapshot is the full state of a channel at one point in time
+type ChannelSnapshot struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+	Channel   string    `gorm:"size:64;index" json:"channel"`
+	Trigger   string    `gorm:"size:32" json:"trigger"` // manual, scheduled or pre_restore
+	NumUsers  int       `json:"num_users"`
+	State     string    `gorm:"type:text" json:"state"` // JSON object: modes, mode params, topic, lists and ops
+	CreatedBy string    `gorm:"size:64" json:"created_by,omitempty"`
+}
+
+// ChannelTakeoverSetting controls takeover detection for one channel; Channel "*" holds the defaults
+type ChannelTakeoverSetting struct {
+	ID                uint      `gorm:"primarykey" json:"id"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+	Channel           string    `gorm:"size:64;uniqueIndex" json:"channel"`
+	Sensitivity       string    `gorm:"size:16;default:medium" json:"sensitivity"` // off, low, medium or high
+	LockTemplateID    *uint     `json:"lock_template_id,omitempty"`                // Template applied to lock the channel
+	GlineClones       bool      `gorm:"default:false" json:"gline_clones"`         // G-Line the IPs behind a clone join flood
+	GlineDuration     string    `gorm:"size:32" json:"gline_duration"`             // e.g. 1h, defaults to 1h
+	UpdatedByUsername string    `gorm:"size:64" json:"updated_by_username"`
+}
+
+// TakeoverIncident records a detected takeover pattern and the response taken
+type TakeoverIncident struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	CreatedAt   time.Time `gorm:"index" json:"created_at"`
+	Channel     string    `gorm:"size:64;index" json:"channel"`
+	Pattern     string    `gorm:"size:32;index" json:"pattern"` // mass_deop, mass_kick, topic_vandalism, channel_lock or clone_flood
+	Actor       string    `gorm:"size:64" json:"actor,omitempty"`
+	Count       int       `json:"count"`
+	Sensitivity string    `gorm:"size:16" json:"sensitivity"`
+	Details     string    `gorm:"type:text" json:"details"`  // JSON object with the pattern specifics
+	Response    string    `gorm:"type:text" json:"response"` // What was done about it, if anything
+}
+
+// ChannelMemberSample is a channel's member count at one sampling interval
+type ChannelMemberSample struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	SampledAt time.Time `gorm:"index" json:"sampled_at"`
+	Channel   string    `gorm:"size:64;index" json:"channel"`
+	NumUsers  int       `json:"num_users"`
+}
+
+// ChannelMemberRollup summarises a channel's member samples over an hour or a day
+type ChannelMemberRollup struct {
+	ID       uint      `gorm:"primarykey" json:"id"`
+	Period   string    `gorm:"size:8;index:idx_channel_rollup_bucket" json:"period"` // hour or day
+	Bucket   time.Time `gorm:"index:idx_channel_rollup_bucket" json:"bucket"`        // Start of the hour or day (UTC)
+	Channel  string    `gorm:"size:64;index" json:"channel"`
+	MinUsers int       `json:"min_users"`
+	MaxUsers int       `json:"max_users"`
+	AvgUsers float64   `json:"avg_users"`
+	Samples  int       `json:"samples"`
+}
+
+// ChannelTopicChange is a topic a channel had, recorded when the panel first saw it
+type ChannelTopicChange struct {
+	ID        uint       `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time  `gorm:"index" json:"created_at"`
+	Channel   string     `gorm:"size:64;index" json:"channel"`
+	Topic     string     `gorm:"type:text" json:"topic"`
+	SetBy     string     `gorm:"size:128" json:"set_by,omitempty"`
+	SetAt     *time.Time `json:"set_at,omitempty"` // When the topic was set, as reported by the server
+}
+
+// ServerLinkAttempt records a link request made through the panel or a link event from the log stream
+type ServerLinkAttempt struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	CreatedAt   time.Time `gorm:"index" json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Server      string    `gorm:"size:128;index" json:"server"`      // Link block or server name
+	Via         string    `gorm:"size:128" json:"via,omitempty"`     // Server asked to make the connection
+	Action      string    `gorm:"size:16" json:"action"`             // connect or disconnect
+	Status      string    `gorm:"size:16;index" json:"status"`       // pending, linked, failed or disconnected
+	EventID     string    `gorm:"size:64" json:"event_id,omitempty"` // Last log event seen for the attempt
+	Message     string    `gorm:"type:text" json:"message,omitempty"`
+	Reason      string    `gorm:"type:text" json:"reason,omitempty"`
+	RequestedBy string    `gorm:"size:64" json:"requested_by,omitempty"` // Panel user, empty for links started on IRC
+}
+
+// RehashRun is a network-wide rehash and its report
+type RehashRun struct {
+	ID                  uint                 `gorm:"primarykey" json:"id"`
+	CreatedAt           time.Time            `gorm:"index" json:"created_at"`
+	CompletedAt         *time.Time           `json:"completed_at,omitempty"`
+	Mode                string               `gorm:"size:16" json:"mode"` // sequential or waves
+	WaveSize            int                  `json:"wave_size"`
+	StopOnFailure       bool                 `json:"stop_on_failure"`
+	Status              string               `gorm:"size:16;index" json:"status"` // running, completed, failed or stopped
+	Total               int                  `json:"total"`
+	Succeeded           int                  `json:"succeeded"`
+	Failed              int                  `json:"failed"`
+	Unconfirmed         int                  `json:"unconfirmed"` // Rehash sent, but the outcome was not reported
+	Skipped             int                  `json:"skipped"`
+	Warnings            int                  `json:"warnings"` // Config warnings over all servers
+	RequestedBy         uint                 `json:"requested_by"`
+	RequestedByUsername string               `gorm:"size:64" json:"requested_by_username"`
+	Results             []RehashServerResult `gorm:"foreignKey:RunID" json:"results,omitempty"`
+}
+
+// RehashServerResult is the outcome of rehashing one server in a RehashRun
+type RehashServerResult struct {
+	ID         uint       `gorm:"primarykey" json:"id"`
+	RunID      uint       `gorm:"index" json:"run_id"`
+	Server     string     `gorm:"size:128" json:"server"`
+	Wave       int        `json:"wave"`
+	Status     string     `gorm:"size:16" json:"status"` // pending, success, unconfirmed, failed or skipped
+	Error      string     `gorm:"type:text" json:"error,omitempty"`
+	Warnings   string     `gorm:"type:text" json:"warnings"` // JSON array of config warnings
+	Errors     string     `gorm:"type:text" json:"errors"`   // JSON array of config errors
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// ServerDrift records a module or version difference between servers; rows are removed once the servers agree again
+type ServerDrift struct {
+	ID            uint      `gorm:"primarykey" json:"id"`
+	CreatedAt     time.Time `json:"created_at"` // When the drift was first detected
+	UpdatedAt     time.Time `json:"updated_at"`
+	Kind          string    `gorm:"size:32;uniqueIndex:idx_server_drift_subject" json:"kind"`     // module_missing, module_version or unrealircd_version
+	Subject       string    `gorm:"size:128;uniqueIndex:idx_server_drift_subject" json:"subject"` // Module name, or "unrealircd"
+	Details       string    `gorm:"type:text" json:"details"`                                     // JSON object of which servers differ and how
+	LastCheckedAt time.Time `json:"last_checked_at"`
+}
+
+// TopologySnapshot is the server tree at one point in time, recorded whenever the servers or their uplinks change
+type TopologySnapshot struct {
+	ID         uint      `gorm:"primarykey" json:"id"`
+	CreatedAt  time.Time `gorm:"index" json:"created_at"`
+	Trigger    string    `gorm:"size:16" json:"trigger"`   // poll or event
+	Signature  string    `gorm:"size:64" json:"signature"` // Hash of the server names and uplinks
+	NumServers int       `json:"num_servers"`
+	NumUsers   int       `json:"num_users"`
+	Servers    string    `gorm:"type:text" json:"servers"` // JSON array of {name, uplink, ulined, users, info}
+}
+
+// TopologyChange is a server that split from the network, joined it or moved to another uplink
+type TopologyChange struct {
+	ID              uint       `gorm:"primarykey" json:"id"`
+	CreatedAt       time.Time  `gorm:"index" json:"created_at"`
+	SnapshotID      uint       `gorm:"index" json:"snapshot_id"`  // Snapshot in which the change was first seen
+	Kind            string     `gorm:"size:16;index" json:"kind"` // split, join or relink
+	Server          string     `gorm:"size:128;index" json:"server"`
+	Uplink          string     `gorm:"size:128" json:"uplink,omitempty"`          // Uplink before a split, after a join or relink
+	PreviousUplink  string     `gorm:"size:128" json:"previous_uplink,omitempty"` // Relink only
+	UsersAffected   int        `json:"users_affected"`
+	EndedAt         *time.Time `json:"ended_at,omitempty"`         // Split only: when the server joined again
+	DurationSeconds int        `json:"duration_seconds,omitempty"` // Split: time until it joined again; join: time it was split
+}
+
+// ServerSample is one server's user count, boot time and lag at one sampling interval
+type ServerSample struct {
+	ID        uint       `gorm:"primarykey" json:"id"`
+	SampledAt time.Time  `gorm:"index" json:"sampled_at"`
+	Server    string     `gorm:"size:128;index" json:"server"`
+	NumUsers  int        `json:"num_users"`
+	BootTime  *time.Time `json:"boot_time,omitempty"`
+	Synced    bool       `json:"synced"`
+	LagMS     *int       `json:"lag_ms,omitempty"` // Round trip of an RPC request forwarded to the server; empty if it did not answer
+	Restarted bool       `json:"restarted"`        // The boot time moved forward since the previous sample
+}
+
 // UserJourneyEvent represents a tracked event for user journey timeline
 type UserJourneyEvent struct {
 	ID        uint      `gorm:"primarykey" json:"id"`
@@ -385,6 +594,10 @@ type UserJourneyEvent struct {
 	EventType string `gorm:"size:64;index" json:"event_type"` // connect, disconnect, nick_change, join, part, kick, ban, etc.
 	Details   string `gorm:"type:text" json:"details"`        // JSON object with event-specific details
 	Server    string `gorm:"size:128" json:"server"`          // Server where event occurred
+	// GeoIP enrichment of IP at the time of the event
+	Country string `gorm:"size:2;index" json:"country,omitempty"` // ISO country code
+	ASN     uint   `gorm:"index" json:"asn,omitempty"`            // Autonomous system number
+	ASOrg   string `gorm:"size:255" json:"as_org,omitempty"`      // Autonomous system organisation
 }
 
 // ComplianceReport represents a GDPR/compliance data export
@@ -485,3 +698,85 @@ type InstalledPlugin struct {
 	InstalledAt     time.Time `gorm:"autoCreateTime" json:"installed_at"`
 	UpdatedAt       time.Time `gorm:"autoUpdateTime" json:"updated_at"`
 }
+
+// BanMetadata stores panel-side information about a ban that is never sent to the IRCd
+type BanMetadata struct {
+	ID                uint      `gorm:"primarykey" json:"id"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+	BanType           string    `gorm:"size:32;uniqueIndex:idx_ban_metadata_key" json:"ban_type"` // gline, kline, zline, name, spamfilter, etc.
+	Mask              string    `gorm:"size:512;uniqueIndex:idx_ban_metadata_key" json:"mask"`    // Ban mask as stored by the IRCd, or match type:targets:action:match string for spamfilters
+	CaseRef           string    `gorm:"size:128;index" json:"case_ref"`                           // Abuse ticket or case reference
+	Evidence          string    `gorm:"type:text" json:"evidence"`                                // Links, log excerpts, etc.
+	InternalNote      string    `gorm:"type:text" json:"internal_note"`                           // Private note for staff only
+	TemplateID        *uint     `json:"template_id,omitempty"`                                    // Reason template used, if any
+	CreatedBy         uint      `json:"created_by"`                                               // User ID who created this
+	CreatedByUsername string    `gorm:"size:64" json:"created_by_username"`                       // Username for display
+	UpdatedByUsername string    `gorm:"size:64" json:"updated_by_username"`                       // Last editor
+}
+
+// MessageTemplate represents a reusable announcement with {variables}
+type MessageTemplate struct {
+	ID                uint           `gorm:"primarykey" json:"id"`
+	CreatedAt         time.Time      `json:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at"`
+	DeletedAt         gorm.DeletedAt `gorm:"index" json:"-"`
+	Name              string         `gorm:"size:128" json:"name"`               // Display name
+	Description       string         `gorm:"type:text" json:"description"`       // Description
+	Type              string         `gorm:"size:32" json:"type"`                // Default message type: notice, privmsg, global, wallops or globops
+	Body              string         `gorm:"type:text" json:"body"`              // Message text, e.g. "Maintenance on {server} at {time}"
+	UseCount          uint           `gorm:"default:0" json:"use_count"`         // Times used
+	CreatedBy         uint           `json:"created_by"`                         // Creator
+	CreatedByUsername string         `gorm:"size:64" json:"created_by_username"` // Creator username
+}
+
+// MessageJob is a message delivered to each of many recipients in the background
+type MessageJob struct {
+	ID                  uint       `gorm:"primarykey" json:"id"`
+	CreatedAt           time.Time  `gorm:"index" json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+	CompletedAt         *time.Time `json:"completed_at,omitempty"`
+	Type                string     `gorm:"size:32" json:"type"`
+	Target              string     `gorm:"size:255" json:"target,omitempty"`
+	Lines               string     `gorm:"type:text" json:"lines"`      // The message as sent, newline-separated
+	Status              string     `gorm:"size:16;index" json:"status"` // running, completed or failed
+	Recipients          int        `json:"recipients"`
+	Sent                int        `json:"sent"`
+	Failed              int        `json:"failed"`
+	FailedNicks         string     `gorm:"type:text" json:"failed_nicks,omitempty"` // Space-separated
+	Error               string     `gorm:"type:text" json:"error,omitempty"`        // Last delivery error
+	RequestedByUsername string     `gorm:"size:64" json:"requested_by_username"`
+}
+
+// BanReasonTemplate represents a reusable ban reason with {placeholders}
+type BanReasonTemplate struct {
+	ID                uint           `gorm:"primarykey" json:"id"`
+	CreatedAt         time.Time      `json:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at"`
+	DeletedAt         gorm.DeletedAt `gorm:"index" json:"-"`
+	Name              string         `gorm:"size:128" json:"name"`               // Display name
+	Description       string         `gorm:"type:text" json:"description"`       // Description
+	BanKind           string         `gorm:"size:32;index" json:"ban_kind"`      // server, name, spamfilter, exception or empty for any
+	Reason            string         `gorm:"type:text" json:"reason"`            // Reason text, e.g. "Abuse ({ticket}), contact {contact}"
+	DefaultDuration   string         `gorm:"size:32" json:"default_duration"`    // Duration used when none is given
+	UseCount          uint           `gorm:"default:0" json:"use_count"`         // Times used
+	CreatedBy         uint           `json:"created_by"`                         // Creator
+	CreatedByUsername string         `gorm:"size:64" json:"created_by_username"` // Creator username
+}
+
+// BanLedgerEntry records an add, delete or expiry of a ban, exception or spamfilter
+type BanLedgerEntry struct {
+	ID         uint       `gorm:"primarykey" json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	OccurredAt time.Time  `gorm:"index" json:"occurred_at"`      // When the change happened (or was first noticed)
+	Action     string     `gorm:"size:16;index" json:"action"`   // add, delete, expire
+	BanType    string     `gorm:"size:32;index" json:"ban_type"` // gline, kline, zline, name, exception, spamfilter, etc.
+	Mask       string     `gorm:"size:512;index" json:"mask"`    // Ban mask as stored by the IRCd, or match type:targets:action:match string for spamfilters
+	Reason     string     `gorm:"type:text" json:"reason"`       // Ban reason at the time of the change
+	SetBy      string     `gorm:"size:255" json:"set_by"`        // Original setter as reported by the IRCd
+	Actor      string     `gorm:"size:255;index" json:"actor"`   // Panel user or IRC oper responsible for this change
+	Source     string     `gorm:"size:16;index" json:"source"`   // panel, log or snapshot
+	Duration   string     `gorm:"size:64" json:"duration"`       // Duration as given when the ban was set
+	ExpireAt   *time.Time `json:"expire_at,omitempty"`           // When the ban was due to expire
+	Server     string     `gorm:"size:128" json:"server"`        // Server that reported the change
+}
@@ -26,6 +26,12 @@ const (
 	PermissionManageRPCServers = "manage_rpc"
 	PermissionManageWebhooks   = "manage_webhooks"
 	PermissionManageSMTP       = "manage_smtp"
+	PermissionApplyPolicy      = "policy_apply"
+	PermissionSendMessages     = "send_messages"
+	PermissionSendGlobal       = "send_global"
+	PermissionForceJoinPart    = "user_join_part"
+	PermissionSetOper          = "user_set_oper"
+	PermissionServerLink       = "server_link"
 )
 
 // AllPermissions returns all available permissions
@@ -47,6 +53,10 @@ var AllPermissions = []struct {
 	{PermissionViewUsers, "View Users", "View IRC users list", "Users"},
 	{PermissionEditUser, "Edit Users", "Modify IRC user settings", "Users"},
 	{PermissionBanUsers, "Ban Users", "Kill and ban IRC users", "Users"},
+	{PermissionForceJoinPart, "Force Join/Part", "Make IRC users join or leave channels", "Users"},
+	{PermissionSetOper, "Set Oper Status", "Give IRC users oper status and change snomasks", "Users"},
+	{PermissionSendMessages, "Send Messages", "Send notices and private messages to users and channels", "Users"},
+	{PermissionSendGlobal, "Send Global Messages", "Send global notices, wallops and globops to the whole network", "Users"},
 
 	// Channel Management
 	{PermissionViewChannels, "View Channels", "View IRC channels list", "Channels"},
@@ -56,6 +66,7 @@ var AllPermissions = []struct {
 	// Server Management
 	{PermissionViewServers, "View Servers", "View server list and details", "Servers"},
 	{PermissionRehash, "Rehash Servers", "Rehash IRC servers", "Servers"},
+	{PermissionServerLink, "Manage Server Links", "Connect servers and disconnect (SQUIT) them from the network", "Servers"},
 
 	// Ban Management
 	{PermissionViewBans, "View Bans", "View server bans and spamfilters", "Bans"},
@@ -67,6 +78,7 @@ var AllPermissions = []struct {
 	{PermissionBanExceptionDel, "Remove Ban Exceptions", "Remove E-Lines", "Bans"},
 	{PermissionSpamfilterAdd, "Add Spamfilters", "Add spamfilter rules", "Bans"},
 	{PermissionSpamfilterDel, "Remove Spamfilters", "Remove spamfilter rules", "Bans"},
+	{PermissionApplyPolicy, "Apply Policy", "Apply desired-state documents for bans, spamfilters and channel templates", "Bans"},
 
 	// Logs
 	{PermissionViewLogs, "View Logs", "View IRC server logs", "Logs"},
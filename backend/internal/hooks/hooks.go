@@ -48,6 +48,9 @@ const (
 
 	// Webhook hooks
 	HookWebhookReceived HookType = iota + 700 // Called when a webhook is received from UnrealIRCd
+
+	// Log stream hooks
+	HookLogEvent HookType = iota + 800 // Called for each log event received over the RPC log subscription
 )
 
 // HookCallback is the function signature for hook callbacks
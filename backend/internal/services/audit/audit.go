@@ -21,6 +21,7 @@ const (
 	EventIDRoleCreate   = "WEBPANEL_ROLE_CREATE"
 	EventIDRoleUpdate   = "WEBPANEL_ROLE_UPDATE"
 	EventIDRoleDelete   = "WEBPANEL_ROLE_DELETE"
+	EventIDAutoGline    = "WEBPANEL_AUTO_GLINE"
 
 	// Subsystem
 	Subsystem = "webpanel"
@@ -113,3 +114,9 @@ func LogRoleDelete(byUser, roleName string) {
 	msg := fmt.Sprintf("Role '%s' deleted by '%s'", roleName, byUser)
 	SendLog(msg, LevelInfo, EventIDRoleDelete)
 }
+
+// LogAutoGline logs an automatic G-Line placed because a user's IP is on a blocklist
+func LogAutoGline(nick, mask, list string) {
+	msg := fmt.Sprintf("Automatic G-Line on '%s' (%s): listed on %s", mask, nick, list)
+	SendLog(msg, LevelWarn, EventIDAutoGline)
+}
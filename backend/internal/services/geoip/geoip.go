@@ -0,0 +1,252 @@
+package geoip
+
+import (
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/config"
+)
+
+// Default database locations, relative to the working directory like the SQLite database
+const (
+	DefaultCountryDB = "data/GeoLite2-Country.mmdb"
+	DefaultASNDB     = "data/GeoLite2-ASN.mmdb"
+)
+
+// defaultReloadInterval is how often database files are checked for replacement
+const defaultReloadInterval = 60 * time.Second
+
+// Info is the GeoIP/ASN data for an IP address
+type Info struct {
+	CountryCode string `json:"country_code,omitempty"`
+	CountryName string `json:"country_name,omitempty"`
+	ASN         uint   `json:"asn,omitempty"`
+	ASOrg       string `json:"as_org,omitempty"`
+}
+
+// Empty reports whether no data was found
+func (i *Info) Empty() bool {
+	return i == nil || (i.CountryCode == "" && i.ASN == 0)
+}
+
+// DatabaseStatus describes a loaded database file
+type DatabaseStatus struct {
+	Path         string    `json:"path"`
+	Loaded       bool      `json:"loaded"`
+	DatabaseType string    `json:"database_type,omitempty"`
+	BuildTime    time.Time `json:"build_time,omitempty"`
+	LoadedAt     time.Time `json:"loaded_at,omitempty"`
+	Error        string    `json:"error,omitempty"`
+}
+
+type database struct {
+	path    string
+	reader  *mmdbReader
+	modTime time.Time
+	size    int64
+	status  DatabaseStatus
+}
+
+// Service holds the country and ASN databases and reloads them when the files change
+type Service struct {
+	mu       sync.RWMutex
+	country  *database
+	asn      *database
+	interval time.Duration
+	stopChan chan struct{}
+}
+
+var (
+	instance *Service
+	once     sync.Once
+)
+
+// GetService returns the singleton GeoIP service
+func GetService() *Service {
+	once.Do(func() {
+		countryPath, asnPath := DefaultCountryDB, DefaultASNDB
+		interval := defaultReloadInterval
+
+		cfg := config.Get().GeoIP
+		if cfg.CountryDB != "" {
+			countryPath = cfg.CountryDB
+		}
+		if cfg.ASNDB != "" {
+			asnPath = cfg.ASNDB
+		}
+		if cfg.ReloadInterval > 0 {
+			interval = time.Duration(cfg.ReloadInterval) * time.Second
+		}
+
+		instance = &Service{
+			country:  &database{path: countryPath, status: DatabaseStatus{Path: countryPath}},
+			asn:      &database{path: asnPath, status: DatabaseStatus{Path: asnPath}},
+			interval: interval,
+			stopChan: make(chan struct{}),
+		}
+	})
+	return instance
+}
+
+// Initialize loads the databases and starts watching them for replacement
+func Initialize() *Service {
+	service := GetService()
+	service.reload()
+	go service.watch()
+	return service
+}
+
+// Stop stops watching the database files
+func (s *Service) Stop() {
+	close(s.stopChan)
+}
+
+// Lookup returns GeoIP/ASN data for an IP address, or nil if nothing is known
+func Lookup(ip string) *Info {
+	return GetService().Lookup(ip)
+}
+
+// Lookup returns GeoIP/ASN data for an IP address, or nil if nothing is known
+func (s *Service) Lookup(ip string) *Info {
+	parsed := net.ParseIP(strings.TrimSpace(ip))
+	if parsed == nil {
+		return nil
+	}
+
+	s.mu.RLock()
+	country, asn := s.country.reader, s.asn.reader
+	s.mu.RUnlock()
+
+	info := &Info{}
+	// Either database may be a combined one, so read every field from both
+	for _, reader := range []*mmdbReader{country, asn} {
+		if reader == nil {
+			continue
+		}
+		record, err := reader.lookup(parsed)
+		if err != nil || record == nil {
+			continue
+		}
+		fillInfo(info, record)
+	}
+
+	if info.Empty() {
+		return nil
+	}
+	return info
+}
+
+// LookupMask returns GeoIP/ASN data for the host part of a ban mask such as
+// "*@192.0.2.1", "*@192.0.2.0/24" or "2001:db8::/32". Non-IP masks return nil.
+func LookupMask(mask string) *Info {
+	host := mask
+	if at := strings.LastIndex(host, "@"); at >= 0 {
+		host = host[at+1:]
+	}
+	if strings.Contains(host, "/") {
+		_, network, err := net.ParseCIDR(host)
+		if err != nil {
+			return nil
+		}
+		return Lookup(network.IP.String())
+	}
+	return Lookup(host)
+}
+
+// Status returns the state of both databases
+func (s *Service) Status() []DatabaseStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return []DatabaseStatus{s.country.status, s.asn.status}
+}
+
+// Available reports whether at least one database is loaded
+func (s *Service) Available() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.country.reader != nil || s.asn.reader != nil
+}
+
+func (s *Service) watch() {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case <-ticker.C:
+			s.reload()
+		}
+	}
+}
+
+// reload (re)loads any database whose file appeared, changed or disappeared
+func (s *Service) reload() {
+	for _, db := range []*database{s.country, s.asn} {
+		info, err := os.Stat(db.path)
+		if err != nil {
+			s.mu.Lock()
+			if db.reader != nil {
+				log.Printf("[GeoIP] %s was removed, unloading", db.path)
+			}
+			db.reader = nil
+			db.modTime = time.Time{}
+			db.status = DatabaseStatus{Path: db.path}
+			if !os.IsNotExist(err) {
+				db.status.Error = err.Error()
+			}
+			s.mu.Unlock()
+			continue
+		}
+
+		if info.ModTime().Equal(db.modTime) && info.Size() == db.size {
+			continue
+		}
+
+		buf, err := os.ReadFile(db.path)
+		var reader *mmdbReader
+		if err == nil {
+			reader, err = newMMDBReader(buf)
+		}
+
+		s.mu.Lock()
+		db.modTime = info.ModTime()
+		db.size = info.Size()
+		if err != nil {
+			// Keep serving the previous version if the new file is bad
+			log.Printf("[GeoIP] Failed to load %s: %v", db.path, err)
+			db.status.Error = err.Error()
+			s.mu.Unlock()
+			continue
+		}
+		db.reader = reader
+		db.status = DatabaseStatus{
+			Path:         db.path,
+			Loaded:       true,
+			DatabaseType: reader.databaseType,
+			BuildTime:    time.Unix(int64(reader.buildEpoch), 0),
+			LoadedAt:     time.Now(),
+		}
+		s.mu.Unlock()
+
+		log.Printf("[GeoIP] Loaded %s (%s)", db.path, reader.databaseType)
+	}
+}
+
+func fillInfo(info *Info, record map[string]interface{}) {
+	if country, ok := record["country"].(map[string]interface{}); ok && info.CountryCode == "" {
+		info.CountryCode, _ = country["iso_code"].(string)
+		if names, ok := country["names"].(map[string]interface{}); ok {
+			info.CountryName, _ = names["en"].(string)
+		}
+	}
+	if asn := toUint64(record["autonomous_system_number"]); asn != 0 && info.ASN == 0 {
+		info.ASN = uint(asn)
+		info.ASOrg, _ = record["autonomous_system_organization"].(string)
+	}
+}
@@ -0,0 +1,338 @@
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+)
+
+// metadataMarker precedes the metadata map at the end of every MaxMind DB file
+var metadataMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+// dataSectionSeparator is the 16 zero bytes between the search tree and the data section
+const dataSectionSeparator = 16
+
+// mmdbReader reads MaxMind DB (mmdb) files as documented at
+// https://maxmind.github.io/MaxMind-DB/. The whole file is kept in memory.
+type mmdbReader struct {
+	buf          []byte
+	nodeCount    uint
+	recordSize   uint
+	ipVersion    uint
+	databaseType string
+	buildEpoch   uint64
+	dataStart    uint
+	ipv4Start    uint
+}
+
+// mmdb data section types
+const (
+	mmdbExtended = iota
+	mmdbPointer
+	mmdbString
+	mmdbDouble
+	mmdbBytes
+	mmdbUint16
+	mmdbUint32
+	mmdbMap
+	mmdbInt32
+	mmdbUint64
+	mmdbUint128
+	mmdbArray
+	mmdbContainer
+	mmdbEndMarker
+	mmdbBool
+	mmdbFloat
+)
+
+var errInvalidDatabase = errors.New("invalid MaxMind DB file")
+
+func newMMDBReader(buf []byte) (*mmdbReader, error) {
+	markerAt := bytes.LastIndex(buf, metadataMarker)
+	if markerAt < 0 {
+		return nil, fmt.Errorf("%w: metadata marker not found", errInvalidDatabase)
+	}
+	metaStart := uint(markerAt + len(metadataMarker))
+
+	meta := &mmdbDecoder{buf: buf[metaStart:]}
+	value, _, err := meta.decode(0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidDatabase, err)
+	}
+	metadata, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: metadata is not a map", errInvalidDatabase)
+	}
+
+	r := &mmdbReader{
+		buf:        buf,
+		nodeCount:  uint(toUint64(metadata["node_count"])),
+		recordSize: uint(toUint64(metadata["record_size"])),
+		ipVersion:  uint(toUint64(metadata["ip_version"])),
+		buildEpoch: toUint64(metadata["build_epoch"]),
+	}
+	r.databaseType, _ = metadata["database_type"].(string)
+
+	switch r.recordSize {
+	case 24, 28, 32:
+	default:
+		return nil, fmt.Errorf("%w: unsupported record size %d", errInvalidDatabase, r.recordSize)
+	}
+
+	treeSize := r.nodeCount * r.recordSize / 4
+	r.dataStart = treeSize + dataSectionSeparator
+	if r.dataStart > metaStart {
+		return nil, fmt.Errorf("%w: search tree is larger than the file", errInvalidDatabase)
+	}
+
+	// IPv4 addresses live under ::/96 in IPv6 databases
+	if r.ipVersion == 6 {
+		node := uint(0)
+		for i := 0; i < 96 && node < r.nodeCount; i++ {
+			node = r.readRecord(node, 0)
+		}
+		r.ipv4Start = node
+	}
+
+	return r, nil
+}
+
+// lookup returns the decoded record for ip, or nil when the address isn't in the database
+func (r *mmdbReader) lookup(ip net.IP) (map[string]interface{}, error) {
+	var bits []byte
+	node := uint(0)
+
+	if ip4 := ip.To4(); ip4 != nil {
+		bits = ip4
+		if r.ipVersion == 6 {
+			node = r.ipv4Start
+		}
+	} else if r.ipVersion == 6 {
+		bits = ip.To16()
+	} else {
+		return nil, nil
+	}
+
+	for i := 0; i < len(bits)*8 && node < r.nodeCount; i++ {
+		bit := (bits[i/8] >> (7 - uint(i%8))) & 1
+		node = r.readRecord(node, bit)
+	}
+
+	if node == r.nodeCount {
+		return nil, nil
+	}
+	if node < r.nodeCount {
+		return nil, fmt.Errorf("%w: search tree too deep", errInvalidDatabase)
+	}
+
+	offset := node - r.nodeCount - dataSectionSeparator
+	data := &mmdbDecoder{buf: r.buf[r.dataStart:]}
+	value, _, err := data.decode(offset, 0)
+	if err != nil {
+		return nil, err
+	}
+	record, _ := value.(map[string]interface{})
+	return record, nil
+}
+
+func (r *mmdbReader) readRecord(node uint, bit byte) uint {
+	b := r.buf
+	switch r.recordSize {
+	case 24:
+		off := node*6 + uint(bit)*3
+		return uint(b[off])<<16 | uint(b[off+1])<<8 | uint(b[off+2])
+	case 28:
+		off := node * 7
+		if bit == 0 {
+			return (uint(b[off+3])&0xF0)<<20 | uint(b[off])<<16 | uint(b[off+1])<<8 | uint(b[off+2])
+		}
+		return (uint(b[off+3])&0x0F)<<24 | uint(b[off+4])<<16 | uint(b[off+5])<<8 | uint(b[off+6])
+	default:
+		off := node*8 + uint(bit)*4
+		return uint(binary.BigEndian.Uint32(b[off : off+4]))
+	}
+}
+
+// mmdbDecoder decodes values from a data section; pointers are relative to buf
+type mmdbDecoder struct {
+	buf []byte
+}
+
+// maxDecodeDepth guards against pointer loops in corrupt files
+const maxDecodeDepth = 32
+
+func (d *mmdbDecoder) decode(offset uint, depth int) (interface{}, uint, error) {
+	if depth > maxDecodeDepth {
+		return nil, 0, errors.New("data section nested too deeply")
+	}
+	if offset >= uint(len(d.buf)) {
+		return nil, 0, errors.New("offset outside of data section")
+	}
+
+	ctrl := d.buf[offset]
+	offset++
+	typeNum := uint(ctrl >> 5)
+
+	if typeNum == mmdbPointer {
+		pointer, next, err := d.decodePointer(ctrl, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		value, _, err := d.decode(pointer, depth+1)
+		return value, next, err
+	}
+
+	if typeNum == mmdbExtended {
+		if offset >= uint(len(d.buf)) {
+			return nil, 0, errors.New("truncated extended type")
+		}
+		typeNum = 7 + uint(d.buf[offset])
+		offset++
+	}
+
+	size, offset, err := d.decodeSize(ctrl, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	switch typeNum {
+	case mmdbMap:
+		m := make(map[string]interface{}, size)
+		for i := uint(0); i < size; i++ {
+			key, next, err := d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			keyStr, ok := key.(string)
+			if !ok {
+				return nil, 0, errors.New("map key is not a string")
+			}
+			value, next, err := d.decode(next, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			m[keyStr] = value
+			offset = next
+		}
+		return m, offset, nil
+	case mmdbArray:
+		a := make([]interface{}, 0, size)
+		for i := uint(0); i < size; i++ {
+			value, next, err := d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			a = append(a, value)
+			offset = next
+		}
+		return a, offset, nil
+	case mmdbBool:
+		return size != 0, offset, nil
+	case mmdbContainer, mmdbEndMarker:
+		return nil, offset, nil
+	}
+
+	end := offset + size
+	if end > uint(len(d.buf)) {
+		return nil, 0, errors.New("value extends past data section")
+	}
+	raw := d.buf[offset:end]
+
+	switch typeNum {
+	case mmdbString:
+		return string(raw), end, nil
+	case mmdbBytes:
+		return append([]byte(nil), raw...), end, nil
+	case mmdbDouble:
+		if size != 8 {
+			return nil, 0, errors.New("invalid double size")
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(raw)), end, nil
+	case mmdbFloat:
+		if size != 4 {
+			return nil, 0, errors.New("invalid float size")
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(raw))), end, nil
+	case mmdbUint16, mmdbUint32, mmdbUint64:
+		return readUint(raw), end, nil
+	case mmdbInt32:
+		return int64(int32(uint32(readUint(raw)))), end, nil
+	case mmdbUint128:
+		// Only used for very large values we don't need, keep the low 64 bits
+		if len(raw) > 8 {
+			raw = raw[len(raw)-8:]
+		}
+		return readUint(raw), end, nil
+	}
+
+	return nil, 0, fmt.Errorf("unknown data type %d", typeNum)
+}
+
+func (d *mmdbDecoder) decodePointer(ctrl byte, offset uint) (uint, uint, error) {
+	ss := uint(ctrl>>3) & 0x3
+	vvv := uint(ctrl) & 0x7
+	n := ss + 1
+	if offset+n > uint(len(d.buf)) {
+		return 0, 0, errors.New("truncated pointer")
+	}
+	b := d.buf[offset : offset+n]
+
+	var pointer uint
+	switch ss {
+	case 0:
+		pointer = vvv<<8 | uint(b[0])
+	case 1:
+		pointer = (vvv<<16 | uint(b[0])<<8 | uint(b[1])) + 2048
+	case 2:
+		pointer = (vvv<<24 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])) + 526336
+	default:
+		pointer = uint(binary.BigEndian.Uint32(b))
+	}
+	return pointer, offset + n, nil
+}
+
+func (d *mmdbDecoder) decodeSize(ctrl byte, offset uint) (uint, uint, error) {
+	size := uint(ctrl) & 0x1f
+	if size < 29 {
+		return size, offset, nil
+	}
+
+	n := size - 28
+	if offset+n > uint(len(d.buf)) {
+		return 0, 0, errors.New("truncated size")
+	}
+	b := d.buf[offset : offset+n]
+
+	switch size {
+	case 29:
+		size = 29 + uint(b[0])
+	case 30:
+		size = 285 + (uint(b[0])<<8 | uint(b[1]))
+	default:
+		size = 65821 + (uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2]))
+	}
+	return size, offset + n, nil
+}
+
+func readUint(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
+
+func toUint64(v interface{}) uint64 {
+	switch n := v.(type) {
+	case uint64:
+		return n
+	case int64:
+		return uint64(n)
+	case float64:
+		return uint64(n)
+	}
+	return 0
+}
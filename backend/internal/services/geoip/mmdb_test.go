@@ -0,0 +1,322 @@
+package geoip
+
+import (
+	"encoding/binary"
+	"math"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// mmdb value encoders for building fixture databases
+
+func encString(s string) []byte {
+	return append(encHeader(mmdbString, len(s)), s...)
+}
+
+func encMap(pairs ...[]byte) []byte {
+	out := encHeader(mmdbMap, len(pairs)/2)
+	for _, p := range pairs {
+		out = append(out, p...)
+	}
+	return out
+}
+
+func encArray(items ...[]byte) []byte {
+	out := encHeader(mmdbArray, len(items))
+	for _, item := range items {
+		out = append(out, item...)
+	}
+	return out
+}
+
+func encUint(typeNum int, v uint64) []byte {
+	var raw []byte
+	for ; v > 0; v >>= 8 {
+		raw = append([]byte{byte(v)}, raw...)
+	}
+	return append(encHeader(typeNum, len(raw)), raw...)
+}
+
+func encDouble(f float64) []byte {
+	raw := make([]byte, 8)
+	binary.BigEndian.PutUint64(raw, math.Float64bits(f))
+	return append(encHeader(mmdbDouble, 8), raw...)
+}
+
+func encBool(b bool) []byte {
+	if b {
+		return encHeader(mmdbBool, 1)
+	}
+	return encHeader(mmdbBool, 0)
+}
+
+// encPointer encodes a pointer with the smallest size that fits
+func encPointer(p uint) []byte {
+	switch {
+	case p < 2048:
+		return []byte{0x20 | byte(p>>8), byte(p)}
+	case p < 526336:
+		p -= 2048
+		return []byte{0x28 | byte(p>>16), byte(p >> 8), byte(p)}
+	default:
+		return []byte{0x38, byte(p >> 24), byte(p >> 16), byte(p >> 8), byte(p)}
+	}
+}
+
+func encHeader(typeNum, size int) []byte {
+	var ctrl byte
+	var ext []byte
+	if typeNum > 7 {
+		ext = []byte{byte(typeNum - 7)}
+	} else {
+		ctrl = byte(typeNum) << 5
+	}
+	var extra []byte
+	switch {
+	case size < 29:
+		ctrl |= byte(size)
+	case size < 285:
+		ctrl |= 29
+		extra = []byte{byte(size - 29)}
+	case size < 65821:
+		ctrl |= 30
+		extra = []byte{byte((size - 285) >> 8), byte(size - 285)}
+	default:
+		ctrl |= 31
+		s := size - 65821
+		extra = []byte{byte(s >> 16), byte(s >> 8), byte(s)}
+	}
+	out := append([]byte{ctrl}, ext...)
+	return append(out, extra...)
+}
+
+// fixtureNetwork maps a prefix onto the offset of its record in the data section
+type fixtureNetwork struct {
+	cidr   string
+	offset uint
+}
+
+// buildMMDB builds an IPv6 database with the given record size
+func buildMMDB(t *testing.T, recordSize uint, data []byte, networks []fixtureNetwork) []byte {
+	t.Helper()
+
+	// Records are node indexes, emptyRecord, or -(offset+1) for data
+	const emptyRecord = -1 << 40
+	nodes := [][2]int64{{emptyRecord, emptyRecord}}
+	for _, n := range networks {
+		_, ipnet, err := net.ParseCIDR(n.cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ip := ipnet.IP.To16()
+		ones, _ := ipnet.Mask.Size()
+		if ipnet.IP.To4() != nil {
+			ones += 96
+			ip = append(make([]byte, 12), ipnet.IP.To4()...)
+		}
+		node := 0
+		for i := 0; i < ones; i++ {
+			bit := (ip[i/8] >> (7 - uint(i%8))) & 1
+			if i == ones-1 {
+				nodes[node][bit] = -int64(n.offset) - 1
+				break
+			}
+			if nodes[node][bit] <= 0 {
+				nodes = append(nodes, [2]int64{emptyRecord, emptyRecord})
+				nodes[node][bit] = int64(len(nodes) - 1)
+			}
+			node = int(nodes[node][bit])
+		}
+	}
+
+	nodeCount := uint(len(nodes))
+	value := func(record int64) uint {
+		switch {
+		case record == emptyRecord:
+			return nodeCount
+		case record < 0:
+			return nodeCount + dataSectionSeparator + uint(-record-1)
+		}
+		return uint(record)
+	}
+
+	var out []byte
+	for _, node := range nodes {
+		left, right := value(node[0]), value(node[1])
+		switch recordSize {
+		case 24:
+			out = append(out, byte(left>>16), byte(left>>8), byte(left), byte(right>>16), byte(right>>8), byte(right))
+		case 28:
+			out = append(out, byte(left>>16), byte(left>>8), byte(left), byte(left>>24)<<4|byte(right>>24)&0x0F,
+				byte(right>>16), byte(right>>8), byte(right))
+		default:
+			out = binary.BigEndian.AppendUint32(out, uint32(left))
+			out = binary.BigEndian.AppendUint32(out, uint32(right))
+		}
+	}
+	out = append(out, make([]byte, dataSectionSeparator)...)
+	out = append(out, data...)
+	out = append(out, metadataMarker...)
+	out = append(out, encMap(
+		encString("node_count"), encUint(mmdbUint32, uint64(nodeCount)),
+		encString("record_size"), encUint(mmdbUint16, uint64(recordSize)),
+		encString("ip_version"), encUint(mmdbUint16, 6),
+		encString("database_type"), encString("Test-City"),
+		encString("build_epoch"), encUint(mmdbUint64, 1700000000),
+	)...)
+	return out
+}
+
+// fixtureData returns a data section with two records; the second reuses the
+// country key and value of the first through pointers
+func fixtureData() ([]byte, uint, uint) {
+	country := encMap(
+		encString("iso_code"), encString("NL"),
+		encString("names"), encMap(encString("en"), encString("Netherlands")),
+	)
+	first := encMap(
+		encString("country"), country,
+		encString("autonomous_system_number"), encUint(mmdbUint32, 64500),
+		encString("location"), encMap(encString("latitude"), encDouble(52.25)),
+		encString("anycast"), encBool(true),
+		encString("tags"), encArray(encString("a"), encString("b")),
+	)
+	countryKeyAt := uint(1) // Right after the map header
+	countryValueAt := countryKeyAt + uint(len(encString("country")))
+
+	secondAt := uint(len(first))
+	second := encMap(
+		encPointer(countryKeyAt), encPointer(countryValueAt),
+		encString("count"), encUint(mmdbUint64, 42),
+		encString("metro"), encUint(mmdbUint16, 501),
+	)
+	return append(first, second...), 0, secondAt
+}
+
+func TestMMDBLookup(t *testing.T) {
+	data, firstAt, secondAt := fixtureData()
+	wantCountry := map[string]interface{}{
+		"iso_code": "NL",
+		"names":    map[string]interface{}{"en": "Netherlands"},
+	}
+	wantFirst := map[string]interface{}{
+		"country":                  wantCountry,
+		"autonomous_system_number": uint64(64500),
+		"location":                 map[string]interface{}{"latitude": 52.25},
+		"anycast":                  true,
+		"tags":                     []interface{}{"a", "b"},
+	}
+	wantSecond := map[string]interface{}{
+		"country": wantCountry,
+		"count":   uint64(42),
+		"metro":   uint64(501),
+	}
+
+	for _, recordSize := range []uint{24, 28, 32} {
+		db := buildMMDB(t, recordSize, data, []fixtureNetwork{
+			{"1.2.3.0/24", firstAt},
+			{"2001:db8::/32", secondAt},
+		})
+		r, err := newMMDBReader(db)
+		if err != nil {
+			t.Fatalf("record size %d: newMMDBReader() error = %v", recordSize, err)
+		}
+		if r.databaseType != "Test-City" || r.buildEpoch != 1700000000 || r.ipVersion != 6 {
+			t.Errorf("record size %d: metadata = %q %d %d", recordSize, r.databaseType, r.buildEpoch, r.ipVersion)
+		}
+
+		tests := []struct {
+			ip   string
+			want map[string]interface{}
+		}{
+			{"1.2.3.4", wantFirst},
+			{"::ffff:1.2.3.200", wantFirst}, // IPv4-mapped
+			{"1.2.4.1", nil},
+			{"2001:db8:1::1", wantSecond},
+			{"2001:db9::1", nil},
+		}
+		for _, tt := range tests {
+			got, err := r.lookup(net.ParseIP(tt.ip))
+			if err != nil {
+				t.Errorf("record size %d: lookup(%s) error = %v", recordSize, tt.ip, err)
+				continue
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("record size %d: lookup(%s) = %#v, want %#v", recordSize, tt.ip, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestMMDBDecodePointer(t *testing.T) {
+	tests := []struct {
+		name string
+		buf  []byte
+		want uint
+		next uint
+	}{
+		{"size 0", []byte{0x21, 0x02}, 0x102, 2},
+		{"size 1", []byte{0x28, 0x00, 0x01}, 2049, 3},
+		{"size 2", []byte{0x30, 0x00, 0x00, 0x01}, 526337, 4},
+		{"size 3", []byte{0x38, 0x00, 0x00, 0x00, 0x05}, 5, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &mmdbDecoder{buf: tt.buf}
+			got, next, err := d.decodePointer(tt.buf[0], 1)
+			if err != nil {
+				t.Fatalf("decodePointer() error = %v", err)
+			}
+			if got != tt.want || next != tt.next {
+				t.Errorf("decodePointer() = %d, %d, want %d, %d", got, next, tt.want, tt.next)
+			}
+		})
+	}
+}
+
+func TestMMDBDecodeLongValues(t *testing.T) {
+	for _, size := range []int{28, 29, 284, 285, 65820, 65821, 70000} {
+		s := strings.Repeat("x", size)
+		d := &mmdbDecoder{buf: encString(s)}
+		got, end, err := d.decode(0, 0)
+		if err != nil {
+			t.Fatalf("size %d: decode() error = %v", size, err)
+		}
+		if got != s || end != uint(len(d.buf)) {
+			t.Errorf("size %d: decode() returned %d bytes ending at %d of %d", size, len(got.(string)), end, len(d.buf))
+		}
+	}
+}
+
+func TestMMDBInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		buf  []byte
+	}{
+		{"no metadata", []byte("not a database")},
+		{"metadata not a map", append(append([]byte{}, metadataMarker...), encString("x")...)},
+		{"unsupported record size", append(append([]byte{}, metadataMarker...), encMap(
+			encString("node_count"), encUint(mmdbUint32, 1),
+			encString("record_size"), encUint(mmdbUint16, 20),
+		)...)},
+		{"tree larger than file", append(append([]byte{}, metadataMarker...), encMap(
+			encString("node_count"), encUint(mmdbUint32, 1000),
+			encString("record_size"), encUint(mmdbUint16, 24),
+		)...)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newMMDBReader(tt.buf); err == nil {
+				t.Error("newMMDBReader() error = nil, want an error")
+			}
+		})
+	}
+
+	// A pointer to itself must not recurse forever
+	d := &mmdbDecoder{buf: []byte{0x20, 0x00}}
+	if _, _, err := d.decode(0, 0); err == nil {
+		t.Error("decode() of a pointer loop error = nil, want an error")
+	}
+}
@@ -0,0 +1,187 @@
+package logstream
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/api/handlers"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/hooks"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/rpc"
+)
+
+// reconnectDelay is how long to wait before re-subscribing after the stream drops
+const reconnectDelay = 15 * time.Second
+
+// maxConsecutiveErrors is how many read errors in a row we tolerate before reconnecting
+const maxConsecutiveErrors = 10
+
+var (
+	instance *Service
+	once     sync.Once
+)
+
+// Service keeps a log subscription open on the active RPC server and
+// dispatches every log entry to the HookLogEvent hook
+type Service struct {
+	mu       sync.Mutex
+	client   *rpc.Client
+	stopChan chan struct{}
+}
+
+// GetService returns the singleton log stream service
+func GetService() *Service {
+	once.Do(func() {
+		instance = &Service{
+			stopChan: make(chan struct{}),
+		}
+	})
+	return instance
+}
+
+// Initialize registers the built-in log event consumers and starts the stream
+func Initialize() *Service {
+	service := GetService()
+
+	// Ban ledger: record TKL additions, removals and expiries made on IRC
+	hooks.RegisterWithPriority(hooks.HookLogEvent, "ban_ledger", func(args interface{}) interface{} {
+		if entry, ok := args.(map[string]interface{}); ok {
+			handlers.RecordBanLogEvent(entry)
+		}
+		return args
+	}, 100)
+
+	// Reputation: check connecting clients against blocklists, auto-gline if configured
+	hooks.RegisterWithPriority(hooks.HookLogEvent, "reputation", func(args interface{}) interface{} {
+		if entry, ok := args.(map[string]interface{}); ok {
+			go handlers.CheckConnectingClient(entry)
+		}
+		return args
+	}, 100)
+
+	// User journey: record connects, quits, nick changes, joins, parts, kicks, kills, bans and spamfilter hits
+	hooks.RegisterWithPriority(hooks.HookLogEvent, "user_journey", func(args interface{}) interface{} {
+		if entry, ok := args.(map[string]interface{}); ok {
+			handlers.IngestJourneyLogEvent(entry, handlers.JourneySourceLog)
+		}
+		return args
+	}, 100)
+
+	// Takeover detection: mass deops and kicks, topic vandalism, +i/+k on registered channels and clone floods
+	hooks.RegisterWithPriority(hooks.HookLogEvent, "takeover", func(args interface{}) interface{} {
+		if entry, ok := args.(map[string]interface{}); ok {
+			handlers.IngestTakeoverLogEvent(entry)
+		}
+		return args
+	}, 100)
+
+	// Server links: track pending, completed and failed link attempts for the topology view
+	hooks.RegisterWithPriority(hooks.HookLogEvent, "server_links", func(args interface{}) interface{} {
+		if entry, ok := args.(map[string]interface{}); ok {
+			handlers.IngestServerLinkLogEvent(entry)
+		}
+		return args
+	}, 100)
+	hooks.RegisterWithPriority(hooks.HookLogEvent, "rehash", func(args interface{}) interface{} {
+		if entry, ok := args.(map[string]interface{}); ok {
+			handlers.IngestRehashLogEvent(entry)
+		}
+		return args
+	}, 100)
+	hooks.RegisterWithPriority(hooks.HookLogEvent, "topology", func(args interface{}) interface{} {
+		if entry, ok := args.(map[string]interface{}); ok {
+			handlers.IngestTopologyLogEvent(entry)
+		}
+		return args
+	}, 100)
+
+	go service.run()
+	return service
+}
+
+// Stop closes the subscription and stops reconnecting
+func (s *Service) Stop() {
+	log.Println("Stopping log stream service...")
+	close(s.stopChan)
+
+	s.mu.Lock()
+	if s.client != nil {
+		s.client.Close()
+	}
+	s.mu.Unlock()
+}
+
+// run subscribes to the log stream and re-subscribes whenever the connection drops
+func (s *Service) run() {
+	for {
+		if err := s.stream(); err != nil {
+			log.Printf("[LogStream] %v, retrying in %s", err, reconnectDelay)
+		}
+
+		select {
+		case <-s.stopChan:
+			return
+		case <-time.After(reconnectDelay):
+		}
+	}
+}
+
+// stream holds one subscription until it fails
+func (s *Service) stream() error {
+	client, err := rpc.GetManager().NewDedicatedClient()
+	if err != nil {
+		return err
+	}
+
+	if _, err := client.Log().Subscribe([]string{"all", "!debug"}); err != nil {
+		client.Close()
+		return err
+	}
+
+	s.mu.Lock()
+	s.client = client
+	s.mu.Unlock()
+
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("[LogStream] PANIC recovered: %v", r)
+		}
+		s.mu.Lock()
+		s.client = nil
+		s.mu.Unlock()
+		client.Close()
+	}()
+
+	log.Printf("[LogStream] Subscribed to log events on %s", client.ServerName())
+
+	consecutiveErrors := 0
+	for {
+		select {
+		case <-s.stopChan:
+			return nil
+		default:
+		}
+
+		event, err := client.EventLoop()
+		if err != nil {
+			errStr := err.Error()
+			consecutiveErrors++
+			if errStr == "websocket: close sent" || errStr == "EOF" || consecutiveErrors >= maxConsecutiveErrors {
+				return err
+			}
+			continue
+		}
+		consecutiveErrors = 0
+
+		// Skip responses to subscribe/unsubscribe, only dispatch log entries
+		entry, ok := event.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if _, hasMsg := entry["msg"]; !hasMsg {
+			continue
+		}
+
+		hooks.RunAll(hooks.HookLogEvent, entry)
+	}
+}
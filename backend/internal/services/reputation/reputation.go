@@ -0,0 +1,575 @@
+package reputation
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/config"
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/utils"
+)
+
+// Confidence levels for lists
+const (
+	ConfidenceLow    = "low"
+	ConfidenceMedium = "medium"
+	ConfidenceHigh   = "high"
+)
+
+// Listing sources
+const (
+	SourceDNSBL = "dnsbl"
+	SourceLocal = "local"
+)
+
+const (
+	defaultTimeout        = 2 * time.Second
+	defaultCacheTTL       = time.Hour
+	defaultReloadInterval = 60 * time.Second
+	maxConcurrentChecks   = 16
+)
+
+// defaultAutoGlineDuration is used when auto_gline has no duration
+const defaultAutoGlineDuration = "1d"
+
+// Listing is a hit on one list
+type Listing struct {
+	List       string   `json:"list"`
+	Source     string   `json:"source"` // dnsbl or local
+	Zone       string   `json:"zone,omitempty"`
+	Codes      []string `json:"codes,omitempty"`   // DNSBL answers
+	Matched    string   `json:"matched,omitempty"` // Local list entry that matched
+	Confidence string   `json:"confidence"`
+	AutoGline  bool     `json:"auto_gline"`
+}
+
+// Result is the outcome of checking an IP against every list
+type Result struct {
+	IP        string    `json:"ip"`
+	Listed    bool      `json:"listed"`
+	Listings  []Listing `json:"listings"`
+	Unchecked []string  `json:"unchecked,omitempty"` // DNSBLs that did not answer, so their status is unknown
+	CheckedAt time.Time `json:"checked_at"`
+	Cached    bool      `json:"cached"`
+}
+
+// HighestConfidence returns the highest confidence of all listings, or "" when not listed
+func (r *Result) HighestConfidence() string {
+	best := ""
+	for _, l := range r.Listings {
+		if confidenceRank(l.Confidence) > confidenceRank(best) {
+			best = l.Confidence
+		}
+	}
+	return best
+}
+
+// AutoGlineListing returns the first listing that should trigger an automatic G-Line
+func (r *Result) AutoGlineListing() *Listing {
+	for i := range r.Listings {
+		if r.Listings[i].AutoGline {
+			return &r.Listings[i]
+		}
+	}
+	return nil
+}
+
+// ListStatus describes a configured list
+type ListStatus struct {
+	Name       string    `json:"name"`
+	Source     string    `json:"source"`
+	Zone       string    `json:"zone,omitempty"`
+	Path       string    `json:"path,omitempty"`
+	Entries    int       `json:"entries,omitempty"`
+	LoadedAt   time.Time `json:"loaded_at,omitempty"`
+	Confidence string    `json:"confidence"`
+	AutoGline  bool      `json:"auto_gline"`
+	Error      string    `json:"error,omitempty"`
+}
+
+type localList struct {
+	cfg      config.LocalListConfig
+	networks []*net.IPNet
+	modTime  time.Time
+	loadedAt time.Time
+	err      string
+}
+
+type cacheEntry struct {
+	result  Result
+	expires time.Time
+}
+
+// hostResolver looks up DNSBL queries; *net.Resolver satisfies it and tests swap in a fake
+type hostResolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// Service checks IPs against DNS blocklists and local lists
+type Service struct {
+	mu       sync.RWMutex
+	cfg      config.ReputationConfig
+	resolver hostResolver
+	timeout  time.Duration
+	ttl      time.Duration
+	interval time.Duration
+	local    []*localList
+	cache    map[string]cacheEntry
+	stopChan chan struct{}
+}
+
+var (
+	instance *Service
+	once     sync.Once
+)
+
+// GetService returns the singleton reputation service
+func GetService() *Service {
+	once.Do(func() {
+		cfg := config.Get().Reputation
+
+		instance = &Service{
+			cfg:      cfg,
+			resolver: newResolver(cfg.Resolver),
+			timeout:  defaultTimeout,
+			ttl:      defaultCacheTTL,
+			interval: defaultReloadInterval,
+			cache:    make(map[string]cacheEntry),
+			stopChan: make(chan struct{}),
+		}
+		if cfg.TimeoutMs > 0 {
+			instance.timeout = time.Duration(cfg.TimeoutMs) * time.Millisecond
+		}
+		if cfg.CacheTTL > 0 {
+			instance.ttl = time.Duration(cfg.CacheTTL) * time.Second
+		}
+		if cfg.ReloadInterval > 0 {
+			instance.interval = time.Duration(cfg.ReloadInterval) * time.Second
+		}
+
+		if gline := &instance.cfg.AutoGline; gline.Enabled {
+			gline.Duration = strings.TrimSpace(gline.Duration)
+			if gline.Duration == "" {
+				log.Printf("[Reputation] auto_gline has no duration, using %s", defaultAutoGlineDuration)
+				gline.Duration = defaultAutoGlineDuration
+			} else if !utils.ValidBanDuration(gline.Duration) {
+				log.Printf("[Reputation] Disabling auto_gline, '%s' is not a valid duration", gline.Duration)
+				gline.Enabled = false
+			}
+		}
+
+		for i := range instance.cfg.DNSBLs {
+			list := &instance.cfg.DNSBLs[i]
+			list.Confidence = normalizeConfidence(list.Confidence)
+			if list.AutoGline && list.Confidence != ConfidenceHigh {
+				log.Printf("[Reputation] Ignoring auto_gline on %s, it is only allowed for high confidence lists", list.Name)
+				list.AutoGline = false
+			}
+		}
+		for _, listCfg := range cfg.LocalLists {
+			listCfg.Confidence = normalizeConfidence(listCfg.Confidence)
+			if listCfg.AutoGline && listCfg.Confidence != ConfidenceHigh {
+				log.Printf("[Reputation] Ignoring auto_gline on %s, it is only allowed for high confidence lists", listCfg.Name)
+				listCfg.AutoGline = false
+			}
+			instance.local = append(instance.local, &localList{cfg: listCfg})
+		}
+	})
+	return instance
+}
+
+// Initialize loads the local lists and starts re-reading them periodically
+func Initialize() *Service {
+	service := GetService()
+	service.reloadLocalLists()
+	go service.watch()
+	return service
+}
+
+// Stop stops the periodic re-read
+func (s *Service) Stop() {
+	close(s.stopChan)
+}
+
+// Enabled reports whether any list is configured
+func (s *Service) Enabled() bool {
+	return len(s.cfg.DNSBLs) > 0 || len(s.local) > 0
+}
+
+// AutoGline returns the automatic G-Line policy
+func (s *Service) AutoGline() config.AutoGlineConfig {
+	return s.cfg.AutoGline
+}
+
+// Check checks an IP against every list, using the cache when possible
+func (s *Service) Check(ip string) (*Result, error) {
+	parsed := net.ParseIP(strings.TrimSpace(ip))
+	if parsed == nil {
+		return nil, fmt.Errorf("invalid IP address: %s", ip)
+	}
+	key := parsed.String()
+
+	s.mu.RLock()
+	entry, ok := s.cache[key]
+	s.mu.RUnlock()
+	if ok && time.Now().Before(entry.expires) {
+		result := entry.result
+		result.Cached = true
+		return &result, nil
+	}
+
+	result := Result{IP: key, Listings: make([]Listing, 0), CheckedAt: time.Now()}
+	result.Listings = append(result.Listings, s.checkLocal(parsed)...)
+	listings, unchecked := s.checkDNSBLs(parsed)
+	result.Listings = append(result.Listings, listings...)
+	result.Unchecked = unchecked
+	result.Listed = len(result.Listings) > 0
+
+	// An incomplete result is not cached, so a DNS hiccup doesn't hide a listing for the whole TTL
+	if len(unchecked) == 0 {
+		s.mu.Lock()
+		s.cache[key] = cacheEntry{result: result, expires: time.Now().Add(s.ttl)}
+		s.pruneCacheLocked()
+		s.mu.Unlock()
+	}
+
+	return &result, nil
+}
+
+// CheckMany checks several IPs concurrently; invalid IPs are skipped
+func (s *Service) CheckMany(ips []string) map[string]*Result {
+	results := make(map[string]*Result, len(ips))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, maxConcurrentChecks)
+
+	for _, ip := range ips {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(ip string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if result, err := s.Check(ip); err == nil {
+				mu.Lock()
+				results[ip] = result
+				mu.Unlock()
+			}
+		}(ip)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// ClearCache forgets all cached results
+func (s *Service) ClearCache() {
+	s.mu.Lock()
+	s.cache = make(map[string]cacheEntry)
+	s.mu.Unlock()
+}
+
+// Status describes every configured list
+func (s *Service) Status() []ListStatus {
+	statuses := make([]ListStatus, 0, len(s.cfg.DNSBLs)+len(s.local))
+	for _, list := range s.cfg.DNSBLs {
+		statuses = append(statuses, ListStatus{
+			Name:       list.Name,
+			Source:     SourceDNSBL,
+			Zone:       list.Zone,
+			Confidence: list.Confidence,
+			AutoGline:  list.AutoGline,
+		})
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, list := range s.local {
+		statuses = append(statuses, ListStatus{
+			Name:       list.cfg.Name,
+			Source:     SourceLocal,
+			Path:       list.cfg.Path,
+			Entries:    len(list.networks),
+			LoadedAt:   list.loadedAt,
+			Confidence: list.cfg.Confidence,
+			AutoGline:  list.cfg.AutoGline,
+			Error:      list.err,
+		})
+	}
+	return statuses
+}
+
+func (s *Service) checkLocal(ip net.IP) []Listing {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	listings := make([]Listing, 0)
+	for _, list := range s.local {
+		for _, network := range list.networks {
+			if network.Contains(ip) {
+				listings = append(listings, Listing{
+					List:       list.cfg.Name,
+					Source:     SourceLocal,
+					Matched:    network.String(),
+					Confidence: list.cfg.Confidence,
+					AutoGline:  list.cfg.AutoGline,
+				})
+				break
+			}
+		}
+	}
+	return listings
+}
+
+// checkDNSBLs returns the DNSBL listings for an IP and the names of lists whose
+// lookup failed with anything other than NXDOMAIN
+func (s *Service) checkDNSBLs(ip net.IP) ([]Listing, []string) {
+	if len(s.cfg.DNSBLs) == 0 {
+		return nil, nil
+	}
+	reversed := reverseIP(ip)
+
+	listings := make([]Listing, 0)
+	var unchecked []string
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, list := range s.cfg.DNSBLs {
+		wg.Add(1)
+		go func(list config.DNSBLConfig) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+			defer cancel()
+
+			addrs, err := s.resolver.LookupHost(ctx, reversed+"."+strings.TrimSuffix(list.Zone, "."))
+			if err != nil {
+				// NXDOMAIN means not listed; timeouts and SERVFAIL leave the status unknown
+				var dnsErr *net.DNSError
+				if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+					return
+				}
+				mu.Lock()
+				unchecked = append(unchecked, list.Name)
+				mu.Unlock()
+				return
+			}
+
+			codes := listedCodes(addrs, list.Codes)
+			if len(codes) == 0 {
+				return
+			}
+
+			mu.Lock()
+			listings = append(listings, Listing{
+				List:       list.Name,
+				Source:     SourceDNSBL,
+				Zone:       list.Zone,
+				Codes:      codes,
+				Confidence: list.Confidence,
+				AutoGline:  list.AutoGline,
+			})
+			mu.Unlock()
+		}(list)
+	}
+	wg.Wait()
+	sort.Strings(unchecked)
+
+	return listings, unchecked
+}
+
+func (s *Service) watch() {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case <-ticker.C:
+			s.reloadLocalLists()
+		}
+	}
+}
+
+// reloadLocalLists re-reads local lists whose file changed and drops the cache if any did
+func (s *Service) reloadLocalLists() {
+	changed := false
+
+	for _, list := range s.local {
+		info, err := os.Stat(list.cfg.Path)
+		if err != nil {
+			s.mu.Lock()
+			if list.networks != nil {
+				changed = true
+			}
+			list.networks = nil
+			list.modTime = time.Time{}
+			list.err = err.Error()
+			s.mu.Unlock()
+			continue
+		}
+		if info.ModTime().Equal(list.modTime) {
+			continue
+		}
+
+		networks, err := readNetworkList(list.cfg.Path)
+		s.mu.Lock()
+		list.modTime = info.ModTime()
+		if err != nil {
+			log.Printf("[Reputation] Failed to read %s: %v", list.cfg.Path, err)
+			list.err = err.Error()
+		} else {
+			list.networks = networks
+			list.loadedAt = time.Now()
+			list.err = ""
+			changed = true
+			log.Printf("[Reputation] Loaded %d entries from %s", len(networks), list.cfg.Path)
+		}
+		s.mu.Unlock()
+	}
+
+	if changed {
+		s.ClearCache()
+	}
+}
+
+func (s *Service) pruneCacheLocked() {
+	if len(s.cache) < 10000 {
+		return
+	}
+	now := time.Now()
+	for key, entry := range s.cache {
+		if now.After(entry.expires) {
+			delete(s.cache, key)
+		}
+	}
+}
+
+// readNetworkList reads IPs and CIDR ranges, one per line. Anything after
+// whitespace, ';' or '#' is treated as a comment.
+func readNetworkList(path string) ([]*net.IPNet, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	networks := make([]*net.IPNet, 0)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexAny(line, "#;"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		entry := fields[0]
+
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				continue
+			}
+			if ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			networks = append(networks, network)
+		}
+	}
+
+	return networks, scanner.Err()
+}
+
+// reverseIP returns the DNSBL query label for an IP: reversed octets for
+// IPv4, reversed nibbles for IPv6
+func reverseIP(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return fmt.Sprintf("%d.%d.%d.%d", ip4[3], ip4[2], ip4[1], ip4[0])
+	}
+
+	const hexDigits = "0123456789abcdef"
+	ip16 := ip.To16()
+	labels := make([]string, 0, 32)
+	for i := len(ip16) - 1; i >= 0; i-- {
+		labels = append(labels, string(hexDigits[ip16[i]&0x0f]), string(hexDigits[ip16[i]>>4]))
+	}
+	return strings.Join(labels, ".")
+}
+
+// listedCodes returns the answers that mean "listed". Only 127.0.0.0/8 answers count and
+// 127.255.255.0/24 is excluded, since lists use those to signal query errors.
+func listedCodes(addrs, wanted []string) []string {
+	codes := make([]string, 0)
+	for _, addr := range addrs {
+		ip := net.ParseIP(addr).To4()
+		if ip == nil || ip[0] != 127 || (ip[1] == 255 && ip[2] == 255) {
+			continue
+		}
+		if len(wanted) > 0 {
+			found := false
+			for _, w := range wanted {
+				if w == addr {
+					found = true
+					break
+				}
+			}
+			if !found {
+				continue
+			}
+		}
+		codes = append(codes, addr)
+	}
+	return codes
+}
+
+func newResolver(address string) *net.Resolver {
+	if address == "" {
+		return net.DefaultResolver
+	}
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, "53")
+	}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, address)
+		},
+	}
+}
+
+func normalizeConfidence(confidence string) string {
+	switch strings.ToLower(confidence) {
+	case ConfidenceHigh:
+		return ConfidenceHigh
+	case ConfidenceLow:
+		return ConfidenceLow
+	default:
+		return ConfidenceMedium
+	}
+}
+
+func confidenceRank(confidence string) int {
+	switch confidence {
+	case ConfidenceLow:
+		return 1
+	case ConfidenceMedium:
+		return 2
+	case ConfidenceHigh:
+		return 3
+	}
+	return 0
+}
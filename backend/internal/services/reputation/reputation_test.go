@@ -0,0 +1,159 @@
+package reputation
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/config"
+)
+
+type fakeAnswer struct {
+	addrs []string
+	err   error
+}
+
+// fakeResolver answers from a map and returns NXDOMAIN for anything else
+type fakeResolver struct {
+	answers map[string]fakeAnswer
+	lookups int
+}
+
+func (f *fakeResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	f.lookups++
+	if answer, ok := f.answers[host]; ok {
+		return answer.addrs, answer.err
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+func newTestService(resolver hostResolver, lists ...config.DNSBLConfig) *Service {
+	return &Service{
+		cfg:      config.ReputationConfig{DNSBLs: lists},
+		resolver: resolver,
+		timeout:  time.Second,
+		ttl:      time.Hour,
+		cache:    make(map[string]cacheEntry),
+	}
+}
+
+func TestCheckDNSBLs(t *testing.T) {
+	list := config.DNSBLConfig{Name: "Test", Zone: "dnsbl.example.", Confidence: ConfidenceHigh, AutoGline: true}
+	filtered := list
+	filtered.Codes = []string{"127.0.0.3"}
+
+	tests := []struct {
+		name          string
+		list          config.DNSBLConfig
+		answer        *fakeAnswer
+		wantCodes     []string
+		wantUnchecked []string
+		wantCached    bool
+	}{
+		{
+			name:       "listed",
+			list:       list,
+			answer:     &fakeAnswer{addrs: []string{"127.0.0.2"}},
+			wantCodes:  []string{"127.0.0.2"},
+			wantCached: true,
+		},
+		{
+			name:       "not listed",
+			list:       list,
+			wantCached: true,
+		},
+		{
+			name:          "timeout",
+			list:          list,
+			answer:        &fakeAnswer{err: &net.DNSError{Err: "i/o timeout", IsTimeout: true}},
+			wantUnchecked: []string{"Test"},
+		},
+		{
+			name:          "servfail",
+			list:          list,
+			answer:        &fakeAnswer{err: errors.New("server misbehaving")},
+			wantUnchecked: []string{"Test"},
+		},
+		{
+			name:       "error code",
+			list:       list,
+			answer:     &fakeAnswer{addrs: []string{"127.255.255.254"}},
+			wantCached: true,
+		},
+		{
+			name:       "code filter match",
+			list:       filtered,
+			answer:     &fakeAnswer{addrs: []string{"127.0.0.2", "127.0.0.3"}},
+			wantCodes:  []string{"127.0.0.3"},
+			wantCached: true,
+		},
+		{
+			name:       "code filter miss",
+			list:       filtered,
+			answer:     &fakeAnswer{addrs: []string{"127.0.0.2"}},
+			wantCached: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver := &fakeResolver{answers: map[string]fakeAnswer{}}
+			if tt.answer != nil {
+				resolver.answers["4.3.2.1.dnsbl.example"] = *tt.answer
+			}
+			service := newTestService(resolver, tt.list)
+
+			result, err := service.Check("1.2.3.4")
+			if err != nil {
+				t.Fatalf("Check() error = %v", err)
+			}
+
+			var codes []string
+			for _, listing := range result.Listings {
+				codes = append(codes, listing.Codes...)
+				if !listing.AutoGline || listing.Source != SourceDNSBL {
+					t.Errorf("listing = %+v, want an auto_gline DNSBL listing", listing)
+				}
+			}
+			if !reflect.DeepEqual(codes, tt.wantCodes) {
+				t.Errorf("codes = %v, want %v", codes, tt.wantCodes)
+			}
+			if result.Listed != (len(tt.wantCodes) > 0) {
+				t.Errorf("Listed = %v, want %v", result.Listed, len(tt.wantCodes) > 0)
+			}
+			if !reflect.DeepEqual(result.Unchecked, tt.wantUnchecked) {
+				t.Errorf("Unchecked = %v, want %v", result.Unchecked, tt.wantUnchecked)
+			}
+
+			again, _ := service.Check("1.2.3.4")
+			if again.Cached != tt.wantCached {
+				t.Errorf("second Check() Cached = %v, want %v", again.Cached, tt.wantCached)
+			}
+			wantLookups := 2
+			if tt.wantCached {
+				wantLookups = 1
+			}
+			if resolver.lookups != wantLookups {
+				t.Errorf("lookups = %d, want %d", resolver.lookups, wantLookups)
+			}
+		})
+	}
+}
+
+func TestReverseIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want string
+	}{
+		{"1.2.3.4", "4.3.2.1"},
+		{"2001:db8::1", "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2"},
+	}
+	for _, tt := range tests {
+		if got := reverseIP(net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("reverseIP(%s) = %s, want %s", tt.ip, got, tt.want)
+		}
+	}
+}
@@ -1,14 +1,13 @@
 package scheduler
 
 import (
-	"encoding/json"
 	"log"
 	"sync"
 	"time"
 
+	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/api/handlers"
 	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/database"
 	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/database/models"
-	"github.com/ValwareIRC/unrealircd-webpanel-2/internal/rpc"
 	"github.com/robfig/cron/v3"
 )
 
@@ -56,6 +55,30 @@ func (s *Scheduler) start() {
 	// Start the digest email checker (runs every minute)
 	s.cron.AddFunc("0 * * * * *", s.checkDigestEmails)
 
+	// Start the ban ledger snapshot diff (runs every 5 minutes)
+	s.cron.AddFunc("0 */5 * * * *", handlers.DiffBanSnapshot)
+
+	// Start the channel template drift check (runs every 5 minutes)
+	s.cron.AddFunc("30 */5 * * * *", handlers.CheckChannelTemplateDrift)
+
+	// Start the channel member count and topic sampler (runs every 5 minutes)
+	s.cron.AddFunc("15 */5 * * * *", handlers.SampleChannelHistory)
+
+	// Start the channel takeover poller (runs every minute)
+	s.cron.AddFunc("40 * * * * *", handlers.SampleChannelTakeover)
+
+	// Start the channel history rollups (runs hourly)
+	s.cron.AddFunc("0 2 * * * *", handlers.RollupChannelHistory)
+
+	// Start the server module and version consistency check (runs every 10 minutes)
+	s.cron.AddFunc("45 */10 * * * *", handlers.CheckServerConsistency)
+
+	// Start the topology snapshot poller (runs every minute)
+	s.cron.AddFunc("20 * * * * *", handlers.RecordTopologySnapshot)
+
+	// Start the server user count, uptime and lag sampler (runs every minute)
+	s.cron.AddFunc("50 * * * * *", handlers.SampleServerMetrics)
+
 	// Start the cleanup job (runs daily at 3 AM)
 	s.cron.AddFunc("0 0 3 * * *", s.cleanupOldData)
 
@@ -177,42 +200,7 @@ func (s *Scheduler) executeCommand(cmdID uint) {
 
 	log.Printf("Executing scheduled command: %s (ID: %d)", cmd.Name, cmd.ID)
 
-	// Parse params
-	var params map[string]interface{}
-	if cmd.Params != "" {
-		json.Unmarshal([]byte(cmd.Params), &params)
-	}
-
-	// Execute the command via RPC
-	manager := rpc.GetManager()
-	var execErr error
-
-	switch cmd.Command {
-	case "kill":
-		_, execErr = manager.WithRetry(func(client *rpc.Client) (interface{}, error) {
-			return client.User().Kill(cmd.Target, getParamString(params, "reason"))
-		})
-	case "gline":
-		duration := getParamString(params, "duration")
-		reason := getParamString(params, "reason")
-		_, execErr = manager.WithRetry(func(client *rpc.Client) (interface{}, error) {
-			return client.ServerBan().Add(cmd.Target, "gline", duration, reason)
-		})
-	case "kline":
-		duration := getParamString(params, "duration")
-		reason := getParamString(params, "reason")
-		_, execErr = manager.WithRetry(func(client *rpc.Client) (interface{}, error) {
-			return client.ServerBan().Add(cmd.Target, "kline", duration, reason)
-		})
-	case "rehash":
-		_, execErr = manager.WithRetry(func(client *rpc.Client) (interface{}, error) {
-			return client.Query("server.rehash", map[string]interface{}{
-				"server": cmd.Target,
-			}, false)
-		})
-	default:
-		log.Printf("Unknown command type: %s", cmd.Command)
-	}
+	result, execErr := handlers.ExecuteScheduledCommand(&cmd)
 
 	// Update last run time and status
 	now := time.Now()
@@ -223,9 +211,9 @@ func (s *Scheduler) executeCommand(cmdID uint) {
 
 	if execErr != nil {
 		log.Printf("Error executing command %d: %v", cmdID, execErr)
-		updates["last_error"] = execErr.Error()
+		updates["last_result"] = "Error: " + execErr.Error()
 	} else {
-		updates["last_error"] = ""
+		updates["last_result"] = result
 		log.Printf("Successfully executed command: %s", cmd.Name)
 	}
 
@@ -327,7 +315,7 @@ func (s *Scheduler) cleanupOldData() {
 
 	// Clean up old user journey events (keep last 90 days)
 	ninetyDaysAgo := time.Now().AddDate(0, 0, -90)
-	db.Where("timestamp < ?", ninetyDaysAgo).Delete(&models.UserJourneyEvent{})
+	db.Where("created_at < ?", ninetyDaysAgo).Delete(&models.UserJourneyEvent{})
 
 	// Clean up old webhook logs (keep last 30 days)
 	thirtyDaysAgo := time.Now().AddDate(0, 0, -30)
@@ -337,22 +325,40 @@ func (s *Scheduler) cleanupOldData() {
 	oneYearAgo := time.Now().AddDate(-1, 0, 0)
 	db.Where("sent_at < ?", oneYearAgo).Delete(&models.DigestHistory{})
 
-	log.Println("Cleanup completed")
-}
+	// Clean up channel snapshots past their retention period
+	handlers.PruneChannelSnapshots()
 
-// Helper functions
-func getParamString(params map[string]interface{}, key string) string {
-	if params == nil {
-		return ""
-	}
-	if val, ok := params[key]; ok {
-		if str, ok := val.(string); ok {
-			return str
-		}
+	// Clean up expired channel drift exemptions
+	db.Where("expires_at IS NOT NULL AND expires_at < ?", time.Now()).Delete(&models.ChannelDriftExemption{})
+
+	// Clean up channel member counts and topic changes past their retention period
+	handlers.PruneChannelHistory()
+
+	// Clean up old takeover incidents (keep last 90 days)
+	db.Where("created_at < ?", ninetyDaysAgo).Delete(&models.TakeoverIncident{})
+
+	// Clean up old server link attempts (keep last 30 days)
+	db.Where("updated_at < ?", thirtyDaysAgo).Delete(&models.ServerLinkAttempt{})
+
+	// Clean up old rehash reports (keep last year)
+	db.Where("run_id IN (?)", db.Model(&models.RehashRun{}).Select("id").Where("created_at < ?", oneYearAgo)).Delete(&models.RehashServerResult{})
+	db.Where("created_at < ?", oneYearAgo).Delete(&models.RehashRun{})
+
+	// Clean up old topology history (keep last 180 days, and always the latest snapshot)
+	halfYearAgo := time.Now().AddDate(0, 0, -180)
+	db.Where("created_at < ?", halfYearAgo).Delete(&models.TopologyChange{})
+	var latestTopology models.TopologySnapshot
+	if db.Order("created_at DESC, id DESC").First(&latestTopology).Error == nil {
+		db.Where("created_at < ? AND id <> ?", halfYearAgo, latestTopology.ID).Delete(&models.TopologySnapshot{})
 	}
-	return ""
+
+	// Clean up server samples past their retention period
+	handlers.PruneServerMetrics()
+
+	log.Println("Cleanup completed")
 }
 
+// Helper functions
 func getDigestStartDate(frequency string, endDate time.Time) time.Time {
 	switch frequency {
 	case "daily":
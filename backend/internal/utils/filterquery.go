@@ -0,0 +1,281 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FilterCondition is a single `field:value` term of a filter expression. Op is one of
+// "", ">", "<", ">=", "<=" or "=". Terms without a field have Field "text".
+type FilterCondition struct {
+	Field string `json:"field"`
+	Op    string `json:"op,omitempty"`
+	Value string `json:"value"`
+}
+
+// FilterExpr is a parsed filter expression such as
+// `server:hub1 AND tls:false AND (ip:10.0.0.0/8 OR modes:+o) AND NOT country:NL`.
+// Terms next to each other without an operator are ANDed, and `-term` is NOT term.
+type FilterExpr struct {
+	op       string // and, or, not, cond
+	children []*FilterExpr
+	cond     FilterCondition
+}
+
+// FilterTextField is the field used for bare words
+const FilterTextField = "text"
+
+// ParseFilter parses a filter expression. An empty expression matches everything.
+func ParseFilter(input string) (*FilterExpr, error) {
+	tokens, err := tokenizeFilter(input)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return &FilterExpr{op: "and"}, nil
+	}
+
+	p := &filterParser{tokens: tokens}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected '%s'", p.tokens[p.pos].text)
+	}
+	return expr, nil
+}
+
+// Eval evaluates the expression, calling match for every condition it needs
+func (e *FilterExpr) Eval(match func(FilterCondition) bool) bool {
+	switch e.op {
+	case "and":
+		for _, child := range e.children {
+			if !child.Eval(match) {
+				return false
+			}
+		}
+		return true
+	case "or":
+		for _, child := range e.children {
+			if child.Eval(match) {
+				return true
+			}
+		}
+		return false
+	case "not":
+		return !e.children[0].Eval(match)
+	}
+	return match(e.cond)
+}
+
+// Conditions returns every condition in the expression, e.g. for validation
+func (e *FilterExpr) Conditions() []FilterCondition {
+	if e.op == "cond" {
+		return []FilterCondition{e.cond}
+	}
+	conds := make([]FilterCondition, 0)
+	for _, child := range e.children {
+		conds = append(conds, child.Conditions()...)
+	}
+	return conds
+}
+
+// WildcardMatch reports whether s matches pattern, where * matches any run of
+// characters and ? a single character. Matching is case-insensitive.
+func WildcardMatch(pattern, s string) bool {
+	p := []rune(strings.ToLower(pattern))
+	str := []rune(strings.ToLower(s))
+
+	pi, si := 0, 0
+	star, mark := -1, 0
+	for si < len(str) {
+		switch {
+		case pi < len(p) && (p[pi] == '?' || p[pi] == str[si]):
+			pi++
+			si++
+		case pi < len(p) && p[pi] == '*':
+			star = pi
+			mark = si
+			pi++
+		case star >= 0:
+			pi = star + 1
+			mark++
+			si = mark
+		default:
+			return false
+		}
+	}
+	for pi < len(p) && p[pi] == '*' {
+		pi++
+	}
+	return pi == len(p)
+}
+
+type filterToken struct {
+	text   string
+	quoted bool
+}
+
+func tokenizeFilter(input string) ([]filterToken, error) {
+	tokens := make([]filterToken, 0)
+	i := 0
+	for i < len(input) {
+		ch := input[i]
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r':
+			i++
+		case ch == '(' || ch == ')':
+			tokens = append(tokens, filterToken{text: string(ch)})
+			i++
+		default:
+			// A word, which may contain quoted sections such as realname:"John Smith"
+			var sb strings.Builder
+			quoted := false
+			for i < len(input) && !strings.ContainsRune(" \t\n\r()", rune(input[i])) {
+				if input[i] == '"' {
+					quoted = true
+					i++
+					for i < len(input) && input[i] != '"' {
+						if input[i] == '\\' && i+1 < len(input) {
+							i++
+						}
+						sb.WriteByte(input[i])
+						i++
+					}
+					if i >= len(input) {
+						return nil, fmt.Errorf("unterminated quote")
+					}
+					i++
+					continue
+				}
+				sb.WriteByte(input[i])
+				i++
+			}
+			tokens = append(tokens, filterToken{text: sb.String(), quoted: quoted})
+		}
+	}
+	return tokens, nil
+}
+
+type filterParser struct {
+	tokens []filterToken
+	pos    int
+}
+
+func (p *filterParser) peekKeyword(keyword string) bool {
+	return p.pos < len(p.tokens) && !p.tokens[p.pos].quoted && p.tokens[p.pos].text == keyword
+}
+
+func (p *filterParser) parseOr() (*FilterExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	children := []*FilterExpr{left}
+	for p.peekKeyword("OR") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, right)
+	}
+	if len(children) == 1 {
+		return left, nil
+	}
+	return &FilterExpr{op: "or", children: children}, nil
+}
+
+func (p *filterParser) parseAnd() (*FilterExpr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	children := []*FilterExpr{left}
+	for p.pos < len(p.tokens) && !p.peekKeyword("OR") && !p.peekKeyword(")") {
+		if p.peekKeyword("AND") {
+			p.pos++
+		}
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, right)
+	}
+	if len(children) == 1 {
+		return left, nil
+	}
+	return &FilterExpr{op: "and", children: children}, nil
+}
+
+func (p *filterParser) parseNot() (*FilterExpr, error) {
+	if p.peekKeyword("NOT") {
+		p.pos++
+		child, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &FilterExpr{op: "not", children: []*FilterExpr{child}}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *filterParser) parsePrimary() (*FilterExpr, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+
+	tok := p.tokens[p.pos]
+	if !tok.quoted && tok.text == "(" {
+		p.pos++
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.peekKeyword(")") {
+			return nil, fmt.Errorf("missing ')'")
+		}
+		p.pos++
+		return expr, nil
+	}
+	if !tok.quoted && (tok.text == ")" || tok.text == "AND" || tok.text == "OR") {
+		return nil, fmt.Errorf("unexpected '%s'", tok.text)
+	}
+	p.pos++
+
+	text := tok.text
+	negate := false
+	if strings.HasPrefix(text, "-") && len(text) > 1 {
+		negate = true
+		text = text[1:]
+	}
+
+	cond := FilterCondition{Field: FilterTextField, Value: text}
+	if colon := strings.Index(text, ":"); colon > 0 && isFilterFieldName(text[:colon]) {
+		cond.Field = strings.ToLower(text[:colon])
+		cond.Value = text[colon+1:]
+		for _, op := range []string{">=", "<=", ">", "<", "="} {
+			if strings.HasPrefix(cond.Value, op) {
+				cond.Op = op
+				cond.Value = cond.Value[len(op):]
+				break
+			}
+		}
+	}
+
+	expr := &FilterExpr{op: "cond", cond: cond}
+	if negate {
+		return &FilterExpr{op: "not", children: []*FilterExpr{expr}}, nil
+	}
+	return expr, nil
+}
+
+func isFilterFieldName(s string) bool {
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z') && !(r >= 'A' && r <= 'Z') && r != '_' {
+			return false
+		}
+	}
+	return true
+}
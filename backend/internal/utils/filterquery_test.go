@@ -0,0 +1,141 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+// formatFilter renders a parsed expression with explicit grouping for comparison
+func formatFilter(e *FilterExpr) string {
+	switch e.op {
+	case "and", "or":
+		parts := make([]string, 0, len(e.children))
+		for _, child := range e.children {
+			parts = append(parts, formatFilter(child))
+		}
+		return e.op + "(" + strings.Join(parts, " ") + ")"
+	case "not":
+		return "not(" + formatFilter(e.children[0]) + ")"
+	}
+	return e.cond.Field + ":" + e.cond.Op + e.cond.Value
+}
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"empty", "   ", "and()"},
+		{"single term", "server:hub1", "server:hub1"},
+		{"bare word", "guest", "text:guest"},
+		{"implicit and", "server:hub1 tls:false", "and(server:hub1 tls:false)"},
+		{"explicit and", "server:hub1 AND tls:false", "and(server:hub1 tls:false)"},
+		{"or binds looser than and", "a:1 AND b:2 OR c:3", "or(and(a:1 b:2) c:3)"},
+		{"parentheses", "a:1 AND (b:2 OR c:3)", "and(a:1 or(b:2 c:3))"},
+		{"not keyword", "NOT country:NL", "not(country:NL)"},
+		{"dash negation", "-modes:+o", "not(modes:+o)"},
+		{"double not", "NOT NOT a:1", "not(not(a:1))"},
+		{"comparison operators", "users:>=50 idle:<30m asn:=64500", "and(users:>=50 idle:<30m asn:=64500)"},
+		{"field name is lower-cased", "Server:Hub1", "server:Hub1"},
+		{"quoted value", `realname:"John Smith"`, "realname:John Smith"},
+		{"escaped quote", `realname:"say \"hi\""`, `realname:say "hi"`},
+		{"quoted keyword is a term", `"OR"`, "text:OR"},
+		{"colon in ip value", "ip:2001:db8::1", "ip:2001:db8::1"},
+		{"non-field prefix stays text", "a-b:c", "text:a-b:c"},
+		{"lone dash is text", "-", "text:-"},
+		{
+			"full example",
+			"server:hub1 AND tls:false AND (ip:10.0.0.0/8 OR modes:+o) AND NOT country:NL",
+			"and(server:hub1 tls:false or(ip:10.0.0.0/8 modes:+o) not(country:NL))",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := ParseFilter(tt.input)
+			if err != nil {
+				t.Fatalf("ParseFilter(%q) error = %v", tt.input, err)
+			}
+			if got := formatFilter(expr); got != tt.want {
+				t.Errorf("ParseFilter(%q) = %s, want %s", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr string
+	}{
+		{"unterminated quote", `realname:"John`, "unterminated quote"},
+		{"missing close paren", "(a:1 OR b:2", "missing ')'"},
+		{"stray close paren", "a:1 )", "unexpected ')'"},
+		{"leading or", "OR a:1", "unexpected 'OR'"},
+		{"trailing and", "a:1 AND", "unexpected end of expression"},
+		{"trailing not", "a:1 NOT", "unexpected end of expression"},
+		{"empty parentheses", "()", "unexpected ')'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseFilter(tt.input)
+			if err == nil {
+				t.Fatalf("ParseFilter(%q) error = nil, want %q", tt.input, tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseFilter(%q) error = %q, want %q", tt.input, err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFilterEval(t *testing.T) {
+	values := map[string]bool{"a:1": true, "b:2": false, "c:3": true}
+	match := func(cond FilterCondition) bool { return values[cond.Field+":"+cond.Value] }
+
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{"", true},
+		{"a:1", true},
+		{"a:1 b:2", false},
+		{"a:1 OR b:2", true},
+		{"b:2 OR (a:1 AND c:3)", true},
+		{"NOT b:2", true},
+		{"-a:1", false},
+	}
+	for _, tt := range tests {
+		expr, err := ParseFilter(tt.input)
+		if err != nil {
+			t.Fatalf("ParseFilter(%q) error = %v", tt.input, err)
+		}
+		if got := expr.Eval(match); got != tt.want {
+			t.Errorf("Eval(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestWildcardMatch(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"*", "", true},
+		{"hub*", "Hub1.example.net", true},
+		{"*.example.net", "leaf.example.net", true},
+		{"h?b1", "hub1", true},
+		{"h?b1", "hb1", false},
+		{"*a*b", "xaxxb", true},
+		{"*a*b", "xaxxbc", false},
+		{"abc", "abcd", false},
+	}
+	for _, tt := range tests {
+		if got := WildcardMatch(tt.pattern, tt.s); got != tt.want {
+			t.Errorf("WildcardMatch(%q, %q) = %v, want %v", tt.pattern, tt.s, got, tt.want)
+		}
+	}
+}
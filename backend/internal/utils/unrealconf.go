@@ -0,0 +1,191 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ConfEntry is a single item of an UnrealIRCd configuration file, e.g.
+// `ban user { mask "*@1.2.3.4"; reason "Abuse"; };` parses into an entry named
+// "ban" with value "user" and two child entries.
+type ConfEntry struct {
+	Name     string      `json:"name"`
+	Value    string      `json:"value,omitempty"`
+	Children []ConfEntry `json:"children,omitempty"`
+	Line     int         `json:"line"`
+}
+
+// Child returns the first child entry with the given name
+func (e *ConfEntry) Child(name string) *ConfEntry {
+	for i := range e.Children {
+		if strings.EqualFold(e.Children[i].Name, name) {
+			return &e.Children[i]
+		}
+	}
+	return nil
+}
+
+// ChildValue returns the value of the first child entry with the given name
+func (e *ConfEntry) ChildValue(name string) string {
+	if child := e.Child(name); child != nil {
+		return child.Value
+	}
+	return ""
+}
+
+type confToken struct {
+	text   string
+	quoted bool
+	line   int
+}
+
+// ParseUnrealConf parses UnrealIRCd configuration syntax into a tree of entries.
+// Comments (/* */, // and #) are skipped. Include directives are not followed.
+func ParseUnrealConf(content string) ([]ConfEntry, error) {
+	tokens, err := tokenizeUnrealConf(content)
+	if err != nil {
+		return nil, err
+	}
+
+	pos := 0
+	entries, err := parseConfEntries(tokens, &pos, false)
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// QuoteConfString quotes a value for use in an UnrealIRCd configuration file
+func QuoteConfString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
+
+func parseConfEntries(tokens []confToken, pos *int, inBlock bool) ([]ConfEntry, error) {
+	entries := make([]ConfEntry, 0)
+
+	for *pos < len(tokens) {
+		tok := tokens[*pos]
+
+		if !tok.quoted && tok.text == "}" {
+			if !inBlock {
+				return nil, fmt.Errorf("line %d: unexpected '}'", tok.line)
+			}
+			*pos++
+			return entries, nil
+		}
+		if !tok.quoted && tok.text == ";" {
+			// Stray semicolon, ignore
+			*pos++
+			continue
+		}
+		if !tok.quoted && tok.text == "{" {
+			return nil, fmt.Errorf("line %d: unexpected '{'", tok.line)
+		}
+
+		entry := ConfEntry{Name: tok.text, Line: tok.line}
+		*pos++
+
+		// Optional value
+		if *pos < len(tokens) {
+			next := tokens[*pos]
+			if next.quoted || (next.text != "{" && next.text != ";" && next.text != "}") {
+				entry.Value = next.text
+				*pos++
+			}
+		}
+
+		// Optional block
+		if *pos < len(tokens) && !tokens[*pos].quoted && tokens[*pos].text == "{" {
+			*pos++
+			children, err := parseConfEntries(tokens, pos, true)
+			if err != nil {
+				return nil, err
+			}
+			entry.Children = children
+		}
+
+		// Terminating semicolon
+		if *pos >= len(tokens) || tokens[*pos].quoted || tokens[*pos].text != ";" {
+			return nil, fmt.Errorf("line %d: missing ';' after '%s'", entry.Line, entry.Name)
+		}
+		*pos++
+
+		entries = append(entries, entry)
+	}
+
+	if inBlock {
+		return nil, fmt.Errorf("unexpected end of input, missing '}'")
+	}
+	return entries, nil
+}
+
+func tokenizeUnrealConf(content string) ([]confToken, error) {
+	tokens := make([]confToken, 0)
+	line := 1
+	i := 0
+
+	for i < len(content) {
+		ch := content[i]
+
+		switch {
+		case ch == '\n':
+			line++
+			i++
+		case ch == ' ' || ch == '\t' || ch == '\r':
+			i++
+		case ch == '#':
+			for i < len(content) && content[i] != '\n' {
+				i++
+			}
+		case ch == '/' && i+1 < len(content) && content[i+1] == '/':
+			for i < len(content) && content[i] != '\n' {
+				i++
+			}
+		case ch == '/' && i+1 < len(content) && content[i+1] == '*':
+			start := line
+			i += 2
+			for i < len(content) && !(content[i] == '*' && i+1 < len(content) && content[i+1] == '/') {
+				if content[i] == '\n' {
+					line++
+				}
+				i++
+			}
+			if i >= len(content) {
+				return nil, fmt.Errorf("line %d: unterminated comment", start)
+			}
+			i += 2
+		case ch == '{' || ch == '}' || ch == ';':
+			tokens = append(tokens, confToken{text: string(ch), line: line})
+			i++
+		case ch == '"':
+			start := line
+			var sb strings.Builder
+			i++
+			for i < len(content) && content[i] != '"' {
+				if content[i] == '\\' && i+1 < len(content) {
+					i++
+				}
+				if content[i] == '\n' {
+					line++
+				}
+				sb.WriteByte(content[i])
+				i++
+			}
+			if i >= len(content) {
+				return nil, fmt.Errorf("line %d: unterminated string", start)
+			}
+			i++
+			tokens = append(tokens, confToken{text: sb.String(), quoted: true, line: start})
+		default:
+			start := i
+			for i < len(content) && !strings.ContainsRune(" \t\r\n{};\"", rune(content[i])) {
+				i++
+			}
+			tokens = append(tokens, confToken{text: content[start:i], line: line})
+		}
+	}
+
+	return tokens, nil
+}
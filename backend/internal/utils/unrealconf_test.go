@@ -0,0 +1,116 @@
+package utils
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseUnrealConf(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []ConfEntry
+	}{
+		{
+			name:    "quoted strings with escapes",
+			content: `ban user { mask "*@1.2.3.4"; reason "Say \"hi\" \\ bye"; };`,
+			want: []ConfEntry{{Name: "ban", Value: "user", Line: 1, Children: []ConfEntry{
+				{Name: "mask", Value: "*@1.2.3.4", Line: 1},
+				{Name: "reason", Value: `Say "hi" \ bye`, Line: 1},
+			}}},
+		},
+		{
+			name: "comments are skipped",
+			content: `# hash comment
+// line comment
+/* block
+   comment */ ban nick { mask "Serv*"; }; // trailing`,
+			want: []ConfEntry{{Name: "ban", Value: "nick", Line: 4, Children: []ConfEntry{
+				{Name: "mask", Value: "Serv*", Line: 4},
+			}}},
+		},
+		{
+			name: "nested blocks and entries without a value",
+			content: `spamfilter {
+	match-type simple;
+	match "*free money*";
+	target { private; channel; };
+	action block;
+};`,
+			want: []ConfEntry{{Name: "spamfilter", Line: 1, Children: []ConfEntry{
+				{Name: "match-type", Value: "simple", Line: 2},
+				{Name: "match", Value: "*free money*", Line: 3},
+				{Name: "target", Line: 4, Children: []ConfEntry{
+					{Name: "private", Line: 4},
+					{Name: "channel", Line: 4},
+				}},
+				{Name: "action", Value: "block", Line: 5},
+			}}},
+		},
+		{
+			name:    "stray semicolons",
+			content: `;; except ban { mask "*@10.0.0.1"; type { gline; }; };;`,
+			want: []ConfEntry{{Name: "except", Value: "ban", Line: 1, Children: []ConfEntry{
+				{Name: "mask", Value: "*@10.0.0.1", Line: 1},
+				{Name: "type", Line: 1, Children: []ConfEntry{{Name: "gline", Line: 1}}},
+			}}},
+		},
+		{
+			name:    "empty input",
+			content: "  \n/* nothing */\n",
+			want:    []ConfEntry{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseUnrealConf(tt.content)
+			if err != nil {
+				t.Fatalf("ParseUnrealConf() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseUnrealConf() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseUnrealConfMalformed(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"missing semicolon", `ban user { mask "*@1.2.3.4" }`, "line 1: missing ';' after 'mask'"},
+		{"missing closing brace", "ban user {\n mask \"*@1.2.3.4\";\n", "missing '}'"},
+		{"unexpected closing brace", "};", "line 1: unexpected '}'"},
+		{"unexpected opening brace", "{ mask x; };", "line 1: unexpected '{'"},
+		{"unterminated string", "ban user {\n reason \"abuse;\n};", "line 2: unterminated string"},
+		{"unterminated comment", "/* never closed\nban user { };", "line 1: unterminated comment"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseUnrealConf(tt.content)
+			if err == nil {
+				t.Fatalf("ParseUnrealConf() error = nil, want %q", tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseUnrealConf() error = %q, want %q", err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestQuoteConfStringRoundTrip(t *testing.T) {
+	for _, value := range []string{"plain", `with "quotes"`, `back\slash`, ""} {
+		entries, err := ParseUnrealConf("reason " + QuoteConfString(value) + ";")
+		if err != nil {
+			t.Fatalf("ParseUnrealConf(%q) error = %v", value, err)
+		}
+		if len(entries) != 1 || entries[0].Value != value {
+			t.Errorf("round trip of %q = %+v", value, entries)
+		}
+	}
+}
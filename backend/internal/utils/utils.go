@@ -7,6 +7,7 @@ import (
 	"encoding/base64"
 	"errors"
 	"io"
+	"regexp"
 	"strings"
 )
 
@@ -91,6 +92,15 @@ func ParseDuration(s string) string {
 	return s
 }
 
+// banDurationPattern matches durations like "3600", "30m" or "1d12h"
+var banDurationPattern = regexp.MustCompile(`^([0-9]+[smhdwy]?)+$`)
+
+// ValidBanDuration reports whether s is a duration the IRCd accepts for a ban:
+// "0" for permanent, a number of seconds or units such as "1d12h"
+func ValidBanDuration(s string) bool {
+	return banDurationPattern.MatchString(strings.ToLower(strings.TrimSpace(s)))
+}
+
 // TruncateString truncates a string to max length with ellipsis
 func TruncateString(s string, maxLen int) string {
 	if len(s) <= maxLen {
@@ -0,0 +1,190 @@
+import { useState } from 'react'
+import { useQuery, useMutation, useQueryClient } from '@tanstack/react-query'
+import { useTranslation } from 'react-i18next'
+import { getNotes, createNote, updateNote, deleteNote, Note, NoteFilter, NoteRequest } from '@/services/noteService'
+import { useAuth } from '@/hooks'
+import { Button } from './Button'
+import { Badge } from './Badge'
+import { LoadingSpinner } from './LoadingSpinner'
+import { StickyNote, Edit2, Trash2 } from 'lucide-react'
+import toast from 'react-hot-toast'
+
+interface NotesPanelProps {
+  filter: NoteFilter // Which notes to list
+  attach: Omit<NoteRequest, 'note'> // What new notes are attached to
+}
+
+const textareaClass =
+  'w-full bg-[var(--bg-tertiary)] border border-[var(--border-color)] rounded-lg px-3 py-2 text-[var(--text-primary)] resize-none'
+
+export function NotesPanel({ filter, attach }: NotesPanelProps) {
+  const { t } = useTranslation()
+  const { user, hasPermission } = useAuth()
+  const queryClient = useQueryClient()
+  const [text, setText] = useState('')
+  const [editing, setEditing] = useState<Note | null>(null)
+  const [editText, setEditText] = useState('')
+
+  const { data: notes = [], isLoading } = useQuery({
+    queryKey: ['notes', filter],
+    queryFn: () => getNotes(filter),
+  })
+
+  const invalidate = () => queryClient.invalidateQueries({ queryKey: ['notes'] })
+
+  const createMutation = useMutation({
+    mutationFn: createNote,
+    onSuccess: () => {
+      invalidate()
+      setText('')
+      toast.success(t('notes.messages.added'))
+    },
+    onError: (err: Error) => {
+      toast.error(err.message || t('notes.messages.addFailed'))
+    },
+  })
+
+  const updateMutation = useMutation({
+    mutationFn: ({ id, data }: { id: number; data: NoteRequest }) => updateNote(id, data),
+    onSuccess: () => {
+      invalidate()
+      setEditing(null)
+      toast.success(t('notes.messages.updated'))
+    },
+    onError: (err: Error) => {
+      toast.error(err.message || t('notes.messages.updateFailed'))
+    },
+  })
+
+  const deleteMutation = useMutation({
+    mutationFn: deleteNote,
+    onSuccess: () => {
+      invalidate()
+      toast.success(t('notes.messages.deleted'))
+    },
+    onError: (err: Error) => {
+      toast.error(err.message || t('notes.messages.deleteFailed'))
+    },
+  })
+
+  // Mirrors the backend: authors and user managers may change a note
+  const canEdit = (note: Note) => note.created_by === user?.username || hasPermission('manage_users')
+
+  const handleAdd = () => {
+    if (!text.trim()) return
+    createMutation.mutate({ ...attach, note: text.trim() })
+  }
+
+  const handleUpdate = () => {
+    if (!editing || !editText.trim()) return
+    updateMutation.mutate({
+      id: editing.id,
+      data: {
+        nick: editing.nick,
+        ip: editing.ip,
+        account: editing.account,
+        ban_type: editing.ban_type,
+        ban_mask: editing.ban_mask,
+        watchlist_id: editing.watchlist_id,
+        note: editText.trim(),
+      },
+    })
+  }
+
+  return (
+    <div className="space-y-4">
+      <div className="space-y-2">
+        <textarea
+          value={text}
+          onChange={(e) => setText(e.target.value)}
+          placeholder={t('notes.placeholder')}
+          rows={3}
+          className={textareaClass}
+        />
+        <div className="flex justify-end">
+          <Button
+            size="sm"
+            leftIcon={<StickyNote size={14} />}
+            onClick={handleAdd}
+            isLoading={createMutation.isPending}
+            disabled={!text.trim()}
+          >
+            {t('notes.add')}
+          </Button>
+        </div>
+      </div>
+
+      {isLoading ? (
+        <div className="flex justify-center py-4">
+          <LoadingSpinner />
+        </div>
+      ) : notes.length === 0 ? (
+        <p className="text-sm text-[var(--text-muted)] text-center py-4">{t('notes.empty')}</p>
+      ) : (
+        <div className="space-y-2">
+          {notes.map((note) => (
+            <div key={note.id} className="p-3 bg-[var(--bg-tertiary)] rounded-lg">
+              <div className="flex items-center justify-between gap-2 mb-1">
+                <div className="flex items-center gap-2 text-xs text-[var(--text-muted)]">
+                  <span className="font-medium text-[var(--text-secondary)]">{note.created_by}</span>
+                  <span>{new Date(note.created_at).toLocaleString()}</span>
+                  {note.updated_by && <span>{t('notes.editedBy', { user: note.updated_by })}</span>}
+                  {note.ban_mask && <Badge variant="default" size="sm">{note.ban_mask}</Badge>}
+                </div>
+                {canEdit(note) && editing?.id !== note.id && (
+                  <div className="flex items-center gap-1">
+                    <Button
+                      variant="ghost"
+                      size="sm"
+                      onClick={() => {
+                        setEditing(note)
+                        setEditText(note.note)
+                      }}
+                      title={t('common.edit')}
+                    >
+                      <Edit2 size={14} />
+                    </Button>
+                    <Button
+                      variant="ghost"
+                      size="sm"
+                      className="text-red-400 hover:text-red-300"
+                      onClick={() => deleteMutation.mutate(note.id)}
+                      title={t('common.delete')}
+                    >
+                      <Trash2 size={14} />
+                    </Button>
+                  </div>
+                )}
+              </div>
+              {editing?.id === note.id ? (
+                <div className="space-y-2">
+                  <textarea
+                    value={editText}
+                    onChange={(e) => setEditText(e.target.value)}
+                    rows={3}
+                    className={textareaClass}
+                  />
+                  <div className="flex justify-end gap-2">
+                    <Button variant="secondary" size="sm" onClick={() => setEditing(null)}>
+                      {t('common.cancel')}
+                    </Button>
+                    <Button
+                      size="sm"
+                      onClick={handleUpdate}
+                      isLoading={updateMutation.isPending}
+                      disabled={!editText.trim()}
+                    >
+                      {t('common.save')}
+                    </Button>
+                  </div>
+                </div>
+              ) : (
+                <p className="text-sm text-[var(--text-primary)] whitespace-pre-wrap">{note.note}</p>
+              )}
+            </div>
+          ))}
+        </div>
+      )}
+    </div>
+  )
+}
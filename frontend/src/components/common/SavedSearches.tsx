@@ -16,11 +16,11 @@ import toast from 'react-hot-toast'
 interface SavedSearchesProps {
   page: string // Which page this is for (users, channels, bans, etc.)
   currentQuery: string
-  currentFilters?: Record<string, unknown>
-  onApplySearch: (query: string, filters?: Record<string, unknown>) => void
+  currentFilter?: string // Filter expression, e.g. server:hub1 AND tls:false
+  onApplySearch: (query: string, filter?: string) => void
 }
 
-export function SavedSearches({ page, currentQuery, currentFilters, onApplySearch }: SavedSearchesProps) {
+export function SavedSearches({ page, currentQuery, currentFilter, onApplySearch }: SavedSearchesProps) {
   const { t } = useTranslation()
   const queryClient = useQueryClient()
   const [showSaveModal, setShowSaveModal] = useState(false)
@@ -62,8 +62,13 @@ export function SavedSearches({ page, currentQuery, currentFilters, onApplySearc
     mutationFn: useSavedSearch,
     onSuccess: (search) => {
       queryClient.invalidateQueries({ queryKey: ['savedSearches', page] })
-      const filters = search.filters ? JSON.parse(search.filters) : undefined
-      onApplySearch(search.query, filters)
+      // Older searches store a JSON object the server can no longer run; apply just their query
+      if (search.filters?.trim().startsWith('{')) {
+        toast.error(t('savedSearches.messages.legacyFilters'))
+        onApplySearch(search.query)
+      } else {
+        onApplySearch(search.query, search.filters || undefined)
+      }
       setShowListModal(false)
     },
     onError: (err: Error) => {
@@ -81,7 +86,7 @@ export function SavedSearches({ page, currentQuery, currentFilters, onApplySearc
       name: searchName.trim(),
       page,
       query: currentQuery,
-      filters: currentFilters ? JSON.stringify(currentFilters) : undefined,
+      filters: currentFilter?.trim() || undefined,
       is_global: isGlobal,
     }
     
@@ -92,7 +97,7 @@ export function SavedSearches({ page, currentQuery, currentFilters, onApplySearc
     useMutation_.mutate(search.id)
   }
 
-  const canSave = currentQuery.trim().length > 0
+  const canSave = currentQuery.trim().length > 0 || !!currentFilter?.trim()
 
   return (
     <div className="flex items-center gap-2">
@@ -155,6 +160,11 @@ export function SavedSearches({ page, currentQuery, currentFilters, onApplySearc
           <div className="bg-[var(--bg-tertiary)] p-3 rounded-lg">
             <div className="text-sm text-[var(--text-muted)]">{t('savedSearches.currentSearch')}</div>
             <div className="font-mono text-[var(--text-primary)]">{currentQuery || t('savedSearches.emptyPlaceholder')}</div>
+            {currentFilter?.trim() && (
+              <div className="font-mono text-sm text-[var(--text-secondary)] mt-1">
+                {t('savedSearches.filter')}: {currentFilter}
+              </div>
+            )}
           </div>
           
           <Input
@@ -223,6 +233,11 @@ export function SavedSearches({ page, currentQuery, currentFilters, onApplySearc
                     <div className="text-sm font-mono text-[var(--text-muted)] mt-1">
                       {search.query || '(no query)'}
                     </div>
+                    {search.filters && !search.filters.trim().startsWith('{') && (
+                      <div className="text-sm font-mono text-[var(--text-secondary)] mt-1">
+                        {t('savedSearches.filter')}: {search.filters}
+                      </div>
+                    )}
                     <div className="text-xs text-[var(--text-muted)] mt-1">
                       {t('savedSearches.usedTimes', { count: search.use_count })}
                       {search.last_used && ` • ${t('savedSearches.lastUsed')}: ${new Date(search.last_used).toLocaleDateString()}`}
@@ -15,3 +15,4 @@ export { SeasonalAnimations, HeaderSeasonalAnimations, useActiveHoliday, useShou
 export { CommandPalette } from './CommandPalette'
 export { SavedSearches } from './SavedSearches'
 export { PluginLoader } from './PluginLoader'
+export { NotesPanel } from './NotesPanel'
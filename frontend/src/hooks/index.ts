@@ -17,6 +17,10 @@ export {
   useIRCServers,
   useIRCServer,
   useRehashServer,
+  useRehashAll,
+  useRehashRun,
+  useServerConsistency,
+  useServerMetrics,
   useServerBans,
   useAddServerBan,
   useDeleteServerBan,
@@ -13,10 +13,10 @@ import type {
 } from '@/types'
 
 // Users - auto-refresh every 5 seconds for live updates
-export function useIRCUsers(options?: Partial<UseQueryOptions<IRCUser[]>>) {
+export function useIRCUsers(filter?: string, options?: Partial<UseQueryOptions<IRCUser[]>>) {
   return useQuery({
-    queryKey: ['irc', 'users'],
-    queryFn: usersService.getAll,
+    queryKey: filter ? ['irc', 'users', { filter }] : ['irc', 'users'],
+    queryFn: () => usersService.getAll(filter),
     refetchInterval: 5000, // Auto-refresh every 5 seconds
     staleTime: 4000, // Consider data stale after 4 seconds
     ...options,
@@ -79,10 +79,10 @@ export function useSetUserVhost() {
 }
 
 // Channels - auto-refresh every 5 seconds
-export function useIRCChannels(options?: Partial<UseQueryOptions<IRCChannel[]>>) {
+export function useIRCChannels(filter?: string, options?: Partial<UseQueryOptions<IRCChannel[]>>) {
   return useQuery({
-    queryKey: ['irc', 'channels'],
-    queryFn: channelsService.getAll,
+    queryKey: filter ? ['irc', 'channels', { filter }] : ['irc', 'channels'],
+    queryFn: () => channelsService.getAll(filter),
     refetchInterval: 5000, // Auto-refresh every 5 seconds
     staleTime: 4000,
     ...options,
@@ -161,6 +161,38 @@ export function useRehashServer() {
   })
 }
 
+export function useRehashAll() {
+  return useMutation({
+    mutationFn: serversService.rehashAll,
+  })
+}
+
+export function useServerConsistency(enabled: boolean) {
+  return useQuery({
+    queryKey: ['serverConsistency'],
+    queryFn: serversService.getConsistency,
+    enabled,
+  })
+}
+
+export function useServerMetrics(name: string | null, range: string) {
+  return useQuery({
+    queryKey: ['serverMetrics', name, range],
+    queryFn: () => serversService.getMetrics(name as string, range),
+    enabled: name !== null,
+    refetchInterval: 60000,
+  })
+}
+
+export function useRehashRun(id: number | null) {
+  return useQuery({
+    queryKey: ['rehashRun', id],
+    queryFn: () => serversService.getRehashRun(id as number),
+    enabled: id !== null,
+    refetchInterval: (query) => (query.state.data?.status === 'running' ? 2000 : false),
+  })
+}
+
 // Server Bans
 export function useServerBans(options?: Partial<UseQueryOptions<ServerBan[]>>) {
   return useQuery({
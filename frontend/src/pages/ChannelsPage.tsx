@@ -10,7 +10,6 @@ import { useTranslation } from 'react-i18next'
 export function ChannelsPage() {
   const { t } = useTranslation()
   const navigate = useNavigate()
-  const { data: channels, isLoading, error } = useIRCChannels()
   const setTopic = useSetChannelTopic()
   const setMode = useSetChannelMode()
   const kickUser = useKickUser()
@@ -23,6 +22,10 @@ export function ChannelsPage() {
   
   // Search state
   const [searchQuery, setSearchQuery] = useState('')
+  // Server-side filter expression; filterInput is applied on Enter or blur
+  const [filterInput, setFilterInput] = useState('')
+  const [filter, setFilter] = useState('')
+  const { data: channels, isLoading, error } = useIRCChannels(filter || undefined)
 
   const [newTopic, setNewTopic] = useState('')
   const [modeData, setModeData] = useState({ modes: '', params: '' })
@@ -134,7 +137,7 @@ export function ChannelsPage() {
     }
   }
 
-  if (error) {
+  if (error && !filter) {
     return (
       <Alert type="error">
         {t('channels.loadError', { error: error instanceof Error ? error.message : 'Unknown error' })}
@@ -149,6 +152,19 @@ export function ChannelsPage() {
         <p className="text-[var(--text-muted)] mt-1">{t('channels.subtitle')}</p>
       </div>
 
+      <Input
+        value={filterInput}
+        onChange={(e) => setFilterInput(e.target.value)}
+        onKeyDown={(e) => {
+          if (e.key === 'Enter') setFilter(filterInput.trim())
+        }}
+        onBlur={() => setFilter(filterInput.trim())}
+        placeholder={t('channels.filterPlaceholder')}
+        className="font-mono text-sm"
+        error={filter && error instanceof Error ? error.message : undefined}
+        helperText={t('channels.filterHelp')}
+      />
+
       <DataTable
         data={channels || []}
         columns={columns}
@@ -161,7 +177,12 @@ export function ChannelsPage() {
           <SavedSearches
             page="channels"
             currentQuery={searchQuery}
-            onApplySearch={(query) => setSearchQuery(query)}
+            currentFilter={filter}
+            onApplySearch={(query, savedFilter) => {
+              setSearchQuery(query)
+              setFilterInput(savedFilter || '')
+              setFilter(savedFilter || '')
+            }}
           />
         }
         actions={(channel) => (
@@ -20,8 +20,21 @@ const COMMAND_TYPES = [
   { value: 'gline', label: 'G-Line (Server Ban)' },
   { value: 'rehash', label: 'Rehash Server' },
   { value: 'message', label: 'Send Message' },
+  { value: 'quit', label: 'Disconnect User (Quit)' },
+  { value: 'join', label: 'Force Join Channel' },
+  { value: 'part', label: 'Force Part Channel' },
+  { value: 'set_username', label: 'Set Username' },
+  { value: 'set_realname', label: 'Set Realname' },
+  { value: 'set_snomask', label: 'Set Snomask' },
+  { value: 'set_oper', label: 'Set Oper' },
+  { value: 'channel_snapshot', label: 'Channel Snapshot' },
+  { value: 'server_connect', label: 'Connect Server' },
+  { value: 'server_disconnect', label: 'Disconnect Server (SQUIT)' },
 ]
 
+// Params of user.set_oper; oper_account and oper_class are required
+const SET_OPER_FIELDS = ['oper_account', 'oper_class', 'class', 'modes', 'snomask', 'vhost']
+
 const SCHEDULE_TYPES = [
   { value: 'once', label: 'One Time' },
   { value: 'hourly', label: 'Every Hour' },
@@ -450,7 +463,75 @@ function ScheduledCommandForm({
       </div>
 
       {/* Command-specific parameters */}
-      {(formData.command === 'kill' || formData.command === 'gline') && (
+      {(formData.command === 'join' || formData.command === 'part') && (
+        <Input
+          label={t('scheduledCommands.form.channelLabel')}
+          value={(formData.params as Record<string, string>)?.channel || ''}
+          onChange={(e) =>
+            setFormData({
+              ...formData,
+              params: { ...formData.params, channel: e.target.value },
+            })
+          }
+          placeholder="#channel"
+        />
+      )}
+
+      {(formData.command === 'set_username' || formData.command === 'set_realname' || formData.command === 'set_snomask') && (
+        <Input
+          label={t('scheduledCommands.form.valueLabel')}
+          value={
+            (formData.params as Record<string, string>)?.[
+              formData.command === 'set_username' ? 'username' : formData.command === 'set_realname' ? 'realname' : 'snomask'
+            ] || ''
+          }
+          onChange={(e) =>
+            setFormData({
+              ...formData,
+              params: {
+                ...formData.params,
+                [formData.command === 'set_username' ? 'username' : formData.command === 'set_realname' ? 'realname' : 'snomask']:
+                  e.target.value,
+              },
+            })
+          }
+        />
+      )}
+
+      {formData.command === 'set_oper' && (
+        <div className="grid grid-cols-2 gap-4">
+          {SET_OPER_FIELDS.map((field) => (
+            <Input
+              key={field}
+              label={t(`scheduledCommands.form.setOper.${field}`)}
+              value={(formData.params as Record<string, string>)?.[field] || ''}
+              onChange={(e) =>
+                setFormData({
+                  ...formData,
+                  params: { ...formData.params, [field]: e.target.value },
+                })
+              }
+            />
+          ))}
+        </div>
+      )}
+
+      {formData.command === 'server_connect' && (
+        <Input
+          label={t('scheduledCommands.form.viaLabel')}
+          value={(formData.params as Record<string, string>)?.via || ''}
+          onChange={(e) =>
+            setFormData({
+              ...formData,
+              params: { ...formData.params, via: e.target.value },
+            })
+          }
+          placeholder="hub.example.org"
+        />
+      )}
+
+      {(formData.command === 'kill' || formData.command === 'gline' || formData.command === 'quit' || formData.command === 'part' ||
+        formData.command === 'server_connect' || formData.command === 'server_disconnect') && (
         <Input
           label={t('scheduledCommands.form.reasonLabel')}
           value={(formData.params as Record<string, string>)?.reason || ''}
@@ -1,8 +1,8 @@
 import { useState } from 'react'
 import { useTranslation } from 'react-i18next'
 import { useServerBans, useAddServerBan, useDeleteServerBan } from '@/hooks'
-import { DataTable, Button, Modal, Input, Select, Alert, Badge } from '@/components/common'
-import { Plus, Trash2, Clock } from 'lucide-react'
+import { DataTable, Button, Modal, Input, Select, Alert, Badge, NotesPanel } from '@/components/common'
+import { Plus, Trash2, Clock, StickyNote } from 'lucide-react'
 import type { ServerBan } from '@/types'
 import toast from 'react-hot-toast'
 
@@ -13,6 +13,7 @@ export function ServerBansPage() {
 
   const [showAddModal, setShowAddModal] = useState(false)
   const [showDeleteModal, setShowDeleteModal] = useState(false)
+  const [showNotesModal, setShowNotesModal] = useState(false)
   const [selectedBan, setSelectedBan] = useState<ServerBan | null>(null)
 
   const [newBan, setNewBan] = useState({
@@ -133,17 +134,30 @@ export function ServerBansPage() {
         isLoading={isLoading}
         searchPlaceholder={t('serverBans.searchPlaceholder')}
         actions={(ban) => (
-          <Button
-            variant="ghost"
-            size="sm"
-            className="text-red-400 hover:text-red-300"
-            onClick={() => {
-              setSelectedBan(ban)
-              setShowDeleteModal(true)
-            }}
-          >
-            <Trash2 size={16} />
-          </Button>
+          <>
+            <Button
+              variant="ghost"
+              size="sm"
+              onClick={() => {
+                setSelectedBan(ban)
+                setShowNotesModal(true)
+              }}
+              title={t('notes.title')}
+            >
+              <StickyNote size={16} />
+            </Button>
+            <Button
+              variant="ghost"
+              size="sm"
+              className="text-red-400 hover:text-red-300"
+              onClick={() => {
+                setSelectedBan(ban)
+                setShowDeleteModal(true)
+              }}
+            >
+              <Trash2 size={16} />
+            </Button>
+          </>
         )}
       />
 
@@ -223,6 +237,21 @@ export function ServerBansPage() {
           {t('serverBans.deleteModal.confirm', { type: selectedBan?.type.toUpperCase(), name: <span className="font-mono">{selectedBan?.name}</span> })}
         </Alert>
       </Modal>
+
+      {/* Notes Modal */}
+      <Modal
+        isOpen={showNotesModal}
+        onClose={() => setShowNotesModal(false)}
+        title={`${t('notes.title')}: ${selectedBan?.name ?? ''}`}
+        size="lg"
+      >
+        {selectedBan && (
+          <NotesPanel
+            filter={{ ban_mask: selectedBan.name }}
+            attach={{ ban_type: selectedBan.type, ban_mask: selectedBan.name }}
+          />
+        )}
+      </Modal>
     </div>
   )
 }
@@ -1,8 +1,9 @@
 import { useState } from 'react'
-import { useIRCServers, useRehashServer } from '@/hooks'
-import { DataTable, Button, Modal, Alert, Badge } from '@/components/common'
-import { Eye, RefreshCw, Server, Clock, Users } from 'lucide-react'
-import type { IRCServer } from '@/types'
+import { LineChart, Line, XAxis, YAxis, CartesianGrid, Tooltip, ResponsiveContainer } from 'recharts'
+import { useIRCServers, useRehashServer, useRehashAll, useRehashRun, useServerConsistency, useServerMetrics } from '@/hooks'
+import { DataTable, Button, Modal, Alert, Badge, Input, Select, LoadingSpinner } from '@/components/common'
+import { Eye, RefreshCw, Server, Clock, Users, GitCompare, Activity } from 'lucide-react'
+import type { IRCServer, RehashAllRequest, RehashServerResult, ConsistencyFinding } from '@/types'
 import toast from 'react-hot-toast'
 import { useTranslation } from 'react-i18next'
 
@@ -14,6 +15,17 @@ export function ServersPage() {
   const [selectedServer, setSelectedServer] = useState<IRCServer | null>(null)
   const [showDetails, setShowDetails] = useState(false)
   const [showRehashModal, setShowRehashModal] = useState(false)
+  const [showRehashAllModal, setShowRehashAllModal] = useState(false)
+  const [rehashAllForm, setRehashAllForm] = useState<RehashAllRequest>({
+    mode: 'sequential',
+    wave_size: 3,
+    stop_on_failure: true,
+  })
+  const [rehashRunId, setRehashRunId] = useState<number | null>(null)
+  const rehashAll = useRehashAll()
+  const { data: rehashRun } = useRehashRun(rehashRunId)
+  const [showConsistency, setShowConsistency] = useState(false)
+  const consistency = useServerConsistency(showConsistency)
 
   const columns = [
     {
@@ -85,6 +97,21 @@ export function ServersPage() {
     }
   }
 
+  const handleRehashAll = async () => {
+    try {
+      const run = await rehashAll.mutateAsync(rehashAllForm)
+      setRehashRunId(run.id)
+    } catch (err: unknown) {
+      const message = err instanceof Error ? err.message : t('servers.rehashAllFailed')
+      toast.error(message)
+    }
+  }
+
+  const closeRehashAll = () => {
+    setShowRehashAllModal(false)
+    setRehashRunId(null)
+  }
+
   if (error) {
     return (
       <Alert type="error">
@@ -95,9 +122,21 @@ export function ServersPage() {
 
   return (
     <div className="space-y-6">
-      <div>
-        <h1 className="text-2xl font-bold text-[var(--text-primary)]">{t('servers.title')}</h1>
-        <p className="text-[var(--text-muted)] mt-1">{t('servers.subtitle')}</p>
+      <div className="flex items-center justify-between">
+        <div>
+          <h1 className="text-2xl font-bold text-[var(--text-primary)]">{t('servers.title')}</h1>
+          <p className="text-[var(--text-muted)] mt-1">{t('servers.subtitle')}</p>
+        </div>
+        <div className="flex items-center gap-2">
+          <Button variant="secondary" onClick={() => setShowConsistency(true)}>
+            <GitCompare size={16} className="mr-2" />
+            {t('servers.consistency')}
+          </Button>
+          <Button variant="secondary" onClick={() => setShowRehashAllModal(true)}>
+            <RefreshCw size={16} className="mr-2" />
+            {t('servers.rehashAll')}
+          </Button>
+        </div>
       </div>
 
       <DataTable
@@ -160,6 +199,8 @@ export function ServersPage() {
               </div>
             </div>
 
+            <ServerMetricsPanel name={selectedServer.name} />
+
             {selectedServer.server?.features && (
               <div>
                 <p className="text-sm text-[var(--text-muted)] mb-2">{t('servers.serverFeatures')}</p>
@@ -225,6 +266,310 @@ export function ServersPage() {
           {t('servers.rehashWarning', { name: selectedServer?.name })}
         </Alert>
       </Modal>
+
+      {/* Consistency Modal */}
+      <Modal
+        isOpen={showConsistency}
+        onClose={() => setShowConsistency(false)}
+        title={t('servers.consistencyTitle')}
+        size="xl"
+        footer={
+          <>
+            <Button variant="secondary" onClick={() => consistency.refetch()} isLoading={consistency.isFetching}>
+              {t('servers.consistencyRecheck')}
+            </Button>
+            <Button variant="secondary" onClick={() => setShowConsistency(false)}>
+              {t('servers.close')}
+            </Button>
+          </>
+        }
+      >
+        {consistency.isLoading ? (
+          <LoadingSpinner />
+        ) : consistency.error ? (
+          <Alert type="error">{consistency.error instanceof Error ? consistency.error.message : t('servers.consistencyFailed')}</Alert>
+        ) : consistency.data && (
+          <div className="space-y-4">
+            {consistency.data.consistent ? (
+              <Alert type="success">{t('servers.consistencyOk')}</Alert>
+            ) : (
+              <div className="space-y-2">
+                {consistency.data.findings.map((finding) => (
+                  <ConsistencyFindingRow key={`${finding.kind}:${finding.subject}`} finding={finding} />
+                ))}
+              </div>
+            )}
+            <table className="w-full text-sm">
+              <thead>
+                <tr className="text-left text-[var(--text-muted)]">
+                  <th className="py-1">{t('servers.server')}</th>
+                  <th className="py-1">{t('servers.version')}</th>
+                  <th className="py-1">{t('servers.consistencyModules')}</th>
+                  <th className="py-1">{t('servers.consistencyThirdParty')}</th>
+                </tr>
+              </thead>
+              <tbody>
+                {consistency.data.servers.map((server) => (
+                  <tr key={server.name} className="border-t border-[var(--border-color)]">
+                    <td className="py-1 text-[var(--text-primary)]">{server.name}</td>
+                    <td className="py-1 text-[var(--text-secondary)]">{server.version || t('servers.unknown')}</td>
+                    <td className="py-1 text-[var(--text-secondary)]">
+                      {server.error ? <span className="text-red-400">{server.error}</span> : server.modules}
+                    </td>
+                    <td className="py-1 text-[var(--text-secondary)] font-mono text-xs">{server.third_party.join(', ')}</td>
+                  </tr>
+                ))}
+              </tbody>
+            </table>
+          </div>
+        )}
+      </Modal>
+
+      {/* Rehash All Modal */}
+      <Modal
+        isOpen={showRehashAllModal}
+        onClose={closeRehashAll}
+        title={t('servers.rehashAllTitle')}
+        size="lg"
+        footer={
+          rehashRunId === null ? (
+            <>
+              <Button variant="secondary" onClick={closeRehashAll}>
+                {t('servers.cancel')}
+              </Button>
+              <Button onClick={handleRehashAll} isLoading={rehashAll.isPending}>
+                {t('servers.rehashAll')}
+              </Button>
+            </>
+          ) : (
+            <Button variant="secondary" onClick={closeRehashAll}>
+              {t('servers.close')}
+            </Button>
+          )
+        }
+      >
+        {rehashRunId === null ? (
+          <div className="space-y-4">
+            <Alert type="warning">{t('servers.rehashAllWarning')}</Alert>
+            <div className="grid grid-cols-2 gap-4">
+              <Select
+                label={t('servers.rehashMode')}
+                value={rehashAllForm.mode}
+                onChange={(e) => setRehashAllForm({ ...rehashAllForm, mode: e.target.value as RehashAllRequest['mode'] })}
+              >
+                <option value="sequential">{t('servers.rehashModeSequential')}</option>
+                <option value="waves">{t('servers.rehashModeWaves')}</option>
+              </Select>
+              {rehashAllForm.mode === 'waves' && (
+                <Input
+                  type="number"
+                  min={1}
+                  label={t('servers.rehashWaveSize')}
+                  value={rehashAllForm.wave_size}
+                  onChange={(e) => setRehashAllForm({ ...rehashAllForm, wave_size: parseInt(e.target.value) || 1 })}
+                />
+              )}
+            </div>
+            <label className="flex items-center gap-2 cursor-pointer">
+              <input
+                type="checkbox"
+                checked={rehashAllForm.stop_on_failure}
+                onChange={(e) => setRehashAllForm({ ...rehashAllForm, stop_on_failure: e.target.checked })}
+                className="w-4 h-4 rounded border-[var(--border-color)] bg-[var(--bg-tertiary)] checked:bg-[var(--accent-color)]"
+              />
+              <span className="text-[var(--text-secondary)]">{t('servers.rehashStopOnFailure')}</span>
+            </label>
+          </div>
+        ) : (
+          <div className="space-y-4">
+            {rehashRun && (
+              <p className="text-[var(--text-secondary)]">
+                {t('servers.rehashAllSummary', {
+                  status: rehashRun.status,
+                  succeeded: rehashRun.succeeded,
+                  failed: rehashRun.failed,
+                  unconfirmed: rehashRun.unconfirmed,
+                  skipped: rehashRun.skipped,
+                  total: rehashRun.total,
+                })}
+              </p>
+            )}
+            <div className="space-y-2 max-h-96 overflow-y-auto">
+              {rehashRun?.results?.map((result) => (
+                <RehashResultRow key={result.id} result={result} />
+              ))}
+            </div>
+          </div>
+        )}
+      </Modal>
+    </div>
+  )
+}
+
+const REHASH_STATUS_VARIANTS: Record<RehashServerResult['status'], 'default' | 'success' | 'warning' | 'error' | 'secondary'> = {
+  pending: 'default',
+  success: 'success',
+  unconfirmed: 'warning',
+  failed: 'error',
+  skipped: 'secondary',
+}
+
+function RehashResultRow({ result }: { result: RehashServerResult }) {
+  const warnings: string[] = JSON.parse(result.warnings || '[]')
+  const errors: string[] = JSON.parse(result.errors || '[]')
+  return (
+    <div className="p-3 rounded-lg bg-[var(--bg-tertiary)]">
+      <div className="flex items-center gap-2">
+        <Badge variant={REHASH_STATUS_VARIANTS[result.status]} size="sm">{result.status}</Badge>
+        <span className="text-[var(--text-primary)] font-medium">{result.server}</span>
+        {result.error && <span className="text-sm text-red-400 truncate">{result.error}</span>}
+      </div>
+      {[...errors.map((msg) => ({ msg, cls: 'text-red-400' })), ...warnings.map((msg) => ({ msg, cls: 'text-yellow-400' }))].map((line, i) => (
+        <p key={i} className={`text-xs font-mono mt-1 ${line.cls}`}>{line.msg}</p>
+      ))}
+    </div>
+  )
+}
+
+function ConsistencyFindingRow({ finding }: { finding: ConsistencyFinding }) {
+  const { t } = useTranslation()
+  return (
+    <div className="p-3 rounded-lg bg-[var(--bg-tertiary)]">
+      <div className="flex items-center gap-2">
+        <Badge variant={finding.kind === 'module_missing' ? 'warning' : 'error'} size="sm">
+          {t(`servers.consistencyKinds.${finding.kind}`)}
+        </Badge>
+        <span className="text-[var(--text-primary)] font-mono">{finding.subject}</span>
+        {finding.third_party && <Badge variant="info" size="sm">{t('servers.consistencyThirdParty')}</Badge>}
+      </div>
+      {finding.missing_on && (
+        <p className="text-xs text-[var(--text-muted)] mt-1">
+          {t('servers.consistencyMissingOn', { servers: finding.missing_on.join(', ') })}
+        </p>
+      )}
+      {finding.versions && Object.entries(finding.versions).map(([version, servers]) => (
+        <p key={version} className="text-xs text-[var(--text-muted)] mt-1">
+          <span className="font-mono">{version}</span>: {servers.join(', ')}
+        </p>
+      ))}
+    </div>
+  )
+}
+
+const METRICS_RANGES = ['1h', '24h', '7d', '30d']
+
+const CHART_TOOLTIP_STYLE = {
+  backgroundColor: 'var(--bg-secondary)',
+  border: '1px solid var(--border-primary)',
+  borderRadius: '8px',
+  color: 'var(--text-primary)',
+}
+
+function ServerMetricsPanel({ name }: { name: string }) {
+  const { t } = useTranslation()
+  const [range, setRange] = useState('24h')
+  const { data: metrics, isLoading } = useServerMetrics(name, range)
+
+  const chartData = (metrics?.points || []).map((point) => {
+    const date = new Date(point.time)
+    return {
+      time: range === '1h' || range === '24h'
+        ? date.toLocaleTimeString([], { hour: '2-digit', minute: '2-digit' })
+        : date.toLocaleDateString([], { month: 'short', day: 'numeric' }),
+      fullTime: date.toLocaleString(),
+      users: Math.round(point.avg_users),
+      lag: point.avg_lag_ms !== undefined ? Math.round(point.avg_lag_ms) : null,
+      uptime: Math.round((point.uptime_seconds / 3600) * 10) / 10,
+    }
+  })
+  const summary = metrics?.summary
+  const current = summary?.current
+
+  const charts = [
+    { key: 'users', label: t('servers.metricsUsers'), color: 'var(--accent)' },
+    { key: 'lag', label: t('servers.metricsLag'), color: 'var(--warning)' },
+    { key: 'uptime', label: t('servers.metricsUptime'), color: 'var(--success)' },
+  ]
+
+  return (
+    <div className="space-y-3">
+      <div className="flex items-center justify-between">
+        <p className="text-sm text-[var(--text-muted)] flex items-center gap-2">
+          <Activity size={14} />
+          {t('servers.metrics')}
+        </p>
+        <div className="flex gap-1">
+          {METRICS_RANGES.map((r) => (
+            <Button key={r} size="sm" variant={range === r ? 'primary' : 'secondary'} onClick={() => setRange(r)}>
+              {r}
+            </Button>
+          ))}
+        </div>
+      </div>
+
+      {isLoading ? (
+        <LoadingSpinner />
+      ) : !metrics || metrics.points.length === 0 ? (
+        <p className="text-sm text-[var(--text-muted)]">{t('servers.metricsEmpty')}</p>
+      ) : (
+        <>
+          <div className="grid grid-cols-4 gap-2 text-sm">
+            <div className="p-2 rounded-lg bg-[var(--bg-tertiary)]">
+              <p className="text-[var(--text-muted)]">{t('servers.metricsCurrentLag')}</p>
+              <p className="text-[var(--text-primary)]">
+                {current?.lag_ms !== undefined ? `${current.lag_ms} ms` : t('servers.metricsNoAnswer')}
+              </p>
+            </div>
+            <div className="p-2 rounded-lg bg-[var(--bg-tertiary)]">
+              <p className="text-[var(--text-muted)]">{t('servers.metricsAvgLag')}</p>
+              <p className="text-[var(--text-primary)]">
+                {summary?.avg_lag_ms !== undefined ? `${Math.round(summary.avg_lag_ms)} ms (max ${summary.max_lag_ms} ms)` : '-'}
+              </p>
+            </div>
+            <div className="p-2 rounded-lg bg-[var(--bg-tertiary)]">
+              <p className="text-[var(--text-muted)]">{t('servers.metricsRestarts')}</p>
+              <p className="text-[var(--text-primary)]">{summary?.restarts ?? 0}</p>
+            </div>
+            <div className="p-2 rounded-lg bg-[var(--bg-tertiary)]">
+              <p className="text-[var(--text-muted)]">{t('servers.metricsSync')}</p>
+              <Badge variant={current?.synced ? 'success' : 'warning'} size="sm">
+                {current?.synced ? t('servers.metricsSynced') : t('servers.metricsSyncing')}
+              </Badge>
+            </div>
+          </div>
+
+          {charts.map((chart) => (
+            <div key={chart.key}>
+              <p className="text-xs text-[var(--text-muted)] mb-1">{chart.label}</p>
+              <div className="h-32">
+                <ResponsiveContainer width="100%" height="100%">
+                  <LineChart data={chartData}>
+                    <CartesianGrid strokeDasharray="3 3" stroke="var(--border-primary)" />
+                    <XAxis dataKey="time" stroke="var(--text-muted)" fontSize={11} />
+                    <YAxis stroke="var(--text-muted)" fontSize={11} width={40} />
+                    <Tooltip
+                      contentStyle={CHART_TOOLTIP_STYLE}
+                      labelFormatter={(_, payload) => payload?.[0]?.payload?.fullTime || ''}
+                    />
+                    <Line type="monotone" dataKey={chart.key} name={chart.label} stroke={chart.color} dot={false} connectNulls={false} />
+                  </LineChart>
+                </ResponsiveContainer>
+              </div>
+            </div>
+          ))}
+
+          {metrics.restarts.length > 0 && (
+            <div className="space-y-1">
+              <p className="text-xs text-[var(--text-muted)]">{t('servers.metricsRestartList')}</p>
+              {metrics.restarts.map((restart) => (
+                <p key={restart.sampled_at} className="text-sm text-[var(--text-secondary)]">
+                  {new Date(restart.boot_time || restart.sampled_at).toLocaleString()}
+                </p>
+              ))}
+            </div>
+          )}
+        </>
+      )}
     </div>
   )
 }
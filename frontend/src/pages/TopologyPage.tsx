@@ -10,10 +10,19 @@ import {
   Info,
   ExternalLink,
   Cpu,
-  Clock
+  Clock,
+  History,
+  Download,
+  AlertTriangle
 } from 'lucide-react';
 import { Button, Badge, LoadingSpinner, Input } from '@/components/common';
-import { topologyService, TopologyNode } from '@/services/topologyService';
+import {
+  topologyService,
+  TopologyNode,
+  TopologyResponse,
+  TopologyTimelineEntry,
+  TopologyExportFormat,
+} from '@/services/topologyService';
 
 interface NodePosition {
   x: number;
@@ -25,12 +34,53 @@ export default function TopologyPage() {
   const [searchTerm, setSearchTerm] = useState('');
   const [viewMode, setViewMode] = useState<'graph' | 'list'>('graph');
 
-  const { data: topology, isLoading, error, refetch } = useQuery({
+  const [asOf, setAsOf] = useState('');
+
+  const { data: liveTopology, isLoading, error, refetch } = useQuery({
     queryKey: ['topology'],
     queryFn: topologyService.getTopology,
     refetchInterval: 30000, // Refresh every 30 seconds
   });
 
+  // Past topology, from the last snapshot recorded before the chosen time
+  const { data: pastTopology, error: pastError } = useQuery({
+    queryKey: ['topology-as-of', asOf],
+    queryFn: () => topologyService.getTopologyAsOf(new Date(asOf).toISOString()),
+    enabled: !!asOf,
+  });
+
+  const { data: timeline } = useQuery({
+    queryKey: ['topology-timeline'],
+    queryFn: () => topologyService.getTimeline('7d'),
+    refetchInterval: 60000,
+  });
+
+  const asOfISO = asOf ? new Date(asOf).toISOString() : undefined;
+
+  const { data: analysis } = useQuery({
+    queryKey: ['topology-analysis', asOf],
+    queryFn: () => topologyService.getAnalysis(asOfISO),
+    refetchInterval: asOf ? false : 60000,
+  });
+
+  const handleExport = async (format: TopologyExportFormat) => {
+    try {
+      const blob = await topologyService.exportTopology(format, asOfISO);
+      const url = URL.createObjectURL(blob);
+      const a = document.createElement('a');
+      a.href = url;
+      a.download = `topology.${format === 'jgf' ? 'json' : format}`;
+      a.click();
+      URL.revokeObjectURL(url);
+    } catch (error) {
+      console.error('Export failed:', error);
+    }
+  };
+
+  const topology: TopologyResponse | undefined = asOf
+    ? pastTopology && { nodes: pastTopology.nodes, links: pastTopology.links, stats: pastTopology.stats }
+    : liveTopology;
+
   const { data: serverDetails, isLoading: detailsLoading } = useQuery({
     queryKey: ['server-details', selectedServer],
     queryFn: () => selectedServer ? topologyService.getServerDetails(selectedServer) : null,
@@ -186,6 +236,21 @@ export default function TopologyPage() {
           </p>
         </div>
         <div className="flex items-center gap-3">
+          <div className="flex items-center gap-2">
+            <History className="w-4 h-4 text-gray-400" />
+            <input
+              type="datetime-local"
+              value={asOf}
+              onChange={(e) => setAsOf(e.target.value)}
+              className="px-2 py-1.5 text-sm rounded-lg bg-gray-800 border border-gray-700 text-gray-300"
+              title="Show the topology as it was at this time"
+            />
+            {asOf && (
+              <Button variant="secondary" size="sm" onClick={() => setAsOf('')}>
+                Live
+              </Button>
+            )}
+          </div>
           <div className="flex rounded-lg overflow-hidden border border-gray-700">
             <button
               className={`px-3 py-2 text-sm ${viewMode === 'graph' ? 'bg-blue-600 text-white' : 'bg-gray-800 text-gray-400 hover:bg-gray-700'}`}
@@ -200,6 +265,14 @@ export default function TopologyPage() {
               List
             </button>
           </div>
+          <div className="flex items-center gap-1">
+            <Download className="w-4 h-4 text-gray-400" />
+            {(['dot', 'graphml', 'jgf'] as TopologyExportFormat[]).map((format) => (
+              <Button key={format} variant="secondary" size="sm" onClick={() => handleExport(format)}>
+                {format === 'jgf' ? 'JSON Graph' : format === 'dot' ? 'DOT' : 'GraphML'}
+              </Button>
+            ))}
+          </div>
           <Button variant="secondary" onClick={() => refetch()}>
             <RefreshCw className="w-4 h-4 mr-2" />
             Refresh
@@ -249,6 +322,83 @@ export default function TopologyPage() {
         </div>
       )}
 
+      {/* Past topology notice */}
+      {asOf && (
+        <div className="bg-yellow-900/30 border border-yellow-700 rounded-lg p-3 text-sm text-yellow-300">
+          {pastTopology
+            ? `Showing the topology recorded at ${new Date(pastTopology.recorded_at).toLocaleString()}, as it was at ${new Date(pastTopology.at).toLocaleString()}.`
+            : pastError
+              ? 'No topology was recorded before that time.'
+              : 'Loading past topology...'}
+        </div>
+      )}
+
+      {/* Link Attempts */}
+      {topology?.link_attempts && topology.link_attempts.length > 0 && (
+        <div className="bg-gray-800 rounded-lg p-4 border border-gray-700">
+          <h3 className="text-sm font-semibold text-gray-300 mb-3">Link Attempts</h3>
+          <div className="space-y-2">
+            {topology.link_attempts.map((attempt) => (
+              <div key={attempt.id} className="flex items-center gap-3 text-sm">
+                <Badge variant={attempt.status === 'failed' ? 'error' : 'warning'}>
+                  {attempt.status === 'failed' ? 'Failed' : 'Connecting'}
+                </Badge>
+                <span className="text-white font-mono">{attempt.server}</span>
+                {attempt.via && <span className="text-gray-500">via {attempt.via}</span>}
+                <span className="text-gray-400 truncate flex-1">{attempt.message}</span>
+                <span className="text-xs text-gray-500">
+                  {new Date(attempt.updated_at).toLocaleTimeString()}
+                </span>
+              </div>
+            ))}
+          </div>
+        </div>
+      )}
+
+      {/* Redundancy analysis */}
+      {analysis && analysis.servers > 1 && (
+        <div className="bg-gray-800 rounded-lg p-4 border border-gray-700">
+          <h3 className="text-sm font-semibold text-gray-300 mb-3 flex items-center gap-2">
+            <AlertTriangle className="w-4 h-4 text-yellow-400" />
+            Redundancy
+          </h3>
+          <p className="text-sm text-gray-400 mb-2">
+            {analysis.redundant
+              ? 'No server is a single point of failure.'
+              : `${analysis.single_points_of_failure.length} single point(s) of failure.`}
+            {analysis.farthest_leaves.length === 2 &&
+              ` Longest path between leaves: ${analysis.max_leaf_hops} hops (${analysis.farthest_leaves.join(' to ')}).`}
+          </p>
+          <div className="space-y-1">
+            {analysis.single_points_of_failure.map((spof) => (
+              <div key={spof.server} className="flex items-center gap-3 text-sm">
+                <Badge variant="warning">SPOF</Badge>
+                <span className="text-white font-mono">{spof.server}</span>
+                <span className="text-gray-400">
+                  a split strands {spof.users_stranded} users on {spof.servers_cut_off.length} servers
+                </span>
+              </div>
+            ))}
+          </div>
+        </div>
+      )}
+
+      {/* Split and join timeline */}
+      {timeline && timeline.entries.length > 0 && (
+        <div className="bg-gray-800 rounded-lg p-4 border border-gray-700">
+          <h3 className="text-sm font-semibold text-gray-300 mb-3">Splits and Joins (last 7 days)</h3>
+          <div className="space-y-2 max-h-64 overflow-y-auto">
+            {timeline.entries.map((entry) => (
+              <TimelineRow
+                key={`${entry.snapshot_id}-${entry.kind}`}
+                entry={entry}
+                onShow={() => setAsOf(toLocalInput(entry.time))}
+              />
+            ))}
+          </div>
+        </div>
+      )}
+
       {/* Search */}
       <div className="max-w-md">
         <Input
@@ -597,3 +747,40 @@ export default function TopologyPage() {
     </div>
   );
 }
+
+function toLocalInput(iso: string): string {
+  const d = new Date(iso);
+  d.setMinutes(d.getMinutes() - d.getTimezoneOffset());
+  return d.toISOString().slice(0, 16);
+}
+
+function formatDuration(seconds: number): string {
+  if (seconds < 60) return `${seconds}s`;
+  if (seconds < 3600) return `${Math.floor(seconds / 60)}m`;
+  if (seconds < 86400) return `${Math.floor(seconds / 3600)}h ${Math.floor((seconds % 3600) / 60)}m`;
+  return `${Math.floor(seconds / 86400)}d ${Math.floor((seconds % 86400) / 3600)}h`;
+}
+
+function TimelineRow({ entry, onShow }: { entry: TopologyTimelineEntry; onShow: () => void }) {
+  const variant = entry.kind === 'split' ? 'error' : entry.kind === 'join' ? 'success' : 'info';
+  const label = entry.kind === 'split' ? 'Split' : entry.kind === 'join' ? 'Join' : 'Relink';
+  return (
+    <div className="flex items-center gap-3 text-sm">
+      <Badge variant={variant}>{label}</Badge>
+      <span className="text-white font-mono truncate">{entry.servers.join(', ')}</span>
+      {entry.uplink && (
+        <span className="text-gray-500">{entry.kind === 'split' ? 'from' : 'to'} {entry.uplink}</span>
+      )}
+      <span className="text-gray-400">{entry.users_affected} users</span>
+      {entry.duration_seconds ? (
+        <span className="text-gray-400">
+          {entry.kind === 'join' ? 'after ' : ''}{formatDuration(entry.duration_seconds)}{entry.ongoing ? ' (ongoing)' : ''}
+        </span>
+      ) : null}
+      <span className="flex-1" />
+      <button className="text-xs text-blue-400 hover:underline" onClick={onShow}>
+        {new Date(entry.time).toLocaleString()}
+      </button>
+    </div>
+  );
+}
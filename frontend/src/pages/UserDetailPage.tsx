@@ -15,8 +15,9 @@ import {
   Skull,
   Copy,
   Star,
+  StickyNote,
 } from 'lucide-react'
-import { Button, Badge, Modal, Input, Select, Alert, PageLoading, UserModeEditor } from '@/components/common'
+import { Button, Badge, Modal, Input, Select, Alert, PageLoading, UserModeEditor, NotesPanel } from '@/components/common'
 import { usersService } from '@/services/irc'
 import toast from 'react-hot-toast'
 
@@ -388,6 +389,18 @@ export default function UserDetailPage() {
               <p className="text-sm text-[var(--text-muted)]">Not in any channels</p>
             )}
           </div>
+
+          {/* Notes Card */}
+          <div className="card">
+            <div className="flex items-center gap-3 mb-4">
+              <StickyNote size={20} className="text-[var(--accent)]" />
+              <h3 className="text-lg font-semibold text-[var(--text-primary)]">{t('notes.title')}</h3>
+            </div>
+            <NotesPanel
+              filter={{ nick: user.name, ip: user.ip, account: user.account }}
+              attach={{ nick: user.name, ip: user.ip, account: user.account }}
+            />
+          </div>
         </div>
       </div>
 
@@ -10,7 +10,6 @@ import { useTranslation } from 'react-i18next'
 export function UsersPage() {
   const navigate = useNavigate()
   const { t } = useTranslation()
-  const { data: users, isLoading, error } = useIRCUsers()
   const killUser = useKillUser()
   const banUser = useBanUser()
   const setUserVhost = useSetUserVhost()
@@ -23,6 +22,10 @@ export function UsersPage() {
   
   // Search state
   const [searchQuery, setSearchQuery] = useState('')
+  // Server-side filter expression; filterInput is applied on Enter or blur
+  const [filterInput, setFilterInput] = useState('')
+  const [filter, setFilter] = useState('')
+  const { data: users, isLoading, error } = useIRCUsers(filter || undefined)
   
   // Bulk selection state
   const [selectedUsers, setSelectedUsers] = useState<Set<string>>(new Set())
@@ -274,7 +277,7 @@ export function UsersPage() {
     }
   }
 
-  if (error) {
+  if (error && !filter) {
     return (
       <Alert type="error">
         {t('users.loadError', { error: error instanceof Error ? error.message : 'Unknown error' })}
@@ -326,6 +329,19 @@ export function UsersPage() {
         </div>
       )}
 
+      <Input
+        value={filterInput}
+        onChange={(e) => setFilterInput(e.target.value)}
+        onKeyDown={(e) => {
+          if (e.key === 'Enter') setFilter(filterInput.trim())
+        }}
+        onBlur={() => setFilter(filterInput.trim())}
+        placeholder={t('users.filterPlaceholder')}
+        className="font-mono text-sm"
+        error={filter && error instanceof Error ? error.message : undefined}
+        helperText={t('users.filterHelp')}
+      />
+
       <DataTable
         data={users || []}
         columns={columns}
@@ -338,7 +354,12 @@ export function UsersPage() {
           <SavedSearches
             page="users"
             currentQuery={searchQuery}
-            onApplySearch={(query) => setSearchQuery(query)}
+            currentFilter={filter}
+            onApplySearch={(query, savedFilter) => {
+              setSearchQuery(query)
+              setFilterInput(savedFilter || '')
+              setFilter(savedFilter || '')
+            }}
           />
         }
         actions={(user) => (
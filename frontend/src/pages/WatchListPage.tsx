@@ -11,8 +11,8 @@ import {
   WatchedUserRequest,
   MatchedIRCUser
 } from '@/services/watchlistService'
-import { DataTable, Button, Modal, Input, Alert, Badge } from '@/components/common'
-import { Eye, Plus, Edit2, Trash2, Users, ExternalLink } from 'lucide-react'
+import { DataTable, Button, Modal, Input, Alert, Badge, NotesPanel } from '@/components/common'
+import { Eye, Plus, Edit2, Trash2, Users, ExternalLink, StickyNote } from 'lucide-react'
 import toast from 'react-hot-toast'
 
 export function WatchListPage() {
@@ -29,6 +29,7 @@ export function WatchListPage() {
   const [showEditModal, setShowEditModal] = useState(false)
   const [showDeleteModal, setShowDeleteModal] = useState(false)
   const [showMatchesModal, setShowMatchesModal] = useState(false)
+  const [showNotesModal, setShowNotesModal] = useState(false)
   const [selectedUser, setSelectedUser] = useState<WatchedUser | null>(null)
   const [formData, setFormData] = useState<WatchedUserRequest>({
     nick: '',
@@ -260,6 +261,17 @@ export function WatchListPage() {
         emptyMessage={t('watchList.emptyMessage')}
         actions={(user) => (
           <>
+            <Button
+              variant="ghost"
+              size="sm"
+              onClick={() => {
+                setSelectedUser(user)
+                setShowNotesModal(true)
+              }}
+              title={t('notes.title')}
+            >
+              <StickyNote size={16} />
+            </Button>
             <Button
               variant="ghost"
               size="sm"
@@ -405,6 +417,21 @@ export function WatchListPage() {
           <Alert type="info">No users currently match this watch criteria.</Alert>
         )}
       </Modal>
+
+      {/* Notes Modal */}
+      <Modal
+        isOpen={showNotesModal}
+        onClose={() => setShowNotesModal(false)}
+        title={t('notes.title')}
+        size="lg"
+      >
+        {selectedUser && (
+          <NotesPanel
+            filter={{ watchlist_id: selectedUser.id }}
+            attach={{ watchlist_id: selectedUser.id }}
+          />
+        )}
+      </Modal>
     </div>
   )
 }
@@ -143,6 +143,7 @@ export const alertEventTypes = [
   { value: 'kill', label: 'Kills' },
   { value: 'tkl', label: 'TKL/Bans' },
   { value: 'flood', label: 'Flood Protection' },
+  { value: 'webpanel', label: 'Web Panel Checks' },
 ]
 
 // Condition operators
@@ -1,14 +1,20 @@
+import axios from 'axios'
 import api from './api'
 import type {
   IRCUser,
   IRCChannel,
   IRCServer,
+  RehashAllRequest,
+  RehashRun,
+  ServerConsistencyReport,
+  ServerMetrics,
   ServerBan,
   NameBan,
   BanException,
   Spamfilter,
   NetworkStats,
   LogEntry,
+  ListPage,
 } from '@/types'
 
 // Debug logging helper
@@ -23,9 +29,32 @@ const debugLog = (message: string, ...args: unknown[]) => {
   }
 }
 
+// fetchFiltered follows next_cursor through every page of a filtered list. Errors
+// carry the server's message so an invalid filter expression can be shown as is.
+async function fetchFiltered<T>(path: string, filter: string): Promise<T[]> {
+  const items: T[] = []
+  let cursor: string | undefined
+  try {
+    do {
+      const response = await api.get<ListPage<T>>(path, { params: { filter, limit: 1000, cursor } })
+      items.push(...response.data.items)
+      cursor = response.data.next_cursor
+    } while (cursor)
+  } catch (err) {
+    if (axios.isAxiosError(err) && err.response?.data?.error) {
+      throw new Error(err.response.data.error)
+    }
+    throw err
+  }
+  return items
+}
+
 // Users
 export const usersService = {
-  getAll: async (): Promise<IRCUser[]> => {
+  getAll: async (filter?: string): Promise<IRCUser[]> => {
+    if (filter) {
+      return fetchFiltered<IRCUser>('/users', filter)
+    }
     const response = await api.get<IRCUser[]>('/users')
     debugLog('Users API response:', response.data)
     return response.data
@@ -59,7 +88,10 @@ export const usersService = {
 
 // Channels
 export const channelsService = {
-  getAll: async (): Promise<IRCChannel[]> => {
+  getAll: async (filter?: string): Promise<IRCChannel[]> => {
+    if (filter) {
+      return fetchFiltered<IRCChannel>('/channels', filter)
+    }
     const response = await api.get<IRCChannel[]>('/channels')
     debugLog('Channels API response:', response.data)
     return response.data
@@ -104,6 +136,26 @@ export const serversService = {
     const response = await api.get(`/servers/${encodeURIComponent(name)}/modules`)
     return response.data
   },
+
+  rehashAll: async (req: RehashAllRequest): Promise<RehashRun> => {
+    const response = await api.post<RehashRun>('/rehash/all', req)
+    return response.data
+  },
+
+  getRehashRun: async (id: number): Promise<RehashRun> => {
+    const response = await api.get<RehashRun>(`/rehash/runs/${id}`)
+    return response.data
+  },
+
+  getConsistency: async (): Promise<ServerConsistencyReport> => {
+    const response = await api.get<ServerConsistencyReport>('/servers/consistency')
+    return response.data
+  },
+
+  getMetrics: async (name: string, range: string): Promise<ServerMetrics> => {
+    const response = await api.get<ServerMetrics>(`/servers/${encodeURIComponent(name)}/metrics`, { params: { range } })
+    return response.data
+  },
 }
 
 // Server Bans
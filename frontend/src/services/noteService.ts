@@ -0,0 +1,55 @@
+import api from './api'
+
+export interface Note {
+  id: number
+  created_at: string
+  updated_at: string
+  created_by: string
+  updated_by?: string
+  nick?: string
+  ip?: string
+  account?: string
+  note: string
+  ban_type?: string
+  ban_mask?: string
+  watchlist_id?: number
+}
+
+export interface NoteRequest {
+  nick?: string
+  ip?: string
+  account?: string
+  note: string
+  ban_type?: string
+  ban_mask?: string
+  watchlist_id?: number
+}
+
+// Nick, IP and account match any of them; ban_mask and watchlist_id narrow the result
+export interface NoteFilter {
+  nick?: string
+  ip?: string
+  account?: string
+  ban_mask?: string
+  watchlist_id?: number
+  q?: string
+}
+
+export async function getNotes(filter: NoteFilter = {}): Promise<Note[]> {
+  const response = await api.get('/notes', { params: filter })
+  return response.data
+}
+
+export async function createNote(data: NoteRequest): Promise<Note> {
+  const response = await api.post('/notes', data)
+  return response.data
+}
+
+export async function updateNote(id: number, data: NoteRequest): Promise<Note> {
+  const response = await api.put(`/notes/${id}`, data)
+  return response.data
+}
+
+export async function deleteNote(id: number): Promise<void> {
+  await api.delete(`/notes/${id}`)
+}
@@ -25,10 +25,71 @@ export interface TopologyStats {
   total_opers: number;
 }
 
+export interface LinkAttempt {
+  id: number;
+  created_at: string;
+  updated_at: string;
+  server: string;
+  via?: string;
+  action: 'connect' | 'disconnect';
+  status: 'pending' | 'linked' | 'failed' | 'disconnected';
+  event_id?: string;
+  message?: string;
+  reason?: string;
+  requested_by?: string;
+}
+
 export interface TopologyResponse {
   nodes: TopologyNode[];
   links: TopologyLink[];
   stats: TopologyStats;
+  link_attempts?: LinkAttempt[];
+}
+
+export interface TopologyTimelineEntry {
+  time: string;
+  snapshot_id: number;
+  kind: 'split' | 'join' | 'relink';
+  servers: string[];
+  uplink?: string;
+  users_affected: number;
+  duration_seconds?: number;
+  ongoing?: boolean;
+}
+
+export interface TopologyTimelineResponse {
+  start: string;
+  end: string;
+  entries: TopologyTimelineEntry[];
+}
+
+export interface TopologyAsOfResponse {
+  at: string;
+  snapshot_id: number;
+  recorded_at: string;
+  nodes: TopologyNode[];
+  links: TopologyLink[];
+  stats: TopologyStats;
+}
+
+export type TopologyExportFormat = 'dot' | 'graphml' | 'jgf';
+
+export interface TopologySplitImpact {
+  server: string;
+  users_stranded: number;
+  servers_cut_off: string[];
+}
+
+export interface TopologyAnalysis {
+  recorded_at?: string;
+  servers: number;
+  links: number;
+  redundant: boolean;
+  single_points_of_failure: TopologySplitImpact[];
+  max_leaf_hops: number;
+  farthest_leaves: string[];
+  impact: TopologySplitImpact[];
+  split?: TopologySplitImpact;
 }
 
 export interface ServerDetails {
@@ -49,6 +110,29 @@ export const topologyService = {
     return response.data;
   },
 
+  getTimeline: async (range = '7d'): Promise<TopologyTimelineResponse> => {
+    const response = await api.get<TopologyTimelineResponse>('/topology/timeline', { params: { range } });
+    return response.data;
+  },
+
+  getTopologyAsOf: async (at: string): Promise<TopologyAsOfResponse> => {
+    const response = await api.get<TopologyAsOfResponse>('/topology/history', { params: { at } });
+    return response.data;
+  },
+
+  exportTopology: async (format: TopologyExportFormat, at?: string): Promise<Blob> => {
+    const response = await api.get('/topology/export', {
+      params: { format, at },
+      responseType: 'blob',
+    });
+    return response.data;
+  },
+
+  getAnalysis: async (at?: string): Promise<TopologyAnalysis> => {
+    const response = await api.get<TopologyAnalysis>('/topology/analysis', { params: { at } });
+    return response.data;
+  },
+
   getServerDetails: async (serverName: string): Promise<ServerDetails> => {
     const response = await api.get<ServerDetails>(`/topology/server/${encodeURIComponent(serverName)}`);
     return response.data;
@@ -49,6 +49,15 @@ export interface IRCUser {
   reputation?: number
 }
 
+// One page of a filtered and sorted user or channel list
+export interface ListPage<T> {
+  items: T[]
+  total: number
+  limit: number
+  sort: string
+  next_cursor?: string
+}
+
 // IRC Channel types
 export interface IRCChannel {
   name: string
@@ -107,6 +116,109 @@ export interface ServerInfo {
   features?: ServerFeatures
 }
 
+export interface RehashAllRequest {
+  mode: 'sequential' | 'waves'
+  wave_size?: number
+  stop_on_failure: boolean
+  servers?: string[]
+  wait_seconds?: number
+}
+
+export interface RehashServerResult {
+  id: number
+  run_id: number
+  server: string
+  wave: number
+  status: 'pending' | 'success' | 'unconfirmed' | 'failed' | 'skipped'
+  error?: string
+  warnings: string  // JSON array of config warnings
+  errors: string    // JSON array of config errors
+  started_at?: string
+  finished_at?: string
+}
+
+export interface RehashRun {
+  id: number
+  created_at: string
+  completed_at?: string
+  mode: 'sequential' | 'waves'
+  wave_size: number
+  stop_on_failure: boolean
+  status: 'running' | 'completed' | 'failed' | 'stopped'
+  total: number
+  succeeded: number
+  failed: number
+  unconfirmed: number
+  skipped: number
+  warnings: number
+  requested_by_username: string
+  results?: RehashServerResult[]
+}
+
+export interface ServerModuleSummary {
+  name: string
+  version: string
+  modules: number
+  third_party: string[]
+  error?: string
+}
+
+export interface ConsistencyFinding {
+  kind: 'module_missing' | 'module_version' | 'unrealircd_version'
+  subject: string
+  third_party?: boolean
+  loaded_on?: string[]
+  missing_on?: string[]
+  versions?: Record<string, string[]>
+  since?: string
+}
+
+export interface ServerConsistencyReport {
+  checked_at: string
+  consistent: boolean
+  servers: ServerModuleSummary[]
+  findings: ConsistencyFinding[]
+  third_party_modules: Record<string, string[]>
+}
+
+export interface ServerStatus {
+  server: string
+  sampled_at: string
+  num_users: number
+  boot_time?: string
+  uptime_seconds?: number
+  synced: boolean
+  lag_ms?: number
+}
+
+export interface ServerMetricsPoint {
+  time: string
+  avg_users: number
+  min_users: number
+  max_users: number
+  avg_lag_ms?: number
+  max_lag_ms?: number
+  uptime_seconds: number
+  samples: number
+}
+
+export interface ServerMetrics {
+  server: string
+  start: string
+  end: string
+  points: ServerMetricsPoint[]
+  restarts: ServerStatus[]
+  summary: {
+    samples: number
+    restarts: number
+    min_users?: number
+    max_users?: number
+    avg_lag_ms?: number
+    max_lag_ms?: number
+    current?: ServerStatus
+  }
+}
+
 // Ban types
 export interface ServerBan {
   name: string